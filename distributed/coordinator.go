@@ -0,0 +1,384 @@
+package distributed
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+	"webcrawler/module"
+	"webcrawler/module/remote"
+	"webcrawler/scheduler"
+	"webcrawler/toolkit/seenset"
+)
+
+// defaultHeartbeatTimeout is the default time after which a worker without
+// any heartbeat is removed.
+const defaultHeartbeatTimeout = 10 * time.Second
+
+type CoordinatorArgs struct {
+	// HeartbeatTimeout is the time after which a worker without any
+	// heartbeat is removed. Zero means 10 seconds.
+	HeartbeatTimeout time.Duration
+	// SeenSet is the seen set shared by the workers, and nil means an exact
+	// one in memory.
+	SeenSet seenset.SeenSet
+	// Authorize authorizes the call of a worker, e.g. by a token in the
+	// header, and the call is rejected if it returns an error. Nil means
+	// accepting the calls from the loopback addresses only.
+	Authorize func(r *http.Request) error
+}
+
+// Coordinator partitions the hosts among the workers, holds the seen set
+// shared by them and relays the requests forwarded between them. It's an
+// HTTP handler serving the workers.
+type Coordinator struct {
+	heartbeatTimeout time.Duration
+	seenSet          seenset.SeenSet
+	authorize        func(r *http.Request) error
+	mux              *http.ServeMux
+	members          map[string]*member
+	version          uint64
+	// orphans are the forwarded requests waiting for any worker to join.
+	orphans []forwardArgs
+	lock    sync.Mutex
+}
+
+type member struct {
+	id       string
+	leaving  bool
+	idle     bool
+	lastSeen time.Time
+	// inbox is the requests forwarded to the member and not yet delivered.
+	inbox []forwardArgs
+	// delivered is the requests in the delivery of the sequence number seq,
+	// which have not been acknowledged.
+	delivered []forwardArgs
+	seq       uint64
+	summary   *scheduler.SummaryStruct
+}
+
+func NewCoordinator(args CoordinatorArgs) (*Coordinator, error) {
+	if args.HeartbeatTimeout < 0 {
+		return nil, genParameterError("negative heartbeat timeout")
+	}
+	if args.HeartbeatTimeout == 0 {
+		args.HeartbeatTimeout = defaultHeartbeatTimeout
+	}
+	if args.SeenSet == nil {
+		args.SeenSet = seenset.NewExact()
+	}
+	if args.Authorize == nil {
+		args.Authorize = remote.AuthorizeLoopback
+	}
+	c := &Coordinator{
+		heartbeatTimeout: args.HeartbeatTimeout,
+		seenSet:          args.SeenSet,
+		authorize:        args.Authorize,
+		mux:              http.NewServeMux(),
+		members:          map[string]*member{},
+	}
+	c.mux.HandleFunc("POST "+pathJoin, c.handleJoin)
+	c.mux.HandleFunc("POST "+pathLeave, c.handleLeave)
+	c.mux.HandleFunc("POST "+pathHeartbeat, c.handleHeartbeat)
+	c.mux.HandleFunc("POST "+pathForward, c.handleForward)
+	c.mux.HandleFunc("POST "+pathSeenAdd, c.handleSeenAdd)
+	c.mux.HandleFunc("POST "+pathSeenContains, c.handleSeenContains)
+	c.mux.HandleFunc("GET "+pathSeenLen, c.handleSeenLen)
+	c.mux.HandleFunc("GET "+pathSummary, c.handleSummary)
+	return c, nil
+}
+
+func (c *Coordinator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := c.authorize(r); err != nil {
+		http.Error(w, fmt.Sprintf("unauthorized call: %s", err), http.StatusForbidden)
+		return
+	}
+	c.mux.ServeHTTP(w, r)
+}
+
+// Members returns the workers owning the hosts, sorted by ID. The leaving
+// workers are excluded.
+func (c *Coordinator) Members() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.expire()
+	return c.activeMembers()
+}
+
+// Idle reports whether all workers are idle and no forwarded request is
+// waiting for delivery.
+func (c *Coordinator) Idle() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.expire()
+	if len(c.members) == 0 || len(c.orphans) > 0 {
+		return false
+	}
+	for _, m := range c.members {
+		if !m.idle || len(m.inbox) > 0 || len(m.delivered) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// activeMembers returns the IDs of the workers not leaving.
+func (c *Coordinator) activeMembers() []string {
+	ids := make([]string, 0, len(c.members))
+	for id, m := range c.members {
+		if !m.leaving {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (c *Coordinator) membership() membership {
+	return membership{Members: c.activeMembers(), Version: c.version}
+}
+
+// route puts the forwarded requests into the inboxes of their owners.
+func (c *Coordinator) route(reqs []forwardArgs) {
+	members := c.activeMembers()
+	if len(members) == 0 {
+		c.orphans = append(c.orphans, reqs...)
+		return
+	}
+	for _, req := range reqs {
+		m := c.members[ownerOf(req.Host, members)]
+		m.inbox = append(m.inbox, req)
+	}
+}
+
+// remove removes the worker and routes its undelivered and unacknowledged
+// requests to the others.
+func (c *Coordinator) remove(id string) {
+	m, ok := c.members[id]
+	if !ok {
+		return
+	}
+	delete(c.members, id)
+	c.version++
+	c.route(append(m.delivered, m.inbox...))
+	logger.Infof("The worker %q has been removed (members: %v)", id, c.activeMembers())
+}
+
+// expire removes the workers without any heartbeat within the timeout, and
+// requeues the requests not yet accepted by them. The requests accepted and
+// pending in their frontiers are lost.
+func (c *Coordinator) expire() {
+	now := time.Now()
+	for id, m := range c.members {
+		if now.Sub(m.lastSeen) > c.heartbeatTimeout {
+			logger.Warnf("The worker %q has been expired (last seen: %s)", id, m.lastSeen)
+			c.remove(id)
+		}
+	}
+}
+
+func (c *Coordinator) handleJoin(w http.ResponseWriter, r *http.Request) {
+	var args joinArgs
+	if !readArgs(w, r, &args) {
+		return
+	}
+	if args.ID == "" {
+		http.Error(w, "empty worker ID", http.StatusBadRequest)
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.expire()
+	m, ok := c.members[args.ID]
+	if !ok {
+		m = &member{id: args.ID}
+		c.members[args.ID] = m
+	}
+	m.leaving = false
+	m.lastSeen = time.Now()
+	c.version++
+	orphans := c.orphans
+	c.orphans = nil
+	c.route(orphans)
+	logger.Infof("The worker %q has joined (members: %v)", args.ID, c.activeMembers())
+	writeReply(w, c.membership())
+}
+
+func (c *Coordinator) handleLeave(w http.ResponseWriter, r *http.Request) {
+	var args leaveArgs
+	if !readArgs(w, r, &args) {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	m, ok := c.members[args.ID]
+	if !ok {
+		writeReply(w, c.membership())
+		return
+	}
+	if !args.Drain {
+		c.remove(args.ID)
+		writeReply(w, c.membership())
+		return
+	}
+	if !m.leaving {
+		m.leaving = true
+		c.version++
+		inbox := m.inbox
+		m.inbox = nil
+		c.route(inbox)
+		logger.Infof("The worker %q is leaving (members: %v)", args.ID, c.activeMembers())
+	}
+	writeReply(w, c.membership())
+}
+
+func (c *Coordinator) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	var args heartbeatArgs
+	if !readArgs(w, r, &args) {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.expire()
+	m, ok := c.members[args.ID]
+	if !ok {
+		http.Error(w, "unknown worker "+args.ID, http.StatusNotFound)
+		return
+	}
+	m.lastSeen = time.Now()
+	m.idle = args.Idle
+	if args.Summary != nil {
+		m.summary = args.Summary
+	}
+	if len(m.delivered) > 0 && args.Ack != m.seq {
+		// The reply of the last delivery has been lost.
+		if m.leaving {
+			c.route(m.delivered)
+		} else {
+			m.inbox = append(m.delivered, m.inbox...)
+		}
+	}
+	m.delivered = nil
+	reply := heartbeatReply{membership: c.membership()}
+	if len(m.inbox) > 0 {
+		m.seq++
+		m.delivered = m.inbox
+		m.inbox = nil
+		reply.Seq = m.seq
+		for _, req := range m.delivered {
+			reply.Requests = append(reply.Requests, req.Request)
+		}
+		// The worker is busy until it reports again after accepting them.
+		m.idle = false
+	}
+	writeReply(w, reply)
+}
+
+func (c *Coordinator) handleForward(w http.ResponseWriter, r *http.Request) {
+	var args forwardArgs
+	if !readArgs(w, r, &args) {
+		return
+	}
+	if len(args.Request) == 0 {
+		http.Error(w, "empty request", http.StatusBadRequest)
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.route([]forwardArgs{args})
+	writeReply(w, struct{}{})
+}
+
+func (c *Coordinator) handleSeenAdd(w http.ResponseWriter, r *http.Request) {
+	c.handleSeen(w, r, c.seenSet.Add)
+}
+
+func (c *Coordinator) handleSeenContains(w http.ResponseWriter, r *http.Request) {
+	c.handleSeen(w, r, c.seenSet.Contains)
+}
+
+func (c *Coordinator) handleSeen(w http.ResponseWriter, r *http.Request, op func(key string) bool) {
+	var args seenArgs
+	if !readArgs(w, r, &args) {
+		return
+	}
+	if len(args.Keys) > maxSeenBatch {
+		http.Error(w, "too many keys", http.StatusBadRequest)
+		return
+	}
+	reply := seenReply{Results: make([]bool, len(args.Keys))}
+	for i, key := range args.Keys {
+		reply.Results[i] = op(key)
+	}
+	writeReply(w, reply)
+}
+
+func (c *Coordinator) handleSeenLen(w http.ResponseWriter, r *http.Request) {
+	writeReply(w, seenReply{Len: c.seenSet.Len()})
+}
+
+func (c *Coordinator) handleSummary(w http.ResponseWriter, r *http.Request) {
+	writeReply(w, c.Summary())
+}
+
+// ClusterSummary is the summary of the schedulers of the workers.
+type ClusterSummary struct {
+	Members []string `json:"members"`
+	Idle    bool     `json:"idle"`
+	// NumURL is the number of the URLs in the shared seen set.
+	NumURL uint64 `json:"url_number"`
+	// NumPending is the number of the requests pending in the frontiers of
+	// the workers or waiting for delivery.
+	NumPending  uint64                 `json:"pending_number"`
+	Downloaders []module.SummaryStruct `json:"downloaders"`
+	Analyzers   []module.SummaryStruct `json:"analyzers"`
+	Pipelines   []module.SummaryStruct `json:"pipelines"`
+	Workers     []WorkerSummaryStruct  `json:"workers"`
+}
+
+type WorkerSummaryStruct struct {
+	ID       string                   `json:"id"`
+	Leaving  bool                     `json:"leaving"`
+	Idle     bool                     `json:"idle"`
+	LastSeen time.Time                `json:"last_seen"`
+	Summary  *scheduler.SummaryStruct `json:"summary,omitempty"`
+}
+
+// Summary aggregates the summaries of the schedulers reported by the
+// workers in their heartbeats.
+func (c *Coordinator) Summary() ClusterSummary {
+	idle := c.Idle()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	summary := ClusterSummary{
+		Members:    c.activeMembers(),
+		Idle:       idle,
+		NumURL:     c.seenSet.Len(),
+		NumPending: uint64(len(c.orphans)),
+	}
+	ids := make([]string, 0, len(c.members))
+	for id := range c.members {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		m := c.members[id]
+		summary.NumPending += uint64(len(m.inbox) + len(m.delivered))
+		summary.Workers = append(summary.Workers, WorkerSummaryStruct{
+			ID:       m.id,
+			Leaving:  m.leaving,
+			Idle:     m.idle,
+			LastSeen: m.lastSeen,
+			Summary:  m.summary,
+		})
+		if m.summary == nil {
+			continue
+		}
+		summary.NumPending += m.summary.NumPending
+		summary.Downloaders = append(summary.Downloaders, m.summary.Downloaders...)
+		summary.Analyzers = append(summary.Analyzers, m.summary.Analyzers...)
+		summary.Pipelines = append(summary.Pipelines, m.summary.Pipelines...)
+	}
+	return summary
+}
@@ -0,0 +1,775 @@
+package distributed
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"os/signal"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"webcrawler/module"
+	"webcrawler/module/local/analyzer"
+	"webcrawler/module/local/downloader"
+	"webcrawler/module/local/pipeline"
+	"webcrawler/scheduler"
+)
+
+const (
+	siteHostNumber = 8
+	sitePageNumber = 5
+)
+
+// site serves the hosts "h<N>.example.com", and records the fetches of the
+// pages with the workers fetching them.
+type site struct {
+	server  *httptest.Server
+	fetches map[string][]string
+	lock    sync.Mutex
+}
+
+func newSite(delay time.Duration) *site {
+	s := &site{fetches: map[string][]string{}}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" || r.URL.Path == "/favicon.ico" {
+			http.NotFound(w, r)
+			return
+		}
+		s.lock.Lock()
+		key := r.Host + r.URL.Path
+		s.fetches[key] = append(s.fetches[key], r.Header.Get("X-Worker"))
+		s.lock.Unlock()
+		time.Sleep(delay)
+		var page strings.Builder
+		page.WriteString("<html><body>")
+		if r.URL.Path == "/" {
+			for i := 0; i < sitePageNumber; i++ {
+				fmt.Fprintf(&page, `<a href="/p/%d">page %d</a>`, i, i)
+			}
+			if strings.HasPrefix(r.Host, "h0.") {
+				for i := 1; i < siteHostNumber; i++ {
+					fmt.Fprintf(&page, `<a href="http://h%d.example.com/">host %d</a>`, i, i)
+				}
+			}
+		} else {
+			page.WriteString(`<a href="/">home</a><a href="http://h0.example.com/">first</a>`)
+		}
+		page.WriteString("</body></html>")
+		fmt.Fprint(w, page.String())
+	}))
+	return s
+}
+
+// check checks that every page has been fetched exactly once, and returns
+// the numbers of the pages fetched by the workers.
+func (s *site) check(t *testing.T) map[string]int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	counts := map[string]int{}
+	for key, workers := range s.fetches {
+		if len(workers) != 1 {
+			t.Errorf("The page %s has been fetched %d times by %v", key, len(workers), workers)
+		}
+		for _, id := range workers {
+			counts[id]++
+		}
+	}
+	if expected := siteHostNumber * (sitePageNumber + 1); len(s.fetches) != expected {
+		t.Errorf("Inconsistent fetched page number, expected: %d, actual: %d", expected, len(s.fetches))
+	}
+	return counts
+}
+
+// workerTransport dials the site for all hosts and marks the requests with
+// the worker ID.
+type workerTransport struct {
+	id        string
+	transport *http.Transport
+}
+
+func newWorkerTransport(id string, siteAddr string) *workerTransport {
+	dialer := &net.Dialer{}
+	return &workerTransport{
+		id: id,
+		transport: &http.Transport{
+			DialContext: func(ctx context.Context, network string, addr string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, siteAddr)
+			},
+		},
+	}
+}
+
+func (wt *workerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("X-Worker", wt.id)
+	return wt.transport.RoundTrip(req)
+}
+
+var hrefPattern = regexp.MustCompile(`href="([^"]+)"`)
+
+func parseHref(httpResp *http.Response, respDepth uint32) ([]module.Data, []error) {
+	defer httpResp.Body.Close()
+	var body strings.Builder
+	buf := make([]byte, 4096)
+	for {
+		n, err := httpResp.Body.Read(buf)
+		body.Write(buf[:n])
+		if err != nil {
+			break
+		}
+	}
+	var dataList []module.Data
+	for _, match := range hrefPattern.FindAllStringSubmatch(body.String(), -1) {
+		ref, err := httpResp.Request.URL.Parse(match[1])
+		if err != nil {
+			return dataList, []error{err}
+		}
+		httpReq, err := http.NewRequest(http.MethodGet, ref.String(), nil)
+		if err != nil {
+			return dataList, []error{err}
+		}
+		dataList = append(dataList, module.NewRequest(httpReq, respDepth+1))
+	}
+	return dataList, nil
+}
+
+// startWorker starts a scheduler crawling the site with the worker.
+func startWorker(id string, coordinator string, siteAddr string) (*Worker, scheduler.Scheduler, error) {
+	worker, err := NewWorker(WorkerArgs{
+		ID:          id,
+		Coordinator: coordinator,
+		Interval:    20 * time.Millisecond,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	client := &http.Client{Transport: newWorkerTransport(id, siteAddr)}
+	d, err := downloader.New(module.MID("D1"), client, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	a, err := analyzer.New(module.MID("A2"), []module.ParseResponse{parseHref}, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	p, err := pipeline.New(module.MID("P3"), []module.ProcessItem{
+		func(item module.Item) (module.Item, error) { return item, nil },
+	}, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	requestArgs := scheduler.RequestArgs{
+		AcceptedDomains: []string{"example.com"},
+		MaxDepth:        100,
+		Router:          worker,
+		SeenSet:         scheduler.SeenSetArgs{Shared: worker.SeenSet()},
+	}
+	dataArgs := scheduler.DataArgs{
+		ReqBufferCap:         50,
+		ReqMaxBufferNumber:   100,
+		RespBufferCap:        50,
+		RespMaxBufferNumber:  10,
+		ItemBufferCap:        50,
+		ItemMaxBufferNumber:  10,
+		ErrorBufferCap:       50,
+		ErrorMaxBufferNumber: 10,
+	}
+	moduleArgs := scheduler.ModuleArgs{
+		Downloaders: []module.Downloader{d},
+		Analyzers:   []module.Analyzer{a},
+		Pipelines:   []module.Pipeline{p},
+	}
+	sched := scheduler.NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		return nil, nil, err
+	}
+	if err := worker.Join(sched); err != nil {
+		return nil, nil, err
+	}
+	go func() {
+		for err := range sched.ErrorChan() {
+			logger.Warnf("An error occurs in the worker %s: %s", id, err)
+		}
+	}()
+	firstHTTPReq, _ := http.NewRequest(http.MethodGet, "http://h0.example.com/", nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		return nil, nil, err
+	}
+	return worker, sched, nil
+}
+
+// waitIdle waits until the cluster keeps idle for a while.
+func waitIdle(c *Coordinator, timeout time.Duration, t *testing.T) {
+	begin := time.Now()
+	var idleSince time.Time
+	for {
+		if time.Since(begin) > timeout {
+			t.Fatalf("The cluster has not been idle: %#v", c.Summary())
+		}
+		if !c.Idle() {
+			idleSince = time.Time{}
+		} else if idleSince.IsZero() {
+			idleSince = time.Now()
+		} else if time.Since(idleSince) > 200*time.Millisecond {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestOwnerOf(t *testing.T) {
+	if owner := ownerOf("a.example.com", nil); owner != "" {
+		t.Fatalf("Inconsistent owner for no member, expected: %q, actual: %q", "", owner)
+	}
+	members := []string{"w1", "w2", "w3"}
+	owners := map[string]string{}
+	counts := map[string]int{}
+	for i := 0; i < 3000; i++ {
+		host := fmt.Sprintf("h%d.example.com", i)
+		owners[host] = ownerOf(host, members)
+		if owner := ownerOf(host, members); owner != owners[host] {
+			t.Fatalf("Inconsistent owner for host %s, expected: %s, actual: %s", host, owners[host], owner)
+		}
+		counts[owners[host]]++
+	}
+	for _, member := range members {
+		if counts[member] < 800 || counts[member] > 1200 {
+			t.Fatalf("Unbalanced hosts of member %s: %d", member, counts[member])
+		}
+	}
+	// Only the hosts of the leaving member move.
+	left := []string{"w1", "w3"}
+	for host, owner := range owners {
+		newOwner := ownerOf(host, left)
+		if owner != "w2" && newOwner != owner {
+			t.Fatalf("The host %s has been moved from %s to %s", host, owner, newOwner)
+		}
+	}
+	// Only the hosts taken by the joining member move.
+	joined := []string{"w1", "w2", "w3", "w4"}
+	for host, owner := range owners {
+		newOwner := ownerOf(host, joined)
+		if newOwner != owner && newOwner != "w4" {
+			t.Fatalf("The host %s has been moved from %s to %s", host, owner, newOwner)
+		}
+	}
+}
+
+func TestCoordinator(t *testing.T) {
+	if _, err := NewCoordinator(CoordinatorArgs{HeartbeatTimeout: -time.Second}); err == nil {
+		t.Fatal("No error when creating a coordinator with negative heartbeat timeout")
+	}
+	c, err := NewCoordinator(CoordinatorArgs{HeartbeatTimeout: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("An error occurs when creating a coordinator: %s", err)
+	}
+	server := httptest.NewServer(c)
+	defer server.Close()
+	ctx := context.Background()
+	post := func(path string, args interface{}, reply interface{}) error {
+		return call(ctx, http.DefaultClient, http.MethodPost, server.URL+path, args, reply)
+	}
+	if err := post(pathJoin, joinArgs{}, nil); err == nil {
+		t.Fatal("No error when joining with empty ID")
+	}
+	// A forwarded request waits for any member to join.
+	req := forwardArgs{From: "w0", Host: "a.example.com", Request: []byte(`{"url":"http://a.example.com/"}`)}
+	if err := post(pathForward, req, nil); err != nil {
+		t.Fatalf("An error occurs when forwarding a request: %s", err)
+	}
+	var m membership
+	for _, id := range []string{"w1", "w2"} {
+		if err := post(pathJoin, joinArgs{ID: id}, &m); err != nil {
+			t.Fatalf("An error occurs when joining: %s (ID: %s)", err, id)
+		}
+	}
+	if len(m.Members) != 2 || m.Members[0] != "w1" || m.Members[1] != "w2" {
+		t.Fatalf("Inconsistent members: %v", m.Members)
+	}
+	if c.Idle() {
+		t.Fatal("The coordinator is idle with an undelivered request")
+	}
+	owner := ownerOf(req.Host, m.Members)
+	var delivered int
+	var seq uint64
+	for _, id := range m.Members {
+		var reply heartbeatReply
+		if err := post(pathHeartbeat, heartbeatArgs{ID: id, Idle: true}, &reply); err != nil {
+			t.Fatalf("An error occurs when sending a heartbeat: %s (ID: %s)", err, id)
+		}
+		if reply.Version != m.Version {
+			t.Fatalf("Inconsistent version, expected: %d, actual: %d", m.Version, reply.Version)
+		}
+		if len(reply.Requests) > 0 && id != owner {
+			t.Fatalf("The request has been delivered to %s, but should be to %s", id, owner)
+		}
+		delivered += len(reply.Requests)
+		if id == owner {
+			seq = reply.Seq
+		}
+	}
+	if delivered != 1 {
+		t.Fatalf("Inconsistent delivered request number, expected: 1, actual: %d", delivered)
+	}
+	if c.Idle() {
+		t.Fatal("The coordinator is idle before the receiver reports again")
+	}
+	// The delivery is repeated until acknowledged, as if the reply was lost.
+	var reply heartbeatReply
+	if err := post(pathHeartbeat, heartbeatArgs{ID: owner, Idle: true}, &reply); err != nil {
+		t.Fatalf("An error occurs when sending a heartbeat: %s", err)
+	}
+	if len(reply.Requests) != 1 || reply.Seq == seq {
+		t.Fatalf("The unacknowledged request has not been delivered again: %#v", reply)
+	}
+	var ackReply heartbeatReply
+	if err := post(pathHeartbeat, heartbeatArgs{ID: owner, Idle: true, Ack: reply.Seq}, &ackReply); err != nil {
+		t.Fatalf("An error occurs when sending a heartbeat: %s", err)
+	}
+	if len(ackReply.Requests) != 0 {
+		t.Fatalf("The acknowledged request has been delivered again: %#v", ackReply)
+	}
+	if !c.Idle() {
+		t.Fatal("The coordinator is not idle")
+	}
+	// The shared seen set.
+	var seen seenReply
+	keys := []string{"http://a.example.com/", "http://a.example.com/"}
+	if err := post(pathSeenAdd, seenArgs{Keys: keys}, &seen); err != nil {
+		t.Fatalf("An error occurs when adding to the seen set: %s", err)
+	}
+	if len(seen.Results) != 2 || !seen.Results[0] || seen.Results[1] {
+		t.Fatalf("Inconsistent results of adding, expected: [true false], actual: %v", seen.Results)
+	}
+	if err := post(pathSeenContains, seenArgs{Keys: keys[:1]}, &seen); err != nil || len(seen.Results) != 1 || !seen.Results[0] {
+		t.Fatalf("The key is not in the seen set: %v", err)
+	}
+	if err := post(pathSeenAdd, seenArgs{Keys: make([]string, maxSeenBatch+1)}, &seen); err == nil {
+		t.Fatal("No error when adding too many keys to the seen set")
+	}
+	if err := call(ctx, http.DefaultClient, http.MethodGet, server.URL+pathSeenLen, nil, &seen); err != nil || seen.Len != 1 {
+		t.Fatalf("Inconsistent seen set length, expected: 1, actual: %d (error: %v)", seen.Len, err)
+	}
+	// The leaving member keeps sending heartbeats without owning any host.
+	if err := post(pathLeave, leaveArgs{ID: "w1", Drain: true}, &m); err != nil {
+		t.Fatalf("An error occurs when leaving: %s", err)
+	}
+	if len(m.Members) != 1 || m.Members[0] != "w2" {
+		t.Fatalf("Inconsistent members after leaving: %v", m.Members)
+	}
+	if err := post(pathHeartbeat, heartbeatArgs{ID: "w1", Idle: true}, nil); err != nil {
+		t.Fatalf("An error occurs when sending a heartbeat while leaving: %s", err)
+	}
+	if err := post(pathLeave, leaveArgs{ID: "w1"}, &m); err != nil {
+		t.Fatalf("An error occurs when leaving: %s", err)
+	}
+	err = post(pathHeartbeat, heartbeatArgs{ID: "w1"}, nil)
+	if se, ok := err.(*statusError); !ok || se.code != http.StatusNotFound {
+		t.Fatalf("Inconsistent error for the removed member: %v", err)
+	}
+	// The member without heartbeats expires.
+	time.Sleep(300 * time.Millisecond)
+	if members := c.Members(); len(members) != 0 {
+		t.Fatalf("The members have not been expired: %v", members)
+	}
+	summary := c.Summary()
+	if summary.NumURL != 1 || len(summary.Workers) != 0 || summary.Idle {
+		t.Fatalf("Inconsistent summary: %#v", summary)
+	}
+}
+
+func TestCoordinatorAuthorize(t *testing.T) {
+	c, _ := NewCoordinator(CoordinatorArgs{})
+	for _, path := range []string{pathJoin, pathLeave, pathForward, pathSeenAdd, pathSeenContains} {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"id":"w1"}`))
+		r.RemoteAddr = "192.0.2.1:1234"
+		w := httptest.NewRecorder()
+		c.ServeHTTP(w, r)
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("Inconsistent status of the call from a non-loopback client, expected: %d, actual: %d (path: %s)",
+				http.StatusForbidden, w.Code, path)
+		}
+	}
+	if members := c.Members(); len(members) != 0 {
+		t.Fatalf("The unauthorized worker has joined: %v", members)
+	}
+	c, _ = NewCoordinator(CoordinatorArgs{
+		Authorize: func(r *http.Request) error {
+			if r.Header.Get("Authorization") != "Bearer token" {
+				return fmt.Errorf("no token")
+			}
+			return nil
+		},
+	})
+	server := httptest.NewServer(c)
+	defer server.Close()
+	worker, _ := NewWorker(WorkerArgs{ID: "w1", Coordinator: server.URL})
+	if err := worker.Join(scheduler.NewScheduler()); err == nil {
+		t.Fatal("No error when joining without the token")
+	}
+	worker, _ = NewWorker(WorkerArgs{
+		ID:          "w1",
+		Coordinator: server.URL,
+		Client:      &http.Client{Transport: tokenTransport{token: "token"}},
+	})
+	if err := worker.Join(scheduler.NewScheduler()); err != nil {
+		t.Fatalf("An error occurs when joining with the token: %s", err)
+	}
+	if members := c.Members(); len(members) != 1 || members[0] != "w1" {
+		t.Fatalf("Inconsistent members: %v", members)
+	}
+}
+
+// tokenTransport calls the coordinator with the bearer token.
+type tokenTransport struct {
+	token string
+}
+
+func (tt tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+tt.token)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestNewWorker(t *testing.T) {
+	argsList := []WorkerArgs{
+		{Coordinator: "http://127.0.0.1:8080"},
+		{ID: "w1", Coordinator: "127.0.0.1:8080"},
+		{ID: "w1", Coordinator: "http://127.0.0.1:8080", Interval: -time.Second},
+	}
+	for _, args := range argsList {
+		if _, err := NewWorker(args); err == nil {
+			t.Fatalf("No error when creating a worker with illegal arguments: %#v", args)
+		}
+	}
+	worker, err := NewWorker(WorkerArgs{ID: "w1", Coordinator: "http://127.0.0.1:8080/"})
+	if err != nil {
+		t.Fatalf("An error occurs when creating a worker: %s", err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://a.example.com/", nil)
+	if !worker.Local(module.NewRequest(req, 0)) {
+		t.Fatal("The request is not local before joining")
+	}
+	if err := worker.Join(nil); err == nil {
+		t.Fatal("No error when joining with nil scheduler")
+	}
+	if worker.client.Timeout != defaultClientTimeout {
+		t.Fatalf("Inconsistent client timeout, expected: %s, actual: %s", defaultClientTimeout, worker.client.Timeout)
+	}
+	client := &http.Client{}
+	worker, _ = NewWorker(WorkerArgs{ID: "w1", Coordinator: "http://127.0.0.1:8080", Client: client})
+	if worker.client.Timeout != defaultClientTimeout || client.Timeout != 0 {
+		t.Fatalf("Inconsistent client timeout, expected: %s, actual: %s", defaultClientTimeout, worker.client.Timeout)
+	}
+}
+
+func TestCoordinatorExpire(t *testing.T) {
+	c, _ := NewCoordinator(CoordinatorArgs{HeartbeatTimeout: 100 * time.Millisecond})
+	server := httptest.NewServer(c)
+	defer server.Close()
+	post := func(path string, args interface{}, reply interface{}) error {
+		return call(context.Background(), http.DefaultClient, http.MethodPost, server.URL+path, args, reply)
+	}
+	var m membership
+	for _, id := range []string{"w1", "w2"} {
+		if err := post(pathJoin, joinArgs{ID: id}, &m); err != nil {
+			t.Fatalf("An error occurs when joining: %s (ID: %s)", err, id)
+		}
+	}
+	req := forwardArgs{From: "w0", Host: "a.example.com", Request: []byte(`{"url":"http://a.example.com/"}`)}
+	owner := ownerOf(req.Host, m.Members)
+	other := m.Members[0]
+	if other == owner {
+		other = m.Members[1]
+	}
+	post(pathForward, req, nil)
+	var reply heartbeatReply
+	if err := post(pathHeartbeat, heartbeatArgs{ID: owner}, &reply); err != nil || len(reply.Requests) != 1 {
+		t.Fatalf("The request has not been delivered: %#v (error: %v)", reply, err)
+	}
+	// The owner expires without acknowledging the delivery.
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		reply = heartbeatReply{}
+		if err := post(pathHeartbeat, heartbeatArgs{ID: other}, &reply); err != nil {
+			t.Fatalf("An error occurs when sending a heartbeat: %s", err)
+		}
+		if len(reply.Requests) > 0 {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("The request delivered to the expired worker has not been requeued")
+}
+
+func TestWorkerBusyScheduler(t *testing.T) {
+	c, _ := NewCoordinator(CoordinatorArgs{HeartbeatTimeout: 200 * time.Millisecond})
+	server := httptest.NewServer(c)
+	defer server.Close()
+	worker, _ := NewWorker(WorkerArgs{ID: "w1", Coordinator: server.URL, Interval: 20 * time.Millisecond})
+	if err := worker.Join(scheduler.NewScheduler()); err != nil {
+		t.Fatalf("An error occurs when joining: %s", err)
+	}
+	number := 3
+	for i := 0; i < number; i++ {
+		req := forwardArgs{From: "w0", Host: "a.example.com",
+			Request: []byte(fmt.Sprintf(`{"url":"http://a.example.com/%d"}`, i))}
+		if err := worker.call(context.Background(), http.MethodPost, pathForward, req, nil); err != nil {
+			t.Fatalf("An error occurs when forwarding a request: %s", err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	release := make(chan struct{})
+	accepted := make(chan *module.Request, number)
+	go worker.Serve(ctx, func(req *module.Request) {
+		<-release
+		accepted <- req
+	})
+	// The heartbeats go on while the scheduler doesn't accept the requests.
+	time.Sleep(500 * time.Millisecond)
+	if members := c.Members(); len(members) != 1 || members[0] != "w1" {
+		t.Fatalf("The busy worker has expired, members: %v", members)
+	}
+	if n := atomic.LoadInt64(&worker.delivering); n < int64(number) {
+		t.Fatalf("Inconsistent delivering number, expected: >=%d, actual: %d", number, n)
+	}
+	close(release)
+	for i := 0; i < number; i++ {
+		select {
+		case <-accepted:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("The delivered requests have not been accepted, accepted: %d", i)
+		}
+	}
+	select {
+	case req := <-accepted:
+		t.Fatalf("The request has been accepted again (URL: %s)", req.HTTPReq().URL)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWorkerSeenSet(t *testing.T) {
+	c, _ := NewCoordinator(CoordinatorArgs{})
+	var calls int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-release
+		}
+		c.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	worker, _ := NewWorker(WorkerArgs{ID: "w1", Coordinator: server.URL})
+	set := worker.SeenSet()
+	number := 20
+	results := make(chan bool, number+1)
+	go func() {
+		results <- set.Add("http://a.example.com/0")
+	}()
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// The keys added during the first call are sent in one call.
+	for i := 0; i < number; i++ {
+		go func(i int) {
+			results <- set.Add(fmt.Sprintf("http://a.example.com/%d", i))
+		}(i)
+	}
+	for {
+		worker.seenAdd.lock.Lock()
+		pending := len(worker.seenAdd.pending)
+		worker.seenAdd.lock.Unlock()
+		if pending == number {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	var added int
+	for i := 0; i < number+1; i++ {
+		if <-results {
+			added++
+		}
+	}
+	if added != number {
+		t.Fatalf("Inconsistent number of the added keys, expected: %d, actual: %d", number, added)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf("Inconsistent number of the calls, expected: 2, actual: %d", n)
+	}
+	if !set.Contains("http://a.example.com/1") || set.Contains("http://a.example.com/x") {
+		t.Fatal("Inconsistent results of checking the shared seen set")
+	}
+	if set.Len() != uint64(number) {
+		t.Fatalf("Inconsistent length of the shared seen set, expected: %d, actual: %d", number, set.Len())
+	}
+}
+
+func TestDistributedRebalance(t *testing.T) {
+	s := newSite(30 * time.Millisecond)
+	defer s.server.Close()
+	siteAddr := s.server.Listener.Addr().String()
+	c, err := NewCoordinator(CoordinatorArgs{})
+	if err != nil {
+		t.Fatalf("An error occurs when creating a coordinator: %s", err)
+	}
+	coordinatorServer := httptest.NewServer(c)
+	defer coordinatorServer.Close()
+	scheds := map[string]scheduler.Scheduler{}
+	workers := map[string]*Worker{}
+	start := func(id string) {
+		worker, sched, err := startWorker(id, coordinatorServer.URL, siteAddr)
+		if err != nil {
+			t.Fatalf("An error occurs when starting the worker %s: %s", id, err)
+		}
+		workers[id] = worker
+		scheds[id] = sched
+	}
+	defer func() {
+		for _, sched := range scheds {
+			sched.Stop()
+		}
+	}()
+	start("w1")
+	start("w2")
+	for i := 0; ; i++ {
+		if i >= 500 {
+			t.Fatal("No page has been fetched")
+		}
+		s.lock.Lock()
+		fetched := len(s.fetches)
+		s.lock.Unlock()
+		if fetched > siteHostNumber {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	start("w3")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := workers["w1"].Leave(ctx); err != nil {
+		t.Fatalf("An error occurs when leaving: %s", err)
+	}
+	if status := scheds["w1"].Status(); status != scheduler.SCHED_STATUS_STOPPED {
+		t.Fatalf("Inconsistent status of the left worker, expected: %s, actual: %s",
+			scheduler.GetStatusDescription(scheduler.SCHED_STATUS_STOPPED), scheduler.GetStatusDescription(status))
+	}
+	if members := c.Members(); len(members) != 2 || members[0] != "w2" || members[1] != "w3" {
+		t.Fatalf("Inconsistent members: %v", members)
+	}
+	waitIdle(c, 20*time.Second, t)
+	counts := s.check(t)
+	if counts["w3"] == 0 {
+		t.Fatalf("No page has been fetched by the joined worker: %v", counts)
+	}
+	summary := c.Summary()
+	expected := uint64(siteHostNumber * (sitePageNumber + 1))
+	if summary.NumURL != expected {
+		t.Fatalf("Inconsistent URL number, expected: %d, actual: %d", expected, summary.NumURL)
+	}
+	if len(summary.Workers) != 2 || len(summary.Downloaders) != 2 || summary.NumPending != 0 {
+		t.Fatalf("Inconsistent cluster summary: %#v", summary)
+	}
+	var downloaded uint64
+	for _, ds := range summary.Downloaders {
+		downloaded += ds.Called
+	}
+	if downloaded == 0 {
+		t.Fatalf("No download in the cluster summary: %#v", summary.Downloaders)
+	}
+}
+
+// TestDistributedWorkerProcess runs a worker in the process started by
+// TestDistributedProcesses, until the process is interrupted.
+func TestDistributedWorkerProcess(t *testing.T) {
+	id := os.Getenv("WEBCRAWLER_WORKER_ID")
+	if id == "" {
+		t.Skip("not a worker process")
+	}
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt)
+	worker, _, err := startWorker(id, os.Getenv("WEBCRAWLER_COORDINATOR"), os.Getenv("WEBCRAWLER_SITE"))
+	if err != nil {
+		t.Fatalf("An error occurs when starting the worker %s: %s", id, err)
+	}
+	<-interrupted
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := worker.Leave(ctx); err != nil {
+		t.Fatalf("An error occurs when leaving: %s", err)
+	}
+}
+
+func TestDistributedProcesses(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the multi-process test in short mode")
+	}
+	if os.Getenv("WEBCRAWLER_WORKER_ID") != "" {
+		t.Skip("in a worker process")
+	}
+	s := newSite(10 * time.Millisecond)
+	defer s.server.Close()
+	c, err := NewCoordinator(CoordinatorArgs{})
+	if err != nil {
+		t.Fatalf("An error occurs when creating a coordinator: %s", err)
+	}
+	coordinatorServer := httptest.NewServer(c)
+	defer coordinatorServer.Close()
+	var cmds []*exec.Cmd
+	for _, id := range []string{"p1", "p2", "p3"} {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestDistributedWorkerProcess$", "-test.v")
+		cmd.Env = append(os.Environ(),
+			"WEBCRAWLER_WORKER_ID="+id,
+			"WEBCRAWLER_COORDINATOR="+coordinatorServer.URL,
+			"WEBCRAWLER_SITE="+s.server.Listener.Addr().String(),
+		)
+		output := &strings.Builder{}
+		cmd.Stdout = output
+		cmd.Stderr = output
+		if err := cmd.Start(); err != nil {
+			t.Fatalf("An error occurs when starting the worker process %s: %s", id, err)
+		}
+		defer func(id string, cmd *exec.Cmd, output *strings.Builder) {
+			if cmd.ProcessState == nil {
+				cmd.Process.Kill()
+				cmd.Wait()
+			}
+			if t.Failed() {
+				t.Logf("The output of the worker process %s:\n%s", id, output)
+			}
+		}(id, cmd, output)
+		cmds = append(cmds, cmd)
+	}
+	for i := 0; len(c.Members()) < len(cmds); i++ {
+		if i >= 1000 {
+			t.Fatalf("The workers have not joined: %v", c.Members())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	waitIdle(c, 30*time.Second, t)
+	counts := s.check(t)
+	if len(counts) < 2 {
+		t.Fatalf("The pages have not been partitioned among the processes: %v", counts)
+	}
+	for _, cmd := range cmds {
+		if err := cmd.Process.Signal(os.Interrupt); err != nil {
+			t.Fatalf("An error occurs when interrupting the worker process: %s", err)
+		}
+	}
+	for _, cmd := range cmds {
+		if err := cmd.Wait(); err != nil {
+			t.Fatalf("The worker process has failed: %s", err)
+		}
+	}
+	if members := c.Members(); len(members) != 0 {
+		t.Fatalf("The workers have not left: %v", members)
+	}
+}
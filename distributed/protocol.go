@@ -0,0 +1,203 @@
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"strings"
+	werr "webcrawler/errors"
+	"webcrawler/helper/log"
+	"webcrawler/module"
+	"webcrawler/scheduler"
+)
+
+var logger = log.DLogger()
+
+// maxMessageSize is the max size of a message between the coordinator and
+// the workers.
+const maxMessageSize = 64 << 20
+
+// maxErrorSize is the max size of the error message read from the
+// coordinator.
+const maxErrorSize = 1024
+
+const (
+	pathJoin         = "/v1/join"
+	pathLeave        = "/v1/leave"
+	pathHeartbeat    = "/v1/heartbeat"
+	pathForward      = "/v1/forward"
+	pathSeenAdd      = "/v1/seen/add"
+	pathSeenContains = "/v1/seen/contains"
+	pathSeenLen      = "/v1/seen/len"
+	pathSummary      = "/v1/summary"
+)
+
+type joinArgs struct {
+	ID string `json:"id"`
+}
+
+type leaveArgs struct {
+	ID string `json:"id"`
+	// Drain marks the worker leaving without removing it, so that it could
+	// forward its requests to the others.
+	Drain bool `json:"drain,omitempty"`
+}
+
+// membership is the workers owning the hosts, sorted by ID.
+type membership struct {
+	Members []string `json:"members"`
+	Version uint64   `json:"version"`
+}
+
+type heartbeatArgs struct {
+	ID   string `json:"id"`
+	Idle bool   `json:"idle"`
+	// Ack is the sequence number of the last delivery received by the
+	// worker.
+	Ack     uint64                   `json:"ack,omitempty"`
+	Summary *scheduler.SummaryStruct `json:"summary,omitempty"`
+}
+
+type heartbeatReply struct {
+	membership
+	// Seq is the sequence number of the delivery of the requests, which is
+	// delivered again until acknowledged by the next heartbeat.
+	Seq      uint64            `json:"seq,omitempty"`
+	Requests []json.RawMessage `json:"requests,omitempty"`
+}
+
+type forwardArgs struct {
+	From    string          `json:"from"`
+	Host    string          `json:"host"`
+	Request json.RawMessage `json:"request"`
+}
+
+// maxSeenBatch is the max number of the keys in a call of the shared seen
+// set.
+const maxSeenBatch = 1000
+
+type seenArgs struct {
+	Keys []string `json:"keys"`
+}
+
+// seenReply holds the results of the keys in order, or the length of the
+// seen set.
+type seenReply struct {
+	Results []bool `json:"results,omitempty"`
+	Len     uint64 `json:"len"`
+}
+
+// hostOf returns the key of the host of the request for partitioning.
+func hostOf(req *module.Request) string {
+	if req == nil || req.HTTPReq() == nil || req.HTTPReq().URL == nil {
+		return ""
+	}
+	return strings.ToLower(req.HTTPReq().URL.Host)
+}
+
+// ownerOf returns the member owning the host by the rendezvous hashing, so
+// that only the hosts of a leaving member move to the others, and a joining
+// member takes its share from each of the others.
+func ownerOf(host string, members []string) string {
+	var owner string
+	var maxWeight uint64
+	for _, member := range members {
+		h := fnv.New64a()
+		h.Write([]byte(host))
+		h.Write([]byte{0})
+		h.Write([]byte(member))
+		if weight := mix64(h.Sum64()); owner == "" || weight > maxWeight {
+			owner = member
+			maxWeight = weight
+		}
+	}
+	return owner
+}
+
+// mix64 is the finalizer of SplitMix64, which spreads the FNV hash of the
+// similar inputs.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// call sends the arguments to the coordinator and decodes the reply. The
+// arguments are not sent if nil, and the reply is not decoded if nil.
+func call(ctx context.Context, client *http.Client, method string, url string, args interface{}, reply interface{}) error {
+	var body io.Reader
+	if args != nil {
+		data, err := json.Marshal(args)
+		if err != nil {
+			return genError(fmt.Sprintf("could not encode the arguments: %s", err))
+		}
+		body = bytes.NewReader(data)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return genError(err.Error())
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		return genError(fmt.Sprintf("could not call the coordinator: %s", err))
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(httpResp.Body, maxErrorSize))
+		return &statusError{
+			code: httpResp.StatusCode,
+			msg:  strings.TrimSpace(string(msg)),
+		}
+	}
+	if reply == nil {
+		return nil
+	}
+	if err := json.NewDecoder(io.LimitReader(httpResp.Body, maxMessageSize)).Decode(reply); err != nil {
+		return genError(fmt.Sprintf("could not decode the reply: %s", err))
+	}
+	return nil
+}
+
+// statusError is the error replied by the coordinator.
+type statusError struct {
+	code int
+	msg  string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("coordinator error (status: %d): %s", e.code, e.msg)
+}
+
+func readArgs(w http.ResponseWriter, r *http.Request, args interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxMessageSize))
+	if err := decoder.Decode(args); err != nil {
+		http.Error(w, fmt.Sprintf("illegal arguments: %s", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeReply(w http.ResponseWriter, reply interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(reply); err != nil {
+		logger.Warnf("An error occurs when writing the reply of the coordinator: %s", err)
+	}
+}
+
+func genParameterError(errMsg string) error {
+	return werr.NewCrawlerErrorBy(werr.ERROR_TYPE_SCHEDULER, werr.NewIllegalParameterError(errMsg))
+}
+
+func genError(errMsg string) error {
+	return werr.NewCrawlerError(werr.ERROR_TYPE_SCHEDULER, errMsg)
+}
@@ -0,0 +1,433 @@
+package distributed
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"webcrawler/module"
+	"webcrawler/module/remote"
+	"webcrawler/scheduler"
+	"webcrawler/toolkit/seenset"
+)
+
+// defaultHeartbeatInterval is the default interval of the heartbeats of a
+// worker.
+const defaultHeartbeatInterval = time.Second
+
+// defaultClientTimeout is the default timeout of the calls of the
+// coordinator.
+const defaultClientTimeout = 10 * time.Second
+
+type WorkerArgs struct {
+	// ID is the unique ID of the worker in the cluster.
+	ID string
+	// Coordinator is the base URL of the coordinator, e.g.
+	// "http://127.0.0.1:8080".
+	Coordinator string
+	// Interval is the interval of the heartbeats, which should be shorter
+	// than the heartbeat timeout of the coordinator. Zero means 1 second.
+	Interval time.Duration
+	// Client is the HTTP client calling the coordinator, and nil means the
+	// default one. A client without timeout is copied with the timeout of 10
+	// seconds.
+	Client *http.Client
+}
+
+// Worker connects a scheduler to the coordinator. It's the router of the
+// scheduler, and its seen set should be the shared one of the scheduler.
+type Worker struct {
+	id          string
+	coordinator string
+	interval    time.Duration
+	client      *http.Client
+	sched       scheduler.Scheduler
+	members     []string
+	version     uint64
+	memberLock  sync.RWMutex
+	leaving     uint32
+	// delivering is the number of the heartbeats in flight and the
+	// delivered requests which have not been accepted.
+	delivering int64
+	// inbox holds the delivered requests until they are accepted, so that a
+	// busy scheduler doesn't delay the heartbeats.
+	inbox       []*module.Request
+	inboxLock   sync.Mutex
+	inboxSignal chan struct{}
+	// acked is the sequence number of the last delivery received.
+	acked       uint64
+	seenAdd     *seenBatcher
+	seenContain *seenBatcher
+}
+
+func NewWorker(args WorkerArgs) (*Worker, error) {
+	if args.ID == "" {
+		return nil, genParameterError("empty worker ID")
+	}
+	if !strings.HasPrefix(args.Coordinator, "http://") &&
+		!strings.HasPrefix(args.Coordinator, "https://") {
+		return nil, genParameterError("illegal coordinator URL: " + args.Coordinator)
+	}
+	if args.Interval < 0 {
+		return nil, genParameterError("negative heartbeat interval")
+	}
+	if args.Interval == 0 {
+		args.Interval = defaultHeartbeatInterval
+	}
+	client := &http.Client{Timeout: defaultClientTimeout}
+	if args.Client != nil {
+		*client = *args.Client
+		if client.Timeout == 0 {
+			client.Timeout = defaultClientTimeout
+		}
+	}
+	w := &Worker{
+		id:          args.ID,
+		coordinator: strings.TrimSuffix(args.Coordinator, "/"),
+		interval:    args.Interval,
+		client:      client,
+		inboxSignal: make(chan struct{}, 1),
+	}
+	w.seenAdd = &seenBatcher{worker: w, path: pathSeenAdd}
+	w.seenContain = &seenBatcher{worker: w, path: pathSeenContains}
+	return w, nil
+}
+
+func (w *Worker) ID() string {
+	return w.id
+}
+
+// SeenSet returns the seen set in the coordinator shared by the workers.
+func (w *Worker) SeenSet() seenset.SeenSet {
+	return &remoteSeenSet{worker: w}
+}
+
+// Members returns the workers owning the hosts known by the worker.
+func (w *Worker) Members() []string {
+	w.memberLock.RLock()
+	defer w.memberLock.RUnlock()
+	return append([]string(nil), w.members...)
+}
+
+func (w *Worker) call(ctx context.Context, method string, path string, args interface{}, reply interface{}) error {
+	return call(ctx, w.client, method, w.coordinator+path, args, reply)
+}
+
+// setMembership applies the membership unless it's older than the known one.
+func (w *Worker) setMembership(m membership, force bool) {
+	w.memberLock.Lock()
+	defer w.memberLock.Unlock()
+	if !force && m.Version < w.version {
+		return
+	}
+	if m.Version != w.version {
+		logger.Infof("The members of the cluster have been changed: %v (version: %d, worker: %s)",
+			m.Members, m.Version, w.id)
+	}
+	w.members = m.Members
+	w.version = m.Version
+}
+
+// Join joins the cluster with the scheduler, which should have been
+// initialized with the worker as its router.
+func (w *Worker) Join(sched scheduler.Scheduler) error {
+	if sched == nil {
+		return genParameterError("nil scheduler")
+	}
+	w.sched = sched
+	atomic.StoreUint32(&w.leaving, 0)
+	return w.join(context.Background())
+}
+
+func (w *Worker) join(ctx context.Context) error {
+	var reply membership
+	if err := w.call(ctx, http.MethodPost, pathJoin, joinArgs{ID: w.id}, &reply); err != nil {
+		return err
+	}
+	w.setMembership(reply, true)
+	return nil
+}
+
+// Local reports whether the host of the request is owned by the worker. All
+// requests are local before joining, and none is local while leaving.
+func (w *Worker) Local(req *module.Request) bool {
+	if atomic.LoadUint32(&w.leaving) == 1 {
+		return false
+	}
+	w.memberLock.RLock()
+	defer w.memberLock.RUnlock()
+	if len(w.members) == 0 {
+		return true
+	}
+	return ownerOf(hostOf(req), w.members) == w.id
+}
+
+// Forward sends the request to the coordinator, which delivers it to the
+// owner of its host.
+func (w *Worker) Forward(req *module.Request) error {
+	data, err := remote.MarshalRequest(req)
+	if err != nil {
+		return genError("could not encode the forwarded request: " + err.Error())
+	}
+	args := forwardArgs{From: w.id, Host: hostOf(req), Request: data}
+	return w.call(context.Background(), http.MethodPost, pathForward, args, nil)
+}
+
+// Serve sends the heartbeats to the coordinator until the context is done,
+// and calls accept with the requests delivered in the replies. The requests
+// are accepted in another goroutine, so that the heartbeats keep their
+// schedule while the scheduler is busy.
+func (w *Worker) Serve(ctx context.Context, accept func(req *module.Request)) {
+	go w.deliver(ctx, accept)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		w.heartbeat(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) heartbeat(ctx context.Context) {
+	args := heartbeatArgs{ID: w.id, Idle: w.Idle(), Ack: atomic.LoadUint64(&w.acked)}
+	if summary := w.sched.Summary(); summary != nil {
+		s := summary.Struct()
+		args.Summary = &s
+	}
+	atomic.AddInt64(&w.delivering, 1)
+	defer atomic.AddInt64(&w.delivering, -1)
+	var reply heartbeatReply
+	err := w.call(ctx, http.MethodPost, pathHeartbeat, args, &reply)
+	var se *statusError
+	if errors.As(err, &se) && se.code == http.StatusNotFound {
+		logger.Warnf("The worker has been removed by the coordinator, join again (worker: %s)", w.id)
+		if atomic.LoadUint32(&w.leaving) == 0 {
+			if err := w.join(ctx); err != nil {
+				logger.Warnf("Could not join the cluster again: %s (worker: %s)", err, w.id)
+			}
+		}
+		return
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Warnf("An error occurs when sending a heartbeat: %s (worker: %s)", err, w.id)
+		}
+		return
+	}
+	w.setMembership(reply.membership, false)
+	reqs := make([]*module.Request, 0, len(reply.Requests))
+	for _, data := range reply.Requests {
+		req, err := remote.UnmarshalRequest(data)
+		if err != nil {
+			logger.Warnf("Ignore the forwarded request since it could not be decoded: %s (worker: %s)", err, w.id)
+			continue
+		}
+		reqs = append(reqs, req)
+	}
+	w.putInbox(reqs)
+	if reply.Seq > 0 {
+		atomic.StoreUint64(&w.acked, reply.Seq)
+	}
+}
+
+// putInbox puts the delivered requests into the inbox without waiting.
+func (w *Worker) putInbox(reqs []*module.Request) {
+	if len(reqs) == 0 {
+		return
+	}
+	atomic.AddInt64(&w.delivering, int64(len(reqs)))
+	w.inboxLock.Lock()
+	w.inbox = append(w.inbox, reqs...)
+	w.inboxLock.Unlock()
+	select {
+	case w.inboxSignal <- struct{}{}:
+	default:
+	}
+}
+
+// deliver calls accept with the requests in the inbox until the context is
+// done. The requests left are accepted by the next call of Serve.
+func (w *Worker) deliver(ctx context.Context, accept func(req *module.Request)) {
+	for {
+		w.inboxLock.Lock()
+		reqs := w.inbox
+		w.inbox = nil
+		w.inboxLock.Unlock()
+		for i, req := range reqs {
+			if ctx.Err() != nil {
+				w.inboxLock.Lock()
+				w.inbox = append(reqs[i:len(reqs):len(reqs)], w.inbox...)
+				w.inboxLock.Unlock()
+				return
+			}
+			accept(req)
+			atomic.AddInt64(&w.delivering, -1)
+		}
+		if len(reqs) > 0 {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.inboxSignal:
+		}
+	}
+}
+
+// Idle reports whether the scheduler is idle and no forwarded request is
+// being delivered to it.
+func (w *Worker) Idle() bool {
+	if w.sched == nil || w.sched.Status() != scheduler.SCHED_STATUS_STARTED {
+		return true
+	}
+	return atomic.LoadInt64(&w.delivering) == 0 && w.sched.Idle()
+}
+
+// Leave hands over the hosts of the worker to the others and stops the
+// scheduler once the requests in it have been forwarded or handled. The
+// scheduler is stopped without waiting if the context is done first.
+func (w *Worker) Leave(ctx context.Context) error {
+	if w.sched == nil {
+		return genError("the worker has not joined")
+	}
+	atomic.StoreUint32(&w.leaving, 1)
+	var reply membership
+	err := w.call(ctx, http.MethodPost, pathLeave, leaveArgs{ID: w.id, Drain: true}, &reply)
+	if err != nil {
+		logger.Warnf("Could not hand over the hosts: %s (worker: %s)", err, w.id)
+	}
+	for err == nil && !w.Idle() {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if w.sched.Status() == scheduler.SCHED_STATUS_STARTED ||
+		w.sched.Status() == scheduler.SCHED_STATUS_PAUSED {
+		if serr := w.sched.Stop(); serr != nil && err == nil {
+			err = serr
+		}
+	}
+	// Tell the coordinator even if the context is done.
+	lerr := w.call(context.Background(), http.MethodPost, pathLeave, leaveArgs{ID: w.id}, &reply)
+	if err == nil {
+		err = lerr
+	}
+	return err
+}
+
+// remoteSeenSet is the seen set in the coordinator. A URL is treated as new
+// if the coordinator could not be called, so that no request is lost.
+type remoteSeenSet struct {
+	worker *Worker
+}
+
+func (set *remoteSeenSet) Add(key string) bool {
+	result, err := set.worker.seenAdd.do(key)
+	if err != nil {
+		logger.Warnf("Could not add the URL to the shared seen set: %s (URL: %s)", err, key)
+		return true
+	}
+	return result
+}
+
+func (set *remoteSeenSet) Contains(key string) bool {
+	result, err := set.worker.seenContain.do(key)
+	if err != nil {
+		logger.Warnf("Could not check the URL in the shared seen set: %s (URL: %s)", err, key)
+		return false
+	}
+	return result
+}
+
+func (set *remoteSeenSet) Len() uint64 {
+	var reply seenReply
+	if err := set.worker.call(context.Background(), http.MethodGet, pathSeenLen, nil, &reply); err != nil {
+		logger.Warnf("Could not get the length of the shared seen set: %s", err)
+		return 0
+	}
+	return reply.Len
+}
+
+// Clear does nothing since the seen set is shared by the workers.
+func (set *remoteSeenSet) Clear() error {
+	return nil
+}
+
+func (set *remoteSeenSet) Close() error {
+	return nil
+}
+
+// seenBatcher calls an operation of the shared seen set with the keys of the
+// concurrent callers in a batch. The keys arriving while a call is in flight
+// are sent together in the next one.
+type seenBatcher struct {
+	worker  *Worker
+	path    string
+	lock    sync.Mutex
+	pending []seenCall
+	running bool
+}
+
+type seenCall struct {
+	key    string
+	result chan seenResult
+}
+
+type seenResult struct {
+	ok  bool
+	err error
+}
+
+func (b *seenBatcher) do(key string) (bool, error) {
+	call := seenCall{key: key, result: make(chan seenResult, 1)}
+	b.lock.Lock()
+	b.pending = append(b.pending, call)
+	if !b.running {
+		b.running = true
+		go b.run()
+	}
+	b.lock.Unlock()
+	result := <-call.result
+	return result.ok, result.err
+}
+
+func (b *seenBatcher) run() {
+	for {
+		b.lock.Lock()
+		calls := b.pending
+		if len(calls) == 0 {
+			b.running = false
+			b.lock.Unlock()
+			return
+		}
+		if len(calls) > maxSeenBatch {
+			calls = calls[:maxSeenBatch]
+		}
+		b.pending = b.pending[len(calls):]
+		b.lock.Unlock()
+		args := seenArgs{Keys: make([]string, len(calls))}
+		for i, call := range calls {
+			args.Keys[i] = call.key
+		}
+		var reply seenReply
+		err := b.worker.call(context.Background(), http.MethodPost, b.path, args, &reply)
+		if err == nil && len(reply.Results) != len(calls) {
+			err = genError("inconsistent number of the results of the shared seen set")
+		}
+		for i, call := range calls {
+			if err != nil {
+				call.result <- seenResult{err: err}
+				continue
+			}
+			call.result <- seenResult{ok: reply.Results[i]}
+		}
+	}
+}
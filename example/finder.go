@@ -3,7 +3,6 @@ package main
 import (
 	"flag"
 	"fmt"
-	"net/http"
 	"os"
 	"strings"
 	"time"
@@ -15,6 +14,7 @@ import (
 
 var (
 	firstURL string
+	seedFile string
 	domains  string
 	depth    uint
 	dirPath  string
@@ -25,6 +25,8 @@ var logger = log.DLogger()
 func init() {
 	flag.StringVar(&firstURL, "first", "http://news.people.com.cn/210801/211150/index.js?cache=false",
 		"The first URL which you want to access.")
+	flag.StringVar(&seedFile, "seeds", "",
+		"The file of the seed URLs, one per line or in JSON, in place of the first URL.")
 	flag.StringVar(&domains, "domains", "people.com.cn,people.cn",
 		"The primary domains which you accepted. "+
 			"please using comma-separated multiple domains.")
@@ -103,12 +105,19 @@ func main() {
 		true,
 		lib.Record,
 	)
-	firstHTTPReq, err := http.NewRequest("GET", firstURL, nil)
+	var seeds []sched.Seed
+	if seedFile != "" {
+		seeds, err = sched.LoadSeedFile(seedFile)
+	} else {
+		var seed sched.Seed
+		seed, err = sched.NewSeed(firstURL)
+		seeds = []sched.Seed{seed}
+	}
 	if err != nil {
 		logger.Fatalln(err)
 		return
 	}
-	err = scheduler.Start(firstHTTPReq)
+	err = scheduler.StartSeeds(seeds)
 	if err != nil {
 		logger.Fatalf("An error occurs when starting scheduler: %s", err)
 	}
@@ -52,6 +52,7 @@ func genResponseParsers() []module.ParseResponse {
 			return nil, []error{err}
 		}
 		errs := make([]error, 0)
+		respMeta, _ := module.MetaFromResponse(httpResp)
 		doc.Find("a").Each(func(index int, sel *goquery.Selection) {
 			href, exists := sel.Attr("href")
 			if !exists || href == "" || href == "#" || href == "/" {
@@ -75,7 +76,8 @@ func genResponseParsers() []module.ParseResponse {
 			if err != nil {
 				errs = append(errs, err)
 			} else {
-				req := module.NewRequest(httpReq, respDepth)
+				meta := respMeta.Child(reqURL.String(), strings.TrimSpace(sel.Text()))
+				req := module.NewRequestWithMeta(httpReq, respDepth, meta)
 				dataList = append(dataList, req)
 			}
 		})
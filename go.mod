@@ -5,11 +5,11 @@ go 1.22.0
 require (
 	github.com/PuerkitoBio/goquery v1.9.2
 	github.com/sirupsen/logrus v1.9.3
+	golang.org/x/net v0.24.0
 	golang.org/x/text v0.14.0
 )
 
 require (
 	github.com/andybalholm/cascadia v1.3.2 // indirect
-	golang.org/x/net v0.24.0 // indirect
 	golang.org/x/sys v0.19.0 // indirect
 )
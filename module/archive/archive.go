@@ -0,0 +1,100 @@
+package archive
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	werr "webcrawler/errors"
+	"webcrawler/helper/log"
+	"webcrawler/module"
+	"webcrawler/toolkit/warc"
+)
+
+var logger = log.DLogger()
+
+// NewDownloader wraps the downloader to archive the request, the response
+// and the metadata of every download into the WARC files by the writer. A
+// failure of archiving is logged without failing the download.
+func NewDownloader(downloader module.Downloader, writer *warc.Writer) (module.Downloader, error) {
+	if downloader == nil {
+		return nil, genParameterError("nil downloader")
+	}
+	if writer == nil {
+		return nil, genParameterError("nil WARC writer")
+	}
+	return &myDownloader{Downloader: downloader, writer: writer}, nil
+}
+
+type myDownloader struct {
+	module.Downloader
+	writer   *warc.Writer
+	archived uint64
+	failed   uint64
+}
+
+// SummaryStruct is the extra of the summary of the archiving downloader.
+type SummaryStruct struct {
+	Archived uint64   `json:"archived"`
+	Failed   uint64   `json:"failed"`
+	Files    []string `json:"files"`
+	// Downloader is the extra of the summary of the wrapped downloader.
+	Downloader interface{} `json:"downloader,omitempty"`
+}
+
+func (d *myDownloader) Download(req *module.Request) (*module.Response, error) {
+	ctx := context.Background()
+	if req != nil && req.HTTPReq() != nil {
+		ctx = req.HTTPReq().Context()
+	}
+	return d.DownloadContext(ctx, req)
+}
+
+func (d *myDownloader) DownloadContext(ctx context.Context, req *module.Request) (*module.Response, error) {
+	resp, err := module.DownloadContext(ctx, d.Downloader, req)
+	if err != nil || resp == nil || resp.HTTPResp() == nil {
+		return resp, err
+	}
+	httpResp := resp.HTTPResp()
+	if httpResp.Request == nil {
+		httpResp.Request = req.HTTPReq()
+	}
+	if err := d.writer.WriteExchange(httpResp, metaFields(resp)); err != nil {
+		atomic.AddUint64(&d.failed, 1)
+		logger.Warnf("Could not archive the response: %s (URL: %s)", err, req.HTTPReq().URL)
+		return resp, nil
+	}
+	atomic.AddUint64(&d.archived, 1)
+	return resp, nil
+}
+
+// metaFields returns the fields of the metadata record of the response.
+func metaFields(resp *module.Response) map[string]string {
+	fields := map[string]string{"depth": strconv.FormatUint(uint64(resp.Depth()), 10)}
+	meta := resp.Meta()
+	if meta.ParentURL != "" {
+		fields["parent-url"] = meta.ParentURL
+	}
+	if meta.AnchorText != "" {
+		fields["anchor-text"] = meta.AnchorText
+	}
+	return fields
+}
+
+func (d *myDownloader) Summary() module.SummaryStruct {
+	summary := d.Downloader.Summary()
+	summary.Extra = SummaryStruct{
+		Archived:   atomic.LoadUint64(&d.archived),
+		Failed:     atomic.LoadUint64(&d.failed),
+		Files:      d.writer.Files(),
+		Downloader: summary.Extra,
+	}
+	return summary
+}
+
+func genParameterError(errMsg string) error {
+	return werr.NewCrawlerErrorBy(werr.ERROR_TYPE_DOWNLOADER, werr.NewIllegalParameterError(errMsg))
+}
+
+func genError(errMsg string) error {
+	return werr.NewCrawlerError(werr.ERROR_TYPE_DOWNLOADER, errMsg)
+}
@@ -0,0 +1,103 @@
+package archive
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"webcrawler/module"
+	"webcrawler/module/local/downloader"
+	"webcrawler/toolkit/warc"
+)
+
+func download(d module.Downloader, rawURL string) (*http.Response, string, error) {
+	httpReq, _ := http.NewRequest(http.MethodGet, rawURL, nil)
+	resp, err := d.Download(module.NewRequestWithMeta(httpReq, 1, module.Meta{AnchorText: "a"}))
+	if err != nil {
+		return nil, "", err
+	}
+	httpResp := resp.HTTPResp()
+	body, err := io.ReadAll(httpResp.Body)
+	httpResp.Body.Close()
+	return httpResp, string(body), err
+}
+
+func TestArchiveAndReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html>%s</html>", r.URL.Path)
+	}))
+	defer server.Close()
+	d, _ := downloader.New(module.MID("D1"), &http.Client{}, nil)
+	if _, err := NewDownloader(d, nil); err == nil {
+		t.Fatal("No error when creating an archiving downloader with nil writer")
+	}
+	dir := t.TempDir()
+	writer, err := warc.NewWriter(warc.WriterArgs{Dir: dir, Gzip: true})
+	if err != nil {
+		t.Fatalf("An error occurs when creating a WARC writer: %s", err)
+	}
+	ad, err := NewDownloader(d, writer)
+	if err != nil {
+		t.Fatalf("An error occurs when creating an archiving downloader: %s", err)
+	}
+	paths := []string{"/a", "/b", "/missing"}
+	expected := map[string]string{}
+	for _, path := range paths {
+		_, body, err := download(ad, server.URL+path)
+		if err != nil {
+			t.Fatalf("An error occurs when downloading: %s", err)
+		}
+		expected[path] = body
+	}
+	if expected["/a"] != "<html>/a</html>" {
+		t.Fatalf("Inconsistent body through the archiving downloader: %q", expected["/a"])
+	}
+	extra, ok := ad.Summary().Extra.(SummaryStruct)
+	if !ok || extra.Archived != 3 || extra.Failed != 0 || len(extra.Files) != 1 {
+		t.Fatalf("Inconsistent summary extra: %#v", ad.Summary().Extra)
+	}
+	writer.Close()
+
+	if _, err := NewReplayDownloader(module.MID("D2"), nil, nil); err == nil {
+		t.Fatal("No error when creating a replay downloader without WARC file")
+	}
+	rd, err := NewReplayDownloader(module.MID("D2"), []string{dir}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a replay downloader: %s", err)
+	}
+	server.Close()
+	for _, path := range paths {
+		httpResp, body, err := download(rd, server.URL+path)
+		if err != nil {
+			t.Fatalf("An error occurs when replaying: %s", err)
+		}
+		if body != expected[path] {
+			t.Fatalf("Inconsistent replayed body of %s, expected: %q, actual: %q", path, expected[path], body)
+		}
+		if path == "/missing" && httpResp.StatusCode != http.StatusNotFound ||
+			path != "/missing" && httpResp.Header.Get("Content-Type") != "text/html" {
+			t.Fatalf("Inconsistent replayed response of %s: %#v", path, httpResp)
+		}
+	}
+	if _, _, err := download(rd, server.URL+"/c"); err == nil {
+		t.Fatal("No error when replaying a URL not archived")
+	}
+	httpReq, _ := http.NewRequest(http.MethodPost, server.URL+"/a", nil)
+	if _, err := rd.Download(module.NewRequest(httpReq, 0)); err == nil {
+		t.Fatal("No error when replaying a POST request")
+	}
+	counts := rd.Counts()
+	if counts.CalledCount != 5 || counts.CompletedCount != 3 {
+		t.Fatalf("Inconsistent counts: %#v", counts)
+	}
+	replayExtra, ok := rd.Summary().Extra.(ReplaySummaryStruct)
+	if !ok || replayExtra.URLs != 3 || replayExtra.Misses != 1 {
+		t.Fatalf("Inconsistent replay summary extra: %#v", rd.Summary().Extra)
+	}
+}
@@ -0,0 +1,192 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"webcrawler/module"
+	"webcrawler/module/stub"
+	"webcrawler/toolkit/urlnorm"
+	"webcrawler/toolkit/warc"
+)
+
+// location is the location of a response record.
+type location struct {
+	path   string
+	offset int64
+}
+
+// NewReplayDownloader creates a downloader serving the responses archived in
+// the WARC files, so that a crawl can be replayed offline through the same
+// analyzers. The paths are the WARC files or the directories of them. The
+// last response of a URL in the files is served, and a URL not archived is
+// an error.
+func NewReplayDownloader(
+	mid module.MID,
+	paths []string,
+	scoreCalculator module.CalculateScore) (module.Downloader, error) {
+	moduleBase, err := stub.NewModuleInternal(mid, scoreCalculator)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, genParameterError("no WARC file")
+	}
+	files, err := warcFiles(paths)
+	if err != nil {
+		return nil, genError(fmt.Sprintf("could not list the WARC files: %s", err))
+	}
+	d := &replayDownloader{
+		ModuleInternal: moduleBase,
+		files:          files,
+		index:          map[string]location{},
+		urlNorm:        urlnorm.DefaultOptions(),
+	}
+	for _, file := range files {
+		if err := d.load(file); err != nil {
+			return nil, genError(fmt.Sprintf("could not index the WARC file %s: %s", file, err))
+		}
+	}
+	return d, nil
+}
+
+type replayDownloader struct {
+	stub.ModuleInternal
+	files   []string
+	index   map[string]location
+	urlNorm urlnorm.Options
+	misses  uint64
+}
+
+// ReplaySummaryStruct is the extra of the summary of the replay downloader.
+type ReplaySummaryStruct struct {
+	Files  []string `json:"files"`
+	URLs   int      `json:"urls"`
+	Misses uint64   `json:"misses"`
+}
+
+// warcFiles expands the directories in the paths to the WARC files in them
+// sorted by name.
+func warcFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, entry := range entries {
+			name := entry.Name()
+			if !entry.IsDir() && (strings.HasSuffix(name, ".warc") || strings.HasSuffix(name, ".warc.gz")) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			files = append(files, filepath.Join(path, name))
+		}
+	}
+	return files, nil
+}
+
+// load indexes the response records in the file.
+func (d *replayDownloader) load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader, err := warc.NewReader(file)
+	if err != nil {
+		return err
+	}
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if record.Type != warc.TYPE_RESPONSE || record.TargetURI == "" {
+			continue
+		}
+		u, err := url.Parse(record.TargetURI)
+		if err != nil {
+			logger.Warnf("Ignore the WARC record with illegal URI: %s (URI: %s)", err, record.TargetURI)
+			continue
+		}
+		d.index[urlnorm.Normalize(u, d.urlNorm).String()] = location{path: path, offset: reader.Offset()}
+	}
+}
+
+func (d *replayDownloader) Download(req *module.Request) (*module.Response, error) {
+	ctx := context.Background()
+	if req != nil && req.HTTPReq() != nil {
+		ctx = req.HTTPReq().Context()
+	}
+	return d.DownloadContext(ctx, req)
+}
+
+func (d *replayDownloader) DownloadContext(ctx context.Context, req *module.Request) (*module.Response, error) {
+	d.IncrHandlingNumber()
+	defer d.DecrHandlingNumber()
+	d.IncrCalledCount()
+	if req == nil {
+		return nil, genParameterError("nil request")
+	}
+	httpReq := req.HTTPReq()
+	if httpReq == nil || httpReq.URL == nil {
+		return nil, genParameterError("nil HTTP request")
+	}
+	if httpReq.Method != "" && httpReq.Method != http.MethodGet {
+		return nil, genError(fmt.Sprintf("could not replay %s (URL: %s)", httpReq.Method, httpReq.URL))
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, genError(err.Error())
+	}
+	d.IncrAcceptedCount()
+	key := urlnorm.Normalize(httpReq.URL, d.urlNorm).String()
+	loc, ok := d.index[key]
+	if !ok {
+		atomic.AddUint64(&d.misses, 1)
+		return nil, genError(fmt.Sprintf("no archived response (URL: %s)", key))
+	}
+	record, err := warc.ReadAt(loc.path, loc.offset)
+	if err != nil {
+		return nil, genError(fmt.Sprintf("could not read the archived response: %s (URL: %s)", err, key))
+	}
+	httpResp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(record.Block)), httpReq)
+	if err != nil {
+		return nil, genError(fmt.Sprintf("could not parse the archived response: %s (URL: %s)", err, key))
+	}
+	d.IncrCompletedCount()
+	return module.NewResponseWithMeta(httpResp, req.Depth(), req.Meta()), nil
+}
+
+func (d *replayDownloader) Summary() module.SummaryStruct {
+	summary := d.ModuleInternal.Summary()
+	summary.Extra = ReplaySummaryStruct{
+		Files:  d.files,
+		URLs:   len(d.index),
+		Misses: atomic.LoadUint64(&d.misses),
+	}
+	return summary
+}
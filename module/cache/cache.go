@@ -0,0 +1,262 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+	werr "webcrawler/errors"
+	"webcrawler/helper/log"
+	"webcrawler/module"
+	"webcrawler/toolkit/urlnorm"
+)
+
+var logger = log.DLogger()
+
+// defaultMaxBodySize is the default max size of a cached response body.
+const defaultMaxBodySize = 32 << 20
+
+// HeaderCache is the header added to the responses by the cache, whose value
+// is one of the CACHE_* constants.
+const HeaderCache = "X-Cache"
+
+const (
+	// CACHE_HIT means the response is served from the cache.
+	CACHE_HIT = "HIT"
+	// CACHE_REVALIDATED means the cached response is served after being
+	// validated by the server.
+	CACHE_REVALIDATED = "REVALIDATED"
+	// CACHE_MISS means the response is downloaded.
+	CACHE_MISS = "MISS"
+)
+
+type Args struct {
+	// Dir is the directory of the cache files, which are kept across the
+	// crawls.
+	Dir string
+	// Offline serves all the responses from the cache regardless of their
+	// freshness without calling the downloader, and a miss is an error.
+	Offline bool
+	// MaxBodySize is the max size of a cached response body. Zero means
+	// 32MB.
+	MaxBodySize int64
+	// URLNorm normalizes the URLs as the keys of the cache. Zero value means
+	// urlnorm.DefaultOptions().
+	URLNorm urlnorm.Options
+}
+
+// NewDownloader wraps the downloader with an on-disk HTTP cache for the GET
+// requests. A fresh response is served from the cache, and a stale one with
+// an ETag or a Last-Modified is revalidated by a conditional request.
+//
+// The counts of the downloader only include the downloads through it, and
+// the ones of the cache are in the extra of the summary.
+func NewDownloader(downloader module.Downloader, args Args) (module.Downloader, error) {
+	if downloader == nil {
+		return nil, genParameterError("nil downloader")
+	}
+	if args.Dir == "" {
+		return nil, genParameterError("empty cache directory")
+	}
+	if args.MaxBodySize < 0 {
+		return nil, genParameterError("negative max body size")
+	}
+	if args.MaxBodySize == 0 {
+		args.MaxBodySize = defaultMaxBodySize
+	}
+	if !args.URLNorm.Enabled() {
+		args.URLNorm = urlnorm.DefaultOptions()
+	}
+	store, err := newDiskStore(args.Dir)
+	if err != nil {
+		return nil, genError(fmt.Sprintf("could not open the cache directory: %s", err))
+	}
+	return &myDownloader{
+		Downloader:  downloader,
+		store:       store,
+		offline:     args.Offline,
+		maxBodySize: args.MaxBodySize,
+		urlNorm:     args.URLNorm,
+		now:         time.Now,
+	}, nil
+}
+
+type myDownloader struct {
+	module.Downloader
+	store       *diskStore
+	offline     bool
+	maxBodySize int64
+	urlNorm     urlnorm.Options
+	stats       cacheStats
+	now         func() time.Time
+}
+
+type cacheStats struct {
+	hits        uint64
+	revalidated uint64
+	misses      uint64
+	stored      uint64
+}
+
+// SummaryStruct is the extra of the summary of the downloader with cache.
+type SummaryStruct struct {
+	Offline     bool   `json:"offline"`
+	Hits        uint64 `json:"hits"`
+	Revalidated uint64 `json:"revalidated"`
+	Misses      uint64 `json:"misses"`
+	Stored      uint64 `json:"stored"`
+	// Downloader is the extra of the summary of the wrapped downloader.
+	Downloader interface{} `json:"downloader,omitempty"`
+}
+
+func (d *myDownloader) Download(req *module.Request) (*module.Response, error) {
+	ctx := context.Background()
+	if req != nil && req.HTTPReq() != nil {
+		ctx = req.HTTPReq().Context()
+	}
+	return d.DownloadContext(ctx, req)
+}
+
+func (d *myDownloader) DownloadContext(ctx context.Context, req *module.Request) (*module.Response, error) {
+	if req == nil {
+		return nil, genParameterError("nil request")
+	}
+	httpReq := req.HTTPReq()
+	if httpReq == nil || httpReq.URL == nil {
+		return nil, genParameterError("nil HTTP request")
+	}
+	if httpReq.Method != "" && httpReq.Method != http.MethodGet ||
+		parseCacheControl(httpReq.Header).has("no-store") {
+		if d.offline {
+			return nil, genError(fmt.Sprintf("could not %s in offline mode (URL: %s)", httpReq.Method, httpReq.URL))
+		}
+		return module.DownloadContext(ctx, d.Downloader, req)
+	}
+	key := urlnorm.Normalize(httpReq.URL, d.urlNorm).String()
+	cached, err := d.store.get(key)
+	if err != nil {
+		logger.Warnf("Ignore the cached response: %s (URL: %s)", err, key)
+		cached = nil
+	}
+	if d.offline {
+		if cached == nil {
+			return nil, genError(fmt.Sprintf("no cached response in offline mode (URL: %s)", key))
+		}
+		return d.hit(cached, req, CACHE_HIT), nil
+	}
+	if cached != nil && cached.fresh(d.now()) && !parseCacheControl(httpReq.Header).has("no-cache") {
+		return d.hit(cached, req, CACHE_HIT), nil
+	}
+	var validators http.Header
+	if cached != nil {
+		validators = cached.validators()
+	}
+	downloadReq := req
+	if validators != nil {
+		condHTTPReq := httpReq.Clone(httpReq.Context())
+		if condHTTPReq.Header == nil {
+			condHTTPReq.Header = http.Header{}
+		}
+		for name, values := range validators {
+			condHTTPReq.Header[name] = values
+		}
+		downloadReq = module.NewRequestWithMeta(condHTTPReq, req.Depth(), req.Meta())
+	}
+	requestTime := d.now()
+	resp, err := module.DownloadContext(ctx, d.Downloader, downloadReq)
+	for req.Attempts() < downloadReq.Attempts() {
+		req.IncrAttempts()
+	}
+	if err != nil || resp == nil || resp.HTTPResp() == nil {
+		return resp, err
+	}
+	httpResp := resp.HTTPResp()
+	if httpResp.StatusCode == http.StatusNotModified && validators != nil {
+		io.Copy(io.Discard, io.LimitReader(httpResp.Body, d.maxBodySize))
+		httpResp.Body.Close()
+		cached.update(httpResp.Header)
+		cached.RequestTime = requestTime
+		cached.ResponseTime = d.now()
+		d.put(cached)
+		atomic.AddUint64(&d.stats.revalidated, 1)
+		return d.hit(cached, req, CACHE_REVALIDATED), nil
+	}
+	atomic.AddUint64(&d.stats.misses, 1)
+	httpResp.Header.Set(HeaderCache, CACHE_MISS)
+	if !storable(httpReq.Header, httpResp) {
+		return resp, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(httpResp.Body, d.maxBodySize+1))
+	if err != nil {
+		httpResp.Body.Close()
+		return nil, genError(fmt.Sprintf("could not read the response body: %s (URL: %s)", err, key))
+	}
+	if int64(len(body)) > d.maxBodySize {
+		httpResp.Body = &multiReadCloser{
+			Reader: io.MultiReader(bytes.NewReader(body), httpResp.Body),
+			Closer: httpResp.Body,
+		}
+		return resp, nil
+	}
+	httpResp.Body.Close()
+	httpResp.Body = io.NopCloser(bytes.NewReader(body))
+	header := httpResp.Header.Clone()
+	header.Del(HeaderCache)
+	d.put(&entry{
+		URL:          key,
+		RequestTime:  requestTime,
+		ResponseTime: d.now(),
+		StatusCode:   httpResp.StatusCode,
+		Header:       header,
+		Body:         body,
+	})
+	return resp, nil
+}
+
+// hit returns the response of the cached entry for the request.
+func (d *myDownloader) hit(cached *entry, req *module.Request, status string) *module.Response {
+	if status == CACHE_HIT {
+		atomic.AddUint64(&d.stats.hits, 1)
+	}
+	httpResp := cached.response(req.HTTPReq())
+	httpResp.Header.Set(HeaderCache, status)
+	return module.NewResponseWithMeta(httpResp, req.Depth(), req.Meta())
+}
+
+func (d *myDownloader) put(e *entry) {
+	if err := d.store.put(e); err != nil {
+		logger.Warnf("Could not store the response in the cache: %s (URL: %s)", err, e.URL)
+		return
+	}
+	atomic.AddUint64(&d.stats.stored, 1)
+}
+
+func (d *myDownloader) Summary() module.SummaryStruct {
+	summary := d.Downloader.Summary()
+	summary.Extra = SummaryStruct{
+		Offline:     d.offline,
+		Hits:        atomic.LoadUint64(&d.stats.hits),
+		Revalidated: atomic.LoadUint64(&d.stats.revalidated),
+		Misses:      atomic.LoadUint64(&d.stats.misses),
+		Stored:      atomic.LoadUint64(&d.stats.stored),
+		Downloader:  summary.Extra,
+	}
+	return summary
+}
+
+// multiReadCloser reads the buffered head of a body before the rest of it.
+type multiReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func genParameterError(errMsg string) error {
+	return werr.NewCrawlerErrorBy(werr.ERROR_TYPE_DOWNLOADER, werr.NewIllegalParameterError(errMsg))
+}
+
+func genError(errMsg string) error {
+	return werr.NewCrawlerError(werr.ERROR_TYPE_DOWNLOADER, errMsg)
+}
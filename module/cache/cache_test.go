@@ -0,0 +1,272 @@
+package cache
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"webcrawler/module"
+	"webcrawler/module/local/downloader"
+)
+
+// cacheServer serves the path with the headers, and counts the requests and
+// the conditional ones.
+type cacheServer struct {
+	*httptest.Server
+	requests    uint64
+	conditional uint64
+}
+
+func newCacheServer(header http.Header, body string) *cacheServer {
+	s := &cacheServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddUint64(&s.requests, 1)
+		for name, values := range header {
+			w.Header()[name] = values
+		}
+		etag := header.Get("ETag")
+		lastModified := header.Get("Last-Modified")
+		if (etag != "" && r.Header.Get("If-None-Match") == etag) ||
+			(lastModified != "" && r.Header.Get("If-Modified-Since") == lastModified) {
+			atomic.AddUint64(&s.conditional, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		io.WriteString(w, body)
+	}))
+	return s
+}
+
+func genCacheDownloader(args Args, client *http.Client, t *testing.T) *myDownloader {
+	if client == nil {
+		client = &http.Client{}
+	}
+	d, err := downloader.New(module.MID("D1"), client, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a downloader: %s", err)
+	}
+	cd, err := NewDownloader(d, args)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a downloader with cache: %s", err)
+	}
+	return cd.(*myDownloader)
+}
+
+func download(d module.Downloader, rawURL string, t *testing.T) (*http.Response, string) {
+	httpReq, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating an HTTP request: %s", err)
+	}
+	resp, err := d.Download(module.NewRequestWithMeta(httpReq, 1, module.Meta{AnchorText: "a"}))
+	if err != nil {
+		t.Fatalf("An error occurs when downloading: %s (URL: %s)", err, rawURL)
+	}
+	if resp.Depth() != 1 || resp.Meta().AnchorText != "a" {
+		t.Fatalf("Inconsistent depth or metadata of the response: %d, %#v", resp.Depth(), resp.Meta())
+	}
+	httpResp := resp.HTTPResp()
+	if httpResp.Request == nil || httpResp.Request.URL.String() != rawURL {
+		t.Fatalf("Inconsistent request of the response: %#v", httpResp.Request)
+	}
+	body, err := io.ReadAll(httpResp.Body)
+	httpResp.Body.Close()
+	if err != nil {
+		t.Fatalf("An error occurs when reading the body: %s", err)
+	}
+	return httpResp, string(body)
+}
+
+func checkCacheStatus(httpResp *http.Response, body string, expectedStatus string, expectedBody string, t *testing.T) {
+	if status := httpResp.Header.Get(HeaderCache); status != expectedStatus {
+		t.Fatalf("Inconsistent cache status, expected: %s, actual: %s", expectedStatus, status)
+	}
+	if body != expectedBody {
+		t.Fatalf("Inconsistent body, expected: %q, actual: %q", expectedBody, body)
+	}
+}
+
+func TestNewDownloader(t *testing.T) {
+	d, _ := downloader.New(module.MID("D1"), &http.Client{}, nil)
+	argsList := []Args{
+		{},
+		{Dir: t.TempDir(), MaxBodySize: -1},
+	}
+	for _, args := range argsList {
+		if _, err := NewDownloader(d, args); err == nil {
+			t.Fatalf("No error when creating a downloader with cache with illegal arguments: %#v", args)
+		}
+	}
+	if _, err := NewDownloader(nil, Args{Dir: t.TempDir()}); err == nil {
+		t.Fatal("No error when creating a downloader with cache with nil downloader")
+	}
+	cd, err := NewDownloader(d, Args{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("An error occurs when creating a downloader with cache: %s", err)
+	}
+	if cd.ID() != d.ID() {
+		t.Fatalf("Inconsistent MID, expected: %s, actual: %s", d.ID(), cd.ID())
+	}
+	if _, ok := cd.(module.ContextDownloader); !ok {
+		t.Fatal("The downloader with cache is not a context downloader")
+	}
+	if _, err := cd.Download(nil); err == nil {
+		t.Fatal("No error when downloading nil request")
+	}
+}
+
+func TestCacheFresh(t *testing.T) {
+	server := newCacheServer(http.Header{"Cache-Control": {"max-age=60"}}, "fresh")
+	defer server.Close()
+	d := genCacheDownloader(Args{Dir: t.TempDir()}, nil, t)
+	httpResp, body := download(d, server.URL+"/page?b=2&a=1", t)
+	checkCacheStatus(httpResp, body, CACHE_MISS, "fresh", t)
+	// The same canonical URL.
+	for _, rawURL := range []string{
+		server.URL + "/page?a=1&b=2",
+		server.URL + "/page?b=2&a=1&utm_source=x#top",
+	} {
+		httpResp, body = download(d, rawURL, t)
+		checkCacheStatus(httpResp, body, CACHE_HIT, "fresh", t)
+	}
+	if requests := atomic.LoadUint64(&server.requests); requests != 1 {
+		t.Fatalf("Inconsistent request number, expected: 1, actual: %d", requests)
+	}
+	// The stale response without validators is downloaded again.
+	d.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	httpResp, body = download(d, server.URL+"/page?a=1&b=2", t)
+	checkCacheStatus(httpResp, body, CACHE_MISS, "fresh", t)
+	if requests := atomic.LoadUint64(&server.requests); requests != 2 {
+		t.Fatalf("Inconsistent request number, expected: 2, actual: %d", requests)
+	}
+	extra, ok := d.Summary().Extra.(SummaryStruct)
+	if !ok || extra.Hits != 2 || extra.Misses != 2 || extra.Stored != 2 {
+		t.Fatalf("Inconsistent summary extra: %#v", d.Summary().Extra)
+	}
+	if called := d.CalledCount(); called != 2 {
+		t.Fatalf("Inconsistent called count, expected: 2, actual: %d", called)
+	}
+}
+
+func TestCacheRevalidate(t *testing.T) {
+	headers := []http.Header{
+		{"Etag": {`"v1"`}, "Cache-Control": {"no-cache"}},
+		{"Last-Modified": {"Mon, 02 Jan 2006 15:04:05 GMT"}, "Cache-Control": {"max-age=10"}},
+	}
+	for _, header := range headers {
+		server := newCacheServer(header, "validated")
+		d := genCacheDownloader(Args{Dir: t.TempDir()}, nil, t)
+		httpResp, body := download(d, server.URL, t)
+		checkCacheStatus(httpResp, body, CACHE_MISS, "validated", t)
+		d.now = func() time.Time { return time.Now().Add(time.Minute) }
+		for i := 0; i < 2; i++ {
+			httpResp, body = download(d, server.URL, t)
+			checkCacheStatus(httpResp, body, CACHE_REVALIDATED, "validated", t)
+			if httpResp.StatusCode != http.StatusOK {
+				t.Fatalf("Inconsistent status code, expected: %d, actual: %d", http.StatusOK, httpResp.StatusCode)
+			}
+		}
+		if conditional := atomic.LoadUint64(&server.conditional); conditional != 2 {
+			t.Fatalf("Inconsistent conditional request number, expected: 2, actual: %d (header: %v)", conditional, header)
+		}
+		server.Close()
+	}
+}
+
+func TestCacheNoStore(t *testing.T) {
+	server := newCacheServer(http.Header{"Cache-Control": {"no-store, max-age=60"}}, "secret")
+	defer server.Close()
+	d := genCacheDownloader(Args{Dir: t.TempDir()}, nil, t)
+	for i := 0; i < 2; i++ {
+		httpResp, body := download(d, server.URL, t)
+		checkCacheStatus(httpResp, body, CACHE_MISS, "secret", t)
+	}
+	if requests := atomic.LoadUint64(&server.requests); requests != 2 {
+		t.Fatalf("Inconsistent request number, expected: 2, actual: %d", requests)
+	}
+}
+
+func TestCacheMaxBodySize(t *testing.T) {
+	large := strings.Repeat("x", 100)
+	server := newCacheServer(http.Header{"Cache-Control": {"max-age=60"}}, large)
+	defer server.Close()
+	d := genCacheDownloader(Args{Dir: t.TempDir(), MaxBodySize: 10}, nil, t)
+	for i := 0; i < 2; i++ {
+		httpResp, body := download(d, server.URL, t)
+		checkCacheStatus(httpResp, body, CACHE_MISS, large, t)
+	}
+	if requests := atomic.LoadUint64(&server.requests); requests != 2 {
+		t.Fatalf("Inconsistent request number, expected: 2, actual: %d", requests)
+	}
+}
+
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, errors.New("offline")
+}
+
+func TestCacheOffline(t *testing.T) {
+	server := newCacheServer(http.Header{"Cache-Control": {"no-cache"}}, "archived")
+	dir := t.TempDir()
+	d := genCacheDownloader(Args{Dir: dir}, nil, t)
+	download(d, server.URL+"/a", t)
+	server.Close()
+	offline := genCacheDownloader(Args{Dir: dir, Offline: true}, &http.Client{Transport: failingTransport{}}, t)
+	httpResp, body := download(offline, server.URL+"/a", t)
+	checkCacheStatus(httpResp, body, CACHE_HIT, "archived", t)
+	httpReq, _ := http.NewRequest(http.MethodGet, server.URL+"/b", nil)
+	if _, err := offline.Download(module.NewRequest(httpReq, 0)); err == nil {
+		t.Fatal("No error when missing the cache in offline mode")
+	}
+	httpReq, _ = http.NewRequest(http.MethodPost, server.URL+"/a", nil)
+	if _, err := offline.Download(module.NewRequest(httpReq, 0)); err == nil {
+		t.Fatal("No error when posting in offline mode")
+	}
+	if called := offline.CalledCount(); called != 0 {
+		t.Fatalf("The downloader has been called in offline mode: %d", called)
+	}
+}
+
+func TestFreshness(t *testing.T) {
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	httpDate := func(t time.Time) string { return t.Format(http.TimeFormat) }
+	cases := []struct {
+		header   http.Header
+		status   int
+		lifetime time.Duration
+	}{
+		{http.Header{"Cache-Control": {"max-age=30"}}, 200, 30 * time.Second},
+		{http.Header{"Cache-Control": {"public, MAX-AGE=\"30\""}}, 200, 30 * time.Second},
+		{http.Header{"Cache-Control": {"no-cache, max-age=30"}}, 200, 0},
+		{http.Header{"Date": {httpDate(date)}, "Expires": {httpDate(date.Add(time.Hour))}}, 200, time.Hour},
+		{http.Header{"Date": {httpDate(date)}, "Expires": {"0"}}, 200, 0},
+		{http.Header{"Date": {httpDate(date)}, "Last-Modified": {httpDate(date.Add(-10 * time.Hour))}}, 200, time.Hour},
+		{http.Header{"Date": {httpDate(date)}, "Last-Modified": {httpDate(date.AddDate(-1, 0, 0))}}, 200, maxHeuristicLifetime},
+		{http.Header{"Date": {httpDate(date)}, "Last-Modified": {httpDate(date.Add(-10 * time.Hour))}}, 500, 0},
+	}
+	for i, c := range cases {
+		if lifetime := freshnessLifetime(c.header, c.status); lifetime != c.lifetime {
+			t.Fatalf("Inconsistent freshness lifetime #%d, expected: %s, actual: %s", i, c.lifetime, lifetime)
+		}
+	}
+	header := http.Header{"Date": {httpDate(date)}, "Age": {"100"}}
+	age := currentAge(header, date, date.Add(time.Second), date.Add(time.Minute))
+	if expected := 100*time.Second + time.Minute; age != expected {
+		t.Fatalf("Inconsistent age, expected: %s, actual: %s", expected, age)
+	}
+	resp := &http.Response{StatusCode: 206, Header: http.Header{"Cache-Control": {"max-age=60"}}}
+	if storable(http.Header{}, resp) {
+		t.Fatal("A partial response is storable")
+	}
+	resp = &http.Response{StatusCode: 500, Header: http.Header{"Cache-Control": {"max-age=60"}}}
+	if !storable(http.Header{}, resp) {
+		t.Fatal("A response with explicit freshness is not storable")
+	}
+	if storable(http.Header{"Cache-Control": {"no-store"}}, resp) {
+		t.Fatal("A response to the request with no-store is storable")
+	}
+}
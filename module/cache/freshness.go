@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxHeuristicLifetime is the max freshness lifetime of a response without
+// any explicit expiration time.
+const maxHeuristicLifetime = 24 * time.Hour
+
+// cacheableStatuses are the status codes cacheable by default, see RFC 9110
+// section 15.1.
+var cacheableStatuses = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusPartialContent:       false,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusPermanentRedirect:    true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+// cacheControl is the directives in the Cache-Control header, whose names
+// are in lower case.
+type cacheControl map[string]string
+
+func parseCacheControl(header http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+			name, arg, _ := strings.Cut(directive, "=")
+			cc[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(arg), `"`)
+		}
+	}
+	return cc
+}
+
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+// seconds returns the value of the directive in seconds, or false if it's
+// absent or illegal.
+func (cc cacheControl) seconds(name string) (time.Duration, bool) {
+	arg, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// storable reports whether the response of the GET request could be stored.
+func storable(reqHeader http.Header, resp *http.Response) bool {
+	if parseCacheControl(reqHeader).has("no-store") {
+		return false
+	}
+	cc := parseCacheControl(resp.Header)
+	if cc.has("no-store") || resp.Header.Get("Vary") == "*" {
+		return false
+	}
+	if cacheableStatuses[resp.StatusCode] {
+		return true
+	}
+	// Any other final status is cacheable with explicit freshness.
+	if resp.StatusCode < 200 || resp.StatusCode == http.StatusNotModified ||
+		resp.StatusCode == http.StatusPartialContent {
+		return false
+	}
+	_, ok := cc.seconds("max-age")
+	return ok || resp.Header.Get("Expires") != ""
+}
+
+// freshnessLifetime computes the time for which the response is fresh, see
+// RFC 9111 section 4.2.1.
+func freshnessLifetime(header http.Header, status int) time.Duration {
+	cc := parseCacheControl(header)
+	if cc.has("no-cache") {
+		return 0
+	}
+	if maxAge, ok := cc.seconds("max-age"); ok {
+		return maxAge
+	}
+	date := parseHTTPTime(header.Get("Date"))
+	if expires := header.Get("Expires"); expires != "" {
+		// An illegal time such as "0" means already expired.
+		t := parseHTTPTime(expires)
+		if t.IsZero() || date.IsZero() || !t.After(date) {
+			return 0
+		}
+		return t.Sub(date)
+	}
+	if !cacheableStatuses[status] {
+		return 0
+	}
+	lastModified := parseHTTPTime(header.Get("Last-Modified"))
+	if lastModified.IsZero() || date.IsZero() || !date.After(lastModified) {
+		return 0
+	}
+	lifetime := date.Sub(lastModified) / 10
+	if lifetime > maxHeuristicLifetime {
+		lifetime = maxHeuristicLifetime
+	}
+	return lifetime
+}
+
+// currentAge computes the age of the response stored at the response time,
+// see RFC 9111 section 4.2.3.
+func currentAge(header http.Header, requestTime time.Time, responseTime time.Time, now time.Time) time.Duration {
+	var apparentAge time.Duration
+	if date := parseHTTPTime(header.Get("Date")); !date.IsZero() && responseTime.After(date) {
+		apparentAge = responseTime.Sub(date)
+	}
+	var ageValue time.Duration
+	if n, err := strconv.ParseInt(header.Get("Age"), 10, 64); err == nil && n > 0 {
+		ageValue = time.Duration(n) * time.Second
+	}
+	correctedAge := ageValue + responseTime.Sub(requestTime)
+	age := apparentAge
+	if correctedAge > age {
+		age = correctedAge
+	}
+	if now.After(responseTime) {
+		age += now.Sub(responseTime)
+	}
+	return age
+}
+
+func parseHTTPTime(value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
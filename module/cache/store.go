@@ -0,0 +1,178 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// entryMagic is the first line of an entry file.
+const entryMagic = "WCCACHE1"
+
+// entry is a response stored in the cache.
+type entry struct {
+	URL         string    `json:"url"`
+	RequestTime time.Time `json:"request_time"`
+	// ResponseTime is the time when the response was received or
+	// revalidated.
+	ResponseTime time.Time   `json:"response_time"`
+	StatusCode   int         `json:"-"`
+	Header       http.Header `json:"-"`
+	Body         []byte      `json:"-"`
+}
+
+// validators returns the headers of the conditional request revalidating
+// the entry, or nil if it has no validator.
+func (e *entry) validators() http.Header {
+	header := http.Header{}
+	if etag := e.Header.Get("ETag"); etag != "" {
+		header.Set("If-None-Match", etag)
+	}
+	if lastModified := e.Header.Get("Last-Modified"); lastModified != "" {
+		header.Set("If-Modified-Since", lastModified)
+	}
+	if len(header) == 0 {
+		return nil
+	}
+	return header
+}
+
+func (e *entry) fresh(now time.Time) bool {
+	lifetime := freshnessLifetime(e.Header, e.StatusCode)
+	return lifetime > currentAge(e.Header, e.RequestTime, e.ResponseTime, now)
+}
+
+// update merges the headers of the response not modified, see RFC 9111
+// section 4.3.4.
+func (e *entry) update(header http.Header) {
+	for name, values := range header {
+		switch http.CanonicalHeaderKey(name) {
+		case "Content-Length", "Content-Encoding", "Transfer-Encoding", "Content-Range":
+			continue
+		}
+		e.Header[name] = append([]string(nil), values...)
+	}
+}
+
+// response returns the HTTP response of the entry for the request.
+func (e *entry) response(httpReq *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       httpReq,
+	}
+}
+
+// diskStore keeps each entry in a file named by the hash of its key. An
+// entry file is a line of magic, a line of JSON metadata and the response in
+// the HTTP/1.1 wire format.
+type diskStore struct {
+	dir string
+}
+
+func newDiskStore(dir string) (*diskStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &diskStore{dir: dir}, nil
+}
+
+func (store *diskStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(store.dir, name[:2], name)
+}
+
+// get returns the entry of the key, or nil if absent.
+func (store *diskStore) get(key string) (*entry, error) {
+	file, err := os.Open(store.path(key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	magic, err := reader.ReadString('\n')
+	if err != nil || magic != entryMagic+"\n" {
+		return nil, fmt.Errorf("invalid cache file %s", file.Name())
+	}
+	metaLine, err := reader.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("corrupted cache file %s: %s", file.Name(), err)
+	}
+	e := &entry{}
+	if err := json.Unmarshal(metaLine, e); err != nil {
+		return nil, fmt.Errorf("corrupted cache file %s: %s", file.Name(), err)
+	}
+	// The key is checked against the hash collision.
+	if e.URL != key {
+		return nil, nil
+	}
+	httpResp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		return nil, fmt.Errorf("corrupted cache file %s: %s", file.Name(), err)
+	}
+	defer httpResp.Body.Close()
+	if e.Body, err = io.ReadAll(httpResp.Body); err != nil {
+		return nil, fmt.Errorf("corrupted cache file %s: %s", file.Name(), err)
+	}
+	e.StatusCode = httpResp.StatusCode
+	e.Header = httpResp.Header
+	e.Header.Del("Content-Length")
+	return e, nil
+}
+
+// put writes the entry into a temporary file and renames it, so that a
+// reader never sees a partial entry.
+func (store *diskStore) put(e *entry) (err error) {
+	path := store.path(e.URL)
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
+	writer := bufio.NewWriter(file)
+	metaLine, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	writer.WriteString(entryMagic + "\n")
+	writer.Write(metaLine)
+	writer.WriteString("\n")
+	httpResp := e.response(nil)
+	httpResp.Header.Del("Transfer-Encoding")
+	if err = httpResp.Write(writer); err != nil {
+		return err
+	}
+	if err = writer.Flush(); err != nil {
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
@@ -0,0 +1,95 @@
+package module
+
+import (
+	"context"
+	"net/http"
+)
+
+// ContextDownloader is a downloader which stops the download once the
+// context is done.
+type ContextDownloader interface {
+	Downloader
+	DownloadContext(ctx context.Context, req *Request) (*Response, error)
+}
+
+// ContextAnalyzer is an analyzer which stops the analysis once the context
+// is done.
+type ContextAnalyzer interface {
+	Analyzer
+	AnalyzeContext(ctx context.Context, resp *Response) ([]Data, []error)
+}
+
+// ContextPipeline is a pipeline which stops the processing once the context
+// is done.
+type ContextPipeline interface {
+	Pipeline
+	SendContext(ctx context.Context, item Item) []error
+}
+
+type ContextParseResponse func(ctx context.Context, httpResp *http.Response, respDepth uint32) ([]Data, []error)
+
+type ContextProcessItem func(ctx context.Context, item Item) (result Item, err error)
+
+// ContextParser adapts a response parser which ignores the context.
+func ContextParser(parser ParseResponse) ContextParseResponse {
+	if parser == nil {
+		return nil
+	}
+	return func(ctx context.Context, httpResp *http.Response, respDepth uint32) ([]Data, []error) {
+		return parser(httpResp, respDepth)
+	}
+}
+
+// ContextProcessor adapts an item processor which ignores the context.
+func ContextProcessor(processor ProcessItem) ContextProcessItem {
+	if processor == nil {
+		return nil
+	}
+	return func(ctx context.Context, item Item) (Item, error) {
+		return processor(item)
+	}
+}
+
+// DownloadContext downloads with the context. For a downloader which is not
+// a ContextDownloader, the context is bound to the HTTP request.
+func DownloadContext(ctx context.Context, downloader Downloader, req *Request) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if d, ok := downloader.(ContextDownloader); ok {
+		return d.DownloadContext(ctx, req)
+	}
+	if req != nil && req.HTTPReq() != nil {
+		req = &Request{
+			httpReq:  req.HTTPReq().WithContext(ctx),
+			depth:    req.depth,
+			meta:     req.meta,
+			attempts: req.Attempts(),
+		}
+	}
+	return downloader.Download(req)
+}
+
+// AnalyzeContext analyzes with the context. An analyzer which is not a
+// ContextAnalyzer is only stopped before the analysis.
+func AnalyzeContext(ctx context.Context, analyzer Analyzer, resp *Response) ([]Data, []error) {
+	if err := ctx.Err(); err != nil {
+		return nil, []error{err}
+	}
+	if a, ok := analyzer.(ContextAnalyzer); ok {
+		return a.AnalyzeContext(ctx, resp)
+	}
+	return analyzer.Analyze(resp)
+}
+
+// SendContext sends the item with the context. A pipeline which is not a
+// ContextPipeline is only stopped before the processing.
+func SendContext(ctx context.Context, pipeline Pipeline, item Item) []error {
+	if err := ctx.Err(); err != nil {
+		return []error{err}
+	}
+	if p, ok := pipeline.(ContextPipeline); ok {
+		return p.SendContext(ctx, item)
+	}
+	return pipeline.Send(item)
+}
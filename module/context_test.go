@@ -0,0 +1,92 @@
+package module
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+type ctxTestDownloader struct {
+	Downloader
+	httpReq *http.Request
+}
+
+func (d *ctxTestDownloader) Download(req *Request) (*Response, error) {
+	d.httpReq = req.HTTPReq()
+	return NewResponse(nil, req.Depth()), nil
+}
+
+type ctxTestAnalyzer struct {
+	Analyzer
+	called bool
+}
+
+func (a *ctxTestAnalyzer) Analyze(resp *Response) ([]Data, []error) {
+	a.called = true
+	return nil, nil
+}
+
+type ctxTestPipeline struct {
+	Pipeline
+	called bool
+}
+
+func (p *ctxTestPipeline) Send(item Item) []error {
+	p.called = true
+	return nil
+}
+
+type ctxKey struct{}
+
+func TestContextAdapters(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ctxKey{}, "v")
+	httpReq, _ := http.NewRequest("GET", "https://github.com/gopcp", nil)
+	req := NewRequestWithMeta(httpReq, 2, Meta{AnchorText: "gopcp"})
+	d := &ctxTestDownloader{}
+	resp, err := DownloadContext(ctx, d, req)
+	if err != nil || resp.Depth() != 2 {
+		t.Fatalf("Inconsistent download result, error: %v", err)
+	}
+	if d.httpReq.Context().Value(ctxKey{}) != "v" {
+		t.Fatal("The context has not been bound to the HTTP request")
+	}
+	if httpReq.Context().Value(ctxKey{}) != nil {
+		t.Fatal("The original HTTP request has been modified")
+	}
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	d = &ctxTestDownloader{}
+	if _, err = DownloadContext(canceledCtx, d, req); err == nil || d.httpReq != nil {
+		t.Fatal("It can still download with a canceled context")
+	}
+	a := &ctxTestAnalyzer{}
+	if _, errs := AnalyzeContext(canceledCtx, a, resp); len(errs) == 0 || a.called {
+		t.Fatal("It can still analyze with a canceled context")
+	}
+	if _, errs := AnalyzeContext(ctx, a, resp); len(errs) != 0 || !a.called {
+		t.Fatal("Could not analyze with the context")
+	}
+	p := &ctxTestPipeline{}
+	if errs := SendContext(canceledCtx, p, Item{}); len(errs) == 0 || p.called {
+		t.Fatal("It can still send item with a canceled context")
+	}
+	if errs := SendContext(ctx, p, Item{}); len(errs) != 0 || !p.called {
+		t.Fatal("Could not send item with the context")
+	}
+	parser := ContextParser(func(httpResp *http.Response, respDepth uint32) ([]Data, []error) {
+		return []Data{Item{"depth": respDepth}}, nil
+	})
+	if dataList, _ := parser(ctx, nil, 3); len(dataList) != 1 {
+		t.Fatal("Inconsistent result of the adapted parser")
+	}
+	processor := ContextProcessor(func(item Item) (Item, error) {
+		item["processed"] = true
+		return item, nil
+	})
+	if item, _ := processor(ctx, Item{}); item["processed"] != true {
+		t.Fatal("Inconsistent result of the adapted processor")
+	}
+	if ContextParser(nil) != nil || ContextProcessor(nil) != nil {
+		t.Fatal("The adapters of nil functions are not nil")
+	}
+}
@@ -1,20 +1,35 @@
 package module
 
-import "net/http"
+import (
+	"net/http"
+	"sync/atomic"
+)
 
 type Data interface {
 	Valid() bool
 }
 
 type Request struct {
-	httpReq *http.Request
-	depth   uint32
+	httpReq  *http.Request
+	depth    uint32
+	meta     Meta
+	attempts uint32
 }
 
 func NewRequest(httpReq *http.Request, depth uint32) *Request {
 	return &Request{httpReq: httpReq, depth: depth}
 }
 
+// NewRequestWithPriority creates a request which is served before the ones
+// with lower priorities. The priority of NewRequest is 0.
+func NewRequestWithPriority(httpReq *http.Request, depth uint32, priority int) *Request {
+	return &Request{httpReq: httpReq, depth: depth, meta: Meta{Priority: priority}}
+}
+
+func NewRequestWithMeta(httpReq *http.Request, depth uint32, meta Meta) *Request {
+	return &Request{httpReq: httpReq, depth: depth, meta: meta}
+}
+
 func (req *Request) HTTPReq() *http.Request {
 	return req.httpReq
 }
@@ -23,6 +38,29 @@ func (req *Request) Depth() uint32 {
 	return req.depth
 }
 
+func (req *Request) Priority() int {
+	return req.meta.Priority
+}
+
+// Meta returns the metadata of the request, in which the retry count is
+// derived from the download attempts.
+func (req *Request) Meta() Meta {
+	meta := req.meta
+	if attempts := req.Attempts(); attempts > 1 {
+		meta.RetryCount = attempts - 1
+	}
+	return meta
+}
+
+// Attempts returns the number of the download attempts of the request.
+func (req *Request) Attempts() uint32 {
+	return atomic.LoadUint32(&req.attempts)
+}
+
+func (req *Request) IncrAttempts() uint32 {
+	return atomic.AddUint32(&req.attempts, 1)
+}
+
 func (req *Request) Valid() bool {
 	return req.httpReq != nil && req.httpReq.URL != nil
 }
@@ -30,12 +68,17 @@ func (req *Request) Valid() bool {
 type Response struct {
 	httpResp *http.Response
 	depth    uint32
+	meta     Meta
 }
 
 func NewResponse(httpResp *http.Response, depth uint32) *Response {
 	return &Response{httpResp: httpResp, depth: depth}
 }
 
+func NewResponseWithMeta(httpResp *http.Response, depth uint32, meta Meta) *Response {
+	return &Response{httpResp: httpResp, depth: depth, meta: meta}
+}
+
 func (resp *Response) HTTPResp() *http.Response {
 	return resp.httpResp
 }
@@ -44,6 +87,10 @@ func (resp *Response) Depth() uint32 {
 	return resp.depth
 }
 
+func (resp *Response) Meta() Meta {
+	return resp.meta
+}
+
 func (resp *Response) Valid() bool {
 	return resp.httpResp != nil && resp.httpResp.Body != nil
 }
@@ -41,6 +41,20 @@ func TestRequest(t *testing.T) {
 	if req.Depth() != expectedDepth {
 		t.Fatalf("Inconsistent depth for request, expected: %d, actual: %d", expectedDepth, req.Depth())
 	}
+	if req.Priority() != 0 {
+		t.Fatalf("Inconsistent priority for request, expected: %d, actual: %d", 0, req.Priority())
+	}
+	expectedPriority := 7
+	req = NewRequestWithPriority(expectedHTTPReq, expectedDepth, expectedPriority)
+	if req.Priority() != expectedPriority || req.Depth() != expectedDepth || req.HTTPReq() != expectedHTTPReq {
+		t.Fatalf("Inconsistent request with priority, expected priority: %d, actual: %d", expectedPriority, req.Priority())
+	}
+	if req.Attempts() != 0 {
+		t.Fatalf("Inconsistent attempts for request, expected: %d, actual: %d", 0, req.Attempts())
+	}
+	if attempts := req.IncrAttempts(); attempts != 1 || req.Attempts() != 1 {
+		t.Fatalf("Inconsistent attempts for request, expected: %d, actual: %d", 1, req.Attempts())
+	}
 	expectedHTTPReq.URL = nil
 	req = NewRequest(expectedHTTPReq, expectedDepth)
 	expectedValidity = false
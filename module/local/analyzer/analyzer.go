@@ -1,7 +1,9 @@
 package analyzer
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	werr "webcrawler/errors"
 	"webcrawler/helper/log"
 	"webcrawler/module"
@@ -12,6 +14,22 @@ import (
 var logger = log.DLogger()
 
 func New(mid module.MID, respParsers []module.ParseResponse, scoreCalculator module.CalculateScore) (module.Analyzer, error) {
+	if respParsers == nil {
+		return nil, genParameterError("nil response parsers")
+	}
+	ctxParsers := make([]module.ContextParseResponse, len(respParsers))
+	for i, parser := range respParsers {
+		ctxParsers[i] = module.ContextParser(parser)
+	}
+	return NewWithContextParsers(mid, ctxParsers, scoreCalculator)
+}
+
+// NewWithContextParsers creates an analyzer whose parsers receive the context
+// of the analysis.
+func NewWithContextParsers(
+	mid module.MID,
+	respParsers []module.ContextParseResponse,
+	scoreCalculator module.CalculateScore) (module.Analyzer, error) {
 	moduleBase, err := stub.NewModuleInternal(mid, scoreCalculator)
 	if err != nil {
 		return nil, err
@@ -22,7 +40,7 @@ func New(mid module.MID, respParsers []module.ParseResponse, scoreCalculator mod
 	if len(respParsers) == 0 {
 		return nil, genParameterError("empty response parsers")
 	}
-	var innerParsers []module.ParseResponse
+	var innerParsers []module.ContextParseResponse
 	for i, parser := range respParsers {
 		if parser == nil {
 			return nil, genParameterError(fmt.Sprintf("nil response parser [%d]", i))
@@ -37,16 +55,27 @@ func New(mid module.MID, respParsers []module.ParseResponse, scoreCalculator mod
 
 type myAnalyzer struct {
 	stub.ModuleInternal
-	respParsers []module.ParseResponse
+	respParsers []module.ContextParseResponse
 }
 
 func (analyzer *myAnalyzer) RespParsers() []module.ParseResponse {
 	parsers := make([]module.ParseResponse, len(analyzer.respParsers))
-	copy(parsers, analyzer.respParsers)
+	for i, parser := range analyzer.respParsers {
+		parser := parser
+		parsers[i] = func(httpResp *http.Response, respDepth uint32) ([]module.Data, []error) {
+			return parser(context.Background(), httpResp, respDepth)
+		}
+	}
 	return parsers
 }
 
 func (analyzer *myAnalyzer) Analyze(resp *module.Response) (dataList []module.Data, errorList []error) {
+	return analyzer.AnalyzeContext(context.Background(), resp)
+}
+
+// AnalyzeContext stops calling the rest of the parsers once the context is
+// done.
+func (analyzer *myAnalyzer) AnalyzeContext(ctx context.Context, resp *module.Response) (dataList []module.Data, errorList []error) {
 	analyzer.IncrHandlingNumber()
 	defer analyzer.DecrHandlingNumber()
 	analyzer.IncrCalledCount()
@@ -83,13 +112,17 @@ func (analyzer *myAnalyzer) Analyze(resp *module.Response) (dataList []module.Da
 	}
 	dataList = []module.Data{}
 	for _, respParser := range analyzer.respParsers {
+		if err := ctx.Err(); err != nil {
+			errorList = append(errorList, genError(fmt.Sprintf("analysis interrupted: %s", err)))
+			break
+		}
 		httpResp.Body = multipleReader.Reader()
-		pDataList, pErrorList := respParser(httpResp, respDepth)
+		pDataList, pErrorList := respParser(ctx, httpResp, respDepth)
 		for _, pData := range pDataList {
 			if pData == nil {
 				continue
 			}
-			dataList = appendDataList(dataList, pData, respDepth)
+			dataList = appendDataList(dataList, pData, respDepth, reqURL.String(), resp.Meta())
 		}
 		for _, pError := range pErrorList {
 			if pError == nil {
@@ -104,17 +137,38 @@ func (analyzer *myAnalyzer) Analyze(resp *module.Response) (dataList []module.Da
 	return dataList, errorList
 }
 
-func appendDataList(dataList []module.Data, data module.Data, respDepth uint32) []module.Data {
+// appendDataList appends the data with the depth and the metadata fixed: the
+// requests get the parent URL, the referrer and the max depth of the response
+// like module.Meta.Child, and the items get the metadata of the response
+// under module.ITEM_KEY_META.
+func appendDataList(dataList []module.Data, data module.Data, respDepth uint32, respURL string, respMeta module.Meta) []module.Data {
 	if data == nil {
 		return dataList
 	}
+	if item, ok := data.(module.Item); ok {
+		if _, exists := item[module.ITEM_KEY_META]; !exists {
+			item[module.ITEM_KEY_META] = respMeta
+		}
+		return append(dataList, item)
+	}
 	req, ok := data.(*module.Request)
 	if !ok {
 		return append(dataList, data)
 	}
 	newDepth := respDepth + 1
-	if req.Depth() != newDepth {
-		req = module.NewRequest(req.HTTPReq(), newDepth)
+	meta := req.Meta()
+	if req.Depth() != newDepth || meta.ParentURL == "" || meta.Referrer == "" ||
+		meta.MaxDepth == 0 && respMeta.MaxDepth > 0 {
+		if meta.ParentURL == "" {
+			meta.ParentURL = respURL
+		}
+		if meta.Referrer == "" {
+			meta.Referrer = meta.ParentURL
+		}
+		if meta.MaxDepth == 0 {
+			meta.MaxDepth = respMeta.MaxDepth
+		}
+		req = module.NewRequestWithMeta(req.HTTPReq(), newDepth, meta)
 	}
 	return append(dataList, req)
 }
@@ -2,6 +2,7 @@ package analyzer
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -274,3 +275,98 @@ func getTestingResps(number uint32, method string, url string, depth uint32, t *
 	}
 	return resps
 }
+
+func TestAnalyzeMeta(t *testing.T) {
+	mid := module.MID("A1|127.0.0.1:8080")
+	a, _ := New(mid, []module.ParseResponse{genTestingRespParser(false)}, nil)
+	url := "https://github.com/gopcp"
+	httpReq, _ := http.NewRequest("GET", url, nil)
+	httpResp := &http.Response{
+		Request: httpReq,
+		Body:    testingReader{strings.NewReader(fmt.Sprintf(fakeHTTPRespBody, 0))},
+	}
+	respMeta := module.Meta{AnchorText: "gopcp", MaxDepth: 3, UserData: map[string]interface{}{"site": "github"}}
+	dataList, errs := a.Analyze(module.NewResponseWithMeta(httpResp, 1, respMeta))
+	if len(errs) > 0 {
+		t.Fatalf("An error occurs when analyzing response: %s", errs[0])
+	}
+	if len(dataList) != 2 {
+		t.Fatalf("Inconsistent data number, expected: %d, actual: %d", 2, len(dataList))
+	}
+	item, ok := dataList[0].(module.Item)
+	if !ok {
+		t.Fatalf("Inconsistent data type, expected: module.Item, actual: %T", dataList[0])
+	}
+	itemMeta, ok := item.Meta()
+	if !ok || itemMeta.AnchorText != "gopcp" || itemMeta.UserData["site"] != "github" {
+		t.Fatalf("Inconsistent item metadata: %#v", itemMeta)
+	}
+	req, ok := dataList[1].(*module.Request)
+	if !ok {
+		t.Fatalf("Inconsistent data type, expected: *module.Request, actual: %T", dataList[1])
+	}
+	if req.Depth() != 2 || req.Meta().ParentURL != url {
+		t.Fatalf("Inconsistent request, expected depth: %d, parent URL: %s, actual depth: %d, parent URL: %s",
+			2, url, req.Depth(), req.Meta().ParentURL)
+	}
+	if req.Meta().MaxDepth != 3 {
+		t.Fatalf("Inconsistent max depth of the request, expected: %d, actual: %d", 3, req.Meta().MaxDepth)
+	}
+	if req.Meta().Referrer != url {
+		t.Fatalf("Inconsistent referrer of the request, expected: %s, actual: %s", url, req.Meta().Referrer)
+	}
+	// The referrer defaults to the parent URL set by the parser.
+	parentURL := "https://github.com/"
+	childHTTPReq, _ := http.NewRequest("GET", "https://github.com/gopcp/1", nil)
+	child := module.NewRequestWithMeta(childHTTPReq, 2, module.Meta{ParentURL: parentURL})
+	dataList = appendDataList(nil, child, 1, url, respMeta)
+	if meta := dataList[0].(*module.Request).Meta(); meta.ParentURL != parentURL || meta.Referrer != parentURL {
+		t.Fatalf("Inconsistent parent URL and referrer, expected: %s, actual: %s and %s",
+			parentURL, meta.ParentURL, meta.Referrer)
+	}
+	// The referrer set by the parser is kept.
+	child = module.NewRequestWithMeta(childHTTPReq, 2, module.Meta{ParentURL: parentURL, Referrer: url})
+	dataList = appendDataList(nil, child, 1, url, respMeta)
+	if meta := dataList[0].(*module.Request).Meta(); meta.Referrer != url {
+		t.Fatalf("Inconsistent referrer, expected: %s, actual: %s", url, meta.Referrer)
+	}
+}
+
+func TestAnalyzeContext(t *testing.T) {
+	mid := module.MID("A1|127.0.0.1:8080")
+	ctx, cancel := context.WithCancel(context.Background())
+	called := 0
+	parsers := []module.ContextParseResponse{
+		func(ctx context.Context, httpResp *http.Response, respDepth uint32) ([]module.Data, []error) {
+			called++
+			cancel()
+			return []module.Data{module.Item{"depth": respDepth}}, nil
+		},
+		func(ctx context.Context, httpResp *http.Response, respDepth uint32) ([]module.Data, []error) {
+			called++
+			return nil, nil
+		},
+	}
+	a, err := NewWithContextParsers(mid, parsers, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating an analyzer: %s", err)
+	}
+	if _, err = NewWithContextParsers(mid, []module.ContextParseResponse{nil}, nil); err == nil {
+		t.Fatal("No error when create an analyzer with nil parser")
+	}
+	if len(a.RespParsers()) != len(parsers) {
+		t.Fatalf("Inconsistent parser number, expected: %d, actual: %d", len(parsers), len(a.RespParsers()))
+	}
+	httpReq, _ := http.NewRequest("GET", "https://github.com/gopcp", nil)
+	httpResp := &http.Response{
+		Request: httpReq,
+		Body:    testingReader{strings.NewReader(fmt.Sprintf(fakeHTTPRespBody, 0))},
+	}
+	dataList, errs := a.(module.ContextAnalyzer).AnalyzeContext(ctx, module.NewResponse(httpResp, 0))
+	if called != 1 {
+		t.Fatalf("Inconsistent called parser number, expected: %d, actual: %d", 1, called)
+	}
+	if len(dataList) != 1 || len(errs) != 1 || !strings.Contains(errs[0].Error(), "interrupted") {
+		t.Fatalf("Inconsistent result of interrupted analysis, data: %#v, errors: %v", dataList, errs)
+	}
+}
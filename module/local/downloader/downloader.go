@@ -1,7 +1,15 @@
 package downloader
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
 	werr "webcrawler/errors"
 	"webcrawler/helper/log"
 	"webcrawler/module"
@@ -10,6 +18,10 @@ import (
 
 var logger = log.DLogger()
 
+// maxDrainSize is the max size of the body read from a discarded response so
+// that the connection can be reused.
+const maxDrainSize = 64 * 1024
+
 func New(mid module.MID, client *http.Client, scoreCalculator module.CalculateScore) (module.Downloader, error) {
 	moduleBase, err := stub.NewModuleInternal(mid, scoreCalculator)
 	if err != nil {
@@ -24,12 +36,46 @@ func New(mid module.MID, client *http.Client, scoreCalculator module.CalculateSc
 	}, nil
 }
 
+// NewWithRetryPolicy creates a downloader which retries the failed downloads
+// according to the policy.
+func NewWithRetryPolicy(
+	mid module.MID,
+	client *http.Client,
+	scoreCalculator module.CalculateScore,
+	policy RetryPolicy) (module.Downloader, error) {
+	if err := policy.check(); err != nil {
+		return nil, err
+	}
+	d, err := New(mid, client, scoreCalculator)
+	if err != nil {
+		return nil, err
+	}
+	d.(*myDownloader).retryPolicy = policy
+	return d, nil
+}
+
 type myDownloader struct {
 	stub.ModuleInternal
-	httpClient http.Client
+	httpClient  http.Client
+	retryPolicy RetryPolicy
+	retryStats  retryStats
+	// transports caches the transports by proxy URL.
+	transports sync.Map
+	// cookieJars caches the cookie jars by cookie jar key.
+	cookieJars sync.Map
 }
 
 func (downloader *myDownloader) Download(req *module.Request) (*module.Response, error) {
+	ctx := context.Background()
+	if req != nil && req.HTTPReq() != nil {
+		ctx = req.HTTPReq().Context()
+	}
+	return downloader.DownloadContext(ctx, req)
+}
+
+// DownloadContext downloads with the context in place of the one of the HTTP
+// request, which also interrupts the backoff between the retries.
+func (downloader *myDownloader) DownloadContext(ctx context.Context, req *module.Request) (*module.Response, error) {
 	downloader.IncrHandlingNumber()
 	defer downloader.DecrHandlingNumber()
 	downloader.IncrCalledCount()
@@ -40,14 +86,141 @@ func (downloader *myDownloader) Download(req *module.Request) (*module.Response,
 	if httpReq == nil {
 		return nil, genParameterError("nil HTTP request")
 	}
+	meta := req.Meta()
+	client, err := downloader.client(meta)
+	if err != nil {
+		return nil, err
+	}
 	downloader.IncrAcceptedCount()
-	logger.Infof("Do the request (URL: %s, depth: %d)... \n", httpReq.URL, req.Depth())
-	httpResp, err := downloader.httpClient.Do(httpReq)
+	httpResp, err := downloader.do(ctx, client, req)
 	if err != nil {
 		return nil, err
 	}
 	downloader.IncrCompletedCount()
-	return module.NewResponse(httpResp, req.Depth()), nil
+	return module.NewResponseWithMeta(httpResp, req.Depth(), req.Meta()), nil
+}
+
+// client returns the HTTP client for the proxy and the cookie jar in the
+// metadata.
+func (downloader *myDownloader) client(meta module.Meta) (*http.Client, error) {
+	if meta.Proxy == "" && meta.CookieJarKey == "" {
+		return &downloader.httpClient, nil
+	}
+	client := downloader.httpClient
+	if meta.Proxy != "" {
+		transport, err := downloader.proxyTransport(meta.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		client.Transport = transport
+	}
+	if meta.CookieJarKey != "" {
+		jar, ok := downloader.cookieJars.Load(meta.CookieJarKey)
+		if !ok {
+			newJar, err := cookiejar.New(nil)
+			if err != nil {
+				return nil, genError(err.Error())
+			}
+			jar, _ = downloader.cookieJars.LoadOrStore(meta.CookieJarKey, newJar)
+		}
+		client.Jar = jar.(http.CookieJar)
+	}
+	return &client, nil
+}
+
+func (downloader *myDownloader) proxyTransport(proxy string) (http.RoundTripper, error) {
+	if transport, ok := downloader.transports.Load(proxy); ok {
+		return transport.(http.RoundTripper), nil
+	}
+	proxyURL, err := url.Parse(proxy)
+	if err != nil || proxyURL.Host == "" {
+		return nil, genParameterError(fmt.Sprintf("illegal proxy %q", proxy))
+	}
+	base, ok := downloader.httpClient.Transport.(*http.Transport)
+	if downloader.httpClient.Transport == nil {
+		base, ok = http.DefaultTransport.(*http.Transport)
+	}
+	if !ok {
+		return nil, genParameterError(fmt.Sprintf("proxy %q is unsupported by the transport %T",
+			proxy, downloader.httpClient.Transport))
+	}
+	transport := base.Clone()
+	transport.Proxy = http.ProxyURL(proxyURL)
+	actual, _ := downloader.transports.LoadOrStore(proxy, transport)
+	return actual.(http.RoundTripper), nil
+}
+
+func (downloader *myDownloader) do(ctx context.Context, client *http.Client, req *module.Request) (*http.Response, error) {
+	meta := req.Meta()
+	httpReq := req.HTTPReq()
+	httpReq = httpReq.WithContext(module.ContextWithMeta(ctx, meta))
+	if meta.Referrer != "" && httpReq.Header.Get("Referer") == "" {
+		httpReq.Header = httpReq.Header.Clone()
+		if httpReq.Header == nil {
+			httpReq.Header = http.Header{}
+		}
+		httpReq.Header.Set("Referer", meta.Referrer)
+	}
+	policy := downloader.retryPolicy
+	for {
+		attempt := req.IncrAttempts()
+		logger.Infof("Do the request (URL: %s, depth: %d, attempt: %d)... \n", httpReq.URL, req.Depth(), attempt)
+		httpResp, err := client.Do(httpReq)
+		retryable := false
+		if err != nil {
+			retryable = retryableError(err)
+		} else {
+			retryable = policy.retryableStatus(httpResp.StatusCode)
+		}
+		if !retryable || !policy.enabled() {
+			if attempt > 1 && err == nil && !retryable {
+				atomic.AddUint64(&downloader.retryStats.recovered, 1)
+			}
+			return httpResp, err
+		}
+		if attempt >= policy.MaxAttempts || httpReq.Context().Err() != nil ||
+			(httpReq.Body != nil && httpReq.Body != http.NoBody && httpReq.GetBody == nil) {
+			atomic.AddUint64(&downloader.retryStats.exhausted, 1)
+			return httpResp, err
+		}
+		backoff := policy.backoff(attempt, httpResp)
+		if httpResp != nil {
+			io.Copy(io.Discard, io.LimitReader(httpResp.Body, maxDrainSize))
+			httpResp.Body.Close()
+		}
+		if err != nil {
+			logger.Warnf("Retry the request in %s (URL: %s, attempt: %d, error: %s)", backoff, httpReq.URL, attempt, err)
+		} else {
+			logger.Warnf("Retry the request in %s (URL: %s, attempt: %d, status: %d)",
+				backoff, httpReq.URL, attempt, httpResp.StatusCode)
+		}
+		if attempt == 1 {
+			atomic.AddUint64(&downloader.retryStats.retried, 1)
+		}
+		atomic.AddUint64(&downloader.retryStats.retries, 1)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-httpReq.Context().Done():
+			timer.Stop()
+			return nil, httpReq.Context().Err()
+		}
+		if httpReq.GetBody != nil {
+			body, err := httpReq.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			httpReq.Body = body
+		}
+	}
+}
+
+func (downloader *myDownloader) Summary() module.SummaryStruct {
+	summary := downloader.ModuleInternal.Summary()
+	if downloader.retryPolicy.enabled() {
+		summary.Extra = downloader.retryStats.summary()
+	}
+	return summary
 }
 
 func genParameterError(errMsg string) error {
@@ -0,0 +1,78 @@
+package downloader
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"webcrawler/module"
+)
+
+func TestDownloadMeta(t *testing.T) {
+	var lastReferrer string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastReferrer = r.Referer()
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "1"})
+			return
+		}
+		if cookie, err := r.Cookie("session"); err == nil {
+			w.Write([]byte(cookie.Value))
+		}
+	}))
+	defer server.Close()
+	mid := module.MID("D1|127.0.0.1:8080")
+	d, _ := New(mid, &http.Client{}, nil)
+	meta := module.Meta{Referrer: "http://a.com/", CookieJarKey: "user1", AnchorText: "login"}
+	httpReq, _ := http.NewRequest("GET", server.URL+"/login", nil)
+	resp, err := d.Download(module.NewRequestWithMeta(httpReq, 0, meta))
+	if err != nil {
+		t.Fatalf("An error occurs when downloading: %s", err)
+	}
+	resp.HTTPResp().Body.Close()
+	if lastReferrer != meta.Referrer {
+		t.Fatalf("Inconsistent referrer, expected: %s, actual: %s", meta.Referrer, lastReferrer)
+	}
+	if httpReq.Header.Get("Referer") != "" {
+		t.Fatal("The header of the original HTTP request has been modified")
+	}
+	if resp.Meta().AnchorText != "login" {
+		t.Fatalf("Inconsistent response metadata: %#v", resp.Meta())
+	}
+	if ctxMeta, ok := module.MetaFromResponse(resp.HTTPResp()); !ok || ctxMeta.AnchorText != "login" {
+		t.Fatalf("Inconsistent metadata from the HTTP response: %#v", ctxMeta)
+	}
+	for key, expected := range map[string]string{"user1": "1", "user2": ""} {
+		httpReq, _ = http.NewRequest("GET", server.URL+"/profile", nil)
+		resp, err = d.Download(module.NewRequestWithMeta(httpReq, 0, module.Meta{CookieJarKey: key}))
+		if err != nil {
+			t.Fatalf("An error occurs when downloading: %s", err)
+		}
+		buf := make([]byte, 8)
+		n, _ := resp.HTTPResp().Body.Read(buf)
+		resp.HTTPResp().Body.Close()
+		if string(buf[:n]) != expected {
+			t.Fatalf("Inconsistent cookie for cookie jar %q, expected: %q, actual: %q", key, expected, buf[:n])
+		}
+	}
+	proxied := 0
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied++
+		target, _ := url.Parse(r.RequestURI)
+		w.Write([]byte(target.Host))
+	}))
+	defer proxy.Close()
+	httpReq, _ = http.NewRequest("GET", "http://proxied.example/", nil)
+	resp, err = d.Download(module.NewRequestWithMeta(httpReq, 0, module.Meta{Proxy: proxy.URL}))
+	if err != nil {
+		t.Fatalf("An error occurs when downloading through proxy: %s", err)
+	}
+	resp.HTTPResp().Body.Close()
+	if proxied != 1 {
+		t.Fatalf("The request has not been sent through the proxy %s", proxy.URL)
+	}
+	httpReq, _ = http.NewRequest("GET", "http://proxied.example/", nil)
+	if _, err = d.Download(module.NewRequestWithMeta(httpReq, 0, module.Meta{Proxy: "::"})); err == nil {
+		t.Fatal("No error when downloading through an illegal proxy")
+	}
+}
@@ -0,0 +1,156 @@
+package downloader
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// RetryPolicy decides whether and when a failed download is attempted again.
+// Network errors and the retryable status codes are retried; the value of
+// the Retry-After header is honored, but never beyond MaxBackoff.
+// MaxAttempts includes the first attempt, so 0 and 1 mean no retry. Jitter is
+// the fraction of the backoff which is randomized.
+type RetryPolicy struct {
+	MaxAttempts          uint32
+	InitialBackoff       time.Duration
+	MaxBackoff           time.Duration
+	Multiplier           float64
+	Jitter               float64
+	RetryableStatusCodes []int
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		RetryableStatusCodes: []int{
+			http.StatusRequestTimeout,
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+func (policy RetryPolicy) check() error {
+	if policy.InitialBackoff < 0 || policy.MaxBackoff < 0 {
+		return genParameterError("negative backoff for retry policy")
+	}
+	if policy.Multiplier != 0 && policy.Multiplier < 1 {
+		return genParameterError("multiplier less than 1 for retry policy")
+	}
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		return genParameterError("jitter out of [0, 1] for retry policy")
+	}
+	return nil
+}
+
+func (policy RetryPolicy) enabled() bool {
+	return policy.MaxAttempts > 1
+}
+
+func (policy RetryPolicy) retryableStatus(code int) bool {
+	for _, retryable := range policy.RetryableStatusCodes {
+		if code == retryable {
+			return true
+		}
+	}
+	return false
+}
+
+// retryableError reports whether the error returned by http.Client.Do comes
+// from the network. *url.Error itself is skipped since it wraps all errors.
+func retryableError(err error) bool {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// backoff returns the delay before the next attempt, where attempt is the
+// number of the attempts made so far.
+func (policy RetryPolicy) backoff(attempt uint32, httpResp *http.Response) time.Duration {
+	if httpResp != nil {
+		if retryAfter, ok := parseRetryAfter(httpResp.Header.Get("Retry-After"), time.Now()); ok {
+			if policy.MaxBackoff > 0 && retryAfter > policy.MaxBackoff {
+				retryAfter = policy.MaxBackoff
+			}
+			return retryAfter
+		}
+	}
+	multiplier := policy.Multiplier
+	if multiplier == 0 {
+		multiplier = 1
+	}
+	backoff := float64(policy.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if policy.MaxBackoff > 0 && backoff > float64(policy.MaxBackoff) {
+		backoff = float64(policy.MaxBackoff)
+	}
+	if policy.Jitter > 0 {
+		backoff += backoff * policy.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(backoff)
+}
+
+// parseRetryAfter parses the value of Retry-After, either delay seconds or
+// an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if d := date.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+type RetrySummaryStruct struct {
+	Retries   uint64 `json:"retries"`
+	Retried   uint64 `json:"retried_requests"`
+	Recovered uint64 `json:"recovered_requests"`
+	Exhausted uint64 `json:"exhausted_requests"`
+}
+
+type retryStats struct {
+	retries   uint64
+	retried   uint64
+	recovered uint64
+	exhausted uint64
+}
+
+func (stats *retryStats) summary() RetrySummaryStruct {
+	return RetrySummaryStruct{
+		Retries:   atomic.LoadUint64(&stats.retries),
+		Retried:   atomic.LoadUint64(&stats.retried),
+		Recovered: atomic.LoadUint64(&stats.recovered),
+		Exhausted: atomic.LoadUint64(&stats.exhausted),
+	}
+}
@@ -0,0 +1,188 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"webcrawler/module"
+)
+
+func genRetryPolicy() RetryPolicy {
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = 10 * time.Millisecond
+	policy.MaxBackoff = 50 * time.Millisecond
+	policy.Jitter = 0
+	return policy
+}
+
+func TestRetryPolicy(t *testing.T) {
+	mid := module.MID("D1|127.0.0.1:8080")
+	illegalPolicies := []RetryPolicy{
+		{InitialBackoff: -1},
+		{Multiplier: 0.5},
+		{Jitter: 1.5},
+	}
+	for _, policy := range illegalPolicies {
+		if _, err := NewWithRetryPolicy(mid, &http.Client{}, nil, policy); err == nil {
+			t.Fatalf("No error when creating a downloader with illegal retry policy %#v", policy)
+		}
+	}
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}
+	for attempt, expected := range map[uint32]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		4: 800 * time.Millisecond,
+		5: time.Second,
+	} {
+		if actual := policy.backoff(attempt, nil); actual != expected {
+			t.Fatalf("Inconsistent backoff for attempt %d, expected: %s, actual: %s", attempt, expected, actual)
+		}
+	}
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if actual := policy.backoff(1, nil); actual < 50*time.Millisecond || actual > 150*time.Millisecond {
+			t.Fatalf("The backoff with jitter is out of range: %s", actual)
+		}
+	}
+	httpResp := &http.Response{Header: http.Header{}}
+	httpResp.Header.Set("Retry-After", "3")
+	if actual := policy.backoff(1, httpResp); actual != time.Second {
+		t.Fatalf("Inconsistent backoff with Retry-After, expected: %s, actual: %s", time.Second, actual)
+	}
+	now := time.Now()
+	if d, ok := parseRetryAfter(now.Add(30*time.Second).UTC().Format(http.TimeFormat), now); !ok || d < 28*time.Second {
+		t.Fatalf("Inconsistent Retry-After with HTTP date: %s", d)
+	}
+	if _, ok := parseRetryAfter("soon", now); ok {
+		t.Fatal("It can still parse an illegal Retry-After")
+	}
+}
+
+func TestDownloadRetry(t *testing.T) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flaky":
+			if atomic.AddInt32(&count, 1) < 3 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("ok"))
+		case "/down":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	mid := module.MID("D1|127.0.0.1:8080")
+	d, err := NewWithRetryPolicy(mid, server.Client(), nil, genRetryPolicy())
+	if err != nil {
+		t.Fatalf("An error occurs when creating a downloader: %s", err)
+	}
+	httpReq, _ := http.NewRequest("GET", server.URL+"/flaky", nil)
+	req := module.NewRequest(httpReq, 0)
+	resp, err := d.Download(req)
+	if err != nil {
+		t.Fatalf("An error occurs when downloading with retry: %s", err)
+	}
+	if resp.HTTPResp().StatusCode != http.StatusOK || req.Attempts() != 3 {
+		t.Fatalf("Inconsistent retried download, status: %d, attempts: %d", resp.HTTPResp().StatusCode, req.Attempts())
+	}
+	httpReq, _ = http.NewRequest("GET", server.URL+"/down", nil)
+	req = module.NewRequest(httpReq, 0)
+	resp, err = d.Download(req)
+	if err != nil || resp.HTTPResp().StatusCode != http.StatusBadGateway || req.Attempts() != 3 {
+		t.Fatalf("Inconsistent exhausted download, error: %v, attempts: %d", err, req.Attempts())
+	}
+	httpReq, _ = http.NewRequest("GET", server.URL+"/missing", nil)
+	req = module.NewRequest(httpReq, 0)
+	if _, err = d.Download(req); err != nil || req.Attempts() != 1 {
+		t.Fatalf("The download has been retried for a non-retryable status, attempts: %d", req.Attempts())
+	}
+	httpReq, _ = http.NewRequest("POST", server.URL+"/down", strings.NewReader("body"))
+	req = module.NewRequest(httpReq, 0)
+	if _, err = d.Download(req); err != nil || req.Attempts() != 3 {
+		t.Fatalf("Inconsistent attempts for a request with body, expected: %d, actual: %d", 3, req.Attempts())
+	}
+	summary, ok := d.Summary().Extra.(RetrySummaryStruct)
+	if !ok {
+		t.Fatalf("Inconsistent extra summary: %#v", d.Summary().Extra)
+	}
+	expected := RetrySummaryStruct{Retries: 6, Retried: 3, Recovered: 1, Exhausted: 2}
+	if summary != expected {
+		t.Fatalf("Inconsistent retry summary, expected: %#v, actual: %#v", expected, summary)
+	}
+}
+
+func TestDownloadRetryNetworkError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+	mid := module.MID("D1|127.0.0.1:8080")
+	d, _ := NewWithRetryPolicy(mid, &http.Client{}, nil, genRetryPolicy())
+	httpReq, _ := http.NewRequest("GET", url, nil)
+	req := module.NewRequest(httpReq, 0)
+	if _, err := d.Download(req); err == nil {
+		t.Fatal("No error when downloading from a closed server")
+	}
+	if req.Attempts() != 3 {
+		t.Fatalf("Inconsistent attempts for network error, expected: %d, actual: %d", 3, req.Attempts())
+	}
+	httpReq, _ = http.NewRequest("GET", "http:///a.com", nil)
+	req = module.NewRequest(httpReq, 0)
+	if _, err := d.Download(req); err == nil || req.Attempts() != 1 {
+		t.Fatalf("Inconsistent attempts for invalid URL, expected: %d, actual: %d", 1, req.Attempts())
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := genRetryPolicy()
+	policy.InitialBackoff = time.Hour
+	policy.MaxBackoff = time.Hour
+	d, _ = NewWithRetryPolicy(mid, &http.Client{}, nil, policy)
+	httpReq, _ = http.NewRequestWithContext(ctx, "GET", url, nil)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	begin := time.Now()
+	if _, err := d.Download(module.NewRequest(httpReq, 0)); err == nil {
+		t.Fatal("No error when downloading with canceled context")
+	}
+	if elapsed := time.Since(begin); elapsed > 5*time.Second {
+		t.Fatalf("The backoff has not been interrupted by the canceled context, elapsed: %s", elapsed)
+	}
+}
+
+func TestDownloadContext(t *testing.T) {
+	block := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(block)
+	mid := module.MID("D1|127.0.0.1:8080")
+	d, _ := NewWithRetryPolicy(mid, server.Client(), nil, genRetryPolicy())
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	httpReq, _ := http.NewRequest("GET", server.URL, nil)
+	req := module.NewRequest(httpReq, 0)
+	begin := time.Now()
+	_, err := d.(module.ContextDownloader).DownloadContext(ctx, req)
+	if err == nil {
+		t.Fatal("No error when downloading with an expired context")
+	}
+	if elapsed := time.Since(begin); elapsed > 5*time.Second {
+		t.Fatalf("The download has not been interrupted by the context, elapsed: %s", elapsed)
+	}
+	if req.Attempts() != 1 {
+		t.Fatalf("The interrupted download has been retried, attempts: %d", req.Attempts())
+	}
+}
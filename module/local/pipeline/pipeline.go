@@ -1,6 +1,7 @@
 package pipeline
 
 import (
+	"context"
 	"fmt"
 	werr "webcrawler/errors"
 	"webcrawler/helper/log"
@@ -11,6 +12,22 @@ import (
 var logger = log.DLogger()
 
 func New(mid module.MID, itemProcessors []module.ProcessItem, scoreCalculator module.CalculateScore) (module.Pipeline, error) {
+	if itemProcessors == nil {
+		return nil, genParameterError("nil item processor list")
+	}
+	ctxProcessors := make([]module.ContextProcessItem, len(itemProcessors))
+	for i, processor := range itemProcessors {
+		ctxProcessors[i] = module.ContextProcessor(processor)
+	}
+	return NewWithContextProcessors(mid, ctxProcessors, scoreCalculator)
+}
+
+// NewWithContextProcessors creates a pipeline whose processors receive the
+// context of the processing.
+func NewWithContextProcessors(
+	mid module.MID,
+	itemProcessors []module.ContextProcessItem,
+	scoreCalculator module.CalculateScore) (module.Pipeline, error) {
 	moduleBase, err := stub.NewModuleInternal(mid, scoreCalculator)
 	if err != nil {
 		return nil, err
@@ -21,7 +38,7 @@ func New(mid module.MID, itemProcessors []module.ProcessItem, scoreCalculator mo
 	if len(itemProcessors) == 0 {
 		return nil, genParameterError("empty item processor list")
 	}
-	var innerProcessors []module.ProcessItem
+	var innerProcessors []module.ContextProcessItem
 	for i, pipeline := range itemProcessors {
 		if pipeline == nil {
 			err := genParameterError(fmt.Sprintf("nil item processor[%d]", i))
@@ -37,17 +54,28 @@ func New(mid module.MID, itemProcessors []module.ProcessItem, scoreCalculator mo
 
 type myPipeline struct {
 	stub.ModuleInternal
-	itemProcessors []module.ProcessItem
+	itemProcessors []module.ContextProcessItem
 	failFast       bool
 }
 
 func (pipeline *myPipeline) ItemProcessors() []module.ProcessItem {
 	processors := make([]module.ProcessItem, len(pipeline.itemProcessors))
-	copy(processors, pipeline.itemProcessors)
+	for i, processor := range pipeline.itemProcessors {
+		processor := processor
+		processors[i] = func(item module.Item) (module.Item, error) {
+			return processor(context.Background(), item)
+		}
+	}
 	return processors
 }
 
 func (pipeline *myPipeline) Send(item module.Item) []error {
+	return pipeline.SendContext(context.Background(), item)
+}
+
+// SendContext stops calling the rest of the processors once the context is
+// done.
+func (pipeline *myPipeline) SendContext(ctx context.Context, item module.Item) []error {
 	pipeline.IncrHandlingNumber()
 	defer pipeline.DecrHandlingNumber()
 	pipeline.IncrCalledCount()
@@ -61,7 +89,11 @@ func (pipeline *myPipeline) Send(item module.Item) []error {
 	logger.Infof("Process item %+v...\n", item)
 	var currentItem = item
 	for _, processor := range pipeline.itemProcessors {
-		processedItem, err := processor(currentItem)
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, genError(fmt.Sprintf("processing interrupted: %s", err)))
+			break
+		}
+		processedItem, err := processor(ctx, currentItem)
 		if err != nil {
 			errs = append(errs, err)
 			if pipeline.failFast {
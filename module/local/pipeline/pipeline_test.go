@@ -1,6 +1,7 @@
 package pipeline
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"testing"
@@ -272,3 +273,40 @@ func genTestingItemProcessor(fail bool) module.ProcessItem {
 		return item, nil
 	}
 }
+
+func TestSendContext(t *testing.T) {
+	mid := module.MID("P1|127.0.0.1:8080")
+	ctx, cancel := context.WithCancel(context.Background())
+	called := 0
+	processors := []module.ContextProcessItem{
+		func(ctx context.Context, item module.Item) (module.Item, error) {
+			called++
+			cancel()
+			return item, nil
+		},
+		func(ctx context.Context, item module.Item) (module.Item, error) {
+			called++
+			return item, nil
+		},
+	}
+	p, err := NewWithContextProcessors(mid, processors, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a pipeline: %s", err)
+	}
+	if _, err = NewWithContextProcessors(mid, []module.ContextProcessItem{nil}, nil); err == nil {
+		t.Fatal("No error when create a pipeline with nil processor")
+	}
+	if len(p.ItemProcessors()) != len(processors) {
+		t.Fatalf("Inconsistent processor number, expected: %d, actual: %d", len(processors), len(p.ItemProcessors()))
+	}
+	errs := p.(module.ContextPipeline).SendContext(ctx, module.Item{"url": "https://github.com/gopcp"})
+	if called != 1 {
+		t.Fatalf("Inconsistent called processor number, expected: %d, actual: %d", 1, called)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("Inconsistent error number of interrupted processing, expected: %d, actual: %d", 1, len(errs))
+	}
+	if p.Counts().CompletedCount != 0 {
+		t.Fatal("The interrupted processing has been counted as completed")
+	}
+}
@@ -0,0 +1,98 @@
+package module
+
+import (
+	"context"
+	"net/http"
+)
+
+// Meta is the metadata of a request. It flows from the parser which found
+// the request to the downloader, the response and the items parsed from the
+// response.
+type Meta struct {
+	ParentURL    string                 `json:"parent_url,omitempty"`
+	Referrer     string                 `json:"referrer,omitempty"`
+	AnchorText   string                 `json:"anchor_text,omitempty"`
+	Priority     int                    `json:"priority,omitempty"`
+	RetryCount   uint32                 `json:"retry_count,omitempty"`
+	CookieJarKey string                 `json:"cookie_jar_key,omitempty"`
+	Proxy        string                 `json:"proxy,omitempty"`
+	UserData     map[string]interface{} `json:"user_data,omitempty"`
+	// MaxDepth is the depth budget of the seed which the request comes
+	// from, and zero means the max depth of the scheduler.
+	MaxDepth uint32 `json:"max_depth,omitempty"`
+}
+
+// ITEM_KEY_META is the reserved item key of the metadata of the response
+// which the item is parsed from.
+const ITEM_KEY_META = "_meta"
+
+// ITEM_KEY_CHANGE is the reserved item key of the change of the page which
+// the item is parsed from in an incremental crawl, whose value is one of the
+// CHANGE_* constants.
+const ITEM_KEY_CHANGE = "_change"
+
+// ITEM_KEY_URL is the reserved item key of the URL of the unchanged page in
+// the item sent to the pipelines in place of its analysis.
+const ITEM_KEY_URL = "_url"
+
+const (
+	CHANGE_NEW       = "new"
+	CHANGE_CHANGED   = "changed"
+	CHANGE_UNCHANGED = "unchanged"
+)
+
+// Child returns the metadata of a request found in the page with the URL.
+// The cookie jar key, the proxy, the max depth and the user data are
+// inherited.
+func (meta Meta) Child(parentURL string, anchorText string) Meta {
+	child := Meta{
+		ParentURL:    parentURL,
+		Referrer:     parentURL,
+		AnchorText:   anchorText,
+		CookieJarKey: meta.CookieJarKey,
+		Proxy:        meta.Proxy,
+		MaxDepth:     meta.MaxDepth,
+	}
+	if meta.UserData != nil {
+		child.UserData = make(map[string]interface{}, len(meta.UserData))
+		for k, v := range meta.UserData {
+			child.UserData[k] = v
+		}
+	}
+	return child
+}
+
+type metaKey struct{}
+
+func ContextWithMeta(ctx context.Context, meta Meta) context.Context {
+	return context.WithValue(ctx, metaKey{}, meta)
+}
+
+func MetaFromContext(ctx context.Context) (Meta, bool) {
+	if ctx == nil {
+		return Meta{}, false
+	}
+	meta, ok := ctx.Value(metaKey{}).(Meta)
+	return meta, ok
+}
+
+// MetaFromResponse returns the metadata of the request of the HTTP response,
+// which is available in the parsers.
+func MetaFromResponse(httpResp *http.Response) (Meta, bool) {
+	if httpResp == nil || httpResp.Request == nil {
+		return Meta{}, false
+	}
+	return MetaFromContext(httpResp.Request.Context())
+}
+
+func (itm Item) Meta() (Meta, bool) {
+	meta, ok := itm[ITEM_KEY_META].(Meta)
+	return meta, ok
+}
+
+// Change returns the change of the page which the item is parsed from in an
+// incremental crawl.
+func (itm Item) Change() (string, bool) {
+	change, ok := itm[ITEM_KEY_CHANGE].(string)
+	return change, ok
+}
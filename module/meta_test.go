@@ -0,0 +1,60 @@
+package module
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestMeta(t *testing.T) {
+	httpReq, _ := http.NewRequest("GET", "https://github.com/gopcp", nil)
+	meta := Meta{
+		AnchorText:   "gopcp",
+		Priority:     3,
+		CookieJarKey: "session",
+		Proxy:        "http://127.0.0.1:3128",
+		MaxDepth:     2,
+		UserData:     map[string]interface{}{"site": "github"},
+	}
+	req := NewRequestWithMeta(httpReq, 1, meta)
+	if req.Priority() != 3 || req.Meta().AnchorText != "gopcp" {
+		t.Fatalf("Inconsistent request metadata: %#v", req.Meta())
+	}
+	req.IncrAttempts()
+	req.IncrAttempts()
+	if retryCount := req.Meta().RetryCount; retryCount != 1 {
+		t.Fatalf("Inconsistent retry count, expected: %d, actual: %d", 1, retryCount)
+	}
+	child := meta.Child("https://github.com/gopcp", "repositories")
+	if child.ParentURL != "https://github.com/gopcp" || child.Referrer != child.ParentURL ||
+		child.AnchorText != "repositories" || child.Priority != 0 ||
+		child.CookieJarKey != "session" || child.Proxy != meta.Proxy || child.MaxDepth != 2 ||
+		child.UserData["site"] != "github" {
+		t.Fatalf("Inconsistent child metadata: %#v", child)
+	}
+	child.UserData["site"] = "gitlab"
+	if meta.UserData["site"] != "github" {
+		t.Fatal("The user data of the parent metadata has been modified by the child")
+	}
+	if _, ok := MetaFromContext(context.Background()); ok {
+		t.Fatal("It can still get metadata from an empty context")
+	}
+	httpResp := &http.Response{Request: httpReq.WithContext(ContextWithMeta(context.Background(), meta))}
+	if actual, ok := MetaFromResponse(httpResp); !ok || actual.AnchorText != "gopcp" {
+		t.Fatalf("Inconsistent metadata from response: %#v", actual)
+	}
+	if _, ok := MetaFromResponse(nil); ok {
+		t.Fatal("It can still get metadata from nil response")
+	}
+	resp := NewResponseWithMeta(httpResp, 1, meta)
+	if resp.Meta().CookieJarKey != "session" {
+		t.Fatalf("Inconsistent response metadata: %#v", resp.Meta())
+	}
+	item := Item{ITEM_KEY_META: meta}
+	if actual, ok := item.Meta(); !ok || actual.Proxy != meta.Proxy {
+		t.Fatalf("Inconsistent item metadata: %#v", actual)
+	}
+	if _, ok := (Item{}).Meta(); ok {
+		t.Fatal("It can still get metadata from an item without metadata")
+	}
+}
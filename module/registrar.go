@@ -2,6 +2,7 @@ package module
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 	"webcrawler/errors"
 )
@@ -10,6 +11,12 @@ type Registrar interface {
 	Register(module Module) (bool, error)
 	Unregister(mid MID) (bool, error)
 	Get(moduleType Type) (Module, error)
+	// Select selects a module of the type by the selector of the type. The
+	// key is the hint of the datum to handle, such as the host of a request.
+	Select(moduleType Type, key string) (Module, error)
+	// SetSelector sets the selector of the type, and nil selector restores
+	// the default one.
+	SetSelector(moduleType Type, selector Selector) error
 	GetAllByType(moduleType Type) (map[MID]Module, error)
 	GetAll() map[MID]Module
 	Clear()
@@ -18,11 +25,20 @@ type Registrar interface {
 func NewRegistrar() Registrar {
 	return &myRegistrar{
 		moduleTypeMap: map[Type]map[MID]Module{},
+		moduleListMap: map[Type][]Module{},
+		selectorMap:   map[Type]Selector{},
 	}
 }
 
+// defaultSelector is the selector of the type without any selector set.
+var defaultSelector = NewScoreSelector()
+
 type myRegistrar struct {
 	moduleTypeMap map[Type]map[MID]Module
+	// moduleListMap holds the modules of each type sorted by MID, which is
+	// replaced rather than modified on change.
+	moduleListMap map[Type][]Module
+	selectorMap   map[Type]Selector
 	rwlock        sync.RWMutex
 }
 
@@ -51,6 +67,7 @@ func (registrar *myRegistrar) Register(module Module) (bool, error) {
 	}
 	modules[mid] = module
 	registrar.moduleTypeMap[moduleType] = modules
+	registrar.moduleListMap[moduleType] = sortModules(modules)
 	return true, nil
 }
 
@@ -67,6 +84,7 @@ func (registrar *myRegistrar) Unregister(mid MID) (bool, error) {
 	if modules, ok := registrar.moduleTypeMap[moduleType]; ok {
 		if _, has := modules[mid]; has {
 			delete(modules, mid)
+			registrar.moduleListMap[moduleType] = sortModules(modules)
 			deleted = true
 		}
 	}
@@ -74,22 +92,40 @@ func (registrar *myRegistrar) Unregister(mid MID) (bool, error) {
 }
 
 func (registrar *myRegistrar) Get(moduleType Type) (Module, error) {
-	modules, err := registrar.GetAllByType(moduleType)
-	if err != nil {
-		return nil, err
+	return registrar.Select(moduleType, "")
+}
+
+func (registrar *myRegistrar) Select(moduleType Type, key string) (Module, error) {
+	if !LegalType(moduleType) {
+		errMsg := fmt.Sprintf("illegal module type: %s", moduleType)
+		return nil, errors.NewIllegalParameterError(errMsg)
 	}
-	minScore := uint64(0)
-	var selectedModule Module
-	for _, module := range modules {
-		SetScore(module)
-		score := module.Score()
-		if minScore == 0 || score < minScore {
-			selectedModule = module
-			minScore = score
-		}
+	registrar.rwlock.RLock()
+	modules := registrar.moduleListMap[moduleType]
+	selector := registrar.selectorMap[moduleType]
+	registrar.rwlock.RUnlock()
+	if len(modules) == 0 {
+		return nil, ErrNotFoundModuleInstance
+	}
+	if selector == nil {
+		selector = defaultSelector
 	}
-	return selectedModule, nil
+	return selector.Select(modules, key), nil
+}
 
+func (registrar *myRegistrar) SetSelector(moduleType Type, selector Selector) error {
+	if !LegalType(moduleType) {
+		errMsg := fmt.Sprintf("illegal module type: %s", moduleType)
+		return errors.NewIllegalParameterError(errMsg)
+	}
+	registrar.rwlock.Lock()
+	defer registrar.rwlock.Unlock()
+	if selector == nil {
+		delete(registrar.selectorMap, moduleType)
+	} else {
+		registrar.selectorMap[moduleType] = selector
+	}
+	return nil
 }
 
 func (registrar *myRegistrar) GetAllByType(moduleType Type) (map[MID]Module, error) {
@@ -97,8 +133,8 @@ func (registrar *myRegistrar) GetAllByType(moduleType Type) (map[MID]Module, err
 		errMsg := fmt.Sprintf("illegal module type: %s", moduleType)
 		return nil, errors.NewIllegalParameterError(errMsg)
 	}
-	registrar.rwlock.Lock()
-	defer registrar.rwlock.Unlock()
+	registrar.rwlock.RLock()
+	defer registrar.rwlock.RUnlock()
 	modules := registrar.moduleTypeMap[moduleType]
 	if len(modules) == 0 {
 		return nil, ErrNotFoundModuleInstance
@@ -126,4 +162,17 @@ func (registrar *myRegistrar) Clear() {
 	registrar.rwlock.Lock()
 	defer registrar.rwlock.Unlock()
 	registrar.moduleTypeMap = map[Type]map[MID]Module{}
+	registrar.moduleListMap = map[Type][]Module{}
+	registrar.selectorMap = map[Type]Selector{}
+}
+
+func sortModules(modules map[MID]Module) []Module {
+	list := make([]Module, 0, len(modules))
+	for _, module := range modules {
+		list = append(list, module)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID() < list[j].ID()
+	})
+	return list
 }
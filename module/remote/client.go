@@ -0,0 +1,352 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	werr "webcrawler/errors"
+	"webcrawler/module"
+	"webcrawler/module/stub"
+)
+
+// maxErrorSize is the max size of the error message read from a module
+// server.
+const maxErrorSize = 1024
+
+// client calls the module server at the address in the MID. The counts of
+// the module are counted by the client.
+type client struct {
+	stub.ModuleInternal
+	baseURL    string
+	httpClient *http.Client
+	errType    werr.ErrorType
+}
+
+func newClient(
+	mid module.MID,
+	network string,
+	httpClient *http.Client,
+	scoreCalculator module.CalculateScore,
+	errType werr.ErrorType) (*client, error) {
+	moduleBase, err := stub.NewModuleInternal(mid, scoreCalculator)
+	if err != nil {
+		return nil, err
+	}
+	if moduleBase.Addr() == "" {
+		errMsg := fmt.Sprintf("no address in MID %q", mid)
+		return nil, werr.NewCrawlerErrorBy(errType, werr.NewIllegalParameterError(errMsg))
+	}
+	if network != "http" && network != "https" {
+		errMsg := fmt.Sprintf("illegal network for remote module: %s", network)
+		return nil, werr.NewCrawlerErrorBy(errType, werr.NewIllegalParameterError(errMsg))
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &client{
+		ModuleInternal: moduleBase,
+		baseURL:        network + "://" + moduleBase.Addr(),
+		httpClient:     httpClient,
+		errType:        errType,
+	}, nil
+}
+
+// call sends the arguments to the module server and decodes the reply. The
+// arguments are not sent if nil.
+func (c *client) call(ctx context.Context, method string, path string, args interface{}, reply interface{}) error {
+	var body io.Reader
+	if args != nil {
+		data, err := json.Marshal(args)
+		if err != nil {
+			return c.genError(fmt.Sprintf("could not encode the arguments: %s", err))
+		}
+		body = bytes.NewReader(data)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return c.genError(err.Error())
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return c.genError(fmt.Sprintf("could not call the module server: %s", err))
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(httpResp.Body, maxErrorSize))
+		errMsg := fmt.Sprintf("module server error (status: %d): %s",
+			httpResp.StatusCode, strings.TrimSpace(string(msg)))
+		return c.genError(errMsg)
+	}
+	decoder := json.NewDecoder(io.LimitReader(httpResp.Body, maxMessageSize))
+	if err := decoder.Decode(reply); err != nil {
+		return c.genError(fmt.Sprintf("could not decode the reply: %s", err))
+	}
+	return nil
+}
+
+func (c *client) genParameterError(errMsg string) error {
+	return werr.NewCrawlerErrorBy(c.errType, werr.NewIllegalParameterError(errMsg))
+}
+
+func (c *client) genError(errMsg string) error {
+	return werr.NewCrawlerError(c.errType, errMsg)
+}
+
+// remoteErrors converts the error messages of the module server.
+func (c *client) remoteErrors(msgs []string) []error {
+	var errs []error
+	for _, msg := range msgs {
+		errs = append(errs, c.genError(fmt.Sprintf("remote: %s", msg)))
+	}
+	return errs
+}
+
+// NewDownloader creates the stub of the downloader served by NewServer at
+// the address in the MID, through the network "http" or "https". The
+// default HTTP client is used if client is nil.
+func NewDownloader(
+	mid module.MID,
+	network string,
+	client *http.Client,
+	scoreCalculator module.CalculateScore) (module.Downloader, error) {
+	c, err := newClient(mid, network, client, scoreCalculator, werr.ERROR_TYPE_DOWNLOADER)
+	if err != nil {
+		return nil, err
+	}
+	return &myDownloader{client: c}, nil
+}
+
+type myDownloader struct {
+	*client
+}
+
+func (downloader *myDownloader) Download(req *module.Request) (*module.Response, error) {
+	ctx := context.Background()
+	if req != nil && req.HTTPReq() != nil {
+		ctx = req.HTTPReq().Context()
+	}
+	return downloader.DownloadContext(ctx, req)
+}
+
+// DownloadContext cancels the call of the module server once the context is
+// done, which stops the download of the remote downloader.
+func (downloader *myDownloader) DownloadContext(ctx context.Context, req *module.Request) (*module.Response, error) {
+	downloader.IncrHandlingNumber()
+	defer downloader.DecrHandlingNumber()
+	downloader.IncrCalledCount()
+	if req == nil {
+		return nil, downloader.genParameterError("nil request")
+	}
+	if req.HTTPReq() == nil {
+		return nil, downloader.genParameterError("nil HTTP request")
+	}
+	args, err := encodeRequest(req)
+	if err != nil {
+		return nil, downloader.genParameterError(err.Error())
+	}
+	downloader.IncrAcceptedCount()
+	var reply downloadReply
+	if err := downloader.call(ctx, http.MethodPost, pathDownload, args, &reply); err != nil {
+		return nil, err
+	}
+	for req.Attempts() < reply.Attempts {
+		req.IncrAttempts()
+	}
+	if reply.Error != "" {
+		return nil, downloader.genError(fmt.Sprintf("remote: %s", reply.Error))
+	}
+	if reply.Response == nil {
+		return nil, downloader.genError("no response from the module server")
+	}
+	resp, err := decodeResponse(ctx, reply.Response)
+	if err != nil {
+		return nil, downloader.genError(err.Error())
+	}
+	downloader.IncrCompletedCount()
+	return resp, nil
+}
+
+// NewAnalyzer creates the stub of the analyzer served by NewServer at the
+// address in the MID, through the network "http" or "https". The default
+// HTTP client is used if client is nil.
+func NewAnalyzer(
+	mid module.MID,
+	network string,
+	client *http.Client,
+	scoreCalculator module.CalculateScore) (module.Analyzer, error) {
+	c, err := newClient(mid, network, client, scoreCalculator, werr.ERROR_TYPE_ANALYZER)
+	if err != nil {
+		return nil, err
+	}
+	return &myAnalyzer{client: c}, nil
+}
+
+type myAnalyzer struct {
+	*client
+}
+
+// RespParsers returns nil since the parsers are in the module server.
+func (analyzer *myAnalyzer) RespParsers() []module.ParseResponse {
+	return nil
+}
+
+func (analyzer *myAnalyzer) Analyze(resp *module.Response) ([]module.Data, []error) {
+	return analyzer.AnalyzeContext(context.Background(), resp)
+}
+
+// AnalyzeContext reads and closes the body of the response, and cancels the
+// call of the module server once the context is done.
+func (analyzer *myAnalyzer) AnalyzeContext(ctx context.Context, resp *module.Response) (dataList []module.Data, errorList []error) {
+	analyzer.IncrHandlingNumber()
+	defer analyzer.DecrHandlingNumber()
+	analyzer.IncrCalledCount()
+	if resp == nil {
+		return nil, []error{analyzer.genParameterError("nil response")}
+	}
+	httpResp := resp.HTTPResp()
+	if httpResp == nil {
+		return nil, []error{analyzer.genParameterError("nil HTTP response")}
+	}
+	if httpResp.Body != nil {
+		defer httpResp.Body.Close()
+	}
+	args, err := encodeResponse(resp)
+	if err != nil {
+		return nil, []error{analyzer.genParameterError(err.Error())}
+	}
+	analyzer.IncrAcceptedCount()
+	var reply analyzeReply
+	if err := analyzer.call(ctx, http.MethodPost, pathAnalyze, args, &reply); err != nil {
+		return nil, []error{err}
+	}
+	errorList = analyzer.remoteErrors(reply.Errors)
+	dataList = []module.Data{}
+	for _, wd := range reply.Data {
+		data, err := decodeData(wd)
+		if err != nil {
+			errorList = append(errorList, analyzer.genError(err.Error()))
+			continue
+		}
+		dataList = append(dataList, data)
+	}
+	if len(errorList) == 0 {
+		analyzer.IncrCompletedCount()
+	}
+	return dataList, errorList
+}
+
+// NewPipeline creates the stub of the pipeline served by NewServer at the
+// address in the MID, through the network "http" or "https". The default
+// HTTP client is used if client is nil.
+func NewPipeline(
+	mid module.MID,
+	network string,
+	client *http.Client,
+	scoreCalculator module.CalculateScore) (module.Pipeline, error) {
+	c, err := newClient(mid, network, client, scoreCalculator, werr.ERROR_TYPE_PIPELINE)
+	if err != nil {
+		return nil, err
+	}
+	return &myPipeline{client: c}, nil
+}
+
+type myPipeline struct {
+	*client
+	// failFast is the last known fail-fast flag of the remote pipeline.
+	failFast uint32
+}
+
+// ItemProcessors returns nil since the processors are in the module server.
+func (pipeline *myPipeline) ItemProcessors() []module.ProcessItem {
+	return nil
+}
+
+func (pipeline *myPipeline) Send(item module.Item) []error {
+	return pipeline.SendContext(context.Background(), item)
+}
+
+// SendContext cancels the call of the module server once the context is
+// done. The values of the item are carried in JSON, and only the metadata is
+// restored to module.Meta in the module server.
+func (pipeline *myPipeline) SendContext(ctx context.Context, item module.Item) []error {
+	pipeline.IncrHandlingNumber()
+	defer pipeline.DecrHandlingNumber()
+	pipeline.IncrCalledCount()
+	if item == nil {
+		return []error{pipeline.genParameterError("nil item")}
+	}
+	pipeline.IncrAcceptedCount()
+	var reply sendReply
+	if err := pipeline.call(ctx, http.MethodPost, pathSend, item, &reply); err != nil {
+		return []error{err}
+	}
+	errs := pipeline.remoteErrors(reply.Errors)
+	if len(errs) == 0 {
+		pipeline.IncrCompletedCount()
+	}
+	return errs
+}
+
+// FailFast returns the fail-fast flag of the remote pipeline, or the last
+// known one if the module server could not be called.
+func (pipeline *myPipeline) FailFast() bool {
+	var reply failFastArgs
+	if err := pipeline.call(context.Background(), http.MethodGet, pathFailFast, nil, &reply); err != nil {
+		logger.Warnf("Could not get the fail-fast flag of the remote pipeline: %s (MID: %s)", err, pipeline.ID())
+		return atomic.LoadUint32(&pipeline.failFast) == 1
+	}
+	pipeline.storeFailFast(reply.FailFast)
+	return reply.FailFast
+}
+
+// SetFailFast sets the fail-fast flag of the remote pipeline, which is
+// shared by all the stubs of it.
+func (pipeline *myPipeline) SetFailFast(failFast bool) {
+	args := failFastArgs{FailFast: failFast}
+	var reply failFastArgs
+	if err := pipeline.call(context.Background(), http.MethodPut, pathFailFast, args, &reply); err != nil {
+		logger.Warnf("Could not set the fail-fast flag of the remote pipeline: %s (MID: %s)", err, pipeline.ID())
+		return
+	}
+	pipeline.storeFailFast(reply.FailFast)
+}
+
+func (pipeline *myPipeline) storeFailFast(failFast bool) {
+	var value uint32
+	if failFast {
+		value = 1
+	}
+	atomic.StoreUint32(&pipeline.failFast, value)
+}
+
+// RemoteSummary returns the summary of the module in the module server,
+// whose counts include the calls from all the stubs of it.
+func RemoteSummary(ctx context.Context, m module.Module) (module.SummaryStruct, error) {
+	var c *client
+	switch m := m.(type) {
+	case *myDownloader:
+		c = m.client
+	case *myAnalyzer:
+		c = m.client
+	case *myPipeline:
+		c = m.client
+	default:
+		errMsg := fmt.Sprintf("not a remote module: %T", m)
+		return module.SummaryStruct{}, genParameterError(errMsg)
+	}
+	var summary module.SummaryStruct
+	err := c.call(ctx, http.MethodGet, pathSummary, nil, &summary)
+	return summary, err
+}
+
+func genParameterError(errMsg string) error {
+	return werr.NewIllegalParameterError(errMsg)
+}
@@ -0,0 +1,286 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"webcrawler/module"
+)
+
+// maxBodySize is the max size of the body of a request or a response
+// carried to or from a module server.
+const maxBodySize = 32 << 20
+
+// maxMessageSize is the max size of a message of the protocol, which holds a
+// body in base64.
+const maxMessageSize = 2 * maxBodySize
+
+const (
+	pathDownload = "/v1/download"
+	pathAnalyze  = "/v1/analyze"
+	pathSend     = "/v1/send"
+	pathFailFast = "/v1/failfast"
+	pathSummary  = "/v1/summary"
+)
+
+type wireRequest struct {
+	Method   string      `json:"method"`
+	URL      string      `json:"url"`
+	Header   http.Header `json:"header,omitempty"`
+	Body     []byte      `json:"body,omitempty"`
+	Depth    uint32      `json:"depth"`
+	Meta     module.Meta `json:"meta"`
+	Attempts uint32      `json:"attempts,omitempty"`
+}
+
+type wireResponse struct {
+	StatusCode int         `json:"status_code"`
+	Proto      string      `json:"proto,omitempty"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       []byte      `json:"body,omitempty"`
+	// Request is the last request sent, whose URL differs from the one of
+	// the original request after redirects.
+	Request wireRequest `json:"request"`
+	Depth   uint32      `json:"depth"`
+	Meta    module.Meta `json:"meta"`
+}
+
+// wireData is a request or an item parsed by an analyzer.
+type wireData struct {
+	Request *wireRequest `json:"request,omitempty"`
+	Item    module.Item  `json:"item,omitempty"`
+}
+
+type downloadReply struct {
+	Response *wireResponse `json:"response,omitempty"`
+	Attempts uint32        `json:"attempts,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+type analyzeReply struct {
+	Data   []wireData `json:"data,omitempty"`
+	Errors []string   `json:"errors,omitempty"`
+}
+
+type sendReply struct {
+	Errors []string `json:"errors,omitempty"`
+}
+
+type failFastArgs struct {
+	FailFast bool `json:"fail_fast"`
+}
+
+// readBody reads the whole body, which is replaced by a reader of the bytes
+// read so that the caller could read it again.
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(*body, maxBodySize+1))
+	(*body).Close()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxBodySize {
+		return nil, fmt.Errorf("body exceeds %d bytes", maxBodySize)
+	}
+	*body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+// encodeHTTPRequest encodes the HTTP request with the body if withBody is
+// true. The body of a request sent has been consumed by the transport.
+func encodeHTTPRequest(httpReq *http.Request, withBody bool) (wireRequest, error) {
+	if httpReq == nil || httpReq.URL == nil {
+		return wireRequest{}, fmt.Errorf("nil HTTP request")
+	}
+	w := wireRequest{
+		Method: httpReq.Method,
+		URL:    httpReq.URL.String(),
+		Header: httpReq.Header,
+	}
+	if !withBody {
+		return w, nil
+	}
+	body := httpReq.Body
+	var err error
+	if httpReq.GetBody != nil {
+		if body, err = httpReq.GetBody(); err != nil {
+			return wireRequest{}, err
+		}
+	}
+	if w.Body, err = readBody(&body); err != nil {
+		return wireRequest{}, err
+	}
+	if httpReq.GetBody == nil {
+		httpReq.Body = body
+	}
+	return w, nil
+}
+
+func encodeRequest(req *module.Request) (wireRequest, error) {
+	if req == nil {
+		return wireRequest{}, fmt.Errorf("nil request")
+	}
+	w, err := encodeHTTPRequest(req.HTTPReq(), true)
+	if err != nil {
+		return wireRequest{}, err
+	}
+	w.Depth = req.Depth()
+	w.Meta = req.Meta()
+	w.Attempts = req.Attempts()
+	return w, nil
+}
+
+// decodeRequest restores the request whose HTTP request carries the context
+// with the metadata.
+func decodeRequest(ctx context.Context, w wireRequest) (*module.Request, error) {
+	var body io.Reader
+	if w.Body != nil {
+		body = bytes.NewReader(w.Body)
+	}
+	httpReq, err := http.NewRequestWithContext(module.ContextWithMeta(ctx, w.Meta), w.Method, w.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	if w.Header != nil {
+		httpReq.Header = w.Header
+	}
+	req := module.NewRequestWithMeta(httpReq, w.Depth, w.Meta)
+	for req.Attempts() < w.Attempts {
+		req.IncrAttempts()
+	}
+	return req, nil
+}
+
+// encodeResponse reads the body of the response, which is replaced by a
+// reader of the bytes read.
+func encodeResponse(resp *module.Response) (*wireResponse, error) {
+	if resp == nil {
+		return nil, fmt.Errorf("nil response")
+	}
+	httpResp := resp.HTTPResp()
+	if httpResp == nil {
+		return nil, fmt.Errorf("nil HTTP response")
+	}
+	body, err := readBody(&httpResp.Body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := encodeHTTPRequest(httpResp.Request, false)
+	if err != nil {
+		return nil, err
+	}
+	// The metadata of the response is the one in the context of its request.
+	req.Depth = resp.Depth()
+	req.Meta = resp.Meta()
+	return &wireResponse{
+		StatusCode: httpResp.StatusCode,
+		Proto:      httpResp.Proto,
+		Header:     httpResp.Header,
+		Body:       body,
+		Request:    req,
+		Depth:      resp.Depth(),
+		Meta:       resp.Meta(),
+	}, nil
+}
+
+func decodeResponse(ctx context.Context, w *wireResponse) (*module.Response, error) {
+	req, err := decodeRequest(ctx, w.Request)
+	if err != nil {
+		return nil, err
+	}
+	header := w.Header
+	if header == nil {
+		header = http.Header{}
+	}
+	httpResp := &http.Response{
+		Status:        fmt.Sprintf("%d %s", w.StatusCode, http.StatusText(w.StatusCode)),
+		StatusCode:    w.StatusCode,
+		Proto:         w.Proto,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(w.Body)),
+		ContentLength: int64(len(w.Body)),
+		Request:       req.HTTPReq(),
+	}
+	return module.NewResponseWithMeta(httpResp, w.Depth, w.Meta), nil
+}
+
+func encodeData(data module.Data) (wireData, error) {
+	switch d := data.(type) {
+	case *module.Request:
+		req, err := encodeRequest(d)
+		if err != nil {
+			return wireData{}, err
+		}
+		return wireData{Request: &req}, nil
+	case module.Item:
+		return wireData{Item: d}, nil
+	default:
+		return wireData{}, fmt.Errorf("unsupported data type %T", data)
+	}
+}
+
+func decodeData(w wireData) (module.Data, error) {
+	if w.Request != nil {
+		return decodeRequest(context.Background(), *w.Request)
+	}
+	return decodeItem(w.Item)
+}
+
+// decodeItem restores the metadata of the item, and the other values are
+// left as they are decoded from JSON.
+func decodeItem(item module.Item) (module.Item, error) {
+	if item == nil {
+		return nil, fmt.Errorf("nil item")
+	}
+	value, ok := item[module.ITEM_KEY_META]
+	if !ok {
+		return item, nil
+	}
+	if _, ok := value.(module.Meta); ok {
+		return item, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var meta module.Meta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("illegal item metadata: %s", err)
+	}
+	item[module.ITEM_KEY_META] = meta
+	return item, nil
+}
+
+func errorStrings(errs []error) []string {
+	var result []string
+	for _, err := range errs {
+		if err != nil {
+			result = append(result, err.Error())
+		}
+	}
+	return result
+}
+
+// MarshalRequest encodes the request with its depth, metadata and attempts,
+// which is used to carry the request to another process.
+func MarshalRequest(req *module.Request) ([]byte, error) {
+	w, err := encodeRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(w)
+}
+
+// UnmarshalRequest decodes the request encoded by MarshalRequest.
+func UnmarshalRequest(data []byte) (*module.Request, error) {
+	var w wireRequest
+	if err := json.Unmarshal(data, &w); err != nil {
+		return nil, err
+	}
+	return decodeRequest(context.Background(), w)
+}
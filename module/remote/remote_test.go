@@ -0,0 +1,360 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"webcrawler/module"
+	"webcrawler/module/local/analyzer"
+	"webcrawler/module/local/downloader"
+	"webcrawler/module/local/pipeline"
+	"webcrawler/module/stub"
+)
+
+var (
+	_ module.ContextDownloader = (*myDownloader)(nil)
+	_ module.ContextAnalyzer   = (*myAnalyzer)(nil)
+	_ module.ContextPipeline   = (*myPipeline)(nil)
+)
+
+// startServer serves the module, and returns the MID of its stub.
+func startServer(m module.Module, letter string, t *testing.T) (module.MID, *httptest.Server) {
+	handler, err := NewServer(m, ServerArgs{})
+	if err != nil {
+		t.Fatalf("An error occurs when creating a module server: %s", err)
+	}
+	server := httptest.NewServer(handler)
+	mid := module.MID(fmt.Sprintf("%s1|%s", letter, strings.TrimPrefix(server.URL, "http://")))
+	return mid, server
+}
+
+func genContentServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/final", http.StatusFound)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Referer", r.Header.Get("Referer"))
+		fmt.Fprintf(w, "%s %s %s", r.Method, r.URL.Path, body)
+	}))
+}
+
+func TestNewServer(t *testing.T) {
+	if _, err := NewServer(nil, ServerArgs{}); err == nil {
+		t.Fatal("No error when creating a module server with nil module")
+	}
+	m, _ := stub.NewModuleInternal("D1", nil)
+	if _, err := NewServer(m, ServerArgs{}); err == nil {
+		t.Fatalf("No error when creating a module server with unsupported module %T", m)
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	if _, err := NewDownloader("D1", "http", nil, nil); err == nil {
+		t.Fatal("No error when creating a remote downloader without address")
+	}
+	if _, err := NewAnalyzer("A1|127.0.0.1:8080", "tcp", nil, nil); err == nil {
+		t.Fatal("No error when creating a remote analyzer with illegal network")
+	}
+	if _, err := NewPipeline("P1|127.0.0.1", "http", nil, nil); err == nil {
+		t.Fatal("No error when creating a remote pipeline with illegal MID")
+	}
+	p, err := NewPipeline("P1|127.0.0.1:8080", "https", nil, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a remote pipeline: %s", err)
+	}
+	if p.Addr() != "127.0.0.1:8080" || p.ItemProcessors() != nil {
+		t.Fatalf("Inconsistent remote pipeline: %s, %v", p.Addr(), p.ItemProcessors())
+	}
+	if _, err := RemoteSummary(context.Background(), p); err == nil {
+		t.Fatal("No error when getting the summary from an unreachable module server")
+	}
+	m, _ := stub.NewModuleInternal("P1", nil)
+	if _, err := RemoteSummary(context.Background(), m); err == nil {
+		t.Fatal("No error when getting the remote summary of a local module")
+	}
+}
+
+func TestRemoteDownloader(t *testing.T) {
+	contentServer := genContentServer()
+	defer contentServer.Close()
+	local, err := downloader.New("D1", &http.Client{}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a downloader: %s", err)
+	}
+	mid, server := startServer(local, "D", t)
+	defer server.Close()
+	d, err := NewDownloader(mid, "http", nil, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a remote downloader: %s", err)
+	}
+	httpReq, _ := http.NewRequest("POST", contentServer.URL+"/echo", strings.NewReader("hello"))
+	meta := module.Meta{Referrer: "http://example.com/", UserData: map[string]interface{}{"k": "v"}}
+	resp, err := d.Download(module.NewRequestWithMeta(httpReq, 2, meta))
+	if err != nil {
+		t.Fatalf("An error occurs when downloading remotely: %s", err)
+	}
+	httpResp := resp.HTTPResp()
+	body, _ := io.ReadAll(httpResp.Body)
+	if httpResp.StatusCode != http.StatusOK || string(body) != "POST /echo hello" {
+		t.Fatalf("Inconsistent response, status: %d, body: %q", httpResp.StatusCode, body)
+	}
+	if referer := httpResp.Header.Get("X-Referer"); referer != meta.Referrer {
+		t.Fatalf("Inconsistent referer, expected: %s, actual: %s", meta.Referrer, referer)
+	}
+	if resp.Depth() != 2 || resp.Meta().UserData["k"] != "v" {
+		t.Fatalf("Inconsistent response depth or metadata: %d, %#v", resp.Depth(), resp.Meta())
+	}
+	if respMeta, ok := module.MetaFromResponse(httpResp); !ok || respMeta.Referrer != meta.Referrer {
+		t.Fatalf("Inconsistent metadata of the HTTP response: %#v", respMeta)
+	}
+	httpReq, _ = http.NewRequest("GET", contentServer.URL+"/redirect", nil)
+	req := module.NewRequest(httpReq, 0)
+	resp, err = d.Download(req)
+	if err != nil {
+		t.Fatalf("An error occurs when downloading remotely: %s", err)
+	}
+	if path := resp.HTTPResp().Request.URL.Path; path != "/final" {
+		t.Fatalf("Inconsistent URL after redirect, expected: /final, actual: %s", path)
+	}
+	if req.Attempts() != 1 {
+		t.Fatalf("Inconsistent attempts, expected: 1, actual: %d", req.Attempts())
+	}
+	if d.CalledCount() != 2 || d.CompletedCount() != 2 || d.HandlingNumber() != 0 {
+		t.Fatalf("Inconsistent counts of the remote downloader: %#v", d.Counts())
+	}
+	summary, err := RemoteSummary(context.Background(), d)
+	if err != nil {
+		t.Fatalf("An error occurs when getting the remote summary: %s", err)
+	}
+	if summary.ID != "D1" || summary.Completed != 2 {
+		t.Fatalf("Inconsistent remote summary: %#v", summary)
+	}
+	httpReq, _ = http.NewRequest("GET", "http://127.0.0.1:1/", nil)
+	if _, err := d.Download(module.NewRequest(httpReq, 0)); err == nil || !strings.Contains(err.Error(), "remote") {
+		t.Fatalf("Inconsistent error of the failed remote download: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	httpReq, _ = http.NewRequest("GET", contentServer.URL, nil)
+	if _, err := d.(module.ContextDownloader).DownloadContext(ctx, module.NewRequest(httpReq, 0)); err == nil {
+		t.Fatal("No error when downloading remotely with canceled context")
+	}
+	if _, err := d.Download(nil); err == nil {
+		t.Fatal("No error when downloading nil request")
+	}
+}
+
+func TestServerAccess(t *testing.T) {
+	contentServer := genContentServer()
+	defer contentServer.Close()
+	local, _ := downloader.New("D1", &http.Client{}, nil)
+	if _, err := NewServer(local, ServerArgs{AllowedHosts: []string{" "}}); err == nil {
+		t.Fatal("No error when creating a module server with empty allowed host")
+	}
+	handler, _ := NewServer(local, ServerArgs{})
+	r := httptest.NewRequest("GET", pathSummary, nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Inconsistent status of the call from a non-loopback client, expected: %d, actual: %d",
+			http.StatusForbidden, w.Code)
+	}
+	handler, _ = NewServer(local, ServerArgs{
+		Authorize: func(r *http.Request) error {
+			if r.Header.Get("Authorization") != "Bearer token" {
+				return fmt.Errorf("no token")
+			}
+			return nil
+		},
+		AllowedHosts: []string{"example.com"},
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	mid := module.MID("D1|" + strings.TrimPrefix(server.URL, "http://"))
+	d, _ := NewDownloader(mid, "http", nil, nil)
+	httpReq, _ := http.NewRequest("GET", contentServer.URL, nil)
+	if _, err := d.Download(module.NewRequest(httpReq, 0)); err == nil || !strings.Contains(err.Error(), "unauthorized") {
+		t.Fatalf("Inconsistent error of the unauthorized download: %v", err)
+	}
+	client := &http.Client{Transport: tokenTransport{}}
+	d, _ = NewDownloader(mid, "http", client, nil)
+	if _, err := d.Download(module.NewRequest(httpReq, 0)); err == nil || !strings.Contains(err.Error(), "disallowed host") {
+		t.Fatalf("Inconsistent error of the download from a disallowed host: %v", err)
+	}
+	summary, err := RemoteSummary(context.Background(), d)
+	if err != nil || summary.ID != "D1" {
+		t.Fatalf("Inconsistent remote summary of the authorized client: %#v, %v", summary, err)
+	}
+}
+
+type tokenTransport struct{}
+
+func (tokenTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer token")
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestRemoteAnalyzer(t *testing.T) {
+	parser := func(httpResp *http.Response, respDepth uint32) ([]module.Data, []error) {
+		body, _ := io.ReadAll(httpResp.Body)
+		if string(body) == "bad" {
+			return nil, []error{fmt.Errorf("bad body")}
+		}
+		meta, _ := module.MetaFromResponse(httpResp)
+		link, _ := http.NewRequest("GET", httpResp.Request.URL.String()+"/next", nil)
+		return []module.Data{
+			module.NewRequest(link, respDepth),
+			module.Item{"body": string(body), "number": 1, "cookie_jar_key": meta.CookieJarKey},
+		}, nil
+	}
+	local, err := analyzer.New("A1", []module.ParseResponse{parser}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating an analyzer: %s", err)
+	}
+	mid, server := startServer(local, "A", t)
+	defer server.Close()
+	a, err := NewAnalyzer(mid, "http", nil, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a remote analyzer: %s", err)
+	}
+	genResp := func(body string) *module.Response {
+		httpReq, _ := http.NewRequest("GET", "http://example.com/page", nil)
+		httpResp := &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    httpReq,
+		}
+		return module.NewResponseWithMeta(httpResp, 1, module.Meta{CookieJarKey: "jar"})
+	}
+	dataList, errs := a.Analyze(genResp("page"))
+	if len(errs) != 0 || len(dataList) != 2 {
+		t.Fatalf("Inconsistent analysis result, data: %v, errors: %v", dataList, errs)
+	}
+	req, ok := dataList[0].(*module.Request)
+	if !ok || req.HTTPReq().URL.String() != "http://example.com/page/next" || req.Depth() != 2 {
+		t.Fatalf("Inconsistent request: %#v", dataList[0])
+	}
+	if req.Meta().ParentURL != "http://example.com/page" {
+		t.Fatalf("Inconsistent parent URL: %s", req.Meta().ParentURL)
+	}
+	item, ok := dataList[1].(module.Item)
+	if !ok || item["body"] != "page" || item["number"] != float64(1) || item["cookie_jar_key"] != "jar" {
+		t.Fatalf("Inconsistent item: %#v", dataList[1])
+	}
+	if itemMeta, ok := item.Meta(); !ok || itemMeta.CookieJarKey != "jar" {
+		t.Fatalf("Inconsistent metadata of the item: %#v", item[module.ITEM_KEY_META])
+	}
+	_, errs = a.Analyze(genResp("bad"))
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "bad body") {
+		t.Fatalf("Inconsistent errors of the remote analysis: %v", errs)
+	}
+	if a.CalledCount() != 2 || a.CompletedCount() != 1 {
+		t.Fatalf("Inconsistent counts of the remote analyzer: %#v", a.Counts())
+	}
+	if _, errs := a.Analyze(nil); len(errs) == 0 {
+		t.Fatal("No error when analyzing nil response")
+	}
+	another, _ := NewAnalyzer(module.MID("A2|"+string(mid)[3:]), "http", nil, nil)
+	d, _ := NewDownloader(module.MID("D2|"+string(mid)[3:]), "http", nil, nil)
+	httpReq, _ := http.NewRequest("GET", "http://example.com/", nil)
+	if _, err := d.Download(module.NewRequest(httpReq, 0)); err == nil {
+		t.Fatal("No error when downloading from an analyzer server")
+	}
+	if _, errs := another.Analyze(genResp("page")); len(errs) != 0 {
+		t.Fatalf("An error occurs when analyzing by another stub: %v", errs)
+	}
+}
+
+func TestRemotePipeline(t *testing.T) {
+	processor := func(item module.Item) (module.Item, error) {
+		if _, ok := item["bad"]; ok {
+			return nil, fmt.Errorf("bad item")
+		}
+		if _, ok := item.Meta(); !ok {
+			return nil, fmt.Errorf("no metadata")
+		}
+		return item, nil
+	}
+	local, err := pipeline.New("P1", []module.ProcessItem{processor}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a pipeline: %s", err)
+	}
+	mid, server := startServer(local, "P", t)
+	defer server.Close()
+	p, err := NewPipeline(mid, "http", nil, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a remote pipeline: %s", err)
+	}
+	item := module.Item{"title": "t", module.ITEM_KEY_META: module.Meta{ParentURL: "http://example.com/"}}
+	if errs := p.Send(item); len(errs) != 0 {
+		t.Fatalf("An error occurs when sending the item remotely: %v", errs)
+	}
+	if errs := p.Send(module.Item{"bad": true}); len(errs) == 0 {
+		t.Fatal("No error when sending a bad item remotely")
+	}
+	if errs := p.Send(nil); len(errs) == 0 {
+		t.Fatal("No error when sending nil item remotely")
+	}
+	if local.CalledCount() != 2 || p.CalledCount() != 3 || p.CompletedCount() != 1 {
+		t.Fatalf("Inconsistent called counts, local: %d, remote: %#v", local.CalledCount(), p.Counts())
+	}
+	if p.FailFast() {
+		t.Fatal("Inconsistent fail-fast flag, expected: false, actual: true")
+	}
+	another, _ := NewPipeline(module.MID("P2|"+string(mid)[3:]), "http", nil, nil)
+	another.SetFailFast(true)
+	if !local.FailFast() || !p.FailFast() {
+		t.Fatal("The fail-fast flag of the remote pipeline has not been set")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if errs := p.(module.ContextPipeline).SendContext(ctx, item); len(errs) == 0 {
+		t.Fatal("No error when sending the item remotely with canceled context")
+	}
+	server.Close()
+	if !p.FailFast() {
+		t.Fatal("The last known fail-fast flag has not been returned")
+	}
+}
+
+func TestMarshalRequest(t *testing.T) {
+	httpReq, _ := http.NewRequest("POST", "http://example.com/form", strings.NewReader("a=1"))
+	httpReq.Header.Set("X-Test", "test")
+	req := module.NewRequestWithMeta(httpReq, 3, module.Meta{AnchorText: "form", Priority: 2})
+	req.IncrAttempts()
+	data, err := MarshalRequest(req)
+	if err != nil {
+		t.Fatalf("An error occurs when marshaling the request: %s", err)
+	}
+	another, err := UnmarshalRequest(data)
+	if err != nil {
+		t.Fatalf("An error occurs when unmarshaling the request: %s", err)
+	}
+	anotherHTTPReq := another.HTTPReq()
+	body, _ := io.ReadAll(anotherHTTPReq.Body)
+	if anotherHTTPReq.Method != "POST" || anotherHTTPReq.URL.String() != "http://example.com/form" ||
+		anotherHTTPReq.Header.Get("X-Test") != "test" || string(body) != "a=1" {
+		t.Fatalf("Inconsistent HTTP request: %s %s %v %q",
+			anotherHTTPReq.Method, anotherHTTPReq.URL, anotherHTTPReq.Header, body)
+	}
+	if another.Depth() != 3 || another.Priority() != 2 || another.Meta().AnchorText != "form" || another.Attempts() != 1 {
+		t.Fatalf("Inconsistent request: %d, %#v, %d", another.Depth(), another.Meta(), another.Attempts())
+	}
+	if body, _ := io.ReadAll(httpReq.Body); string(body) != "a=1" {
+		t.Fatalf("The body of the marshaled request has been consumed: %q", body)
+	}
+	if _, err := MarshalRequest(nil); err == nil {
+		t.Fatal("No error when marshaling nil request")
+	}
+	if _, err := UnmarshalRequest([]byte("{")); err == nil {
+		t.Fatal("No error when unmarshaling illegal data")
+	}
+}
@@ -0,0 +1,231 @@
+package remote
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+	"webcrawler/helper/log"
+	"webcrawler/module"
+)
+
+var logger = log.DLogger()
+
+// ServerArgs is the access control of a module server.
+type ServerArgs struct {
+	// Authorize authorizes the call of a client, e.g. by a token in the
+	// header, and the call is rejected if it returns an error. Nil means
+	// accepting the calls from the loopback addresses only.
+	Authorize func(r *http.Request) error
+	// AllowedHosts are the hosts which a downloader server downloads from,
+	// including their subdomains. Empty means any host.
+	AllowedHosts []string
+}
+
+// NewServer returns the HTTP handler which exposes the module to the client
+// stubs of this package. The module could be a downloader, an analyzer or a
+// pipeline.
+func NewServer(m module.Module, args ServerArgs) (http.Handler, error) {
+	if m == nil {
+		return nil, genParameterError("nil module")
+	}
+	s := &server{module: m, mux: http.NewServeMux(), authorize: args.Authorize}
+	if s.authorize == nil {
+		s.authorize = AuthorizeLoopback
+	}
+	for _, host := range args.AllowedHosts {
+		host = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(host), "."))
+		if host == "" {
+			return nil, genParameterError("empty allowed host")
+		}
+		s.allowedHosts = append(s.allowedHosts, host)
+	}
+	switch m := m.(type) {
+	case module.Downloader:
+		s.mux.HandleFunc("POST "+pathDownload, s.handleDownload(m))
+	case module.Analyzer:
+		s.mux.HandleFunc("POST "+pathAnalyze, s.handleAnalyze(m))
+	case module.Pipeline:
+		s.mux.HandleFunc("POST "+pathSend, s.handleSend(m))
+		s.mux.HandleFunc("GET "+pathFailFast, s.handleGetFailFast(m))
+		s.mux.HandleFunc("PUT "+pathFailFast, s.handleSetFailFast(m))
+	default:
+		errMsg := fmt.Sprintf("unsupported module type %T", m)
+		return nil, genParameterError(errMsg)
+	}
+	s.mux.HandleFunc("GET "+pathSummary, s.handleSummary)
+	return s, nil
+}
+
+// ListenAndServe serves the handler of a module server at the address. It
+// listens on the loopback address if the host is empty, e.g. ":8080", and
+// "0.0.0.0:8080" listens on all the interfaces.
+func ListenAndServe(addr string, handler http.Handler) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return genParameterError(fmt.Sprintf("illegal address of the module server: %s", err))
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	server := &http.Server{
+		Addr:              net.JoinHostPort(host, port),
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return server.ListenAndServe()
+}
+
+type server struct {
+	module       module.Module
+	mux          *http.ServeMux
+	authorize    func(r *http.Request) error
+	allowedHosts []string
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := s.authorize(r); err != nil {
+		http.Error(w, fmt.Sprintf("unauthorized call: %s", err), http.StatusForbidden)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// AuthorizeLoopback accepts the calls from the loopback addresses only. It's
+// the default authorization of the servers.
+func AuthorizeLoopback(r *http.Request) error {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("non-loopback client %s", r.RemoteAddr)
+	}
+	return nil
+}
+
+// allowed reports whether the host is one of the allowed hosts or their
+// subdomains.
+func (s *server) allowed(host string) bool {
+	if len(s.allowedHosts) == 0 {
+		return true
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, allowed := range s.allowedHosts {
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *server) handleDownload(d module.Downloader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var args wireRequest
+		if !readArgs(w, r, &args) {
+			return
+		}
+		req, err := decodeRequest(r.Context(), args)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("illegal request: %s", err), http.StatusBadRequest)
+			return
+		}
+		if host := req.HTTPReq().URL.Hostname(); !s.allowed(host) {
+			http.Error(w, fmt.Sprintf("disallowed host %q", host), http.StatusForbidden)
+			return
+		}
+		var reply downloadReply
+		resp, err := module.DownloadContext(r.Context(), d, req)
+		reply.Attempts = req.Attempts()
+		if err == nil && resp != nil {
+			reply.Response, err = encodeResponse(resp)
+			if httpResp := resp.HTTPResp(); httpResp != nil && httpResp.Body != nil {
+				httpResp.Body.Close()
+			}
+		}
+		if err != nil {
+			reply.Error = err.Error()
+		}
+		writeReply(w, reply)
+	}
+}
+
+func (s *server) handleAnalyze(a module.Analyzer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var args wireResponse
+		if !readArgs(w, r, &args) {
+			return
+		}
+		resp, err := decodeResponse(r.Context(), &args)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("illegal response: %s", err), http.StatusBadRequest)
+			return
+		}
+		dataList, errs := module.AnalyzeContext(r.Context(), a, resp)
+		reply := analyzeReply{Errors: errorStrings(errs)}
+		for _, data := range dataList {
+			wd, err := encodeData(data)
+			if err != nil {
+				reply.Errors = append(reply.Errors, err.Error())
+				continue
+			}
+			reply.Data = append(reply.Data, wd)
+		}
+		writeReply(w, reply)
+	}
+}
+
+func (s *server) handleSend(p module.Pipeline) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var args module.Item
+		if !readArgs(w, r, &args) {
+			return
+		}
+		item, err := decodeItem(args)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("illegal item: %s", err), http.StatusBadRequest)
+			return
+		}
+		errs := module.SendContext(r.Context(), p, item)
+		writeReply(w, sendReply{Errors: errorStrings(errs)})
+	}
+}
+
+func (s *server) handleGetFailFast(p module.Pipeline) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeReply(w, failFastArgs{FailFast: p.FailFast()})
+	}
+}
+
+func (s *server) handleSetFailFast(p module.Pipeline) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var args failFastArgs
+		if !readArgs(w, r, &args) {
+			return
+		}
+		p.SetFailFast(args.FailFast)
+		writeReply(w, args)
+	}
+}
+
+func (s *server) handleSummary(w http.ResponseWriter, r *http.Request) {
+	writeReply(w, s.module.Summary())
+}
+
+func readArgs(w http.ResponseWriter, r *http.Request, args interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxMessageSize))
+	if err := decoder.Decode(args); err != nil {
+		http.Error(w, fmt.Sprintf("illegal arguments: %s", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeReply(w http.ResponseWriter, reply interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(reply); err != nil {
+		logger.Warnf("An error occurs when writing the reply of the module server: %s", err)
+	}
+}
@@ -0,0 +1,214 @@
+package module
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+)
+
+// Selector selects one of the candidate modules of the same type, which are
+// sorted by MID and never empty. The key is the hint of the datum to handle,
+// such as the host of a request, and may be empty.
+type Selector interface {
+	Name() string
+	Select(modules []Module, key string) Module
+}
+
+// NewScoreSelector returns the selector which selects the module with the
+// minimum score calculated by its score calculator. It's the default selector
+// of the registrar.
+func NewScoreSelector() Selector {
+	return scoreSelector{}
+}
+
+type scoreSelector struct{}
+
+func (scoreSelector) Name() string {
+	return "score"
+}
+
+func (scoreSelector) Select(modules []Module, key string) Module {
+	var selected Module
+	var minScore uint64
+	for _, module := range modules {
+		SetScore(module)
+		score := module.Score()
+		if selected == nil || score < minScore {
+			selected = module
+			minScore = score
+		}
+	}
+	return selected
+}
+
+// NewRoundRobinSelector returns the selector which selects the modules in
+// turn.
+func NewRoundRobinSelector() Selector {
+	return &roundRobinSelector{}
+}
+
+type roundRobinSelector struct {
+	next uint64
+}
+
+func (selector *roundRobinSelector) Name() string {
+	return "round-robin"
+}
+
+func (selector *roundRobinSelector) Select(modules []Module, key string) Module {
+	n := atomic.AddUint64(&selector.next, 1) - 1
+	return modules[n%uint64(len(modules))]
+}
+
+// NewWeightedSelector returns the selector which selects the modules in turn
+// in proportion to their weights, interleaving them smoothly. The module not
+// in weights or with zero weight has weight 1.
+func NewWeightedSelector(weights map[MID]uint32) Selector {
+	copied := make(map[MID]int64, len(weights))
+	for mid, weight := range weights {
+		copied[mid] = int64(weight)
+	}
+	return &weightedSelector{
+		weights: copied,
+		current: map[MID]int64{},
+	}
+}
+
+type weightedSelector struct {
+	weights map[MID]int64
+	// current is the current weight of each module for the smooth weighted
+	// round-robin.
+	current map[MID]int64
+	lock    sync.Mutex
+}
+
+func (selector *weightedSelector) Name() string {
+	return "weighted"
+}
+
+func (selector *weightedSelector) Select(modules []Module, key string) Module {
+	selector.lock.Lock()
+	defer selector.lock.Unlock()
+	var selected Module
+	var total, maxCurrent int64
+	for _, module := range modules {
+		mid := module.ID()
+		weight := selector.weights[mid]
+		if weight == 0 {
+			weight = 1
+		}
+		total += weight
+		current := selector.current[mid] + weight
+		selector.current[mid] = current
+		if selected == nil || current > maxCurrent {
+			selected = module
+			maxCurrent = current
+		}
+	}
+	selector.current[selected.ID()] -= total
+	return selected
+}
+
+// NewLeastInFlightSelector returns the selector which selects the module
+// with the minimum handling number.
+func NewLeastInFlightSelector() Selector {
+	return leastInFlightSelector{}
+}
+
+type leastInFlightSelector struct{}
+
+func (leastInFlightSelector) Name() string {
+	return "least-in-flight"
+}
+
+func (leastInFlightSelector) Select(modules []Module, key string) Module {
+	selected := modules[0]
+	minNumber := selected.HandlingNumber()
+	for _, module := range modules[1:] {
+		if number := module.HandlingNumber(); number < minNumber {
+			selected = module
+			minNumber = number
+		}
+	}
+	return selected
+}
+
+// NewPowerOfTwoSelector returns the selector which selects the module with
+// the lower handling number from two random modules.
+func NewPowerOfTwoSelector() Selector {
+	return powerOfTwoSelector{}
+}
+
+type powerOfTwoSelector struct{}
+
+func (powerOfTwoSelector) Name() string {
+	return "power-of-two"
+}
+
+func (powerOfTwoSelector) Select(modules []Module, key string) Module {
+	n := len(modules)
+	if n == 1 {
+		return modules[0]
+	}
+	i := rand.Intn(n)
+	j := rand.Intn(n - 1)
+	if j >= i {
+		j++
+	}
+	if modules[j].HandlingNumber() < modules[i].HandlingNumber() {
+		return modules[j]
+	}
+	return modules[i]
+}
+
+// NewHostAffinitySelector returns the selector which always selects the same
+// module for the same key, so that the requests for the same host are
+// downloaded by the same downloader to reuse its connections. Only the keys
+// of a removed module move to the others, by the rendezvous hashing. The
+// fallback selects the module for empty key, and the least in-flight selector
+// is used if it's nil.
+func NewHostAffinitySelector(fallback Selector) Selector {
+	if fallback == nil {
+		fallback = NewLeastInFlightSelector()
+	}
+	return hostAffinitySelector{fallback: fallback}
+}
+
+type hostAffinitySelector struct {
+	fallback Selector
+}
+
+func (selector hostAffinitySelector) Name() string {
+	return "host-affinity"
+}
+
+func (selector hostAffinitySelector) Select(modules []Module, key string) Module {
+	if key == "" {
+		return selector.fallback.Select(modules, key)
+	}
+	var selected Module
+	var maxWeight uint64
+	for _, module := range modules {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+		h.Write([]byte(module.ID()))
+		if weight := mix64(h.Sum64()); selected == nil || weight > maxWeight {
+			selected = module
+			maxWeight = weight
+		}
+	}
+	return selected
+}
+
+// mix64 is the finalizer of SplitMix64, which spreads the FNV hash of the
+// similar inputs.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
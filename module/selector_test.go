@@ -0,0 +1,210 @@
+package module
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func genSelectorModules(handlingNumbers ...uint64) []Module {
+	modules := make([]Module, len(handlingNumbers))
+	for i, number := range handlingNumbers {
+		// The handling number of the fake module is its count plus 2.
+		modules[i] = &fakeModule{
+			mid:             MID(fmt.Sprintf("D%d", i)),
+			count:           number - 2,
+			scoreCalculator: CalculateScoreSimple,
+		}
+	}
+	return modules
+}
+
+func countSelected(selector Selector, modules []Module, key string, times int) map[MID]int {
+	counts := map[MID]int{}
+	for i := 0; i < times; i++ {
+		counts[selector.Select(modules, key).ID()]++
+	}
+	return counts
+}
+
+func TestSelectorScore(t *testing.T) {
+	modules := genSelectorModules(5, 2, 9)
+	selected := NewScoreSelector().Select(modules, "")
+	if selected.ID() != "D1" {
+		t.Fatalf("Inconsistent selected module, expected: D1, actual: %s", selected.ID())
+	}
+	if modules[0].Score() == 0 {
+		t.Fatal("The scores of the modules have not been set")
+	}
+}
+
+func TestSelectorRoundRobin(t *testing.T) {
+	modules := genSelectorModules(2, 2, 2)
+	selector := NewRoundRobinSelector()
+	for i := 0; i < 6; i++ {
+		expected := modules[i%3].ID()
+		if selected := selector.Select(modules, ""); selected.ID() != expected {
+			t.Fatalf("Inconsistent selected module at %d, expected: %s, actual: %s",
+				i, expected, selected.ID())
+		}
+	}
+}
+
+func TestSelectorWeighted(t *testing.T) {
+	modules := genSelectorModules(2, 2, 2)
+	selector := NewWeightedSelector(map[MID]uint32{"D0": 5, "D1": 0})
+	var sequence string
+	for i := 0; i < 7; i++ {
+		sequence += string(selector.Select(modules, "").ID())
+	}
+	// The smooth weighted round-robin interleaves the modules.
+	expected := "D0D0D1D0D2D0D0"
+	if sequence != expected {
+		t.Fatalf("Inconsistent selected sequence, expected: %s, actual: %s", expected, sequence)
+	}
+	counts := countSelected(selector, modules, "", 700)
+	if counts["D0"] != 500 || counts["D1"] != 100 || counts["D2"] != 100 {
+		t.Fatalf("Inconsistent selected counts: %v", counts)
+	}
+}
+
+func TestSelectorLeastInFlight(t *testing.T) {
+	modules := genSelectorModules(5, 3, 3, 8)
+	counts := countSelected(NewLeastInFlightSelector(), modules, "", 10)
+	if counts["D1"] != 10 {
+		t.Fatalf("Inconsistent selected counts: %v", counts)
+	}
+}
+
+func TestSelectorPowerOfTwo(t *testing.T) {
+	selector := NewPowerOfTwoSelector()
+	modules := genSelectorModules(2)
+	if selected := selector.Select(modules, ""); selected != modules[0] {
+		t.Fatalf("Inconsistent selected module: %s", selected.ID())
+	}
+	modules = genSelectorModules(9, 2, 9, 9)
+	counts := countSelected(selector, modules, "", 1200)
+	// The least loaded module is in half of the pairs.
+	if counts["D1"] < 450 || counts["D1"] > 750 {
+		t.Fatalf("Inconsistent selected counts: %v", counts)
+	}
+	modules = genSelectorModules(2, 3, 4, 5)
+	counts = countSelected(selector, modules, "", 1200)
+	if counts["D3"] != 0 || counts["D0"] <= counts["D1"] || counts["D1"] <= counts["D2"] {
+		t.Fatalf("Inconsistent selected counts: %v", counts)
+	}
+}
+
+func TestSelectorHostAffinity(t *testing.T) {
+	modules := genSelectorModules(2, 2, 2, 2)
+	selector := NewHostAffinitySelector(nil)
+	hostModules := map[string]MID{}
+	counts := map[MID]int{}
+	for i := 0; i < 400; i++ {
+		host := fmt.Sprintf("host%d.example.com", i)
+		mid := selector.Select(modules, host).ID()
+		for j := 0; j < 3; j++ {
+			if another := selector.Select(modules, host).ID(); another != mid {
+				t.Fatalf("Inconsistent selected module for %s, expected: %s, actual: %s", host, mid, another)
+			}
+		}
+		hostModules[host] = mid
+		counts[mid]++
+	}
+	for _, m := range modules {
+		if counts[m.ID()] < 50 {
+			t.Fatalf("Unbalanced selected counts: %v", counts)
+		}
+	}
+	// Only the hosts of the removed module move to the others.
+	remaining := append([]Module{}, modules[:2]...)
+	remaining = append(remaining, modules[3])
+	for host, mid := range hostModules {
+		another := selector.Select(remaining, host).ID()
+		if mid != "D2" && another != mid {
+			t.Fatalf("The host %s has moved from %s to %s", host, mid, another)
+		}
+	}
+	modules = genSelectorModules(5, 3, 4)
+	if selected := selector.Select(modules, ""); selected.ID() != "D1" {
+		t.Fatalf("Inconsistent selected module for empty key, expected: D1, actual: %s", selected.ID())
+	}
+}
+
+func TestSelectorInParallel(t *testing.T) {
+	modules := genSelectorModules(2, 2, 2)
+	selectors := []Selector{
+		NewScoreSelector(),
+		NewRoundRobinSelector(),
+		NewWeightedSelector(map[MID]uint32{"D0": 2}),
+		NewLeastInFlightSelector(),
+		NewPowerOfTwoSelector(),
+		NewHostAffinitySelector(nil),
+	}
+	for _, selector := range selectors {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					if selector.Select(modules, fmt.Sprintf("host%d", i)) == nil {
+						t.Errorf("No module has been selected by %s", selector.Name())
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+	}
+}
+
+func TestRegSelector(t *testing.T) {
+	registrar := NewRegistrar()
+	if err := registrar.SetSelector(illegalTypes[0], NewRoundRobinSelector()); err == nil {
+		t.Fatalf("No error when setting selector with illegal type %q", illegalTypes[0])
+	}
+	if _, err := registrar.Select(illegalTypes[0], ""); err == nil {
+		t.Fatalf("No error when selecting module instance with illegal type %q", illegalTypes[0])
+	}
+	if _, err := registrar.Select(TYPE_DOWNLOADER, ""); err == nil {
+		t.Fatal("No error when selecting nonexist module instance")
+	}
+	var mids []MID
+	for i := 0; i < 3; i++ {
+		mid := MID(fmt.Sprintf("D%d", i))
+		mids = append(mids, mid)
+		if _, err := registrar.Register(NewFakeDownloader(mid, CalculateScoreSimple)); err != nil {
+			t.Fatalf("An error occurs when registering module instance: %s (MID: %s)", err, mid)
+		}
+	}
+	if err := registrar.SetSelector(TYPE_DOWNLOADER, NewRoundRobinSelector()); err != nil {
+		t.Fatalf("An error occurs when setting selector: %s", err)
+	}
+	for i := 0; i < 6; i++ {
+		m, err := registrar.Select(TYPE_DOWNLOADER, "")
+		if err != nil {
+			t.Fatalf("An error occurs when selecting module instance: %s", err)
+		}
+		if m.ID() != mids[i%3] {
+			t.Fatalf("Inconsistent selected module at %d, expected: %s, actual: %s", i, mids[i%3], m.ID())
+		}
+	}
+	if _, err := registrar.Unregister(mids[1]); err != nil {
+		t.Fatalf("An error occurs when unregistering module instance: %s", err)
+	}
+	for i := 0; i < 4; i++ {
+		m, _ := registrar.Get(TYPE_DOWNLOADER)
+		if m.ID() == mids[1] {
+			t.Fatalf("The unregistered module %s has been selected", mids[1])
+		}
+	}
+	if err := registrar.SetSelector(TYPE_DOWNLOADER, nil); err != nil {
+		t.Fatalf("An error occurs when restoring default selector: %s", err)
+	}
+	for i := 0; i < 4; i++ {
+		// The fake modules have the same score, and the first one is selected.
+		if m, _ := registrar.Get(TYPE_DOWNLOADER); m.ID() != mids[0] {
+			t.Fatalf("Inconsistent selected module, expected: %s, actual: %s", mids[0], m.ID())
+		}
+	}
+}
@@ -1,20 +1,159 @@
 package scheduler
 
-import "webcrawler/module"
+import (
+	"fmt"
+	"time"
+	"webcrawler/module"
+	"webcrawler/toolkit/recrawl"
+	"webcrawler/toolkit/seenset"
+	"webcrawler/toolkit/urlnorm"
+)
 
 type Args interface {
 	Check() error
 }
 
 type RequestArgs struct {
-	AcceptedDomains []string `json:"accepted_primary_domains"`
-	MaxDepth        uint32   `json:"max_depth"`
+	AcceptedDomains []string        `json:"accepted_primary_domains"`
+	MaxDepth        uint32          `json:"max_depth"`
+	Politeness      PolitenessArgs  `json:"politeness"`
+	Robots          RobotsArgs      `json:"robots"`
+	Sitemaps        SitemapArgs     `json:"sitemaps"`
+	ScopeRules      []ScopeRule     `json:"scope_rules,omitempty"`
+	URLNorm         urlnorm.Options `json:"url_normalization"`
+	SeenSet         SeenSetArgs     `json:"seen_set"`
+	Recrawl         RecrawlArgs     `json:"recrawl"`
+	Timeouts        TimeoutArgs     `json:"timeouts"`
+	CrawlStrategy   string          `json:"crawl_strategy,omitempty"`
+	Scorer          Scorer          `json:"-"`
+	// Router partitions the requests among the schedulers of a distributed
+	// crawl, and nil means the scheduler crawls alone.
+	Router Router `json:"-"`
+}
+
+const (
+	SEEN_SET_TYPE_EXACT = "exact"
+	SEEN_SET_TYPE_BLOOM = "bloom"
+	SEEN_SET_TYPE_DISK  = "disk"
+)
+
+// SeenSetArgs selects the set recording the requested URLs. An empty type
+// means the exact one.
+type SeenSetArgs struct {
+	Type              string  `json:"type"`
+	Capacity          uint64  `json:"capacity,omitempty"`
+	FalsePositiveRate float64 `json:"false_positive_rate,omitempty"`
+	Dir               string  `json:"dir,omitempty"`
+	// Shared is the seen set shared with the other schedulers, which is used
+	// in place of the type and never cleared or closed by the scheduler.
+	Shared seenset.SeenSet `json:"-"`
+}
+
+// RecrawlArgs enables the incremental crawl, in which the fetched URLs are
+// remembered in the store and revisited by the policy. Only the new and
+// changed pages are analyzed.
+type RecrawlArgs struct {
+	// Store is kept across the crawls by the caller, and nil disables the
+	// incremental crawl.
+	Store recrawl.Store `json:"-"`
+	// Policy is recrawl.DefaultPolicy() if zero.
+	Policy recrawl.Policy `json:"policy"`
+}
+
+func (args RecrawlArgs) policy() recrawl.Policy {
+	if args.Policy == (recrawl.Policy{}) {
+		return recrawl.DefaultPolicy()
+	}
+	return args.Policy
+}
+
+type RobotsArgs struct {
+	Enabled   bool          `json:"enabled"`
+	UserAgent string        `json:"user_agent"`
+	Expiry    time.Duration `json:"expiry"`
+}
+
+// SitemapArgs enables discovering the sitemaps of every crawled host from
+// robots.txt and /sitemap.xml, and enqueuing their URLs at the depth of the
+// request which led to the host.
+type SitemapArgs struct {
+	Enabled bool `json:"enabled"`
+	// MaxSitemaps limits the sitemaps fetched per host, including the ones in
+	// the sitemap indexes. Zero means 100.
+	MaxSitemaps uint32 `json:"max_sitemaps"`
+	// MaxURLs limits the URLs taken from the sitemaps of a host. Zero means
+	// no limit.
+	MaxURLs uint32 `json:"max_urls"`
+	// MaxHosts limits the hosts whose sitemaps are fetched at the same time.
+	// Zero means 2.
+	MaxHosts uint32 `json:"max_hosts"`
+}
+
+// TimeoutArgs limits the time spent on each request in each stage. The
+// download timeout also covers reading the response body. Zero means no limit.
+type TimeoutArgs struct {
+	Download time.Duration `json:"download"`
+	Analyze  time.Duration `json:"analyze"`
+	Pipeline time.Duration `json:"pipeline"`
+}
+
+type PolitenessArgs struct {
+	MinDelay        time.Duration `json:"min_delay"`
+	MaxConnsPerHost uint32        `json:"max_conns_per_host"`
+	// MaxQueued is the max number of the requests in the host queues, and
+	// zero means 10000. A host without queued requests gets one beyond it.
+	MaxQueued uint32 `json:"max_queued"`
+	// MaxQueuedPerHost is the max number of the requests in the queue of a
+	// host, and zero means 1000. The requests beyond the bounds are parked
+	// by host until the queue of their host has room.
+	MaxQueuedPerHost uint32 `json:"max_queued_per_host"`
+	// MaxCrawlDelay caps the Crawl-delay in robots.txt, and zero means one
+	// minute.
+	MaxCrawlDelay time.Duration `json:"max_crawl_delay"`
 }
 
 func (args *RequestArgs) Check() error {
 	if args.AcceptedDomains == nil {
 		return genError("nil accepted primary domain list")
 	}
+	if args.Politeness.MinDelay < 0 {
+		return genError("negative min delay for politeness")
+	}
+	if args.Politeness.MaxCrawlDelay < 0 {
+		return genError("negative max crawl delay for politeness")
+	}
+	if args.Robots.Expiry < 0 {
+		return genError("negative expiry for robots.txt")
+	}
+	if args.Timeouts.Download < 0 || args.Timeouts.Analyze < 0 || args.Timeouts.Pipeline < 0 {
+		return genError("negative timeout")
+	}
+	if _, err := newCrawlStrategy(args.CrawlStrategy, args.Scorer); err != nil {
+		return err
+	}
+	switch args.SeenSet.Type {
+	case "", SEEN_SET_TYPE_EXACT:
+	case SEEN_SET_TYPE_BLOOM:
+		if args.SeenSet.FalsePositiveRate < 0 || args.SeenSet.FalsePositiveRate >= 1 {
+			return genError("illegal false positive rate for seen set")
+		}
+	case SEEN_SET_TYPE_DISK:
+		if args.SeenSet.Dir == "" {
+			return genError("empty directory for disk seen set")
+		}
+	default:
+		return genError(fmt.Sprintf("unsupported seen set type %q", args.SeenSet.Type))
+	}
+	if args.Recrawl.Store != nil {
+		if err := args.Recrawl.policy().Check(); err != nil {
+			return genErrorByError(err)
+		}
+	}
+	for _, rule := range args.ScopeRules {
+		if _, err := compileScopeRule(rule); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -25,6 +164,38 @@ func (args *RequestArgs) Same(another *RequestArgs) bool {
 	if another.MaxDepth != args.MaxDepth {
 		return false
 	}
+	if another.Politeness != args.Politeness {
+		return false
+	}
+	if another.Robots != args.Robots {
+		return false
+	}
+	if another.Sitemaps != args.Sitemaps {
+		return false
+	}
+	if another.Timeouts != args.Timeouts {
+		return false
+	}
+	if another.CrawlStrategy != args.CrawlStrategy {
+		return false
+	}
+	if another.SeenSet != args.SeenSet {
+		return false
+	}
+	if another.Recrawl != args.Recrawl {
+		return false
+	}
+	if !another.URLNorm.Same(args.URLNorm) {
+		return false
+	}
+	if len(another.ScopeRules) != len(args.ScopeRules) {
+		return false
+	}
+	for i, rule := range another.ScopeRules {
+		if rule != args.ScopeRules[i] {
+			return false
+		}
+	}
 	anotherDomains := another.AcceptedDomains
 	if len(anotherDomains) != len(args.AcceptedDomains) {
 		return false
@@ -38,14 +209,27 @@ func (args *RequestArgs) Same(another *RequestArgs) bool {
 }
 
 type DataArgs struct {
-	ReqBufferCap         uint32 `json:"req_buffer_cap"`
-	ReqMaxBufferNumber   uint32 `json:"req_max_buffer_number"`
-	RespBufferCap        uint32 `json:"resp_buffer_cap"`
-	RespMaxBufferNumber  uint32 `json:"resp_max_buffer_number"`
-	ItemBufferCap        uint32 `json:"item_buffer_cap"`
-	ItemMaxBufferNumber  uint32 `json:"item_max_buffer_number"`
-	ErrorBufferCap       uint32 `json:"error_buffer_cap"`
-	ErrorMaxBufferNumber uint32 `json:"error_max_buffer_number"`
+	ReqBufferCap         uint32          `json:"req_buffer_cap"`
+	ReqMaxBufferNumber   uint32          `json:"req_max_buffer_number"`
+	RespBufferCap        uint32          `json:"resp_buffer_cap"`
+	RespMaxBufferNumber  uint32          `json:"resp_max_buffer_number"`
+	ItemBufferCap        uint32          `json:"item_buffer_cap"`
+	ItemMaxBufferNumber  uint32          `json:"item_max_buffer_number"`
+	ErrorBufferCap       uint32          `json:"error_buffer_cap"`
+	ErrorMaxBufferNumber uint32          `json:"error_max_buffer_number"`
+	Concurrency          ConcurrencyArgs `json:"concurrency"`
+	FrontierDir          string          `json:"frontier_dir,omitempty"`
+	Frontier             Frontier        `json:"-"`
+}
+
+// ConcurrencyArgs is the number of the workers of each stage. Zero means the
+// number of the registered modules of the stage when the scheduler starts.
+// The numbers are fixed once started, and the modules added by AddModule are
+// called by the existing workers.
+type ConcurrencyArgs struct {
+	Download uint32 `json:"download"`
+	Analyze  uint32 `json:"analyze"`
+	Pick     uint32 `json:"pick"`
 }
 
 func (args *DataArgs) Check() error {
@@ -86,6 +270,9 @@ type ModuleArgs struct {
 	Downloaders []module.Downloader
 	Analyzers   []module.Analyzer
 	Pipelines   []module.Pipeline
+	// Selectors are the selectors of the module types, and the type without
+	// selector selects the module with the minimum score.
+	Selectors map[module.Type]module.Selector
 }
 
 func (args *ModuleArgs) Check() error {
@@ -98,6 +285,12 @@ func (args *ModuleArgs) Check() error {
 	if len(args.Pipelines) == 0 {
 		return genError("empty pipelines list")
 	}
+	for moduleType := range args.Selectors {
+		if !module.LegalType(moduleType) {
+			errMsg := fmt.Sprintf("illegal module type for selector: %s", moduleType)
+			return genError(errMsg)
+		}
+	}
 	return nil
 }
 
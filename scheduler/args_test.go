@@ -144,6 +144,10 @@ func TestArgsModule(t *testing.T) {
 		genSimpleModuleArgs(3, 2, 0, t),
 		{},
 	}
+	moduleArgs.Selectors = map[module.Type]module.Selector{
+		module.Type("illegal"): module.NewRoundRobinSelector(),
+	}
+	moduleArgsList = append(moduleArgsList, moduleArgs)
 	for _, moduleArgs := range moduleArgsList {
 		if err := moduleArgs.Check(); err == nil {
 			t.Fatalf("No error when check module arguments (moduleArgs: %#v)", moduleArgs)
@@ -24,9 +24,27 @@ func TestGetPrimaryDomain(t *testing.T) {
 	if err == nil {
 		t.Fatalf("It can still get primary domain for an empty host")
 	}
-	host = "123.abc"
+	hosts := map[string]string{
+		"news.bbc.co.uk":       "bbc.co.uk",
+		"www.sina.com.cn":      "sina.com.cn",
+		"golang.github.io":     "golang.github.io",
+		"[::1]:8080":           "::1",
+		"www.example.com:8080": "example.com",
+		"shop.x.co.ke":         "x.co.ke",
+		"www.example.unknown":  "example.unknown",
+	}
+	for host, expectedPd := range hosts {
+		pd, err = getPrimaryDomain(host)
+		if err != nil {
+			t.Fatalf("An error occurs when getting the primary domain: %s, (host: %s)", err, host)
+		}
+		if pd != expectedPd {
+			t.Fatalf("Inconsistent primary domain, expected: %s, actual: %s", expectedPd, pd)
+		}
+	}
+	host = "co.ke"
 	_, err = getPrimaryDomain(host)
 	if err == nil {
-		t.Fatalf("It can still get primary domain for a unrecognized host %q", host)
+		t.Fatalf("It can still get primary domain for a public suffix %q", host)
 	}
 }
@@ -0,0 +1,99 @@
+package scheduler
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// drainInterval is the interval for checking whether the scheduler has been
+// drained.
+const drainInterval = 10 * time.Millisecond
+
+// DrainResult is the result of stopping the scheduler gracefully.
+type DrainResult struct {
+	// Drained reports whether all responses and items have been handled
+	// before the timeout.
+	Drained          bool          `json:"drained"`
+	FlushedItems     uint64        `json:"flushed_items"`
+	DroppedItems     uint64        `json:"dropped_items"`
+	DroppedResponses uint64        `json:"dropped_responses"`
+	PendingRequests  uint64        `json:"pending_requests"`
+	Elapsed          time.Duration `json:"elapsed"`
+}
+
+// StopGracefully stops accepting requests, waits for the downloads in
+// progress and drains the responses and items through the analyzers and the
+// pipelines before stopping. The requests not downloaded are left pending in
+// the frontier for restoring. Zero timeout means waiting until drained.
+func (sched *myScheduler) StopGracefully(timeout time.Duration) (result DrainResult, err error) {
+	logger.Info("Stop scheduler gracefully...")
+	if timeout < 0 {
+		err = genParameterError("negative timeout for graceful stop")
+		return
+	}
+	logger.Info("Check status for stop")
+	var oldStatus Status
+	oldStatus, err = sched.checkAndSetStatus(SCHED_STATUS_STOPPING)
+	defer func() {
+		sched.statusLock.Lock()
+		if err != nil {
+			sched.status = oldStatus
+		} else {
+			sched.status = SCHED_STATUS_STOPPED
+		}
+		sched.statusLock.Unlock()
+	}()
+	if err != nil {
+		return
+	}
+	begin := time.Now()
+	pickedItems := atomic.LoadUint64(&sched.pickedItems)
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	sched.acceptCancelFunc()
+	sched.pauseGate.resume()
+	logger.Info("Wait for the downloads in progress...")
+	result.Drained = sched.waitDrained(deadline)
+	result.FlushedItems = atomic.LoadUint64(&sched.pickedItems) - pickedItems
+	if n := atomic.LoadInt64(&sched.pendingItems); n > 0 {
+		result.DroppedItems = uint64(n)
+	}
+	if n := atomic.LoadInt64(&sched.pendingResps); n > 0 {
+		result.DroppedResponses = uint64(n)
+	}
+	sched.shutdown()
+	result.PendingRequests = sched.frontier.Len()
+	result.Elapsed = time.Since(begin)
+	logger.Infof("Scheduler has been stopped gracefully (drained: %v, flushed items: %d, dropped items: %d, "+
+		"dropped responses: %d, pending requests: %d, elapsed: %s)",
+		result.Drained, result.FlushedItems, result.DroppedItems,
+		result.DroppedResponses, result.PendingRequests, result.Elapsed)
+	return result, nil
+}
+
+// waitDrained waits until the downloads in progress have been done and all
+// responses and items have been handled, or the deadline is reached.
+func (sched *myScheduler) waitDrained(deadline <-chan time.Time) bool {
+	select {
+	case <-sched.downloadDone:
+	case <-deadline:
+		return false
+	}
+	logger.Info("Drain the responses and items...")
+	ticker := time.NewTicker(drainInterval)
+	defer ticker.Stop()
+	for {
+		if atomic.LoadInt64(&sched.pendingResps) <= 0 && atomic.LoadInt64(&sched.pendingItems) <= 0 {
+			return true
+		}
+		select {
+		case <-ticker.C:
+		case <-deadline:
+			return false
+		}
+	}
+}
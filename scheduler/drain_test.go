@@ -0,0 +1,107 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"webcrawler/module"
+	"webcrawler/module/local/pipeline"
+)
+
+func genDrainServer(linkNumber int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			time.Sleep(300 * time.Millisecond)
+			fmt.Fprint(w, `<html><body><a href="/">home</a></body></html>`)
+			return
+		}
+		var page strings.Builder
+		page.WriteString("<html><body>")
+		for i := 0; i < linkNumber; i++ {
+			fmt.Fprintf(&page, `<a href="/p/%d">page %d</a>`, i, i)
+		}
+		page.WriteString("</body></html>")
+		fmt.Fprint(w, page.String())
+	}))
+}
+
+func startDrainSched(serverURL string, moduleArgs ModuleArgs, t *testing.T) *myScheduler {
+	requestArgs := genRequestArgs([]string{}, 1)
+	dataArgs := genDataArgs(10, 2, 1)
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", serverURL, nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	mySched := sched.(*myScheduler)
+	for i := 0; atomic.LoadUint64(&mySched.pickedItems) == 0; i++ {
+		if i >= 500 {
+			t.Fatal("No item has been processed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return mySched
+}
+
+func TestSchedStopGracefully(t *testing.T) {
+	linkNumber := 20
+	server := genDrainServer(linkNumber)
+	defer server.Close()
+	sched := startDrainSched(server.URL, genSimpleModuleArgs(1, 1, 1, t), t)
+	if _, err := sched.StopGracefully(-time.Second); err == nil {
+		t.Fatal("No error when stopping scheduler gracefully with negative timeout")
+	}
+	result, err := sched.StopGracefully(5 * time.Second)
+	if err != nil {
+		t.Fatalf("An error occurs when stopping scheduler gracefully: %s", err)
+	}
+	if !result.Drained || result.DroppedItems != 0 || result.DroppedResponses != 0 {
+		t.Fatalf("The scheduler has not been drained: %#v", result)
+	}
+	if picked := atomic.LoadUint64(&sched.pickedItems); picked < uint64(linkNumber) {
+		t.Fatalf("Inconsistent processed item number, expected: at least %d, actual: %d", linkNumber, picked)
+	}
+	if result.PendingRequests == 0 {
+		t.Fatalf("No request has been left pending: %#v", result)
+	}
+	if sched.Status() != SCHED_STATUS_STOPPED {
+		t.Fatalf("Inconsistent status, expected: %s, actual: %s",
+			GetStatusDescription(SCHED_STATUS_STOPPED), GetStatusDescription(sched.Status()))
+	}
+	if _, err := sched.StopGracefully(time.Second); err == nil {
+		t.Fatal("No error when stopping a stopped scheduler gracefully")
+	}
+}
+
+func TestSchedStopGracefullyTimeout(t *testing.T) {
+	server := genDrainServer(20)
+	defer server.Close()
+	moduleArgs := genSimpleModuleArgs(1, 1, 0, t)
+	slowProcessor := func(item module.Item) (module.Item, error) {
+		time.Sleep(200 * time.Millisecond)
+		return item, nil
+	}
+	p, err := pipeline.New(module.MID("P1"), []module.ProcessItem{slowProcessor}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a pipeline: %s", err)
+	}
+	moduleArgs.Pipelines = []module.Pipeline{p}
+	sched := startDrainSched(server.URL, moduleArgs, t)
+	result, err := sched.StopGracefully(50 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("An error occurs when stopping scheduler gracefully: %s", err)
+	}
+	if result.Drained || result.DroppedItems == 0 {
+		t.Fatalf("Inconsistent result of the timed out graceful stop: %#v", result)
+	}
+	if result.Elapsed > time.Second {
+		t.Fatalf("The graceful stop has not been timed out, elapsed: %s", result.Elapsed)
+	}
+}
@@ -3,7 +3,6 @@ package scheduler
 import (
 	"webcrawler/errors"
 	"webcrawler/module"
-	"webcrawler/toolkit/buffer"
 )
 
 func genError(errMsg string) error {
@@ -18,8 +17,11 @@ func genParameterError(errMsg string) error {
 	return errors.NewCrawlerErrorBy(errors.ERROR_TYPE_SCHEDULER, errors.NewIllegalParameterError(errMsg))
 }
 
-func sendError(err error, mid module.MID, errBufferPool buffer.Pool) bool {
-	if err == nil || errBufferPool == nil || errBufferPool.Closed() {
+// sendError sends the error as a crawler error of the type of the module
+// through the sender. The error is dropped if all the goroutines of the
+// sender are busy, so that an unread error buffer never blocks the workers.
+func sendError(err error, mid module.MID, errSender *sender) bool {
+	if err == nil || errSender == nil {
 		return false
 	}
 	var crawlerError errors.CrawlerError
@@ -43,13 +45,9 @@ func sendError(err error, mid module.MID, errBufferPool buffer.Pool) bool {
 		}
 		crawlerError = errors.NewCrawlerError(errorType, err.Error())
 	}
-	if errBufferPool.Closed() {
+	if !errSender.trySend(crawlerError) {
+		logger.Warnf("Drop the error as the error buffer pool is full or closed: %s", crawlerError)
 		return false
 	}
-	go func(crawlerError errors.CrawlerError) {
-		if err := errBufferPool.Put(crawlerError); err != nil {
-			logger.Warnf("The error buffer pool was closed. Ignore error sending")
-		}
-	}(crawlerError)
 	return true
 }
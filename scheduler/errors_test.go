@@ -1,6 +1,7 @@
 package scheduler
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -65,11 +66,12 @@ func TestErrorSend(t *testing.T) {
 	cerr := werr.NewCrawlerError(werr.ERROR_TYPE_SCHEDULER, "testing error")
 	mid := module.MID("")
 	buffer, _ := buffer.NewPool(10, 2)
-	if !sendError(cerr, mid, buffer) {
+	errSender := newSender(context.Background(), "error", buffer, 10)
+	if !sendError(cerr, mid, errSender) {
 		t.Fatalf("could not send error, (error: %s, MID: %s, buffer: %#v)", cerr, mid, buffer)
 	}
 	err := errors.New("testing error")
-	if !sendError(err, mid, buffer) {
+	if !sendError(err, mid, errSender) {
 		t.Fatalf("could not send error, (error: %s, MID: %s, buffer: %#v)", cerr, mid, buffer)
 	}
 	mids := []module.MID{
@@ -78,18 +80,18 @@ func TestErrorSend(t *testing.T) {
 		module.MID("P0"),
 	}
 	for _, mid := range mids {
-		if !sendError(err, mid, buffer) {
+		if !sendError(err, mid, errSender) {
 			t.Fatalf("could not send error (error: %s, MID: %s, buffer: %#v)", err, mid, buffer)
 		}
 	}
-	if sendError(nil, mid, buffer) {
+	if sendError(nil, mid, errSender) {
 		t.Fatalf("It can still send error with nil error")
 	}
 	if sendError(err, mid, nil) {
-		t.Fatalf("It can still send error with nil buffer")
+		t.Fatalf("It can still send error with nil sender")
 	}
 	buffer.Close()
-	if sendError(err, mid, buffer) {
+	if sendError(err, mid, errSender) {
 		t.Fatalf("It can still send error with closed buffer")
 	}
 
@@ -0,0 +1,531 @@
+package scheduler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"webcrawler/module"
+)
+
+type Frontier interface {
+	Add(req *module.Request) error
+	Done(req *module.Request) error
+	Restore(fn func(req *module.Request, done bool)) error
+	Len() uint64
+	Clear() error
+	Close() error
+}
+
+func frontierKey(req *module.Request) string {
+	if req == nil || req.HTTPReq() == nil || req.HTTPReq().URL == nil {
+		return ""
+	}
+	return req.HTTPReq().URL.String()
+}
+
+func NewMemoryFrontier() Frontier {
+	return &memoryFrontier{
+		pending:   map[string]*module.Request{},
+		compactAt: memoryFrontierMinCompactLen,
+	}
+}
+
+// memoryFrontierMinCompactLen is the min number of the requests in the
+// memory frontier to compact at.
+const memoryFrontierMinCompactLen = 1024
+
+// The memory frontier keeps only the pending requests in the order they are
+// added, so the visited ones are not restored and only kept in the seen set
+// of the scheduler. The done and the repeated requests are dropped whenever
+// the number of the requests doubles.
+type memoryFrontier struct {
+	order   []*module.Request
+	pending map[string]*module.Request
+	// compactAt is the number of the requests in order to compact at.
+	compactAt int
+	lock      sync.RWMutex
+}
+
+func (frontier *memoryFrontier) Add(req *module.Request) error {
+	key := frontierKey(req)
+	if key == "" {
+		return genParameterError("invalid request for frontier")
+	}
+	frontier.lock.Lock()
+	defer frontier.lock.Unlock()
+	frontier.order = append(frontier.order, req)
+	frontier.pending[key] = req
+	frontier.compactIfNeeded()
+	return nil
+}
+
+func (frontier *memoryFrontier) Done(req *module.Request) error {
+	key := frontierKey(req)
+	if key == "" {
+		return genParameterError("invalid request for frontier")
+	}
+	frontier.lock.Lock()
+	defer frontier.lock.Unlock()
+	delete(frontier.pending, key)
+	return nil
+}
+
+// compactIfNeeded drops the done and the repeated requests if the frontier
+// reaches the number to compact at.
+func (frontier *memoryFrontier) compactIfNeeded() {
+	if len(frontier.order) < frontier.compactAt {
+		return
+	}
+	order := make([]*module.Request, 0, len(frontier.pending))
+	kept := make(map[string]bool, len(frontier.pending))
+	for _, req := range frontier.order {
+		key := frontierKey(req)
+		if frontier.pending[key] == req && !kept[key] {
+			kept[key] = true
+			order = append(order, req)
+		}
+	}
+	frontier.order = order
+	frontier.compactAt = 2 * len(order)
+	if frontier.compactAt < memoryFrontierMinCompactLen {
+		frontier.compactAt = memoryFrontierMinCompactLen
+	}
+}
+
+func (frontier *memoryFrontier) Restore(fn func(req *module.Request, done bool)) error {
+	frontier.lock.RLock()
+	order := make([]*module.Request, 0, len(frontier.pending))
+	restored := make(map[string]bool, len(frontier.pending))
+	for _, req := range frontier.order {
+		key := frontierKey(req)
+		if frontier.pending[key] == req && !restored[key] {
+			restored[key] = true
+			order = append(order, req)
+		}
+	}
+	frontier.lock.RUnlock()
+	for _, req := range order {
+		fn(req, false)
+	}
+	return nil
+}
+
+func (frontier *memoryFrontier) Len() uint64 {
+	frontier.lock.RLock()
+	defer frontier.lock.RUnlock()
+	return uint64(len(frontier.pending))
+}
+
+func (frontier *memoryFrontier) Clear() error {
+	frontier.lock.Lock()
+	defer frontier.lock.Unlock()
+	frontier.order = nil
+	frontier.pending = map[string]*module.Request{}
+	frontier.compactAt = memoryFrontierMinCompactLen
+	return nil
+}
+
+func (frontier *memoryFrontier) Close() error {
+	return nil
+}
+
+const (
+	frontierLogName       = "frontier.log"
+	frontierRecordAdd     = 'A'
+	frontierRecordDone    = 'D'
+	frontierRecordVisited = 'V'
+)
+
+// frontierMinCompactSize is the min size of the frontier log to compact at.
+const frontierMinCompactSize = 4 << 20
+
+type frontierRecord struct {
+	Method string       `json:"method"`
+	URL    string       `json:"url"`
+	Header http.Header  `json:"header,omitempty"`
+	Body   []byte       `json:"body,omitempty"`
+	Depth  uint32       `json:"depth"`
+	Meta   *module.Meta `json:"meta,omitempty"`
+}
+
+// The disk frontier appends one line per event to frontier.log and keeps an
+// in-memory index from URL to the offset of its pending "A" record. A torn
+// last line left by a crash is ignored when the log is replayed.
+//
+// The log is compacted when it is opened and whenever it doubles in size,
+// which keeps the pending requests in full and only the method, the URL and
+// the depth of the visited ones ("V" records) for restoring the seen set.
+// The records are written without fsync, so they survive a crash of the
+// process, but the ones after the last compaction or Close may be lost on a
+// crash of the system.
+func NewDiskFrontier(dir string) (Frontier, error) {
+	if dir == "" {
+		return nil, genParameterError("empty frontier directory")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, genErrorByError(err)
+	}
+	path := filepath.Join(dir, frontierLogName)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, genErrorByError(err)
+	}
+	frontier := &diskFrontier{
+		path:  path,
+		file:  file,
+		index: map[string]int64{},
+	}
+	if err = frontier.loadIndex(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err = frontier.compact(); err != nil {
+		if frontier.file != nil {
+			frontier.file.Close()
+		}
+		return nil, err
+	}
+	return frontier, nil
+}
+
+type diskFrontier struct {
+	path  string
+	file  *os.File
+	size  int64
+	index map[string]int64
+	// compactAt is the size of the log to compact at.
+	compactAt int64
+	lock      sync.Mutex
+}
+
+// scanFrontierLog calls fn with the kind, the payload and the offset of every
+// complete line, and returns the size of them.
+func scanFrontierLog(r io.Reader, fn func(kind byte, payload []byte, offset int64)) (int64, error) {
+	reader := bufio.NewReader(r)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				logger.Warnf("Ignore the torn frontier record at offset %d", offset)
+			}
+			return offset, nil
+		}
+		if err != nil {
+			return offset, genErrorByError(err)
+		}
+		lineOffset := offset
+		offset += int64(len(line))
+		if len(line) < 3 || line[1] != ' ' {
+			logger.Warnf("Ignore the broken frontier record at offset %d", lineOffset)
+			continue
+		}
+		fn(line[0], line[2:len(line)-1], lineOffset)
+	}
+}
+
+func (frontier *diskFrontier) loadIndex() error {
+	if _, err := frontier.file.Seek(0, io.SeekStart); err != nil {
+		return genErrorByError(err)
+	}
+	size, err := scanFrontierLog(frontier.file, func(kind byte, payload []byte, offset int64) {
+		switch kind {
+		case frontierRecordAdd:
+			var record frontierRecord
+			if err := json.Unmarshal(payload, &record); err != nil {
+				logger.Warnf("Ignore the broken frontier record at offset %d: %s", offset, err)
+				return
+			}
+			frontier.index[record.URL] = offset
+		case frontierRecordDone:
+			delete(frontier.index, string(payload))
+		}
+	})
+	if err != nil {
+		return err
+	}
+	frontier.size = size
+	return nil
+}
+
+// compact rewrites the log with the pending requests and the visited URLs,
+// syncs it to the disk and replaces the old one with it.
+func (frontier *diskFrontier) compact() error {
+	src, err := os.Open(frontier.path)
+	if err != nil {
+		return genErrorByError(err)
+	}
+	defer src.Close()
+	tmpPath := frontier.path + ".tmp"
+	dst, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return genErrorByError(err)
+	}
+	writer := bufio.NewWriter(dst)
+	index := make(map[string]int64, len(frontier.index))
+	visited := map[string]bool{}
+	var size int64
+	write := func(kind byte, payload []byte) {
+		writer.WriteByte(kind)
+		writer.WriteByte(' ')
+		writer.Write(payload)
+		writer.WriteByte('\n')
+		size += int64(len(payload)) + 3
+	}
+	_, err = scanFrontierLog(io.LimitReader(src, frontier.size), func(kind byte, payload []byte, offset int64) {
+		if kind != frontierRecordAdd && kind != frontierRecordVisited {
+			return
+		}
+		var record frontierRecord
+		if err := json.Unmarshal(payload, &record); err != nil {
+			return
+		}
+		pendingOffset, pending := frontier.index[record.URL]
+		if kind == frontierRecordAdd && pending && pendingOffset == offset {
+			index[record.URL] = size
+			write(frontierRecordAdd, payload)
+			return
+		}
+		if pending || visited[record.URL] {
+			return
+		}
+		visited[record.URL] = true
+		if kind == frontierRecordAdd {
+			record = frontierRecord{Method: record.Method, URL: record.URL, Depth: record.Depth}
+			if payload, err = json.Marshal(record); err != nil {
+				return
+			}
+		}
+		write(frontierRecordVisited, payload)
+	})
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = dst.Sync()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, frontier.path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return genErrorByError(err)
+	}
+	syncDir(filepath.Dir(frontier.path))
+	frontier.file.Close()
+	frontier.file, err = os.OpenFile(frontier.path, os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		frontier.file = nil
+		return genErrorByError(err)
+	}
+	frontier.size = size
+	frontier.index = index
+	frontier.compactAt = 2 * size
+	if frontier.compactAt < frontierMinCompactSize {
+		frontier.compactAt = frontierMinCompactSize
+	}
+	return nil
+}
+
+// syncDir syncs the directory so that a renamed file in it is durable.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+func (frontier *diskFrontier) append(kind byte, payload []byte) (int64, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(kind)
+	buf.WriteByte(' ')
+	buf.Write(payload)
+	buf.WriteByte('\n')
+	offset := frontier.size
+	n, err := frontier.file.Write(buf.Bytes())
+	frontier.size += int64(n)
+	if err != nil {
+		return offset, genErrorByError(err)
+	}
+	return offset, nil
+}
+
+// compactIfNeeded compacts the log if it reaches the size to compact at.
+func (frontier *diskFrontier) compactIfNeeded() error {
+	if frontier.size < frontier.compactAt {
+		return nil
+	}
+	logger.Infof("Compact the frontier log (size: %d, pending: %d)", frontier.size, len(frontier.index))
+	if err := frontier.compact(); err != nil {
+		// The log is still usable, and the compaction is retried later.
+		frontier.compactAt = 2 * frontier.size
+		return err
+	}
+	return nil
+}
+
+func (frontier *diskFrontier) Add(req *module.Request) error {
+	key := frontierKey(req)
+	if key == "" {
+		return genParameterError("invalid request for frontier")
+	}
+	record, err := encodeFrontierRecord(req)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return genErrorByError(err)
+	}
+	frontier.lock.Lock()
+	defer frontier.lock.Unlock()
+	if frontier.file == nil {
+		return genError("closed frontier")
+	}
+	offset, err := frontier.append(frontierRecordAdd, payload)
+	if err != nil {
+		return err
+	}
+	frontier.index[key] = offset
+	return frontier.compactIfNeeded()
+}
+
+func (frontier *diskFrontier) Done(req *module.Request) error {
+	key := frontierKey(req)
+	if key == "" {
+		return genParameterError("invalid request for frontier")
+	}
+	frontier.lock.Lock()
+	defer frontier.lock.Unlock()
+	if frontier.file == nil {
+		return genError("closed frontier")
+	}
+	if _, ok := frontier.index[key]; !ok {
+		return nil
+	}
+	if _, err := frontier.append(frontierRecordDone, []byte(key)); err != nil {
+		return err
+	}
+	delete(frontier.index, key)
+	return frontier.compactIfNeeded()
+}
+
+func (frontier *diskFrontier) Restore(fn func(req *module.Request, done bool)) error {
+	frontier.lock.Lock()
+	defer frontier.lock.Unlock()
+	if frontier.file == nil {
+		return genError("closed frontier")
+	}
+	file, err := os.Open(frontier.path)
+	if err != nil {
+		return genErrorByError(err)
+	}
+	defer file.Close()
+	_, err = scanFrontierLog(io.LimitReader(file, frontier.size), func(kind byte, payload []byte, offset int64) {
+		if kind != frontierRecordAdd && kind != frontierRecordVisited {
+			return
+		}
+		var record frontierRecord
+		if err := json.Unmarshal(payload, &record); err != nil {
+			return
+		}
+		req, err := decodeFrontierRecord(record)
+		if err != nil {
+			logger.Warnf("Ignore the invalid frontier record at offset %d: %s", offset, err)
+			return
+		}
+		pendingOffset, pending := frontier.index[record.URL]
+		fn(req, kind == frontierRecordVisited || !pending || pendingOffset != offset)
+	})
+	return err
+}
+
+func (frontier *diskFrontier) Len() uint64 {
+	frontier.lock.Lock()
+	defer frontier.lock.Unlock()
+	return uint64(len(frontier.index))
+}
+
+func (frontier *diskFrontier) Clear() error {
+	frontier.lock.Lock()
+	defer frontier.lock.Unlock()
+	if frontier.file == nil {
+		return genError("closed frontier")
+	}
+	if err := frontier.file.Truncate(0); err != nil {
+		return genErrorByError(err)
+	}
+	frontier.size = 0
+	frontier.index = map[string]int64{}
+	frontier.compactAt = frontierMinCompactSize
+	return nil
+}
+
+func (frontier *diskFrontier) Close() error {
+	frontier.lock.Lock()
+	defer frontier.lock.Unlock()
+	if frontier.file == nil {
+		return nil
+	}
+	err := frontier.file.Sync()
+	if cerr := frontier.file.Close(); err == nil {
+		err = cerr
+	}
+	frontier.file = nil
+	if err != nil {
+		return genErrorByError(err)
+	}
+	return nil
+}
+
+func encodeFrontierRecord(req *module.Request) (frontierRecord, error) {
+	httpReq := req.HTTPReq()
+	record := frontierRecord{
+		Method: httpReq.Method,
+		URL:    httpReq.URL.String(),
+		Header: httpReq.Header,
+		Depth:  req.Depth(),
+	}
+	if meta := req.Meta(); !reflect.DeepEqual(meta, module.Meta{}) {
+		record.Meta = &meta
+	}
+	if httpReq.GetBody != nil {
+		body, err := httpReq.GetBody()
+		if err != nil {
+			return record, genErrorByError(err)
+		}
+		record.Body, err = io.ReadAll(body)
+		body.Close()
+		if err != nil {
+			return record, genErrorByError(err)
+		}
+	}
+	return record, nil
+}
+
+func decodeFrontierRecord(record frontierRecord) (*module.Request, error) {
+	var body io.Reader
+	if len(record.Body) > 0 {
+		body = bytes.NewReader(record.Body)
+	}
+	httpReq, err := http.NewRequest(record.Method, record.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	if record.Header != nil {
+		httpReq.Header = record.Header
+	}
+	if record.Meta != nil {
+		return module.NewRequestWithMeta(httpReq, record.Depth, *record.Meta), nil
+	}
+	return module.NewRequest(httpReq, record.Depth), nil
+}
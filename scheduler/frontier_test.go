@@ -0,0 +1,264 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"webcrawler/module"
+)
+
+func genFrontierRequests(t *testing.T, urls ...string) []*module.Request {
+	reqs := make([]*module.Request, 0, len(urls))
+	for i, url := range urls {
+		httpReq, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatalf("An error occurs when creating a HTTP request: %s (url: %s)", err, url)
+		}
+		reqs = append(reqs, module.NewRequest(httpReq, uint32(i)))
+	}
+	return reqs
+}
+
+func checkFrontierRestore(t *testing.T, frontier Frontier, expectedDone map[string]bool) {
+	actual := map[string]bool{}
+	err := frontier.Restore(func(req *module.Request, done bool) {
+		actual[req.HTTPReq().URL.String()] = done
+	})
+	if err != nil {
+		t.Fatalf("An error occurs when restoring the frontier: %s", err)
+	}
+	if len(actual) != len(expectedDone) {
+		t.Fatalf("Inconsistent restored request number, expected: %d, actual: %d", len(expectedDone), len(actual))
+	}
+	for url, done := range expectedDone {
+		if actual[url] != done {
+			t.Fatalf("Inconsistent done mark for %s, expected: %v, actual: %v", url, done, actual[url])
+		}
+	}
+}
+
+func TestFrontierMemory(t *testing.T) {
+	frontier := NewMemoryFrontier()
+	reqs := genFrontierRequests(t, "http://a.com/1", "http://a.com/2", "http://a.com/3")
+	for _, req := range reqs {
+		if err := frontier.Add(req); err != nil {
+			t.Fatalf("An error occurs when adding a request to the frontier: %s", err)
+		}
+	}
+	if err := frontier.Done(reqs[1]); err != nil {
+		t.Fatalf("An error occurs when marking a request as done: %s", err)
+	}
+	if frontier.Len() != 2 {
+		t.Fatalf("Inconsistent frontier length, expected: %d, actual: %d", 2, frontier.Len())
+	}
+	checkFrontierRestore(t, frontier, map[string]bool{
+		"http://a.com/1": false,
+		"http://a.com/3": false,
+	})
+	if err := frontier.Add(nil); err == nil {
+		t.Fatal("No error when adding a nil request to the frontier")
+	}
+	frontier.Clear()
+	if frontier.Len() != 0 {
+		t.Fatalf("Inconsistent frontier length after clear, expected: %d, actual: %d", 0, frontier.Len())
+	}
+}
+
+func TestFrontierMemoryCompact(t *testing.T) {
+	frontier := NewMemoryFrontier()
+	memory := frontier.(*memoryFrontier)
+	reqs := genFrontierRequests(t, "http://a.com/1", "http://a.com/2", "http://a.com/3")
+	for _, req := range reqs {
+		frontier.Add(req)
+	}
+	frontier.Done(reqs[0])
+	frontier.Done(reqs[2])
+	memory.compactAt = 1
+	// The repeated request is dropped on compaction.
+	frontier.Add(reqs[1])
+	checkFrontierRestore(t, frontier, map[string]bool{"http://a.com/2": false})
+	if len(memory.order) != 1 {
+		t.Fatalf("Inconsistent compacted request number, expected: %d, actual: %d", 1, len(memory.order))
+	}
+	if memory.compactAt != memoryFrontierMinCompactLen {
+		t.Fatalf("Inconsistent number to compact at, expected: %d, actual: %d", memoryFrontierMinCompactLen, memory.compactAt)
+	}
+	for i := 0; i < 4*memoryFrontierMinCompactLen; i++ {
+		req := genFrontierRequests(t, fmt.Sprintf("http://b.com/%d", i))[0]
+		frontier.Add(req)
+		frontier.Done(req)
+	}
+	if len(memory.order) > memoryFrontierMinCompactLen {
+		t.Fatalf("The done requests are kept in the memory frontier (requests: %d)", len(memory.order))
+	}
+}
+
+func TestFrontierDisk(t *testing.T) {
+	dir := t.TempDir()
+	frontier, err := NewDiskFrontier(dir)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a disk frontier: %s", err)
+	}
+	reqs := genFrontierRequests(t, "http://a.com/1", "http://a.com/2", "http://a.com/3")
+	for _, req := range reqs {
+		if err := frontier.Add(req); err != nil {
+			t.Fatalf("An error occurs when adding a request to the frontier: %s", err)
+		}
+	}
+	frontier.Done(reqs[0])
+	frontier.Close()
+	if err := frontier.Add(reqs[0]); err == nil {
+		t.Fatal("No error when adding a request to a closed frontier")
+	}
+	logFile, err := os.OpenFile(filepath.Join(dir, frontierLogName), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("An error occurs when opening the frontier log: %s", err)
+	}
+	logFile.WriteString(`A {"method":"GET","url":"http://a.com/4"`)
+	logFile.Close()
+
+	frontier, err = NewDiskFrontier(dir)
+	if err != nil {
+		t.Fatalf("An error occurs when reopening a disk frontier: %s", err)
+	}
+	defer frontier.Close()
+	if frontier.Len() != 2 {
+		t.Fatalf("Inconsistent frontier length, expected: %d, actual: %d", 2, frontier.Len())
+	}
+	more := genFrontierRequests(t, "http://a.com/5")
+	frontier.Add(more[0])
+	frontier.Done(reqs[2])
+	checkFrontierRestore(t, frontier, map[string]bool{
+		"http://a.com/1": true,
+		"http://a.com/2": false,
+		"http://a.com/3": true,
+		"http://a.com/5": false,
+	})
+	if err := frontier.Clear(); err != nil {
+		t.Fatalf("An error occurs when clearing the frontier: %s", err)
+	}
+	checkFrontierRestore(t, frontier, map[string]bool{})
+	if _, err := NewDiskFrontier(""); err == nil {
+		t.Fatal("No error when creating a disk frontier with empty directory")
+	}
+}
+
+func TestFrontierDiskCompact(t *testing.T) {
+	dir := t.TempDir()
+	frontier, err := NewDiskFrontier(dir)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a disk frontier: %s", err)
+	}
+	defer frontier.Close()
+	disk := frontier.(*diskFrontier)
+	reqs := genFrontierRequests(t, "http://a.com/1", "http://a.com/2", "http://a.com/3")
+	for _, req := range reqs {
+		req.HTTPReq().Header.Set("User-Agent", "webcrawler")
+		if err := frontier.Add(req); err != nil {
+			t.Fatalf("An error occurs when adding a request to the frontier: %s", err)
+		}
+	}
+	frontier.Done(reqs[0])
+	disk.compactAt = 1
+	frontier.Done(reqs[2])
+	expectedDone := map[string]bool{
+		"http://a.com/1": true,
+		"http://a.com/2": false,
+		"http://a.com/3": true,
+	}
+	checkFrontierRestore(t, frontier, expectedDone)
+	data, err := os.ReadFile(filepath.Join(dir, frontierLogName))
+	if err != nil {
+		t.Fatalf("An error occurs when reading the frontier log: %s", err)
+	}
+	if int64(len(data)) != disk.size {
+		t.Fatalf("Inconsistent frontier log size, expected: %d, actual: %d", disk.size, len(data))
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Inconsistent compacted record number, expected: %d, actual: %d (log: %q)", 3, len(lines), data)
+	}
+	for _, line := range lines {
+		if line[0] == frontierRecordVisited && strings.Contains(line, "User-Agent") {
+			t.Fatalf("The header of the visited request is kept in the compacted log: %s", line)
+		}
+	}
+	if disk.compactAt != frontierMinCompactSize {
+		t.Fatalf("Inconsistent size to compact at, expected: %d, actual: %d", frontierMinCompactSize, disk.compactAt)
+	}
+	frontier.Close()
+	frontier, err = NewDiskFrontier(dir)
+	if err != nil {
+		t.Fatalf("An error occurs when reopening a disk frontier: %s", err)
+	}
+	defer frontier.Close()
+	if frontier.Len() != 1 {
+		t.Fatalf("Inconsistent frontier length, expected: %d, actual: %d", 1, frontier.Len())
+	}
+	checkFrontierRestore(t, frontier, expectedDone)
+}
+
+func TestSchedRestore(t *testing.T) {
+	frontier, err := NewDiskFrontier(t.TempDir())
+	if err != nil {
+		t.Fatalf("An error occurs when creating a disk frontier: %s", err)
+	}
+	defer frontier.Close()
+	reqs := genFrontierRequests(t, "http://cn.bing.com/search?q=golang", "http://cn.bing.com/search?q=rust")
+	for _, req := range reqs {
+		frontier.Add(req)
+	}
+	frontier.Done(reqs[0])
+	requestArgs := genRequestArgs([]string{}, 0)
+	dataArgs := genDataArgs(10, 2, 1)
+	dataArgs.Frontier = frontier
+	moduleArgs := genSimpleModuleArgs(3, 2, 1, t)
+	sched := NewScheduler()
+	if err = sched.Restore(); err == nil {
+		t.Fatal("No error when restoring the scheduler before initialize")
+	}
+	if err = sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	if err = sched.Resume(); err == nil {
+		t.Fatal("No error when resuming the scheduler which has not been paused")
+	}
+	if err = sched.Restore(); err != nil {
+		t.Fatalf("An error occurs when restoring scheduler: %s", err)
+	}
+	defer sched.Stop()
+	mySched := sched.(*myScheduler)
+	for _, req := range reqs {
+		if !mySched.seenSet.Contains(req.HTTPReq().URL.String()) {
+			t.Fatalf("The visited URL %s has not been restored", req.HTTPReq().URL)
+		}
+	}
+	if _, ok := mySched.acceptedDomainMap.Load("bing.com"); !ok {
+		t.Fatal("The primary domain of the first request has not been restored")
+	}
+	if err = sched.Restore(); err == nil {
+		t.Fatal("No error when repeatedly restoring scheduler")
+	}
+	if err = sched.Resume(); err == nil {
+		t.Fatal("No error when resuming the started scheduler")
+	}
+}
+
+func TestFrontierRecordPriority(t *testing.T) {
+	httpReq, _ := http.NewRequest("GET", "http://a.com/1", nil)
+	record, err := encodeFrontierRecord(module.NewRequestWithPriority(httpReq, 2, 9))
+	if err != nil {
+		t.Fatalf("An error occurs when encoding a frontier record: %s", err)
+	}
+	req, err := decodeFrontierRecord(record)
+	if err != nil {
+		t.Fatalf("An error occurs when decoding a frontier record: %s", err)
+	}
+	if req.Depth() != 2 || req.Priority() != 9 {
+		t.Fatalf("Inconsistent decoded request, expected depth: %d, priority: %d, actual depth: %d, priority: %d",
+			2, 9, req.Depth(), req.Priority())
+	}
+}
@@ -0,0 +1,133 @@
+package scheduler
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+	"webcrawler/module"
+)
+
+// moduleDrainInterval is the interval for checking whether a module being
+// removed is still handling data.
+const moduleDrainInterval = 10 * time.Millisecond
+
+// moduleRetryDelay is how long a host is held after its request could not
+// get a downloader.
+const moduleRetryDelay = 100 * time.Millisecond
+
+// getModule selects a module of the type by the key, and counts the call of
+// it until release is called.
+func (sched *myScheduler) getModule(moduleType module.Type, key string) (m module.Module, release func(), err error) {
+	sched.moduleLock.RLock()
+	defer sched.moduleLock.RUnlock()
+	m, err = sched.registrar.Select(moduleType, key)
+	if err != nil || m == nil {
+		return m, func() {}, err
+	}
+	calls := sched.moduleCallCounter(m.ID())
+	atomic.AddInt64(calls, 1)
+	return m, func() {
+		atomic.AddInt64(calls, -1)
+	}, nil
+}
+
+func (sched *myScheduler) moduleCallCounter(mid module.MID) *int64 {
+	calls, _ := sched.moduleCalls.LoadOrStore(mid, new(int64))
+	return calls.(*int64)
+}
+
+// AddModule registers the module while the scheduler is running, which takes
+// part in the selection immediately. The number of the workers is not
+// changed, see ConcurrencyArgs.
+func (sched *myScheduler) AddModule(m module.Module) (err error) {
+	if err = sched.checkStatusForModules(); err != nil {
+		return
+	}
+	if m == nil {
+		return genParameterError("nil module")
+	}
+	ok, err := sched.registrar.Register(m)
+	if err != nil {
+		return genErrorByError(err)
+	}
+	if !ok {
+		errMsg := fmt.Sprintf("the module with MID %q has been registered", m.ID())
+		return genParameterError(errMsg)
+	}
+	logger.Infof("The module has been added (MID: %s)", m.ID())
+	return nil
+}
+
+// RemoveModule stops selecting the module and waits until it has handled the
+// data in progress. The module is left unregistered on timeout, and zero
+// timeout means waiting until drained. The last module of a type could not
+// be removed from the running scheduler.
+func (sched *myScheduler) RemoveModule(mid module.MID, timeout time.Duration) (err error) {
+	if timeout < 0 {
+		return genParameterError("negative timeout for module removal")
+	}
+	if err = sched.checkStatusForModules(); err != nil {
+		return
+	}
+	ok, moduleType := module.GetType(mid)
+	if !ok {
+		errMsg := fmt.Sprintf("illegal MID %q", mid)
+		return genParameterError(errMsg)
+	}
+	sched.moduleLock.Lock()
+	modules, _ := sched.registrar.GetAllByType(moduleType)
+	m, ok := modules[mid]
+	if !ok {
+		sched.moduleLock.Unlock()
+		errMsg := fmt.Sprintf("module not found (MID: %s)", mid)
+		return genParameterError(errMsg)
+	}
+	if len(modules) == 1 {
+		status := sched.Status()
+		if status == SCHED_STATUS_STARTED || status == SCHED_STATUS_PAUSED {
+			sched.moduleLock.Unlock()
+			errMsg := fmt.Sprintf("could not remove the last %s (MID: %s)", moduleType, mid)
+			return genError(errMsg)
+		}
+	}
+	if _, err = sched.registrar.Unregister(mid); err != nil {
+		sched.moduleLock.Unlock()
+		return genErrorByError(err)
+	}
+	sched.moduleLock.Unlock()
+	logger.Infof("Drain the module (MID: %s)...", mid)
+	calls := sched.moduleCallCounter(mid)
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	ticker := time.NewTicker(moduleDrainInterval)
+	defer ticker.Stop()
+	for atomic.LoadInt64(calls) > 0 || m.HandlingNumber() > 0 {
+		select {
+		case <-ticker.C:
+		case <-deadline:
+			errMsg := fmt.Sprintf("timeout when draining the module (MID: %s, handling number: %d)",
+				mid, m.HandlingNumber())
+			return genError(errMsg)
+		}
+	}
+	sched.moduleCalls.Delete(mid)
+	logger.Infof("The module has been removed (MID: %s)", mid)
+	return nil
+}
+
+func (sched *myScheduler) checkStatusForModules() error {
+	switch sched.Status() {
+	case SCHED_STATUS_INITIALIZED, SCHED_STATUS_STARTED, SCHED_STATUS_PAUSED, SCHED_STATUS_STOPPED:
+		return nil
+	case SCHED_STATUS_UNINITIALIZED:
+		return genError("the scheduler has not yet been initialized")
+	default:
+		errMsg := fmt.Sprintf("could not change the modules while the scheduler is %s",
+			GetStatusDescription(sched.Status()))
+		return genError(errMsg)
+	}
+}
@@ -0,0 +1,246 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"webcrawler/module"
+	"webcrawler/module/local/analyzer"
+	"webcrawler/module/remote"
+)
+
+func genSlowAnalyzer(mid module.MID, delay time.Duration, t *testing.T) module.Analyzer {
+	parser := func(httpResp *http.Response, respDepth uint32) ([]module.Data, []error) {
+		time.Sleep(delay)
+		return parseATag(httpResp, respDepth)
+	}
+	a, err := analyzer.New(mid, []module.ParseResponse{parser}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating an analyzer: %s (mid: %s)", err, mid)
+	}
+	return a
+}
+
+func TestSchedModules(t *testing.T) {
+	server := genDrainServer(20)
+	defer server.Close()
+	slowAnalyzer := genSlowAnalyzer("A101", 500*time.Millisecond, t)
+	moduleArgs := genSimpleModuleArgs(1, 0, 1, t)
+	moduleArgs.Analyzers = []module.Analyzer{slowAnalyzer}
+	sched := NewScheduler()
+	if err := sched.AddModule(slowAnalyzer); err == nil {
+		t.Fatal("No error when adding a module to the uninitialized scheduler")
+	}
+	if err := sched.Init(genRequestArgs([]string{}, 1), genDataArgs(10, 2, 1), moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL, nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	for i := 0; slowAnalyzer.HandlingNumber() == 0; i++ {
+		if i >= 500 {
+			t.Fatal("The analyzer has not been called")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := sched.AddModule(nil); err == nil {
+		t.Fatal("No error when adding nil module")
+	}
+	if err := sched.AddModule(slowAnalyzer); err == nil {
+		t.Fatal("No error when adding a registered module")
+	}
+	if err := sched.RemoveModule(slowAnalyzer.ID(), 0); err == nil {
+		t.Fatal("No error when removing the last analyzer")
+	}
+	if err := sched.RemoveModule(moduleArgs.Downloaders[0].ID(), 0); err == nil {
+		t.Fatal("No error when removing the last downloader")
+	}
+	if err := sched.RemoveModule("A999", 0); err == nil {
+		t.Fatal("No error when removing an unregistered module")
+	}
+	if err := sched.RemoveModule(slowAnalyzer.ID(), -time.Second); err == nil {
+		t.Fatal("No error when removing a module with negative timeout")
+	}
+	fastAnalyzer := genSlowAnalyzer("A102", 0, t)
+	if err := sched.AddModule(fastAnalyzer); err != nil {
+		t.Fatalf("An error occurs when adding a module: %s", err)
+	}
+	if err := sched.RemoveModule(slowAnalyzer.ID(), 10*time.Millisecond); err == nil {
+		t.Fatal("No error when the module has not been drained before the timeout")
+	}
+	mySched := sched.(*myScheduler)
+	modules, _ := mySched.registrar.GetAllByType(module.TYPE_ANALYZER)
+	if _, ok := modules[slowAnalyzer.ID()]; ok || len(modules) != 1 {
+		t.Fatalf("Inconsistent analyzers after removal: %v", modules)
+	}
+	begin := time.Now()
+	for !sched.Idle() || time.Since(begin) < 100*time.Millisecond {
+		if time.Since(begin) > 10*time.Second {
+			t.Fatal("The scheduler has not been idle")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if slowAnalyzer.CalledCount() != 1 || fastAnalyzer.CalledCount() != 20 {
+		t.Fatalf("Inconsistent called count, slow analyzer: %d, fast analyzer: %d",
+			slowAnalyzer.CalledCount(), fastAnalyzer.CalledCount())
+	}
+	anotherAnalyzer := genSlowAnalyzer("A103", 0, t)
+	if err := sched.AddModule(anotherAnalyzer); err != nil {
+		t.Fatalf("An error occurs when adding a module: %s", err)
+	}
+	if err := sched.RemoveModule(fastAnalyzer.ID(), time.Second); err != nil {
+		t.Fatalf("An error occurs when removing a module: %s", err)
+	}
+}
+
+func TestSchedSelectors(t *testing.T) {
+	server := genDrainServer(20)
+	defer server.Close()
+	moduleArgs := genSimpleModuleArgs(3, 2, 1, t)
+	moduleArgs.Selectors = map[module.Type]module.Selector{
+		module.TYPE_DOWNLOADER: module.NewHostAffinitySelector(nil),
+		module.TYPE_ANALYZER:   module.NewRoundRobinSelector(),
+	}
+	sched := startDrainSched(server.URL, moduleArgs, t)
+	defer sched.Stop()
+	begin := time.Now()
+	for !sched.Idle() || time.Since(begin) < 100*time.Millisecond {
+		if time.Since(begin) > 10*time.Second {
+			t.Fatal("The scheduler has not been idle")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	var calledDownloaders int
+	for _, d := range moduleArgs.Downloaders {
+		if d.CalledCount() > 0 {
+			calledDownloaders++
+		}
+	}
+	if calledDownloaders != 1 {
+		t.Fatalf("The requests for the same host have been downloaded by %d downloaders", calledDownloaders)
+	}
+	for _, a := range moduleArgs.Analyzers {
+		if count := a.CalledCount(); count < 10 || count > 11 {
+			t.Fatalf("Unbalanced called count of the analyzer %s: %d", a.ID(), count)
+		}
+	}
+}
+
+// flakySelector selects no module for the first calls.
+type flakySelector struct {
+	failures int32
+}
+
+func (s *flakySelector) Name() string { return "flaky" }
+
+func (s *flakySelector) Select(modules []module.Module, key string) module.Module {
+	if atomic.AddInt32(&s.failures, -1) >= 0 {
+		return nil
+	}
+	return modules[0]
+}
+
+func TestSchedNoDownloader(t *testing.T) {
+	server := genDrainServer(5)
+	defer server.Close()
+	moduleArgs := genSimpleModuleArgs(1, 1, 1, t)
+	moduleArgs.Selectors = map[module.Type]module.Selector{
+		module.TYPE_DOWNLOADER: &flakySelector{failures: 3},
+	}
+	sched := NewScheduler()
+	if err := sched.Init(genRequestArgs([]string{}, 1), genDataArgs(10, 2, 1), moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL, nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	// The first request is requeued until a downloader is selected.
+	errChan := sched.ErrorChan()
+	var errs []error
+	begin := time.Now()
+	for moduleArgs.Analyzers[0].CalledCount() < 6 || !sched.Idle() {
+		if time.Since(begin) > 10*time.Second {
+			t.Fatalf("The requests have not been downloaded, analyzed: %d, errors: %v",
+				moduleArgs.Analyzers[0].CalledCount(), errs)
+		}
+		select {
+		case err := <-errChan:
+			errs = append(errs, err)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if len(errs) != 3 {
+		t.Fatalf("Inconsistent error number, expected: 3, actual: %d (%v)", len(errs), errs)
+	}
+	for _, err := range errs {
+		if !strings.Contains(err.Error(), "could not get a downloader") {
+			t.Fatalf("Inconsistent error when no downloader is selected: %s", err)
+		}
+	}
+	mySched := sched.(*myScheduler)
+	if pending := mySched.frontier.Len(); pending != 0 {
+		t.Fatalf("Inconsistent pending request number, expected: 0, actual: %d", pending)
+	}
+}
+
+// serveRemote serves the module, and returns the MID of its stub with the
+// serial number.
+func serveRemote(m module.Module, letter string, sn int, t *testing.T) (module.MID, *httptest.Server) {
+	handler, err := remote.NewServer(m, remote.ServerArgs{})
+	if err != nil {
+		t.Fatalf("An error occurs when creating a module server: %s", err)
+	}
+	server := httptest.NewServer(handler)
+	return module.MID(fmt.Sprintf("%s%d|%s", letter, sn, strings.TrimPrefix(server.URL, "http://"))), server
+}
+
+func TestSchedRemoteModules(t *testing.T) {
+	server := genDrainServer(5)
+	defer server.Close()
+	localArgs := genSimpleModuleArgs(2, 1, 1, t)
+	moduleArgs := ModuleArgs{Pipelines: localArgs.Pipelines}
+	for i, d := range localArgs.Downloaders {
+		mid, moduleServer := serveRemote(d, "D", 10+i, t)
+		defer moduleServer.Close()
+		stub, err := remote.NewDownloader(mid, "http", nil, nil)
+		if err != nil {
+			t.Fatalf("An error occurs when creating a remote downloader: %s", err)
+		}
+		moduleArgs.Downloaders = append(moduleArgs.Downloaders, stub)
+	}
+	mid, moduleServer := serveRemote(localArgs.Analyzers[0], "A", 10, t)
+	defer moduleServer.Close()
+	stub, err := remote.NewAnalyzer(mid, "http", nil, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a remote analyzer: %s", err)
+	}
+	moduleArgs.Analyzers = []module.Analyzer{stub}
+	sched := startDrainSched(server.URL, moduleArgs, t)
+	defer sched.Stop()
+	begin := time.Now()
+	for !sched.Idle() || time.Since(begin) < 100*time.Millisecond {
+		if time.Since(begin) > 10*time.Second {
+			t.Fatal("The scheduler has not been idle")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	var downloaded uint64
+	for _, d := range localArgs.Downloaders {
+		downloaded += d.CompletedCount()
+	}
+	if downloaded != 6 || localArgs.Analyzers[0].CompletedCount() != 6 {
+		t.Fatalf("Inconsistent remote counts, downloaded: %d, analyzed: %d",
+			downloaded, localArgs.Analyzers[0].CompletedCount())
+	}
+	if picked := localArgs.Pipelines[0].CalledCount(); picked != 5 {
+		t.Fatalf("Inconsistent picked item number, expected: 5, actual: %d", picked)
+	}
+}
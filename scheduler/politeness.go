@@ -0,0 +1,377 @@
+package scheduler
+
+import (
+	"container/heap"
+	"context"
+	"strings"
+	"sync"
+	"time"
+	"webcrawler/module"
+)
+
+type queuedRequest struct {
+	req  *module.Request
+	rank int
+	seq  uint64
+}
+
+// before reports whether qr should be served before another one: the higher
+// rank first, then the earlier one.
+func (qr queuedRequest) before(another queuedRequest) bool {
+	if qr.rank != another.rank {
+		return qr.rank > another.rank
+	}
+	return qr.seq < another.seq
+}
+
+type requestHeap []queuedRequest
+
+func (h requestHeap) Len() int           { return len(h) }
+func (h requestHeap) Less(i, j int) bool { return h[i].before(h[j]) }
+func (h requestHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *requestHeap) Push(x interface{}) {
+	*h = append(*h, x.(queuedRequest))
+}
+
+func (h *requestHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	qr := old[n-1]
+	old[n-1] = queuedRequest{}
+	*h = old[:n-1]
+	return qr
+}
+
+const (
+	defaultMaxQueued        = 10000
+	defaultMaxQueuedPerHost = 1000
+	defaultMaxCrawlDelay    = time.Minute
+	// politenessPollInterval is the interval of checking a host which has
+	// reached the max connections for a fetch out of the queues.
+	politenessPollInterval = 10 * time.Millisecond
+)
+
+type hostQueue struct {
+	reqs requestHeap
+	// parked is the requests of the host beyond the bounds of the queues in
+	// the order they are pushed.
+	parked   []*module.Request
+	inFlight uint32
+	nextTime time.Time
+}
+
+// politeness holds the requests taken from the request buffer pool or pushed
+// by the stages in per-host priority queues, so that a host which has to wait does not block
+// others. Among the eligible hosts the request with the highest priority is
+// served first. A queue holds at most maxQueuedPerHost requests, and the
+// queues hold at most maxQueued requests in total unless a host has none.
+// The requests beyond the bounds are parked by host, and moved into the queue
+// of their host in order once it has room, so a slow host only holds its own
+// requests.
+type politeness struct {
+	strategy         crawlStrategy
+	minDelay         time.Duration
+	maxInFlight      uint32
+	maxQueued        uint64
+	maxQueuedPerHost int
+	maxCrawlDelay    time.Duration
+	hosts            map[string]*hostQueue
+	delays           map[string]time.Duration
+	seq              uint64
+	queued           uint64
+	parked           uint64
+	inFlight         uint64
+	lock             sync.Mutex
+	signal           chan struct{}
+}
+
+func newPoliteness(args PolitenessArgs, strategy crawlStrategy) *politeness {
+	p := &politeness{
+		strategy:         strategy,
+		minDelay:         args.MinDelay,
+		maxInFlight:      args.MaxConnsPerHost,
+		maxQueued:        uint64(args.MaxQueued),
+		maxQueuedPerHost: int(args.MaxQueuedPerHost),
+		maxCrawlDelay:    args.MaxCrawlDelay,
+		hosts:            map[string]*hostQueue{},
+		delays:           map[string]time.Duration{},
+		signal:           make(chan struct{}, 1),
+	}
+	if p.maxQueued == 0 {
+		p.maxQueued = defaultMaxQueued
+	}
+	if p.maxQueuedPerHost == 0 {
+		p.maxQueuedPerHost = defaultMaxQueuedPerHost
+	}
+	if p.maxCrawlDelay == 0 {
+		p.maxCrawlDelay = defaultMaxCrawlDelay
+	}
+	return p
+}
+
+func hostKey(req *module.Request) string {
+	httpReq := req.HTTPReq()
+	if httpReq == nil || httpReq.URL == nil {
+		return ""
+	}
+	return strings.ToLower(httpReq.URL.Host)
+}
+
+func (p *politeness) notify() {
+	select {
+	case p.signal <- struct{}{}:
+	default:
+	}
+}
+
+// hasRoom reports whether the queue of the host has room for another
+// request.
+func (p *politeness) hasRoom(hq *hostQueue) bool {
+	if len(hq.reqs) == 0 {
+		return true
+	}
+	return len(hq.reqs) < p.maxQueuedPerHost && p.queued < p.maxQueued
+}
+
+// unpark moves the parked requests of the host into its queue while it has
+// room.
+func (p *politeness) unpark(hq *hostQueue) {
+	for len(hq.parked) > 0 && p.hasRoom(hq) {
+		req := hq.parked[0]
+		hq.parked[0] = nil
+		hq.parked = hq.parked[1:]
+		p.parked--
+		p.enqueue(hq, req)
+	}
+	if len(hq.parked) == 0 {
+		hq.parked = nil
+	}
+}
+
+func (p *politeness) enqueue(hq *hostQueue, req *module.Request) {
+	rank := req.Priority()
+	if p.strategy.rank != nil {
+		rank = p.strategy.rank(req)
+	}
+	p.seq++
+	seq := p.seq
+	if p.strategy.lifo {
+		seq = ^seq
+	}
+	heap.Push(&hq.reqs, queuedRequest{req: req, rank: rank, seq: seq})
+	p.queued++
+}
+
+func (p *politeness) push(req *module.Request) {
+	if req == nil {
+		return
+	}
+	key := hostKey(req)
+	p.lock.Lock()
+	hq, ok := p.hosts[key]
+	if !ok {
+		hq = &hostQueue{}
+		p.hosts[key] = hq
+	}
+	if len(hq.parked) == 0 && p.hasRoom(hq) {
+		p.enqueue(hq, req)
+	} else {
+		hq.parked = append(hq.parked, req)
+		p.parked++
+	}
+	p.lock.Unlock()
+	p.notify()
+}
+
+// postpone puts the request back into its host queue, and holds the host
+// until the time.
+func (p *politeness) postpone(req *module.Request, until time.Time) {
+	p.push(req)
+	p.lock.Lock()
+	if hq, ok := p.hosts[hostKey(req)]; ok && hq.nextTime.Before(until) {
+		hq.nextTime = until
+	}
+	p.lock.Unlock()
+}
+
+func (p *politeness) next(ctx context.Context) (*module.Request, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		p.lock.Lock()
+		req, wait := p.pick(time.Now())
+		p.lock.Unlock()
+		if req != nil {
+			p.notify()
+			return req, nil
+		}
+		var timer *time.Timer
+		var timeoutCh <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			timeoutCh = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-p.signal:
+		case <-timeoutCh:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (p *politeness) pick(now time.Time) (*module.Request, time.Duration) {
+	var selected *hostQueue
+	var selectedKey string
+	var wait time.Duration
+	for key, hq := range p.hosts {
+		p.unpark(hq)
+		if len(hq.reqs) == 0 {
+			if hq.inFlight == 0 && !now.Before(hq.nextTime) {
+				delete(p.hosts, key)
+			}
+			continue
+		}
+		if p.maxInFlight > 0 && hq.inFlight >= p.maxInFlight {
+			continue
+		}
+		if now.Before(hq.nextTime) {
+			if d := hq.nextTime.Sub(now); wait == 0 || d < wait {
+				wait = d
+			}
+			continue
+		}
+		if selected == nil || hq.reqs[0].before(selected.reqs[0]) {
+			selected = hq
+			selectedKey = key
+		}
+	}
+	if selected == nil {
+		return nil, wait
+	}
+	req := heap.Pop(&selected.reqs).(queuedRequest).req
+	selected.inFlight++
+	selected.nextTime = now.Add(p.delay(selectedKey))
+	p.queued--
+	p.inFlight++
+	p.unpark(selected)
+	return req, 0
+}
+
+// delay returns the delay between the requests of the host.
+func (p *politeness) delay(host string) time.Duration {
+	delay := p.minDelay
+	if hostDelay := p.delays[host]; hostDelay > delay {
+		delay = hostDelay
+	}
+	return delay
+}
+
+// acquire waits until the host is eligible for a fetch out of the queues,
+// e.g. of a sitemap, which is then counted like a picked request of the host
+// until release is called. The host is polled while it has reached the max
+// connections.
+func (p *politeness) acquire(ctx context.Context, host string) error {
+	host = strings.ToLower(host)
+	for {
+		p.lock.Lock()
+		hq, ok := p.hosts[host]
+		if !ok {
+			hq = &hostQueue{}
+			p.hosts[host] = hq
+		}
+		now := time.Now()
+		wait := politenessPollInterval
+		if p.maxInFlight == 0 || hq.inFlight < p.maxInFlight {
+			if !now.Before(hq.nextTime) {
+				hq.inFlight++
+				hq.nextTime = now.Add(p.delay(host))
+				p.lock.Unlock()
+				return nil
+			}
+			wait = hq.nextTime.Sub(now)
+		}
+		p.lock.Unlock()
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// release ends the fetch started by acquire.
+func (p *politeness) release(host string) {
+	p.lock.Lock()
+	if hq, ok := p.hosts[strings.ToLower(host)]; ok && hq.inFlight > 0 {
+		hq.inFlight--
+	}
+	p.lock.Unlock()
+	p.notify()
+}
+
+func (p *politeness) done(req *module.Request) {
+	if req == nil {
+		return
+	}
+	p.lock.Lock()
+	if hq, ok := p.hosts[hostKey(req)]; ok && hq.inFlight > 0 {
+		hq.inFlight--
+		p.inFlight--
+	}
+	p.lock.Unlock()
+	p.notify()
+}
+
+// setDelay sets the delay of the host, e.g. the Crawl-delay in robots.txt,
+// which is capped by the max crawl delay. The next request of the host waits
+// for the delay.
+func (p *politeness) setDelay(host string, delay time.Duration) {
+	if delay > p.maxCrawlDelay {
+		delay = p.maxCrawlDelay
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.delays[host] = delay
+	if hq, ok := p.hosts[host]; ok {
+		if next := time.Now().Add(delay); hq.nextTime.Before(next) {
+			hq.nextTime = next
+		}
+	}
+}
+
+func (p *politeness) clear() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.hosts = map[string]*hostQueue{}
+	p.delays = map[string]time.Duration{}
+	p.queued = 0
+	p.parked = 0
+	p.inFlight = 0
+}
+
+func (p *politeness) total() uint64 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.queued + p.parked + p.inFlight
+}
+
+func (p *politeness) summary() HostQueueSummaryStruct {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return HostQueueSummaryStruct{
+		HostNumber: uint64(len(p.hosts)),
+		Queued:     p.queued,
+		Parked:     p.parked,
+		InFlight:   p.inFlight,
+	}
+}
@@ -0,0 +1,204 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+	"webcrawler/module"
+)
+
+func TestPolitenessDelay(t *testing.T) {
+	delay := 200 * time.Millisecond
+	p := newPoliteness(PolitenessArgs{MinDelay: delay}, crawlStrategy{})
+	reqs := genFrontierRequests(t, "http://a.com/1", "http://a.com/2", "http://b.com/1")
+	for _, req := range reqs {
+		p.push(req)
+	}
+	if p.total() != 3 {
+		t.Fatalf("Inconsistent total, expected: %d, actual: %d", 3, p.total())
+	}
+	ctx := context.Background()
+	begin := time.Now()
+	first, _ := p.next(ctx)
+	second, _ := p.next(ctx)
+	if first != reqs[0] {
+		t.Fatalf("Inconsistent first request, expected: %s, actual: %s", reqs[0].HTTPReq().URL, first.HTTPReq().URL)
+	}
+	if second != reqs[2] {
+		t.Fatalf("The other host has been blocked, expected: %s, actual: %s", reqs[2].HTTPReq().URL, second.HTTPReq().URL)
+	}
+	if elapsed := time.Since(begin); elapsed >= delay {
+		t.Fatalf("The request of another host has been delayed for %s", elapsed)
+	}
+	third, _ := p.next(ctx)
+	if third != reqs[1] {
+		t.Fatalf("Inconsistent third request, expected: %s, actual: %s", reqs[1].HTTPReq().URL, third.HTTPReq().URL)
+	}
+	if elapsed := time.Since(begin); elapsed < delay {
+		t.Fatalf("The min delay has not been enforced, elapsed: %s, expected at least: %s", elapsed, delay)
+	}
+	summary := p.summary()
+	if summary.Queued != 0 || summary.InFlight != 3 {
+		t.Fatalf("Inconsistent host queue summary: %#v", summary)
+	}
+	for _, req := range reqs {
+		p.done(req)
+	}
+	if p.total() != 0 {
+		t.Fatalf("Inconsistent total, expected: %d, actual: %d", 0, p.total())
+	}
+}
+
+func TestPolitenessMaxConns(t *testing.T) {
+	p := newPoliteness(PolitenessArgs{MaxConnsPerHost: 1}, crawlStrategy{})
+	reqs := genFrontierRequests(t, "http://a.com/1", "http://a.com/2")
+	for _, req := range reqs {
+		p.push(req)
+	}
+	first, err := p.next(context.Background())
+	if err != nil || first != reqs[0] {
+		t.Fatalf("Could not get the first request: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if req, err := p.next(ctx); err == nil {
+		t.Fatalf("It can still get the request %s beyond the max connections per host", req.HTTPReq().URL)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		p.done(first)
+	}()
+	second, err := p.next(context.Background())
+	if err != nil || second != reqs[1] {
+		t.Fatalf("Could not get the second request after release: %v", err)
+	}
+	p.clear()
+	if p.total() != 0 {
+		t.Fatalf("Inconsistent total after clear, expected: %d, actual: %d", 0, p.total())
+	}
+}
+
+func TestPolitenessAcquire(t *testing.T) {
+	delay := 100 * time.Millisecond
+	p := newPoliteness(PolitenessArgs{MinDelay: delay, MaxConnsPerHost: 1}, crawlStrategy{})
+	reqs := genFrontierRequests(t, "http://a.com/1")
+	p.push(reqs[0])
+	first, _ := p.next(context.Background())
+	// The fetch out of the queues waits for the request of the host.
+	ctx, cancel := context.WithTimeout(context.Background(), 2*delay)
+	defer cancel()
+	if err := p.acquire(ctx, "A.com"); err == nil {
+		t.Fatal("The host has been acquired beyond the max connections per host")
+	}
+	p.done(first)
+	begin := time.Now()
+	if err := p.acquire(context.Background(), "a.com"); err != nil {
+		t.Fatalf("An error occurs when acquiring the host: %s", err)
+	}
+	if elapsed := time.Since(begin); elapsed > delay {
+		t.Fatalf("The host has been acquired after %s", elapsed)
+	}
+	// The request of the host waits for the fetch and the delay after it.
+	p.push(genFrontierRequests(t, "http://a.com/2")[0])
+	go func() {
+		time.Sleep(delay / 2)
+		p.release("a.com")
+	}()
+	if _, err := p.next(context.Background()); err != nil {
+		t.Fatalf("An error occurs when getting the request: %s", err)
+	}
+	if elapsed := time.Since(begin); elapsed < delay {
+		t.Fatalf("The min delay after the fetch has not been enforced, elapsed: %s", elapsed)
+	}
+}
+
+func TestPolitenessPriority(t *testing.T) {
+	reqs := genFrontierRequests(t, "http://a.com/1", "http://a.com/2", "http://b.com/1", "http://b.com/2")
+	prioritized := []*module.Request{
+		module.NewRequestWithPriority(reqs[0].HTTPReq(), 0, 1),
+		module.NewRequestWithPriority(reqs[1].HTTPReq(), 1, 5),
+		module.NewRequestWithPriority(reqs[2].HTTPReq(), 2, 3),
+		module.NewRequestWithPriority(reqs[3].HTTPReq(), 3, 5),
+	}
+	p := newPoliteness(PolitenessArgs{}, crawlStrategy{})
+	for _, req := range prioritized {
+		p.push(req)
+	}
+	ctx := context.Background()
+	for _, index := range []int{1, 3, 2, 0} {
+		req, err := p.next(ctx)
+		if err != nil || req != prioritized[index] {
+			t.Fatalf("Inconsistent request order, expected: %s, actual: %s (error: %v)",
+				prioritized[index].HTTPReq().URL, req.HTTPReq().URL, err)
+		}
+	}
+	depthFirst := func(req *module.Request) int {
+		return int(req.Depth())
+	}
+	p = newPoliteness(PolitenessArgs{}, crawlStrategy{rank: depthFirst})
+	for _, req := range prioritized {
+		p.push(req)
+	}
+	for _, index := range []int{3, 2, 1, 0} {
+		req, err := p.next(ctx)
+		if err != nil || req != prioritized[index] {
+			t.Fatalf("Inconsistent request order with scorer, expected: %s, actual: %s (error: %v)",
+				prioritized[index].HTTPReq().URL, req.HTTPReq().URL, err)
+		}
+	}
+}
+
+func TestPolitenessBounds(t *testing.T) {
+	p := newPoliteness(PolitenessArgs{MaxQueued: 3, MaxQueuedPerHost: 2, MaxCrawlDelay: time.Second}, crawlStrategy{})
+	reqs := genFrontierRequests(t, "http://a.com/1", "http://a.com/2", "http://a.com/3", "http://b.com/1",
+		"http://b.com/2", "http://c.com/1")
+	for _, req := range reqs {
+		p.push(req)
+	}
+	// a.com/3 is beyond the queue of a.com, b.com/2 is beyond all the queues,
+	// and c.com/1 is the first one of c.com.
+	summary := p.summary()
+	if summary.Queued != 4 || summary.Parked != 2 {
+		t.Fatalf("Inconsistent queued and parked numbers, expected: %d and %d, actual: %d and %d",
+			4, 2, summary.Queued, summary.Parked)
+	}
+	if total := p.total(); total != uint64(len(reqs)) {
+		t.Fatalf("Inconsistent total request number, expected: %d, actual: %d", len(reqs), total)
+	}
+	// The slow host holds only its own requests.
+	p.setDelay("a.com", time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	fetched := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		req, err := p.next(ctx)
+		if err != nil {
+			t.Fatalf("An error occurs when taking a request: %s (fetched: %v)", err, fetched)
+		}
+		fetched[req.HTTPReq().URL.String()] = true
+		p.done(req)
+	}
+	for _, url := range []string{"http://b.com/1", "http://b.com/2", "http://c.com/1"} {
+		if !fetched[url] {
+			t.Fatalf("The request %s has not been taken behind the slow host (fetched: %v)", url, fetched)
+		}
+	}
+	summary = p.summary()
+	if summary.Queued != 2 || summary.Parked != 1 {
+		t.Fatalf("Inconsistent queued and parked numbers, expected: %d and %d, actual: %d and %d",
+			2, 1, summary.Queued, summary.Parked)
+	}
+	if delay := p.delays["a.com"]; delay != time.Second {
+		t.Fatalf("Inconsistent capped crawl delay, expected: %s, actual: %s", time.Second, delay)
+	}
+	p.clear()
+	if len(p.delays) != 0 {
+		t.Fatalf("The crawl delays have not been cleared: %v", p.delays)
+	}
+	p = newPoliteness(PolitenessArgs{}, crawlStrategy{})
+	if p.maxQueued != defaultMaxQueued || p.maxQueuedPerHost != defaultMaxQueuedPerHost ||
+		p.maxCrawlDelay != defaultMaxCrawlDelay {
+		t.Fatalf("Inconsistent default bounds, max queued: %d, max queued per host: %d, max crawl delay: %s",
+			p.maxQueued, p.maxQueuedPerHost, p.maxCrawlDelay)
+	}
+}
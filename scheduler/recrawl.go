@@ -0,0 +1,247 @@
+package scheduler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+	"webcrawler/module"
+	"webcrawler/toolkit/recrawl"
+)
+
+// maxTrackedBodySize is the max size of the body of a page whose changes are
+// tracked, since the body is read into memory for hashing.
+const maxTrackedBodySize = 32 << 20
+
+// recrawler remembers the fetched URLs in an incremental crawl, and skips
+// the ones not due for a revisit.
+type recrawler struct {
+	store     recrawl.Store
+	policy    recrawl.Policy
+	now       func() time.Time
+	newPages  uint64
+	changed   uint64
+	unchanged uint64
+	skipped   uint64
+	scheduled uint64
+}
+
+func newRecrawler(args RecrawlArgs) *recrawler {
+	return &recrawler{store: args.Store, policy: args.policy(), now: time.Now}
+}
+
+func (r *recrawler) enabled() bool {
+	return r.store != nil
+}
+
+// skip reports whether the URL of the request has been fetched and is not
+// due yet.
+func (r *recrawler) skip(req *module.Request) bool {
+	if !r.enabled() {
+		return false
+	}
+	record, ok := r.store.Get(req.HTTPReq().URL.String())
+	if !ok || record.Due(r.now()) {
+		return false
+	}
+	atomic.AddUint64(&r.skipped, 1)
+	return true
+}
+
+// expedite makes the record of the URL due if the page has been modified
+// after the last fetch, e.g. by the lastmod in a sitemap, and reports
+// whether it was not due.
+func (r *recrawler) expedite(url string, lastMod time.Time) bool {
+	if !r.enabled() || lastMod.IsZero() {
+		return false
+	}
+	now := r.now()
+	record, ok := r.store.Get(url)
+	if !ok || !lastMod.After(record.LastFetch) || record.Due(now) {
+		return false
+	}
+	record.NextFetch = now
+	if err := r.store.Put(record); err != nil {
+		logger.Warnf("Could not expedite the recrawl record: %s (URL: %s)", err, url)
+		return false
+	}
+	return true
+}
+
+// due returns the requests of the URLs due for a revisit.
+func (r *recrawler) due() []*module.Request {
+	if !r.enabled() {
+		return nil
+	}
+	now := r.now()
+	var reqs []*module.Request
+	r.store.Range(func(record recrawl.Record) bool {
+		if !record.Due(now) {
+			return true
+		}
+		httpReq, err := http.NewRequest(http.MethodGet, record.URL, nil)
+		if err != nil {
+			logger.Warnf("Ignore the recrawl record with illegal URL: %s (URL: %s)", err, record.URL)
+			return true
+		}
+		reqs = append(reqs, module.NewRequest(httpReq, record.Depth))
+		return true
+	})
+	atomic.AddUint64(&r.scheduled, uint64(len(reqs)))
+	return reqs
+}
+
+// addValidators makes the request conditional with the validators of the
+// last fetch.
+func (r *recrawler) addValidators(req *module.Request) {
+	if !r.enabled() {
+		return
+	}
+	httpReq := req.HTTPReq()
+	record, ok := r.store.Get(httpReq.URL.String())
+	if !ok || (record.ETag == "" && record.LastModified == "") {
+		return
+	}
+	header := httpReq.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	if record.ETag != "" && header.Get("If-None-Match") == "" {
+		header.Set("If-None-Match", record.ETag)
+	}
+	if record.LastModified != "" && header.Get("If-Modified-Since") == "" {
+		header.Set("If-Modified-Since", record.LastModified)
+	}
+	httpReq.Header = header
+}
+
+// check compares the response with the last fetch and updates the record.
+// The body of a successful response is read into memory for hashing, up to
+// maxTrackedBodySize. It returns an empty change for the untracked responses,
+// including the ones with larger bodies, and an error for a 304 response
+// without the record of the last fetch.
+func (r *recrawler) check(req *module.Request, resp *module.Response) (string, error) {
+	if !r.enabled() {
+		return "", nil
+	}
+	httpResp := resp.HTTPResp()
+	url := req.HTTPReq().URL.String()
+	record, exists := r.store.Get(url)
+	var hash string
+	switch {
+	case httpResp.StatusCode == http.StatusNotModified:
+		if !exists {
+			httpResp.Body.Close()
+			return "", fmt.Errorf("not modified response without the last fetch (URL: %s)", url)
+		}
+		hash = record.ContentHash
+	case httpResp.StatusCode >= 200 && httpResp.StatusCode < 300:
+		body, err := io.ReadAll(io.LimitReader(httpResp.Body, maxTrackedBodySize+1))
+		if err != nil {
+			httpResp.Body.Close()
+			return "", err
+		}
+		if len(body) > maxTrackedBodySize {
+			logger.Warnf("Do not track the change of the page larger than %d bytes (URL: %s)", maxTrackedBodySize, url)
+			httpResp.Body = readCloser{io.MultiReader(bytes.NewReader(body), httpResp.Body), httpResp.Body}
+			return "", nil
+		}
+		httpResp.Body.Close()
+		httpResp.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(body)
+		hash = hex.EncodeToString(sum[:])
+	default:
+		return "", nil
+	}
+	now := r.now()
+	change := module.CHANGE_UNCHANGED
+	if !exists {
+		change = module.CHANGE_NEW
+		record = recrawl.Record{URL: url, Depth: req.Depth(), FirstFetch: now}
+	} else if hash != record.ContentHash {
+		change = module.CHANGE_CHANGED
+	}
+	if req.Depth() < record.Depth {
+		record.Depth = req.Depth()
+	}
+	record.ContentHash = hash
+	if etag := httpResp.Header.Get("ETag"); etag != "" {
+		record.ETag = etag
+	}
+	if lastModified := httpResp.Header.Get("Last-Modified"); lastModified != "" {
+		record.LastModified = lastModified
+	}
+	record.LastFetch = now
+	record.Fetches++
+	switch change {
+	case module.CHANGE_NEW:
+		record.LastChange = now
+		atomic.AddUint64(&r.newPages, 1)
+	case module.CHANGE_CHANGED:
+		record.LastChange = now
+		record.Changes++
+		atomic.AddUint64(&r.changed, 1)
+	default:
+		atomic.AddUint64(&r.unchanged, 1)
+	}
+	r.policy.Schedule(&record, change != module.CHANGE_UNCHANGED, now)
+	return change, r.store.Put(record)
+}
+
+func (r *recrawler) summary() RecrawlSummaryStruct {
+	summary := RecrawlSummaryStruct{Enabled: r.enabled()}
+	if !summary.Enabled {
+		return summary
+	}
+	summary.Records = r.store.Len()
+	summary.New = atomic.LoadUint64(&r.newPages)
+	summary.Changed = atomic.LoadUint64(&r.changed)
+	summary.Unchanged = atomic.LoadUint64(&r.unchanged)
+	summary.Skipped = atomic.LoadUint64(&r.skipped)
+	summary.Scheduled = atomic.LoadUint64(&r.scheduled)
+	return summary
+}
+
+// trackChange checks the change of the page in an incremental crawl, and
+// reports whether the response should be analyzed. An item marked unchanged
+// is sent to the pipelines in place of the analysis of an unchanged page.
+func (sched *myScheduler) trackChange(req *module.Request, resp *module.Response) bool {
+	change, err := sched.recrawl.check(req, resp)
+	if err != nil {
+		sendError(err, "", sched.errSender)
+		if change == "" {
+			return false
+		}
+	}
+	switch change {
+	case "":
+		return true
+	case module.CHANGE_UNCHANGED:
+		resp.HTTPResp().Body.Close()
+		sched.putItem(unchangedItem(req))
+		return false
+	default:
+		sched.changes.Store(resp, change)
+		return true
+	}
+}
+
+// unchangedItem is sent to the pipelines in place of the analysis of the
+// unchanged page.
+func unchangedItem(req *module.Request) module.Item {
+	return module.Item{
+		module.ITEM_KEY_URL:    req.HTTPReq().URL.String(),
+		module.ITEM_KEY_CHANGE: module.CHANGE_UNCHANGED,
+		module.ITEM_KEY_META:   req.Meta(),
+	}
+}
+
+// readCloser reads from the reader and closes the closer.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
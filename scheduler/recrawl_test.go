@@ -0,0 +1,206 @@
+package scheduler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"webcrawler/module"
+	"webcrawler/module/local/analyzer"
+	"webcrawler/module/local/downloader"
+	"webcrawler/module/local/pipeline"
+	"webcrawler/toolkit/recrawl"
+)
+
+// changeCollector collects the changes of the items by URL.
+type changeCollector struct {
+	changes map[string]string
+	lock    sync.Mutex
+}
+
+func (c *changeCollector) process(item module.Item) (module.Item, error) {
+	url, _ := item["url"].(string)
+	if url == "" {
+		url, _ = item[module.ITEM_KEY_URL].(string)
+	}
+	change, _ := item.Change()
+	c.lock.Lock()
+	c.changes[url] = change
+	c.lock.Unlock()
+	return item, nil
+}
+
+var recrawlHrefPattern = regexp.MustCompile(`href="([^"]+)"`)
+
+func parseRecrawlPage(httpResp *http.Response, respDepth uint32) ([]module.Data, []error) {
+	defer httpResp.Body.Close()
+	buf := make([]byte, 4096)
+	n, _ := httpResp.Body.Read(buf)
+	dataList := []module.Data{module.Item{"url": httpResp.Request.URL.String()}}
+	for _, match := range recrawlHrefPattern.FindAllStringSubmatch(string(buf[:n]), -1) {
+		ref, _ := httpResp.Request.URL.Parse(match[1])
+		httpReq, _ := http.NewRequest(http.MethodGet, ref.String(), nil)
+		dataList = append(dataList, module.NewRequest(httpReq, respDepth+1))
+	}
+	return dataList, nil
+}
+
+func runRecrawl(serverURL string, args RecrawlArgs, t *testing.T) (map[string]string, RecrawlSummaryStruct) {
+	collector := &changeCollector{changes: map[string]string{}}
+	d, _ := downloader.New("D1", &http.Client{}, nil)
+	a, _ := analyzer.New("A2", []module.ParseResponse{parseRecrawlPage}, nil)
+	p, _ := pipeline.New("P3", []module.ProcessItem{collector.process}, nil)
+	requestArgs := genRequestArgs([]string{}, 10)
+	requestArgs.Recrawl = args
+	sched := NewScheduler()
+	moduleArgs := ModuleArgs{
+		Downloaders: []module.Downloader{d},
+		Analyzers:   []module.Analyzer{a},
+		Pipelines:   []module.Pipeline{p},
+	}
+	if err := sched.Init(requestArgs, genDataArgs(10, 2, 1), moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest(http.MethodGet, serverURL+"/", nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	begin := time.Now()
+	for !sched.Idle() || time.Since(begin) < 200*time.Millisecond {
+		if time.Since(begin) > 10*time.Second {
+			t.Fatal("The scheduler has not been idle")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return collector.changes, sched.Summary().Struct().Recrawl
+}
+
+func TestSchedRecrawl(t *testing.T) {
+	var version int32 = 1
+	var conditional int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<a href="/a">a</a><a href="/b">b</a>`)
+		case "/a":
+			if r.Header.Get("If-None-Match") == `"a1"` {
+				atomic.AddInt32(&conditional, 1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"a1"`)
+			fmt.Fprint(w, `<a href="/">home</a>`)
+		case "/b":
+			fmt.Fprintf(w, `<a href="/">home</a> version %d`, atomic.LoadInt32(&version))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	store := recrawl.NewMemory()
+	// Every URL is due right after being fetched.
+	args := RecrawlArgs{
+		Store: store,
+		Policy: recrawl.Policy{
+			InitialInterval: time.Nanosecond,
+			MinInterval:     time.Nanosecond,
+			MaxInterval:     time.Hour,
+			Factor:          2,
+		},
+	}
+	changes, summary := runRecrawl(server.URL, args, t)
+	expected := map[string]string{
+		server.URL + "/":  module.CHANGE_NEW,
+		server.URL + "/a": module.CHANGE_NEW,
+		server.URL + "/b": module.CHANGE_NEW,
+	}
+	checkChanges := func(changes map[string]string, summary RecrawlSummaryStruct) {
+		if len(changes) != len(expected) {
+			t.Fatalf("Inconsistent changes, expected: %v, actual: %v", expected, changes)
+		}
+		for url, change := range expected {
+			if changes[url] != change {
+				t.Fatalf("Inconsistent change of %s, expected: %q, actual: %q", url, change, changes[url])
+			}
+		}
+		if !summary.Enabled || summary.Records != 3 {
+			t.Fatalf("Inconsistent recrawl summary: %#v", summary)
+		}
+	}
+	checkChanges(changes, summary)
+	if summary.New != 3 || summary.Scheduled != 0 {
+		t.Fatalf("Inconsistent recrawl summary: %#v", summary)
+	}
+	atomic.StoreInt32(&version, 2)
+	changes, summary = runRecrawl(server.URL, args, t)
+	expected[server.URL+"/"] = module.CHANGE_UNCHANGED
+	expected[server.URL+"/a"] = module.CHANGE_UNCHANGED
+	expected[server.URL+"/b"] = module.CHANGE_CHANGED
+	checkChanges(changes, summary)
+	if summary.Changed != 1 || summary.Unchanged != 2 || summary.Scheduled != 3 {
+		t.Fatalf("Inconsistent recrawl summary: %#v", summary)
+	}
+	if n := atomic.LoadInt32(&conditional); n != 1 {
+		t.Fatalf("Inconsistent conditional request number, expected: 1, actual: %d", n)
+	}
+	record, _ := store.Get(server.URL + "/a")
+	if record.Fetches != 2 || record.Changes != 0 || record.ETag != `"a1"` {
+		t.Fatalf("Inconsistent record: %#v", record)
+	}
+	record, _ = store.Get(server.URL + "/b")
+	if record.Fetches != 2 || record.Changes != 1 || record.Depth != 1 {
+		t.Fatalf("Inconsistent record: %#v", record)
+	}
+	// Nothing is due with the default policy.
+	args.Policy = recrawl.Policy{}
+	store.Range(func(record recrawl.Record) bool {
+		recrawl.DefaultPolicy().Schedule(&record, false, time.Now())
+		store.Put(record)
+		return true
+	})
+	// The seed is fetched even if it's not due, and its links are not
+	// followed since it's unchanged.
+	changes, summary = runRecrawl(server.URL, args, t)
+	if len(changes) != 1 || changes[server.URL+"/"] != module.CHANGE_UNCHANGED ||
+		summary.Unchanged != 1 || summary.Scheduled != 0 {
+		t.Fatalf("Inconsistent recrawl result, changes: %v, summary: %#v", changes, summary)
+	}
+	illegalArgs := genRequestArgs([]string{}, 0)
+	illegalArgs.Recrawl = RecrawlArgs{Store: store, Policy: recrawl.Policy{Factor: 2}}
+	if err := illegalArgs.Check(); err == nil {
+		t.Fatal("No error when checking illegal recrawl arguments")
+	}
+}
+
+func TestRecrawlCheck(t *testing.T) {
+	r := newRecrawler(RecrawlArgs{Store: recrawl.NewMemory()})
+	httpReq, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req := module.NewRequest(httpReq, 1)
+	httpResp := &http.Response{
+		StatusCode: http.StatusNotModified,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    httpReq,
+	}
+	if _, err := r.check(req, module.NewResponse(httpResp, 1)); err == nil {
+		t.Fatal("No error when checking a not modified response without record")
+	}
+	if r.store.Len() != 0 {
+		t.Fatalf("Inconsistent record number, expected: 0, actual: %d", r.store.Len())
+	}
+	httpResp.StatusCode = http.StatusOK
+	httpResp.Body = io.NopCloser(strings.NewReader("page"))
+	if change, err := r.check(req, module.NewResponse(httpResp, 1)); err != nil || change != module.CHANGE_NEW {
+		t.Fatalf("Inconsistent change, expected: %q, actual: %q (error: %v)", module.CHANGE_NEW, change, err)
+	}
+	if body, _ := io.ReadAll(httpResp.Body); string(body) != "page" {
+		t.Fatalf("Inconsistent body after checking, expected: %q, actual: %q", "page", body)
+	}
+}
@@ -0,0 +1,86 @@
+package scheduler
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+	"webcrawler/module"
+	"webcrawler/toolkit/robots"
+)
+
+const maxRejectedURLNumber = 100
+
+// maxRobotsFailures is the number of the failed fetches of robots.txt of a
+// host, after which its requests are rejected.
+const maxRobotsFailures = 5
+
+type robotsFilter struct {
+	cache        robots.Cache
+	rejected     uint64
+	rejectedURLs []string
+	postponed    uint64
+	lock         sync.Mutex
+}
+
+func newRobotsFilter(args RobotsArgs) *robotsFilter {
+	filter := &robotsFilter{}
+	if args.Enabled {
+		client := &http.Client{Timeout: 10 * time.Second}
+		filter.cache = robots.NewCache(client, args.UserAgent, args.Expiry)
+	}
+	return filter
+}
+
+func (filter *robotsFilter) enabled() bool {
+	return filter.cache != nil
+}
+
+// check checks the request against robots.txt of its host, fetching it if
+// needed. An *robots.UnavailableError is returned while robots.txt is
+// unavailable, until it has failed maxRobotsFailures times, after which the
+// host is disallowed.
+func (filter *robotsFilter) check(req *module.Request) (bool, *robots.Rules, error) {
+	if !filter.enabled() {
+		return true, nil, nil
+	}
+	reqURL := req.HTTPReq().URL
+	rules, err := filter.cache.Rules(reqURL.Scheme, reqURL.Host)
+	if err != nil {
+		var unavailable *robots.UnavailableError
+		if errors.As(err, &unavailable) && unavailable.Failures < maxRobotsFailures {
+			filter.lock.Lock()
+			filter.postponed++
+			filter.lock.Unlock()
+			return false, nil, err
+		}
+		rules = robots.DisallowAll()
+	}
+	allowed := rules.Allowed(reqURL)
+	if !allowed {
+		filter.lock.Lock()
+		filter.rejected++
+		filter.rejectedURLs = append(filter.rejectedURLs, reqURL.String())
+		if len(filter.rejectedURLs) > maxRejectedURLNumber {
+			filter.rejectedURLs = filter.rejectedURLs[len(filter.rejectedURLs)-maxRejectedURLNumber:]
+		}
+		filter.lock.Unlock()
+	}
+	return allowed, rules, nil
+}
+
+func (filter *robotsFilter) summary() RobotsSummaryStruct {
+	summary := RobotsSummaryStruct{}
+	if filter.enabled() {
+		summary.HostNumber = uint64(filter.cache.Len())
+	}
+	filter.lock.Lock()
+	defer filter.lock.Unlock()
+	summary.Rejected = filter.rejected
+	summary.Postponed = filter.postponed
+	if len(filter.rejectedURLs) > 0 {
+		summary.RejectedURLs = make([]string, len(filter.rejectedURLs))
+		copy(summary.RejectedURLs, filter.rejectedURLs)
+	}
+	return summary
+}
@@ -0,0 +1,104 @@
+package scheduler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+	"webcrawler/module"
+)
+
+func TestSchedRobots(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("User-agent: webcrawler\nDisallow: /secret\nCrawl-delay: 3\n"))
+			return
+		}
+		w.Write([]byte("<html></html>"))
+	}))
+	defer server.Close()
+	requestArgs := genRequestArgs([]string{}, 1)
+	requestArgs.Robots = RobotsArgs{Enabled: true, UserAgent: "webcrawler/1.0"}
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(1, 1, 1, t)
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL+"/", nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	mySched := sched.(*myScheduler)
+	secretHTTPReq, _ := http.NewRequest("GET", server.URL+"/secret/1", nil)
+	if !mySched.sendReq(module.NewRequest(secretHTTPReq, 1)) {
+		t.Fatalf("Could not send the request checked against robots.txt in the download stage (URL: %s)", secretHTTPReq.URL)
+	}
+	var summary RobotsSummaryStruct
+	for i := 0; ; i++ {
+		summary = sched.Summary().Struct().Robots
+		if summary.Rejected > 0 {
+			break
+		}
+		if i >= 500 {
+			t.Fatal("The request disallowed by robots.txt has not been rejected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if summary.Rejected != 1 || len(summary.RejectedURLs) != 1 || summary.RejectedURLs[0] != secretHTTPReq.URL.String() {
+		t.Fatalf("Inconsistent robots summary: %#v", summary)
+	}
+	if summary.HostNumber != 1 {
+		t.Fatalf("Inconsistent robots host number, expected: %d, actual: %d", 1, summary.HostNumber)
+	}
+	mySched.politeness.lock.Lock()
+	delay := mySched.politeness.delays[secretHTTPReq.URL.Host]
+	mySched.politeness.lock.Unlock()
+	if delay != 3*time.Second {
+		t.Fatalf("Inconsistent crawl delay from robots.txt, expected: %s, actual: %s", 3*time.Second, delay)
+	}
+}
+
+func TestSchedRobotsUnavailable(t *testing.T) {
+	var robotsCount, pageCount int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			if atomic.AddInt32(&robotsCount, 1) <= 2 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("User-agent: *\nDisallow: /secret\n"))
+			return
+		}
+		atomic.AddInt32(&pageCount, 1)
+		w.Write([]byte("<html></html>"))
+	}))
+	defer server.Close()
+	requestArgs := genRequestArgs([]string{}, 1)
+	requestArgs.Robots = RobotsArgs{Enabled: true, UserAgent: "webcrawler/1.0", Expiry: 20 * time.Millisecond}
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, genDataArgs(10, 2, 1), genSimpleModuleArgs(1, 1, 1, t)); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL+"/", nil)
+	begin := time.Now()
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Fatalf("The start has been blocked by robots.txt for %s", elapsed)
+	}
+	for i := 0; atomic.LoadInt32(&pageCount) == 0; i++ {
+		if i >= 500 {
+			t.Fatal("The request postponed while robots.txt is unavailable has not been downloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	summary := sched.Summary().Struct().Robots
+	if summary.Postponed != 2 || summary.Rejected != 0 {
+		t.Fatalf("Inconsistent robots summary: %#v", summary)
+	}
+}
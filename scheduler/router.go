@@ -0,0 +1,52 @@
+package scheduler
+
+import (
+	"context"
+	"webcrawler/module"
+)
+
+// Router partitions the requests among the schedulers of a distributed
+// crawl, which share the seen set.
+type Router interface {
+	// Local reports whether the request is crawled by this scheduler.
+	Local(req *module.Request) bool
+	// Forward sends the request, which has been added to the seen set, to
+	// the scheduler crawling it.
+	Forward(req *module.Request) error
+	// Serve calls accept with the requests forwarded from the other
+	// schedulers until the context is done.
+	Serve(ctx context.Context, accept func(req *module.Request))
+}
+
+// forward sends the request to its owner, or accepts it locally if the
+// forwarding fails, see acceptReq for seed.
+func (sched *myScheduler) forward(req *module.Request, seed bool) bool {
+	if err := sched.router.Forward(req); err != nil {
+		sendError(err, "", sched.errSender)
+		logger.Warnf("Accept the request locally since it could not be forwarded (URL: %s)", req.HTTPReq().URL)
+		return sched.acceptReq(req, false, seed)
+	}
+	return false
+}
+
+// receive accepts the request forwarded from another scheduler, or forwards
+// it again if it has been moved since.
+func (sched *myScheduler) receive(req *module.Request) {
+	if req == nil || !req.Valid() {
+		return
+	}
+	if !sched.router.Local(req) {
+		sched.forward(req, false)
+		return
+	}
+	sched.acceptReq(req, false, false)
+}
+
+// serveRouter starts receiving the forwarded requests if the scheduler is
+// distributed.
+func (sched *myScheduler) serveRouter() {
+	if sched.router == nil {
+		return
+	}
+	go sched.router.Serve(sched.acceptCtx, sched.receive)
+}
@@ -4,12 +4,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 	"webcrawler/helper/log"
 	"webcrawler/module"
 	"webcrawler/toolkit/buffer"
+	"webcrawler/toolkit/publicsuffix"
+	"webcrawler/toolkit/robots"
+	"webcrawler/toolkit/seenset"
+	"webcrawler/toolkit/urlnorm"
 )
 
 var logger = log.DLogger()
@@ -17,7 +24,21 @@ var logger = log.DLogger()
 type Scheduler interface {
 	Init(requestArgs RequestArgs, dataArgs DataArgs, moduleArgs ModuleArgs) (err error)
 	Start(firstHTTPReq *http.Request) (err error)
+	// StartSeeds starts the scheduler with the seeds, and accepts their
+	// primary domains.
+	StartSeeds(seeds []Seed) (err error)
+	// AddSeeds sends more seeds to the started or paused scheduler.
+	AddSeeds(seeds []Seed) (accepted int, err error)
+	// Restore starts the initialized or stopped scheduler with the pending
+	// requests in the frontier, and marks the visited ones as seen.
+	Restore() (err error)
+	// Resume resumes the paused scheduler.
+	Resume() (err error)
+	Pause() (err error)
 	Stop() (err error)
+	AddModule(m module.Module) (err error)
+	RemoveModule(mid module.MID, timeout time.Duration) (err error)
+	StopGracefully(timeout time.Duration) (result DrainResult, err error)
 	Status() Status
 	ErrorChan() <-chan error
 	Idle() bool
@@ -36,12 +57,47 @@ type myScheduler struct {
 	respBufferPool    buffer.Pool
 	itemBufferPool    buffer.Pool
 	errorBufferPool   buffer.Pool
-	urlMap            sync.Map
+	seenSet           seenset.SeenSet
+	sharedSeenSet     bool
+	router            Router
+	frontier          Frontier
+	politeness        *politeness
+	robots            *robotsFilter
+	sitemaps          *sitemapper
+	recrawl           *recrawler
+	scope             *scope
+	timeouts          TimeoutArgs
+	concurrency       ConcurrencyArgs
+	workers           ConcurrencyArgs
+	reqSender         *sender
+	respSender        *sender
+	itemSender        *sender
+	errSender         *sender
+	urlNorm           urlnorm.Options
 	ctx               context.Context
 	cancelFunc        context.CancelFunc
 	status            Status
 	statusLock        sync.RWMutex
 	summary           SchedSummary
+	// changes maps the responses of the new and changed pages to their
+	// changes in an incremental crawl until they are analyzed.
+	changes sync.Map
+	// acceptCtx is canceled once the scheduler stops accepting requests.
+	acceptCtx        context.Context
+	acceptCancelFunc context.CancelFunc
+	downloadDone     chan struct{}
+	// pendingResps and pendingItems count the responses and items which
+	// have been sent but not yet handled.
+	pendingResps int64
+	pendingItems int64
+	pickedItems  uint64
+	// seenSetFailed is set once the error of the seen set has been sent.
+	seenSetFailed uint32
+	// pauseGate holds the workers between data while paused.
+	pauseGate pauseGate
+	// moduleLock guards the selection of modules against their removal.
+	moduleLock  sync.RWMutex
+	moduleCalls sync.Map
 }
 
 func (sched *myScheduler) Init(requestArgs RequestArgs, dataArgs DataArgs, moduleArgs ModuleArgs) (err error) {
@@ -84,11 +140,43 @@ func (sched *myScheduler) Init(requestArgs RequestArgs, dataArgs DataArgs, modul
 	logger.Infof("-- Max depth: %d", sched.maxDepth)
 	sched.acceptedDomainMap = sync.Map{}
 	for _, domain := range requestArgs.AcceptedDomains {
-		sched.acceptedDomainMap.Store(domain, struct{}{})
+		// The primary domains are resolved in punycode.
+		sched.acceptedDomainMap.Store(publicsuffix.ToASCII(domain), struct{}{})
 	}
 	logger.Infof("-- Accepted primary domains: %v", requestArgs.AcceptedDomains)
-	sched.urlMap = sync.Map{}
+	if sched.scope, err = newScope(requestArgs.ScopeRules); err != nil {
+		return err
+	}
+	logger.Infof("-- Scope rules: %d", len(requestArgs.ScopeRules))
+	sched.router = requestArgs.Router
+	logger.Infof("-- Distributed: %v", sched.router != nil)
+	sched.timeouts = requestArgs.Timeouts
+	logger.Infof("-- Timeouts: download: %s, analyze: %s, pipeline: %s",
+		sched.timeouts.Download, sched.timeouts.Analyze, sched.timeouts.Pipeline)
+	sched.urlNorm = requestArgs.URLNorm
+	logger.Infof("-- URL normalization: enabled: %v", sched.urlNorm.Enabled())
+	strategy, err := newCrawlStrategy(requestArgs.CrawlStrategy, requestArgs.Scorer)
+	if err != nil {
+		return err
+	}
+	logger.Infof("-- Crawl strategy: %s", strategy.name)
+	sched.politeness = newPoliteness(requestArgs.Politeness, strategy)
+	logger.Infof("-- Politeness: min delay: %s, max connections per host: %d",
+		requestArgs.Politeness.MinDelay, requestArgs.Politeness.MaxConnsPerHost)
+	sched.robots = newRobotsFilter(requestArgs.Robots)
+	logger.Infof("-- Robots: enabled: %v, user agent: %q", requestArgs.Robots.Enabled, requestArgs.Robots.UserAgent)
+	sched.sitemaps = newSitemapper(requestArgs.Sitemaps, requestArgs.Robots, sched.robots, sched.politeness)
+	logger.Infof("-- Sitemaps: enabled: %v", sched.sitemaps.enabled())
+	sched.recrawl = newRecrawler(requestArgs.Recrawl)
+	logger.Infof("-- Incremental: %v", sched.recrawl.enabled())
+	if err = sched.initSeenSet(requestArgs.SeenSet); err != nil {
+		return err
+	}
+	if err = sched.initFrontier(dataArgs); err != nil {
+		return err
+	}
 	sched.initBufferPool(dataArgs)
+	sched.concurrency = dataArgs.Concurrency
 	sched.resetContext()
 	sched.summary = newSchedSummary(requestArgs, dataArgs, moduleArgs, sched)
 	logger.Info("Register modules")
@@ -100,6 +188,10 @@ func (sched *myScheduler) Init(requestArgs RequestArgs, dataArgs DataArgs, modul
 }
 
 func (sched *myScheduler) Start(firstHTTPReq *http.Request) (err error) {
+	return sched.StartSeeds([]Seed{{HTTPReq: firstHTTPReq}})
+}
+
+func (sched *myScheduler) StartSeeds(seeds []Seed) (err error) {
 	defer func() {
 		if p := recover(); p != nil {
 			errMsg := fmt.Sprintf("Fatal scheduler error %s", p)
@@ -123,30 +215,115 @@ func (sched *myScheduler) Start(firstHTTPReq *http.Request) (err error) {
 	if err != nil {
 		return
 	}
-	logger.Info("Check first HTTP request...")
-	if firstHTTPReq == nil {
-		err = genParameterError("nil first HTTP request")
+	logger.Info("Check seeds...")
+	var primaryDomains []string
+	primaryDomains, err = checkSeeds(seeds)
+	if err != nil {
+		return
+	}
+	logger.Infof("-- Seeds: %d, primary domains: %v", len(seeds), primaryDomains)
+	for _, domain := range primaryDomains {
+		sched.acceptedDomainMap.Store(domain, struct{}{})
+	}
+	if err = sched.checkBufferPoolForStart(); err != nil {
+		return
+	}
+	if err = sched.frontier.Clear(); err != nil {
+		err = genErrorByError(err)
+		return
+	}
+	if err = sched.clearSeenSet(); err != nil {
 		return
 	}
-	logger.Info("The first HTTP request is valid")
-	logger.Info("Get the primary domain...")
-	logger.Infof("-- Host: %s", firstHTTPReq.Host)
-	var primaryDomain string
-	primaryDomain, err = getPrimaryDomain(firstHTTPReq.Host)
+	sched.politeness.clear()
+	sched.sitemaps.clear()
+	sched.changes = sync.Map{}
+	sched.initSenders()
+	sched.download()
+	sched.analyze()
+	sched.pick()
+	sched.serveRouter()
+	logger.Info("Scheduler has been started")
+	for _, seed := range seeds {
+		sched.sendSeed(seed.request())
+	}
+	if dueReqs := sched.recrawl.due(); len(dueReqs) > 0 {
+		logger.Infof("Schedule %d URLs due for a revisit", len(dueReqs))
+		for _, req := range dueReqs {
+			sched.sendReq(req)
+		}
+	}
+	return nil
+}
+
+func (sched *myScheduler) Restore() (err error) {
+	logger.Info("Restore scheduler...")
+	logger.Info("Check status for restore...")
+	var oldStatus Status
+	oldStatus, err = sched.checkAndSetStatus(SCHED_STATUS_STARTING)
+	defer func() {
+		sched.statusLock.Lock()
+		if err != nil {
+			sched.status = oldStatus
+		} else {
+			sched.status = SCHED_STATUS_STARTED
+		}
+		sched.statusLock.Unlock()
+	}()
 	if err != nil {
 		return
 	}
-	logger.Infof("-- Primary domain: %s", primaryDomain)
-	sched.acceptedDomainMap.Store(primaryDomain, struct{}{})
 	if err = sched.checkBufferPoolForStart(); err != nil {
 		return
 	}
+	sched.politeness.clear()
+	sched.sitemaps.clear()
+	logger.Info("Restore requests from the frontier...")
+	var pendingReqs []*module.Request
+	var visitedNumber int
+	// The seen set is kept, since the memory frontier only restores the
+	// pending requests.
+	err = sched.frontier.Restore(func(req *module.Request, done bool) {
+		httpReq := req.HTTPReq()
+		sched.seenSet.Add(httpReq.URL.String())
+		visitedNumber++
+		if req.Depth() == 0 {
+			if pd, err := getPrimaryDomain(httpReq.Host); err == nil {
+				sched.acceptedDomainMap.Store(pd, struct{}{})
+			}
+		}
+		if !done {
+			pendingReqs = append(pendingReqs, req)
+		}
+	})
+	if err != nil {
+		err = genErrorByError(err)
+		return
+	}
+	logger.Infof("-- Visited URLs: %d, pending requests: %d", visitedNumber, len(pendingReqs))
+	sched.initSenders()
 	sched.download()
 	sched.analyze()
 	sched.pick()
-	logger.Info("Scheduler has been started")
-	firstReq := module.NewRequest(firstHTTPReq, 0)
-	sched.sendReq(firstReq)
+	sched.serveRouter()
+	logger.Info("Scheduler has been restored")
+	for _, req := range pendingReqs {
+		sched.putReq(req)
+	}
+	return nil
+}
+
+func (sched *myScheduler) Resume() (err error) {
+	logger.Info("Resume scheduler...")
+	logger.Info("Check status for resume...")
+	sched.statusLock.Lock()
+	defer sched.statusLock.Unlock()
+	if sched.status != SCHED_STATUS_PAUSED {
+		return genError("the scheduler has not been paused")
+	}
+	sched.status = SCHED_STATUS_STARTED
+	sched.pauseGate.resume()
+	logger.Info("Scheduler has been resumed")
 	return nil
 }
 
@@ -167,13 +344,38 @@ func (sched *myScheduler) Stop() (err error) {
 	if err != nil {
 		return
 	}
+	sched.shutdown()
+	sched.pauseGate.resume()
+	logger.Info("Scheduler has been stopped")
+	return nil
+}
+
+// Pause stops the workers from handling more data, and keeps the buffered
+// data for resuming. It does not wait for the data being handled, which are
+// finished in the background unless they wait for the full buffer pools.
+func (sched *myScheduler) Pause() (err error) {
+	logger.Info("Pause scheduler...")
+	logger.Info("Check status for pause")
+	if _, err = sched.checkAndSetStatus(SCHED_STATUS_PAUSING); err != nil {
+		return
+	}
+	sched.pauseGate.pause()
+	sched.statusLock.Lock()
+	// The scheduler may have been stopped meanwhile.
+	if sched.status == SCHED_STATUS_PAUSING {
+		sched.status = SCHED_STATUS_PAUSED
+	}
+	sched.statusLock.Unlock()
+	logger.Info("Scheduler has been paused")
+	return nil
+}
+
+func (sched *myScheduler) shutdown() {
 	sched.cancelFunc()
 	sched.reqBufferPool.Close()
 	sched.respBufferPool.Close()
 	sched.itemBufferPool.Close()
 	sched.errorBufferPool.Close()
-	logger.Info("Scheduler has been stopped")
-	return nil
 }
 
 func (sched *myScheduler) Status() Status {
@@ -187,13 +389,13 @@ func (sched *myScheduler) Status() Status {
 func (sched *myScheduler) ErrorChan() <-chan error {
 	errBuffer := sched.errorBufferPool
 	errCh := make(chan error, errBuffer.BufferCap())
-	go func(errBuffer buffer.Pool, errCh chan error) {
+	go func(ctx context.Context, errBuffer buffer.Pool, errCh chan error) {
 		for {
 			if sched.canceled() {
 				close(errCh)
 				break
 			}
-			datum, err := errBuffer.Get()
+			datum, err := errBuffer.Get(ctx)
 			if err != nil {
 				logger.Warnln("The error buffer pool was closed. Break error reception")
 				close(errCh)
@@ -202,7 +404,7 @@ func (sched *myScheduler) ErrorChan() <-chan error {
 			err, ok := datum.(error)
 			if !ok {
 				errMsg := fmt.Sprintf("incorrect error type: %T", datum)
-				sendError(errors.New(errMsg), "", sched.errorBufferPool)
+				sendError(errors.New(errMsg), "", sched.errSender)
 				continue
 			}
 			if sched.canceled() {
@@ -211,7 +413,7 @@ func (sched *myScheduler) ErrorChan() <-chan error {
 			}
 			errCh <- err
 		}
-	}(errBuffer, errCh)
+	}(sched.ctx, errBuffer, errCh)
 	return errCh
 }
 
@@ -222,7 +424,14 @@ func (sched *myScheduler) Idle() bool {
 			return false
 		}
 	}
+	if sched.reqSender.busy() ||
+		sched.sitemaps.busy() ||
+		atomic.LoadInt64(&sched.pendingResps) > 0 ||
+		atomic.LoadInt64(&sched.pendingItems) > 0 {
+		return false
+	}
 	if sched.reqBufferPool.Total() > 0 ||
+		sched.politeness.total() > 0 ||
 		sched.respBufferPool.Total() > 0 ||
 		sched.itemBufferPool.Total() > 0 {
 		return false
@@ -272,8 +481,90 @@ func (sched *myScheduler) initBufferPool(dataArgs DataArgs) {
 		sched.errorBufferPool.BufferCap(), sched.errorBufferPool.MaxBufferNumber())
 }
 
+func (sched *myScheduler) initSeenSet(args SeenSetArgs) (err error) {
+	if sched.seenSet != nil && !sched.sharedSeenSet {
+		if err = sched.seenSet.Close(); err != nil {
+			logger.Warnf("An error occurs when closing the seen set: %s", err)
+		}
+	}
+	sched.seenSet = nil
+	atomic.StoreUint32(&sched.seenSetFailed, 0)
+	sched.sharedSeenSet = args.Shared != nil
+	if sched.sharedSeenSet {
+		sched.seenSet = args.Shared
+		logger.Info("-- Seen set: shared")
+		return nil
+	}
+	switch args.Type {
+	case SEEN_SET_TYPE_BLOOM:
+		sched.seenSet, err = seenset.NewBloom(args.Capacity, args.FalsePositiveRate)
+	case SEEN_SET_TYPE_DISK:
+		sched.seenSet, err = seenset.NewDisk(args.Dir)
+	default:
+		sched.seenSet = seenset.NewExact()
+	}
+	if err != nil {
+		sched.seenSet = nil
+		return genErrorByError(err)
+	}
+	if args.Type == "" {
+		args.Type = SEEN_SET_TYPE_EXACT
+	}
+	logger.Infof("-- Seen set: %s", args.Type)
+	return nil
+}
+
+// clearSeenSet clears the seen set unless it's shared with the other
+// schedulers.
+func (sched *myScheduler) clearSeenSet() error {
+	atomic.StoreUint32(&sched.seenSetFailed, 0)
+	if sched.sharedSeenSet {
+		return nil
+	}
+	if err := sched.seenSet.Clear(); err != nil {
+		return genErrorByError(err)
+	}
+	return nil
+}
+
+// checkSeenSet sends the error of the seen set once, since it reports the
+// URLs as seen meanwhile.
+func (sched *myScheduler) checkSeenSet() {
+	err := seenset.Err(sched.seenSet)
+	if err != nil && atomic.CompareAndSwapUint32(&sched.seenSetFailed, 0, 1) {
+		sendError(fmt.Errorf("the seen set failed: %s", err), "", sched.errSender)
+	}
+}
+
+func (sched *myScheduler) initFrontier(dataArgs DataArgs) (err error) {
+	if sched.frontier != nil && sched.frontier != dataArgs.Frontier {
+		if err = sched.frontier.Close(); err != nil {
+			logger.Warnf("An error occurs when closing the frontier: %s", err)
+		}
+	}
+	switch {
+	case dataArgs.Frontier != nil:
+		sched.frontier = dataArgs.Frontier
+		logger.Infof("-- Frontier: %T", sched.frontier)
+	case dataArgs.FrontierDir != "":
+		sched.frontier, err = NewDiskFrontier(dataArgs.FrontierDir)
+		if err != nil {
+			sched.frontier = nil
+			return
+		}
+		logger.Infof("-- Frontier: disk (dir: %s, pending: %d)", dataArgs.FrontierDir, sched.frontier.Len())
+	default:
+		sched.frontier = NewMemoryFrontier()
+		logger.Info("-- Frontier: memory")
+	}
+	return nil
+}
+
 func (sched *myScheduler) resetContext() {
 	sched.ctx, sched.cancelFunc = context.WithCancel(context.Background())
+	sched.acceptCtx, sched.acceptCancelFunc = context.WithCancel(sched.ctx)
+	atomic.StoreInt64(&sched.pendingResps, 0)
+	atomic.StoreInt64(&sched.pendingItems, 0)
 }
 
 func (sched *myScheduler) registerModules(moduleArgs ModuleArgs) error {
@@ -319,6 +610,14 @@ func (sched *myScheduler) registerModules(moduleArgs ModuleArgs) error {
 		}
 	}
 	logger.Infof("All pipelines have been registered (number: %d)", len(moduleArgs.Pipelines))
+	for moduleType, selector := range moduleArgs.Selectors {
+		if err := sched.registrar.SetSelector(moduleType, selector); err != nil {
+			return genErrorByError(err)
+		}
+		if selector != nil {
+			logger.Infof("-- Selector of %s: %s", moduleType, selector.Name())
+		}
+	}
 	return nil
 }
 
@@ -351,12 +650,14 @@ func (sched *myScheduler) checkBufferPoolForStart() error {
 }
 
 func (sched *myScheduler) download() {
-	go func() {
+	go func(hostQueues *politeness) {
 		for {
 			if sched.canceled() {
 				break
 			}
-			datum, err := sched.reqBufferPool.Get()
+			// The host queues park the requests beyond their bounds, so
+			// that no host blocks the others.
+			datum, err := sched.reqBufferPool.Get(sched.ctx)
 			if err != nil {
 				logger.Warnln("The request buffer pool was closed. Break request reception")
 				break
@@ -364,11 +665,34 @@ func (sched *myScheduler) download() {
 			req, ok := datum.(*module.Request)
 			if !ok {
 				errMsg := fmt.Sprintf("incorrect request type: %T", datum)
-				sendError(errors.New(errMsg), "", sched.errorBufferPool)
+				sendError(errors.New(errMsg), "", sched.errSender)
+				continue
 			}
-			sched.downloadOne(req)
+			hostQueues.push(req)
 		}
-	}()
+	}(sched.politeness)
+	var wg sync.WaitGroup
+	for i := uint32(0); i < sched.workers.Download; i++ {
+		wg.Add(1)
+		go func(ctx context.Context, hostQueues *politeness) {
+			defer wg.Done()
+			for {
+				req, err := hostQueues.next(ctx)
+				if err != nil {
+					break
+				}
+				// The request taken before pausing waits for resuming.
+				sched.pauseGate.wait(ctx)
+				sched.downloadOne(req)
+				hostQueues.done(req)
+			}
+		}(sched.acceptCtx, sched.politeness)
+	}
+	sched.downloadDone = make(chan struct{})
+	go func(done chan struct{}) {
+		wg.Wait()
+		close(done)
+	}(sched.downloadDone)
 }
 
 func (sched *myScheduler) downloadOne(req *module.Request) {
@@ -378,30 +702,72 @@ func (sched *myScheduler) downloadOne(req *module.Request) {
 	if sched.canceled() {
 		return
 	}
-	m, err := sched.registrar.Get(module.TYPE_DOWNLOADER)
+	// The host of the request may have been moved to another scheduler.
+	if sched.router != nil && !sched.router.Local(req) {
+		err := sched.router.Forward(req)
+		if err == nil {
+			if ferr := sched.frontier.Done(req); ferr != nil {
+				sendError(ferr, "", sched.errSender)
+			}
+			return
+		}
+		sendError(err, "", sched.errSender)
+	}
+	if !sched.checkRobots(req) {
+		return
+	}
+	sched.recrawl.addValidators(req)
+	m, release, err := sched.getModule(module.TYPE_DOWNLOADER, hostKey(req))
+	defer release()
 	if err != nil || m == nil {
 		errMsg := fmt.Sprintf("could not get a downloader: %s", err)
-		sendError(errors.New(errMsg), "", sched.errorBufferPool)
-		sched.sendReq(req)
+		sendError(errors.New(errMsg), "", sched.errSender)
+		sched.requeueReq(req)
 		return
 	}
 	downloader, ok := m.(module.Downloader)
 	if !ok {
 		errMsg := fmt.Sprintf("incorrect downloader type: %T (MID: %s)", m, m.ID())
-		sendError(errors.New(errMsg), m.ID(), sched.errorBufferPool)
-		sched.sendReq(req)
+		sendError(errors.New(errMsg), m.ID(), sched.errSender)
+		sched.requeueReq(req)
+		return
+	}
+	ctx, cancel := sched.stageContext(sched.timeouts.Download)
+	resp, err := module.DownloadContext(ctx, downloader, req)
+	if resp != nil && resp.HTTPResp() != nil && resp.HTTPResp().Body != nil {
+		httpResp := resp.HTTPResp()
+		httpResp.Body = &cancelOnClose{ReadCloser: httpResp.Body, cancel: cancel}
+	} else {
+		cancel()
+	}
+	// The request interrupted by stopping is left pending for restoring.
+	if err == nil || !sched.canceled() {
+		if ferr := sched.frontier.Done(req); ferr != nil {
+			sendError(ferr, "", sched.errSender)
+		}
+	}
+	if resp != nil && err == nil && !sched.trackChange(req, resp) {
 		return
 	}
-	resp, err := downloader.Download(req)
 	if resp != nil {
-		sendResp(resp, sched.respBufferPool)
+		sched.putResp(resp)
 	}
 	if err != nil {
-		sendError(err, m.ID(), sched.errorBufferPool)
+		sendError(err, m.ID(), sched.errSender)
 	}
 }
 
 func (sched *myScheduler) sendReq(req *module.Request) bool {
+	return sched.sendRequest(req, false)
+}
+
+// sendSeed sends the request of a seed, which is fetched even if it's not due
+// for a revisit in an incremental crawl.
+func (sched *myScheduler) sendSeed(req *module.Request) bool {
+	return sched.sendRequest(req, true)
+}
+
+func (sched *myScheduler) sendRequest(req *module.Request, seed bool) bool {
 	if req == nil {
 		return false
 	}
@@ -423,31 +789,176 @@ func (sched *myScheduler) sendReq(req *module.Request) bool {
 		logger.Warnf("Ignore the request, Its URL scheme is %q, but should be http or https (URL: %s)", scheme, reqURL)
 		return false
 	}
-	if _, ok := sched.urlMap.Load(reqURL.String()); ok {
+	if sched.urlNorm.Enabled() {
+		normalized := urlnorm.Normalize(reqURL, sched.urlNorm)
+		if strings.EqualFold(httpReq.Host, reqURL.Host) {
+			httpReq.Host = normalized.Host
+		}
+		httpReq.URL = normalized
+		reqURL = normalized
+	}
+	if sched.seenSet.Contains(reqURL.String()) {
+		sched.checkSeenSet()
 		logger.Warnf("Ignore the request, Its URL is repeated. (URL: %s)", reqURL)
 		return false
 	}
 	pd, _ := getPrimaryDomain(httpReq.Host)
 	if _, ok := sched.acceptedDomainMap.Load(pd); !ok {
-		if pd == "bing.net" {
-			panic(httpReq.URL)
-		}
 		logger.Warnf("Ignore the request. Its host %q is not in the primary domain map (url: %s)", httpReq.Host, reqURL)
 		return false
 	}
-	if req.Depth() > sched.maxDepth {
-		logger.Warnf("Ignore the request. Its depth reaches the max %d (URL: %s)", req.Depth(), reqURL)
+	maxDepth := sched.maxDepth
+	if budget := req.Meta().MaxDepth; budget > 0 {
+		maxDepth = budget
+	}
+	if req.Depth() > maxDepth {
+		logger.Warnf("Ignore the request. Its depth reaches the max %d (URL: %s)", maxDepth, reqURL)
+		return false
+	}
+	if inScope, rule := sched.scope.check(reqURL); !inScope {
+		if rule != nil {
+			logger.Warnf("Ignore the request. It is excluded by scope rule %s (URL: %s)", rule, reqURL)
+		} else {
+			logger.Warnf("Ignore the request. It matches no include scope rule (URL: %s)", reqURL)
+		}
+		return false
+	}
+	if !seed && sched.recrawl.skip(req) {
+		logger.Infof("Ignore the request. It is not due for a revisit (URL: %s)", reqURL)
+		return false
+	}
+	if sched.router != nil && !sched.router.Local(req) {
+		if !sched.seenSet.Add(reqURL.String()) {
+			sched.checkSeenSet()
+			logger.Warnf("Ignore the request, Its URL is repeated. (URL: %s)", reqURL)
+			return false
+		}
+		return sched.forward(req, seed)
+	}
+	return sched.acceptReq(req, true, seed)
+}
+
+// checkRobots checks the request against robots.txt of its host, which is
+// fetched in the download stage so that a slow host only holds its own
+// requests. The request is put back into its host queue while robots.txt is
+// unavailable.
+func (sched *myScheduler) checkRobots(req *module.Request) bool {
+	reqURL := req.HTTPReq().URL
+	allowed, rules, err := sched.robots.check(req)
+	var unavailable *robots.UnavailableError
+	if errors.As(err, &unavailable) {
+		logger.Warnf("Postpone the request until %s. %s (URL: %s)",
+			unavailable.RetryAt.Format(time.RFC3339), err, reqURL)
+		sched.politeness.postpone(req, unavailable.RetryAt)
 		return false
 	}
-	go func(req *module.Request) {
-		if err := sched.reqBufferPool.Put(req); err != nil {
-			logger.Warnln("The request buffer pool was closed. Ignore the request sending")
+	if rules != nil && rules.CrawlDelay() > 0 {
+		sched.politeness.setDelay(hostKey(req), rules.CrawlDelay())
+	}
+	if !allowed {
+		logger.Warnf("Ignore the request. It is disallowed by robots.txt (URL: %s)", reqURL)
+		if ferr := sched.frontier.Done(req); ferr != nil {
+			sendError(ferr, "", sched.errSender)
 		}
-	}(req)
-	sched.urlMap.Store(reqURL.String(), struct{}{})
+		return false
+	}
+	return true
+}
+
+// acceptReq puts the request into the frontier and the host queues. The
+// request is added to the seen set if markSeen is true. A seed is put into
+// the request buffer pool instead, see putReq and pushReq.
+func (sched *myScheduler) acceptReq(req *module.Request, markSeen bool, seed bool) bool {
+	reqURL := req.HTTPReq().URL
+	if markSeen && !sched.seenSet.Add(reqURL.String()) {
+		sched.checkSeenSet()
+		logger.Warnf("Ignore the request, Its URL is repeated. (URL: %s)", reqURL)
+		return false
+	}
+	if err := sched.frontier.Add(req); err != nil {
+		sendError(err, "", sched.errSender)
+	}
+	if !sched.accepting() {
+		logger.Warnf("Leave the request pending in the frontier. The scheduler is stopping (URL: %s)", reqURL)
+		return false
+	}
+	if seed {
+		sched.putReq(req)
+	} else {
+		sched.pushReq(req)
+	}
+	sched.discoverSitemaps(req)
 	return true
 }
 
+// putReq puts the request from outside the stages into the request buffer
+// pool, which waits while the pool is full until the scheduler is stopped.
+func (sched *myScheduler) putReq(req *module.Request) {
+	if req != nil {
+		sched.reqSender.send(req)
+	}
+}
+
+// pushReq pushes the request from the stages, e.g. found by an analyzer,
+// into the host queues. It never waits, since the stages wait for each other
+// in a cycle through the buffer pools.
+func (sched *myScheduler) pushReq(req *module.Request) {
+	if req != nil {
+		sched.politeness.push(req)
+	}
+}
+
+// requeueReq puts the request which could not be downloaded back into its
+// host queue, and holds the host for a while. The request has been seen, so
+// it skips the checks of sendReq and its frontier entry stays pending.
+func (sched *myScheduler) requeueReq(req *module.Request) {
+	if req != nil {
+		sched.politeness.postpone(req, time.Now().Add(moduleRetryDelay))
+	}
+}
+
+func (sched *myScheduler) putResp(resp *module.Response) {
+	if resp == nil {
+		return
+	}
+	atomic.AddInt64(&sched.pendingResps, 1)
+	if !sched.respSender.send(resp) {
+		atomic.AddInt64(&sched.pendingResps, -1)
+	}
+}
+
+func (sched *myScheduler) putItem(item module.Item) {
+	if item == nil {
+		return
+	}
+	atomic.AddInt64(&sched.pendingItems, 1)
+	if !sched.itemSender.send(item) {
+		atomic.AddInt64(&sched.pendingItems, -1)
+	}
+}
+
+// stageContext derives the context for handling one datum in a stage from the
+// context of the scheduler.
+func (sched *myScheduler) stageContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(sched.ctx, timeout)
+	}
+	return context.WithCancel(sched.ctx)
+}
+
+// cancelOnClose releases the context of the download when the response body
+// is closed, since the body is read after the download returns.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (body *cancelOnClose) Close() error {
+	err := body.ReadCloser.Close()
+	body.cancel()
+	return err
+}
+
 func (sched *myScheduler) canceled() bool {
 	select {
 	case <-sched.ctx.Done():
@@ -457,25 +968,25 @@ func (sched *myScheduler) canceled() bool {
 	}
 }
 
+func (sched *myScheduler) accepting() bool {
+	select {
+	case <-sched.acceptCtx.Done():
+		return false
+	default:
+		return true
+	}
+}
+
 func (sched *myScheduler) analyze() {
-	go func() {
-		for {
-			if sched.canceled() {
-				break
-			}
-			datum, err := sched.respBufferPool.Get()
-			if err != nil {
-				logger.Warnln("The response buffer pool was closed. Break response reception")
-				break
-			}
-			resp, ok := datum.(*module.Response)
-			if !ok {
-				errMsg := fmt.Sprintf("incorrect response type: %T", datum)
-				sendError(errors.New(errMsg), "", sched.errorBufferPool)
-			}
-			sched.analyzeOne(resp)
+	sched.dispatch(sched.respBufferPool, "response", sched.workers.Analyze, func(datum interface{}) {
+		resp, ok := datum.(*module.Response)
+		if !ok {
+			errMsg := fmt.Sprintf("incorrect response type: %T", datum)
+			sendError(errors.New(errMsg), "", sched.errSender)
 		}
-	}()
+		sched.analyzeOne(resp)
+		atomic.AddInt64(&sched.pendingResps, -1)
+	})
 }
 
 func (sched *myScheduler) analyzeOne(resp *module.Response) {
@@ -485,21 +996,25 @@ func (sched *myScheduler) analyzeOne(resp *module.Response) {
 	if sched.canceled() {
 		return
 	}
-	m, err := sched.registrar.Get(module.TYPE_ANALYZER)
+	m, release, err := sched.getModule(module.TYPE_ANALYZER, "")
+	defer release()
 	if err != nil || m == nil {
 		errMsg := fmt.Sprintf("could not get an analyzer: %s", err)
-		sendError(errors.New(errMsg), "", sched.errorBufferPool)
-		sendResp(resp, sched.respBufferPool)
+		sendError(errors.New(errMsg), "", sched.errSender)
+		sched.putResp(resp)
 		return
 	}
 	analyzer, ok := m.(module.Analyzer)
 	if !ok {
 		errMsg := fmt.Sprintf("incorrect analyzer type: %T (MID: %s)", m, m.ID())
-		sendError(errors.New(errMsg), m.ID(), sched.errorBufferPool)
-		sendResp(resp, sched.respBufferPool)
+		sendError(errors.New(errMsg), m.ID(), sched.errSender)
+		sched.putResp(resp)
 		return
 	}
-	dataList, errs := analyzer.Analyze(resp)
+	ctx, cancel := sched.stageContext(sched.timeouts.Analyze)
+	dataList, errs := module.AnalyzeContext(ctx, analyzer, resp)
+	cancel()
+	change, tracked := sched.changes.LoadAndDelete(resp)
 	for _, data := range dataList {
 		if data == nil {
 			continue
@@ -508,82 +1023,56 @@ func (sched *myScheduler) analyzeOne(resp *module.Response) {
 		case *module.Request:
 			sched.sendReq(d)
 		case module.Item:
-			sendItem(d, sched.itemBufferPool)
+			if _, ok := d[module.ITEM_KEY_CHANGE]; tracked && !ok {
+				d[module.ITEM_KEY_CHANGE] = change
+			}
+			sched.putItem(d)
 		default:
 			errMsg := fmt.Sprintf("Unsupported data type: %T (data: %#v)", d, d)
-			sendError(errors.New(errMsg), m.ID(), sched.errorBufferPool)
+			sendError(errors.New(errMsg), m.ID(), sched.errSender)
 		}
 	}
 	for _, err := range errs {
-		sendError(err, m.ID(), sched.errorBufferPool)
-	}
-}
-
-func sendResp(resp *module.Response, respBufferPool buffer.Pool) bool {
-	if resp == nil || respBufferPool == nil || respBufferPool.Closed() {
-		return false
+		sendError(err, m.ID(), sched.errSender)
 	}
-	go func(resp *module.Response) {
-		if err := respBufferPool.Put(resp); err != nil {
-			logger.Warnf("The reponse buffer pool was closed. Ignore request sending")
-		}
-	}(resp)
-	return true
-}
-
-func sendItem(item module.Item, itemBufferPool buffer.Pool) bool {
-	if item == nil || itemBufferPool == nil || itemBufferPool.Closed() {
-		return false
-	}
-	go func(item module.Item) {
-		if err := itemBufferPool.Put(item); err != nil {
-			logger.Warnln("The item buffer pool was closed. Ignore item sending")
-		}
-	}(item)
-	return true
 }
 
 func (sched *myScheduler) pick() {
-	go func() {
-		for {
-			if sched.canceled() {
-				break
-			}
-			datum, err := sched.itemBufferPool.Get()
-			if err != nil {
-				logger.Warnln("The item buffer pool was closed. Break item reception")
-				break
-			}
-			item, ok := datum.(module.Item)
-			if !ok {
-				errMsg := fmt.Sprintf("incorrect item type: %T", datum)
-				sendError(errors.New(errMsg), "", sched.errorBufferPool)
-			}
-			sched.pickOne(item)
+	sched.dispatch(sched.itemBufferPool, "item", sched.workers.Pick, func(datum interface{}) {
+		item, ok := datum.(module.Item)
+		if !ok {
+			errMsg := fmt.Sprintf("incorrect item type: %T", datum)
+			sendError(errors.New(errMsg), "", sched.errSender)
 		}
-	}()
+		sched.pickOne(item)
+		atomic.AddInt64(&sched.pendingItems, -1)
+	})
 }
 
 func (sched *myScheduler) pickOne(item module.Item) {
 	if sched.canceled() {
 		return
 	}
-	m, err := sched.registrar.Get(module.TYPE_PIPELINE)
+	m, release, err := sched.getModule(module.TYPE_PIPELINE, "")
+	defer release()
 	if err != nil || m == nil {
 		errMsg := fmt.Sprintf("could not get a pipeline: %s", err)
-		sendError(errors.New(errMsg), "", sched.errorBufferPool)
-		sendItem(item, sched.itemBufferPool)
+		sendError(errors.New(errMsg), "", sched.errSender)
+		sched.putItem(item)
 		return
 	}
 	pipeline, ok := m.(module.Pipeline)
 	if !ok {
 		errMsg := fmt.Sprintf("incorrect pipeline type: %T (MID: %s)", m, m.ID())
-		sendError(errors.New(errMsg), m.ID(), sched.errorBufferPool)
-		sendItem(item, sched.itemBufferPool)
+		sendError(errors.New(errMsg), m.ID(), sched.errSender)
+		sched.putItem(item)
 		return
 	}
-	errs := pipeline.Send(item)
+	ctx, cancel := sched.stageContext(sched.timeouts.Pipeline)
+	errs := module.SendContext(ctx, pipeline, item)
+	cancel()
+	atomic.AddUint64(&sched.pickedItems, 1)
 	for _, err := range errs {
-		sendError(err, m.ID(), sched.errorBufferPool)
+		sendError(err, m.ID(), sched.errSender)
 	}
 }
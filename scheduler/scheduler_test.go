@@ -1,13 +1,20 @@
 package scheduler
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
-	"runtime"
-	"sync"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 	"webcrawler/module"
-	"webcrawler/toolkit/buffer"
+	"webcrawler/module/local/analyzer"
+	"webcrawler/module/local/downloader"
+	"webcrawler/module/local/pipeline"
+	"webcrawler/toolkit/seenset"
+	"webcrawler/toolkit/urlnorm"
 )
 
 var snGen = module.NewSNGenerator(1, 0)
@@ -141,6 +148,157 @@ func TestSchedStop(t *testing.T) {
 	}
 }
 
+func TestSchedPause(t *testing.T) {
+	server := genDrainServer(20)
+	defer server.Close()
+	sched := startDrainSched(server.URL, genSimpleModuleArgs(1, 1, 1, t), t)
+	if err := sched.Pause(); err != nil {
+		t.Fatalf("An error occurs when pausing scheduler: %s", err)
+	}
+	if sched.Status() != SCHED_STATUS_PAUSED {
+		t.Fatalf("Inconsistent status, expected: %s, actual: %s",
+			GetStatusDescription(SCHED_STATUS_PAUSED), GetStatusDescription(sched.Status()))
+	}
+	if err := sched.Pause(); err == nil {
+		t.Fatal("No error when pausing a paused scheduler")
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL, nil)
+	if err := sched.Start(firstHTTPReq); err == nil {
+		t.Fatal("No error when starting a paused scheduler")
+	}
+	// The download in progress is finished in the background.
+	time.Sleep(400 * time.Millisecond)
+	picked := atomic.LoadUint64(&sched.pickedItems)
+	frontierLen := sched.frontier.Len()
+	time.Sleep(500 * time.Millisecond)
+	if actual := atomic.LoadUint64(&sched.pickedItems); actual != picked {
+		t.Fatalf("The paused scheduler still processes items, expected: %d, actual: %d", picked, actual)
+	}
+	if actual := sched.frontier.Len(); actual != frontierLen {
+		t.Fatalf("The paused scheduler still downloads, pending requests expected: %d, actual: %d", frontierLen, actual)
+	}
+	if err := sched.Resume(); err != nil {
+		t.Fatalf("An error occurs when resuming scheduler: %s", err)
+	}
+	if sched.Status() != SCHED_STATUS_STARTED {
+		t.Fatalf("Inconsistent status, expected: %s, actual: %s",
+			GetStatusDescription(SCHED_STATUS_STARTED), GetStatusDescription(sched.Status()))
+	}
+	for i := 0; sched.frontier.Len() == frontierLen; i++ {
+		if i >= 500 {
+			t.Fatal("The resumed scheduler does not download")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := sched.Pause(); err != nil {
+		t.Fatalf("An error occurs when pausing scheduler: %s", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- sched.Stop()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("An error occurs when stopping a paused scheduler: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Could not stop a paused scheduler")
+	}
+}
+
+func TestSchedPauseFullBuffers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html><body>")
+		for i := 0; i < 50; i++ {
+			fmt.Fprintf(w, `<a href="%s/%d">page</a>`, r.URL.Path, i)
+		}
+		fmt.Fprint(w, "</body></html>")
+	}))
+	defer server.Close()
+	sched := NewScheduler()
+	if err := sched.Init(genRequestArgs([]string{}, 3), genDataArgs(1, 1, 0), genSimpleModuleArgs(2, 1, 1, t)); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL, nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		for i := 0; i < 20; i++ {
+			time.Sleep(20 * time.Millisecond)
+			if err := sched.Pause(); err != nil {
+				done <- err
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+			if err := sched.Resume(); err != nil {
+				done <- err
+				return
+			}
+		}
+		if err := sched.Pause(); err != nil {
+			done <- err
+			return
+		}
+		done <- sched.Stop()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("An error occurs when pausing, resuming or stopping scheduler: %s", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("The scheduler with full buffer pools is stuck (status: %s)", GetStatusDescription(sched.Status()))
+	}
+}
+
+func TestSchedFullBuffers(t *testing.T) {
+	var downloaded int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&downloaded, 1)
+		for i := 0; i < 20; i++ {
+			fmt.Fprintf(w, `<a href="%s/%d">page</a>`, strings.TrimSuffix(r.URL.Path, "/"), i)
+		}
+	}))
+	defer server.Close()
+	// The requests found by the analyzers outnumber all the buffers and the
+	// host queues.
+	requestArgs := genRequestArgs([]string{}, 2)
+	requestArgs.Politeness.MaxQueued = 5
+	requestArgs.Politeness.MaxQueuedPerHost = 5
+	d1, _ := downloader.New("D1", &http.Client{}, nil)
+	d2, _ := downloader.New("D2", &http.Client{}, nil)
+	a, _ := analyzer.New("A3", []module.ParseResponse{parseRecrawlPage}, nil)
+	p, _ := pipeline.New("P4", []module.ProcessItem{func(item module.Item) (module.Item, error) {
+		return item, nil
+	}}, nil)
+	moduleArgs := ModuleArgs{
+		Downloaders: []module.Downloader{d1, d2},
+		Analyzers:   []module.Analyzer{a},
+		Pipelines:   []module.Pipeline{p},
+	}
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, genDataArgs(1, 1, 0), moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL, nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	expected := int64(1 + 20 + 20*20)
+	begin := time.Now()
+	for atomic.LoadInt64(&downloaded) < expected {
+		if time.Since(begin) > 10*time.Second {
+			t.Fatalf("The scheduler with full buffer pools is stuck (downloaded: %d, expected: %d)",
+				atomic.LoadInt64(&downloaded), expected)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
 func TestSchedStatus(t *testing.T) {
 	requestArgs := genRequestArgs([]string{"bing.com"}, 0)
 	dataArgs := genDataArgs(10, 2, 1)
@@ -275,7 +433,7 @@ func TestSchedSendReq(t *testing.T) {
 	if mySched.sendReq(req) {
 		t.Fatalf("It can still send req repeatedly")
 	}
-	mySched.urlMap = sync.Map{}
+	mySched.seenSet.Clear()
 	httpReq.URL.Scheme = "tcp"
 	if mySched.sendReq(req) {
 		t.Fatalf("It can still send request with unsupported URL scheme")
@@ -291,35 +449,174 @@ func TestSchedSendReq(t *testing.T) {
 	}
 }
 
-func TestSendResp(t *testing.T) {
-	buffer, _ := buffer.NewPool(10, 2)
-	if sendResp(nil, buffer) {
-		t.Fatalf("It can still send nil response")
-	}
-	httpReq, _ := http.NewRequest("GET", "https://github.com/gopcp", nil)
-	httpResp := &http.Response{
-		Request: httpReq,
-		Body:    nil,
-	}
-	resp := module.NewResponse(httpResp, 0)
-	buffer.Close()
-	done := sendResp(resp, buffer)
-	runtime.Gosched()
-	if done {
-		t.Fatalf("It can stil send response to closed buffer")
+func TestSchedSendReqNormalized(t *testing.T) {
+	requestArgs := genRequestArgs([]string{}, 0)
+	requestArgs.URLNorm = urlnorm.DefaultOptions()
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(1, 1, 1, t)
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", "http://cn.bing.com/search?q=golang", nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	mySched := sched.(*myScheduler)
+	httpReq, _ := http.NewRequest("GET", "http://CN.bing.com:80/x/../images?b=1&a=2&utm_source=s#frag", nil)
+	if !mySched.sendReq(module.NewRequest(httpReq, 0)) {
+		t.Fatal("Could not send request")
+	}
+	expectedURL := "http://cn.bing.com/images?a=2&b=1"
+	if httpReq.URL.String() != expectedURL || httpReq.Host != "cn.bing.com" {
+		t.Fatalf("Inconsistent normalized request, expected: %s, actual: %s (host: %s)",
+			expectedURL, httpReq.URL, httpReq.Host)
+	}
+	for _, url := range []string{
+		"http://cn.bing.com/images?a=2&b=1",
+		"http://cn.bing.com/images?b=1&a=2#top",
+		"http://cn.bing.com/./images?a=2&b=1&fbclid=f",
+	} {
+		httpReq, _ := http.NewRequest("GET", url, nil)
+		if mySched.sendReq(module.NewRequest(httpReq, 0)) {
+			t.Fatalf("It can still send the request with a repeated normalized URL (URL: %s)", url)
+		}
+	}
+}
+
+func TestSchedSeenSet(t *testing.T) {
+	argsList := []SeenSetArgs{
+		{},
+		{Type: SEEN_SET_TYPE_BLOOM, Capacity: 100, FalsePositiveRate: 0.01},
+		{Type: SEEN_SET_TYPE_DISK, Dir: t.TempDir()},
+	}
+	for _, seenSetArgs := range argsList {
+		requestArgs := genRequestArgs([]string{}, 0)
+		requestArgs.SeenSet = seenSetArgs
+		dataArgs := genDataArgs(10, 2, 1)
+		moduleArgs := genSimpleModuleArgs(1, 1, 1, t)
+		sched := NewScheduler()
+		if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+			t.Fatalf("An error occurs when initializing scheduler: %s (seen set: %#v)", err, seenSetArgs)
+		}
+		firstHTTPReq, _ := http.NewRequest("GET", "http://cn.bing.com/search?q=golang", nil)
+		if err := sched.Start(firstHTTPReq); err != nil {
+			t.Fatalf("An error occurs when starting scheduler: %s (seen set: %#v)", err, seenSetArgs)
+		}
+		mySched := sched.(*myScheduler)
+		for i := 0; i < 3; i++ {
+			httpReq, _ := http.NewRequest("GET", fmt.Sprintf("http://cn.bing.com/search?q=%d", i), nil)
+			if !mySched.sendReq(module.NewRequest(httpReq, 0)) {
+				t.Fatalf("Could not send request (URL: %s, seen set: %#v)", httpReq.URL, seenSetArgs)
+			}
+			if mySched.sendReq(module.NewRequest(httpReq, 0)) {
+				t.Fatalf("It can still send req repeatedly (URL: %s, seen set: %#v)", httpReq.URL, seenSetArgs)
+			}
+		}
+		if numURL := sched.Summary().Struct().NumURL; numURL != 4 {
+			t.Fatalf("Inconsistent URL number, expected: %d, actual: %d (seen set: %#v)", 4, numURL, seenSetArgs)
+		}
+		sched.Stop()
+	}
+	illegalArgsList := []SeenSetArgs{
+		{Type: "redis"},
+		{Type: SEEN_SET_TYPE_BLOOM, FalsePositiveRate: 1},
+		{Type: SEEN_SET_TYPE_DISK},
+	}
+	for _, seenSetArgs := range illegalArgsList {
+		requestArgs := genRequestArgs([]string{}, 0)
+		requestArgs.SeenSet = seenSetArgs
+		if err := requestArgs.Check(); err == nil {
+			t.Fatalf("No error when checking illegal seen set arguments: %#v", seenSetArgs)
+		}
+	}
+}
+
+// failingSeenSet is a seen set which reports the keys as present with an
+// error.
+type failingSeenSet struct {
+	seenset.SeenSet
+}
+
+func (set failingSeenSet) Add(key string) bool      { return false }
+func (set failingSeenSet) Contains(key string) bool { return true }
+func (set failingSeenSet) Err() error               { return errors.New("broken disk") }
+
+func TestSchedSeenSetError(t *testing.T) {
+	requestArgs := genRequestArgs([]string{}, 0)
+	requestArgs.SeenSet = SeenSetArgs{Shared: failingSeenSet{seenset.NewExact()}}
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, genDataArgs(10, 2, 1), genSimpleModuleArgs(1, 1, 1, t)); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", "http://cn.bing.com/search?q=golang", nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	mySched := sched.(*myScheduler)
+	for i := 0; i < 3; i++ {
+		httpReq, _ := http.NewRequest("GET", fmt.Sprintf("http://cn.bing.com/search?q=%d", i), nil)
+		if mySched.sendReq(module.NewRequest(httpReq, 0)) {
+			t.Fatalf("The request is sent while the seen set fails (URL: %s)", httpReq.URL)
+		}
+	}
+	errChan := sched.ErrorChan()
+	var errs []error
+	timer := time.After(200 * time.Millisecond)
+	for done := false; !done; {
+		select {
+		case err := <-errChan:
+			errs = append(errs, err)
+		case <-timer:
+			done = true
+		}
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "broken disk") {
+		t.Fatalf("Inconsistent errors of the failing seen set, expected one, actual: %v", errs)
 	}
 }
 
-func TestSendItem(t *testing.T) {
-	buffer, _ := buffer.NewPool(10, 2)
-	if sendItem(nil, buffer) {
-		t.Fatalf("It can still send nil item")
-	}
-	item := module.Item(map[string]interface{}{})
-	buffer.Close()
-	done := sendItem(item, buffer)
-	runtime.Gosched()
-	if done {
-		t.Fatalf("It can still send item to closed buffer")
+func TestSchedTimeouts(t *testing.T) {
+	block := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(block)
+	requestArgs := genRequestArgs([]string{}, 0)
+	requestArgs.Timeouts = TimeoutArgs{Download: 100 * time.Millisecond}
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(1, 1, 1, t)
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL+"/slow", nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	select {
+	case err := <-sched.ErrorChan():
+		if err == nil || !strings.Contains(err.Error(), "deadline exceeded") {
+			t.Fatalf("Inconsistent error of the download timeout: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The download has not been interrupted by the timeout")
+	}
+	for _, timeouts := range []TimeoutArgs{
+		{Download: -1},
+		{Analyze: -time.Second},
+		{Pipeline: -time.Millisecond},
+	} {
+		requestArgs.Timeouts = timeouts
+		if err := requestArgs.Check(); err == nil {
+			t.Fatalf("No error when checking illegal timeouts: %#v", timeouts)
+		}
 	}
 }
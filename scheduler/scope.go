@@ -0,0 +1,200 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+type ScopeAction string
+
+const (
+	SCOPE_ACTION_INCLUDE ScopeAction = "include"
+	SCOPE_ACTION_EXCLUDE ScopeAction = "exclude"
+)
+
+type ScopeType string
+
+const (
+	SCOPE_TYPE_HOST   ScopeType = "host"
+	SCOPE_TYPE_PATH   ScopeType = "path"
+	SCOPE_TYPE_REGEX  ScopeType = "regex"
+	SCOPE_TYPE_QUERY  ScopeType = "query"
+	SCOPE_TYPE_EXT    ScopeType = "ext"
+	SCOPE_TYPE_SCHEME ScopeType = "scheme"
+)
+
+type ScopeRule struct {
+	Name    string      `json:"name,omitempty"`
+	Action  ScopeAction `json:"action"`
+	Type    ScopeType   `json:"type"`
+	Pattern string      `json:"pattern"`
+}
+
+func (rule ScopeRule) String() string {
+	if rule.Name != "" {
+		return rule.Name
+	}
+	return fmt.Sprintf("%s %s %q", rule.Action, rule.Type, rule.Pattern)
+}
+
+func LoadScopeRules(r io.Reader) ([]ScopeRule, error) {
+	var rules []ScopeRule
+	if err := json.NewDecoder(r).Decode(&rules); err != nil {
+		return nil, genErrorByError(err)
+	}
+	for _, rule := range rules {
+		if _, err := compileScopeRule(rule); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+type scopeMatcher func(u *url.URL) bool
+
+type compiledScopeRule struct {
+	rule  ScopeRule
+	match scopeMatcher
+	hits  uint64
+}
+
+func compileScopeRule(rule ScopeRule) (scopeMatcher, error) {
+	if rule.Action != SCOPE_ACTION_INCLUDE && rule.Action != SCOPE_ACTION_EXCLUDE {
+		return nil, genParameterError(fmt.Sprintf("illegal action for scope rule %s", rule))
+	}
+	pattern := strings.TrimSpace(rule.Pattern)
+	if pattern == "" {
+		return nil, genParameterError(fmt.Sprintf("empty pattern for scope rule %s", rule))
+	}
+	switch rule.Type {
+	case SCOPE_TYPE_HOST:
+		pattern = strings.ToLower(pattern)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, genParameterError(fmt.Sprintf("illegal host glob for scope rule %s: %s", rule, err))
+		}
+		return func(u *url.URL) bool {
+			matched, _ := path.Match(pattern, strings.ToLower(u.Hostname()))
+			return matched
+		}, nil
+	case SCOPE_TYPE_PATH:
+		return func(u *url.URL) bool {
+			p := u.Path
+			if p == "" {
+				p = "/"
+			}
+			return strings.HasPrefix(p, pattern)
+		}, nil
+	case SCOPE_TYPE_REGEX:
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, genParameterError(fmt.Sprintf("illegal regex for scope rule %s: %s", rule, err))
+		}
+		return func(u *url.URL) bool {
+			return re.MatchString(u.String())
+		}, nil
+	case SCOPE_TYPE_QUERY:
+		name, value, hasValue := strings.Cut(pattern, "=")
+		return func(u *url.URL) bool {
+			values, ok := u.Query()[name]
+			if !ok {
+				return false
+			}
+			if !hasValue {
+				return true
+			}
+			for _, v := range values {
+				if v == value {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case SCOPE_TYPE_EXT:
+		exts := map[string]struct{}{}
+		for _, ext := range strings.Split(strings.ToLower(pattern), ",") {
+			ext = strings.TrimSpace(ext)
+			if ext == "" {
+				continue
+			}
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			exts[ext] = struct{}{}
+		}
+		return func(u *url.URL) bool {
+			_, ok := exts[strings.ToLower(path.Ext(u.Path))]
+			return ok
+		}, nil
+	case SCOPE_TYPE_SCHEME:
+		pattern = strings.ToLower(pattern)
+		return func(u *url.URL) bool {
+			return strings.ToLower(u.Scheme) == pattern
+		}, nil
+	}
+	return nil, genParameterError(fmt.Sprintf("illegal type for scope rule %s", rule))
+}
+
+// scope rejects a URL matching any exclude rule. When there are include rules,
+// the URL must also match one of them.
+type scope struct {
+	includes []*compiledScopeRule
+	excludes []*compiledScopeRule
+	all      []*compiledScopeRule
+}
+
+func newScope(rules []ScopeRule) (*scope, error) {
+	s := &scope{}
+	for _, rule := range rules {
+		match, err := compileScopeRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		compiled := &compiledScopeRule{rule: rule, match: match}
+		s.all = append(s.all, compiled)
+		if rule.Action == SCOPE_ACTION_INCLUDE {
+			s.includes = append(s.includes, compiled)
+		} else {
+			s.excludes = append(s.excludes, compiled)
+		}
+	}
+	return s, nil
+}
+
+func (s *scope) check(u *url.URL) (bool, *ScopeRule) {
+	for _, compiled := range s.excludes {
+		if compiled.match(u) {
+			atomic.AddUint64(&compiled.hits, 1)
+			return false, &compiled.rule
+		}
+	}
+	if len(s.includes) == 0 {
+		return true, nil
+	}
+	for _, compiled := range s.includes {
+		if compiled.match(u) {
+			atomic.AddUint64(&compiled.hits, 1)
+			return true, &compiled.rule
+		}
+	}
+	return false, nil
+}
+
+func (s *scope) summary() []ScopeRuleSummaryStruct {
+	if len(s.all) == 0 {
+		return nil
+	}
+	summaries := make([]ScopeRuleSummaryStruct, 0, len(s.all))
+	for _, compiled := range s.all {
+		summaries = append(summaries, ScopeRuleSummaryStruct{
+			Rule: compiled.rule.String(),
+			Hits: atomic.LoadUint64(&compiled.hits),
+		})
+	}
+	return summaries
+}
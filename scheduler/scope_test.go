@@ -0,0 +1,116 @@
+package scheduler
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"webcrawler/module"
+)
+
+func TestScopeRuleCompile(t *testing.T) {
+	cases := []struct {
+		rule     ScopeRule
+		url      string
+		expected bool
+	}{
+		{ScopeRule{Action: SCOPE_ACTION_INCLUDE, Type: SCOPE_TYPE_HOST, Pattern: "*.bing.com"}, "http://CN.bing.com/a", true},
+		{ScopeRule{Action: SCOPE_ACTION_INCLUDE, Type: SCOPE_TYPE_HOST, Pattern: "*.bing.com"}, "http://bing.com/a", false},
+		{ScopeRule{Action: SCOPE_ACTION_INCLUDE, Type: SCOPE_TYPE_PATH, Pattern: "/news/"}, "http://a.com/news/1.html", true},
+		{ScopeRule{Action: SCOPE_ACTION_INCLUDE, Type: SCOPE_TYPE_PATH, Pattern: "/news/"}, "http://a.com/sport/1.html", false},
+		{ScopeRule{Action: SCOPE_ACTION_EXCLUDE, Type: SCOPE_TYPE_REGEX, Pattern: `\d{4}/\d{2}`}, "http://a.com/2024/05/x", true},
+		{ScopeRule{Action: SCOPE_ACTION_EXCLUDE, Type: SCOPE_TYPE_QUERY, Pattern: "sessionid"}, "http://a.com/?sessionid=1", true},
+		{ScopeRule{Action: SCOPE_ACTION_EXCLUDE, Type: SCOPE_TYPE_QUERY, Pattern: "page=2"}, "http://a.com/?page=1", false},
+		{ScopeRule{Action: SCOPE_ACTION_EXCLUDE, Type: SCOPE_TYPE_QUERY, Pattern: "page=2"}, "http://a.com/?page=1&page=2", true},
+		{ScopeRule{Action: SCOPE_ACTION_EXCLUDE, Type: SCOPE_TYPE_EXT, Pattern: "jpg, .PNG"}, "http://a.com/a/b.png", true},
+		{ScopeRule{Action: SCOPE_ACTION_EXCLUDE, Type: SCOPE_TYPE_EXT, Pattern: "jpg, .PNG"}, "http://a.com/a/b.html", false},
+		{ScopeRule{Action: SCOPE_ACTION_INCLUDE, Type: SCOPE_TYPE_SCHEME, Pattern: "HTTPS"}, "https://a.com/", true},
+		{ScopeRule{Action: SCOPE_ACTION_INCLUDE, Type: SCOPE_TYPE_SCHEME, Pattern: "https"}, "http://a.com/", false},
+	}
+	for _, c := range cases {
+		match, err := compileScopeRule(c.rule)
+		if err != nil {
+			t.Fatalf("An error occurs when compiling scope rule %s: %s", c.rule, err)
+		}
+		u, _ := url.Parse(c.url)
+		if actual := match(u); actual != c.expected {
+			t.Fatalf("Inconsistent match result of scope rule %s for %s, expected: %v, actual: %v",
+				c.rule, c.url, c.expected, actual)
+		}
+	}
+	illegalRules := []ScopeRule{
+		{Action: "allow", Type: SCOPE_TYPE_HOST, Pattern: "a.com"},
+		{Action: SCOPE_ACTION_INCLUDE, Type: "port", Pattern: "80"},
+		{Action: SCOPE_ACTION_INCLUDE, Type: SCOPE_TYPE_HOST, Pattern: " "},
+		{Action: SCOPE_ACTION_INCLUDE, Type: SCOPE_TYPE_REGEX, Pattern: "(a"},
+		{Action: SCOPE_ACTION_INCLUDE, Type: SCOPE_TYPE_HOST, Pattern: "[a"},
+	}
+	for _, rule := range illegalRules {
+		if _, err := compileScopeRule(rule); err == nil {
+			t.Fatalf("No error when compiling illegal scope rule %s", rule)
+		}
+	}
+}
+
+func TestScopeLoad(t *testing.T) {
+	data := `[
+		{"name": "news only", "action": "include", "type": "path", "pattern": "/news/"},
+		{"action": "exclude", "type": "ext", "pattern": "pdf"}
+	]`
+	rules, err := LoadScopeRules(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("An error occurs when loading scope rules: %s", err)
+	}
+	if len(rules) != 2 || rules[0].Name != "news only" || rules[1].Type != SCOPE_TYPE_EXT {
+		t.Fatalf("Inconsistent scope rules: %#v", rules)
+	}
+	if _, err = LoadScopeRules(strings.NewReader(`[{"action": "include", "type": "x", "pattern": "y"}]`)); err == nil {
+		t.Fatal("No error when loading illegal scope rules")
+	}
+	if _, err = LoadScopeRules(strings.NewReader(`{`)); err == nil {
+		t.Fatal("No error when loading broken scope rules")
+	}
+}
+
+func TestSchedScope(t *testing.T) {
+	requestArgs := genRequestArgs([]string{}, 1)
+	requestArgs.ScopeRules = []ScopeRule{
+		{Action: SCOPE_ACTION_INCLUDE, Type: SCOPE_TYPE_PATH, Pattern: "/search"},
+		{Name: "no images", Action: SCOPE_ACTION_EXCLUDE, Type: SCOPE_TYPE_EXT, Pattern: "png"},
+	}
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(1, 1, 1, t)
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", "http://cn.bing.com/search?q=golang", nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	mySched := sched.(*myScheduler)
+	cases := map[string]bool{
+		"http://cn.bing.com/search?q=rust":    true,
+		"http://cn.bing.com/search/logo.png":  false,
+		"http://cn.bing.com/images?q=golang":  false,
+		"http://cn.bing.com/search/more.html": true,
+	}
+	for url, expected := range cases {
+		httpReq, _ := http.NewRequest("GET", url, nil)
+		if actual := mySched.sendReq(module.NewRequest(httpReq, 1)); actual != expected {
+			t.Fatalf("Inconsistent sending result for %s, expected: %v, actual: %v", url, expected, actual)
+		}
+	}
+	summaries := sched.Summary().Struct().ScopeRules
+	if len(summaries) != 2 {
+		t.Fatalf("Inconsistent scope rule summary number, expected: %d, actual: %d", 2, len(summaries))
+	}
+	if summaries[0].Hits != 3 || summaries[1].Hits != 1 || summaries[1].Rule != "no images" {
+		t.Fatalf("Inconsistent scope rule summaries: %#v", summaries)
+	}
+	requestArgs.ScopeRules = []ScopeRule{{Action: SCOPE_ACTION_INCLUDE, Type: SCOPE_TYPE_REGEX, Pattern: "(a"}}
+	if err := requestArgs.Check(); err == nil {
+		t.Fatal("No error when checking request arguments with illegal scope rules")
+	}
+}
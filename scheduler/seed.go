@@ -0,0 +1,159 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"webcrawler/module"
+)
+
+// Seed is a request starting the crawl, whose primary domain is accepted.
+type Seed struct {
+	HTTPReq *http.Request
+	// MaxDepth is the max depth of the requests from the seed, and zero
+	// means the max depth in the request arguments.
+	MaxDepth uint32
+	// Meta is inherited by the requests from the seed like the metadata of
+	// a response.
+	Meta module.Meta
+}
+
+// NewSeed creates a seed of the GET request of the URL.
+func NewSeed(rawURL string) (Seed, error) {
+	httpReq, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return Seed{}, genParameterError(fmt.Sprintf("illegal seed URL: %s", err))
+	}
+	return Seed{HTTPReq: httpReq}, nil
+}
+
+// request returns the request of the seed at depth 0.
+func (seed Seed) request() *module.Request {
+	meta := seed.Meta
+	if seed.MaxDepth > 0 {
+		meta.MaxDepth = seed.MaxDepth
+	}
+	return module.NewRequestWithMeta(seed.HTTPReq, 0, meta)
+}
+
+// checkSeeds checks the seeds and returns their primary domains.
+func checkSeeds(seeds []Seed) ([]string, error) {
+	if len(seeds) == 0 {
+		return nil, genParameterError("empty seed list")
+	}
+	domains := make([]string, 0, len(seeds))
+	for i, seed := range seeds {
+		if seed.HTTPReq == nil || seed.HTTPReq.URL == nil {
+			return nil, genParameterError(fmt.Sprintf("nil HTTP request of seed %d", i))
+		}
+		domain, err := getPrimaryDomain(seed.HTTPReq.Host)
+		if err != nil {
+			return nil, err
+		}
+		domains = append(domains, domain)
+	}
+	return domains, nil
+}
+
+// AddSeeds sends the seeds to the started or paused scheduler, and accepts
+// their primary domains. It returns the number of the seeds accepted by the
+// filters. The seeds could be added while paused. The status is not held
+// while they are sent, so the scheduler could be paused or stopped meanwhile,
+// and stopping it returns an error with the seeds accepted so far.
+func (sched *myScheduler) AddSeeds(seeds []Seed) (accepted int, err error) {
+	status := sched.Status()
+	if status != SCHED_STATUS_STARTED && status != SCHED_STATUS_PAUSED {
+		return 0, genError(fmt.Sprintf("could not add seeds to the scheduler in status %q",
+			GetStatusDescription(status)))
+	}
+	domains, err := checkSeeds(seeds)
+	if err != nil {
+		return 0, err
+	}
+	for i, seed := range seeds {
+		if !sched.accepting() {
+			logger.Infof("Add seeds: %d, accepted: %d, stopped", len(seeds), accepted)
+			return accepted, genError("the scheduler was stopped while adding seeds")
+		}
+		sched.acceptedDomainMap.Store(domains[i], struct{}{})
+		if sched.sendSeed(seed.request()) {
+			accepted++
+		}
+	}
+	logger.Infof("Add seeds: %d, accepted: %d", len(seeds), accepted)
+	return accepted, nil
+}
+
+// seedRecord is a seed in a seed file.
+type seedRecord struct {
+	URL      string      `json:"url"`
+	MaxDepth uint32      `json:"max_depth,omitempty"`
+	Meta     module.Meta `json:"meta"`
+}
+
+func (record seedRecord) seed() (Seed, error) {
+	seed, err := NewSeed(record.URL)
+	if err != nil {
+		return seed, err
+	}
+	if seed.HTTPReq.URL.Host == "" {
+		return seed, genParameterError(fmt.Sprintf("no host in seed URL %q", record.URL))
+	}
+	seed.MaxDepth = record.MaxDepth
+	seed.Meta = record.Meta
+	return seed, nil
+}
+
+// LoadSeeds loads the seeds in either a JSON array of the objects with
+// "url", "max_depth" and "meta", or lines of URLs or such objects. The empty
+// lines and the ones starting with "#" are ignored.
+func LoadSeeds(r io.Reader) ([]Seed, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, genErrorByError(err)
+	}
+	var records []seedRecord
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &records); err != nil {
+			return nil, genParameterError(fmt.Sprintf("illegal seed list: %s", err))
+		}
+	} else {
+		for i, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			record := seedRecord{URL: line}
+			if strings.HasPrefix(line, "{") {
+				record = seedRecord{}
+				if err := json.Unmarshal([]byte(line), &record); err != nil {
+					return nil, genParameterError(fmt.Sprintf("illegal seed at line %d: %s", i+1, err))
+				}
+			}
+			records = append(records, record)
+		}
+	}
+	seeds := make([]Seed, 0, len(records))
+	for _, record := range records {
+		seed, err := record.seed()
+		if err != nil {
+			return nil, err
+		}
+		seeds = append(seeds, seed)
+	}
+	return seeds, nil
+}
+
+// LoadSeedFile loads the seeds in the file, see LoadSeeds.
+func LoadSeedFile(path string) ([]Seed, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, genErrorByError(err)
+	}
+	defer file.Close()
+	return LoadSeeds(file)
+}
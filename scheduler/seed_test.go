@@ -0,0 +1,301 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"webcrawler/module"
+	"webcrawler/module/local/analyzer"
+	"webcrawler/module/local/downloader"
+	"webcrawler/module/local/pipeline"
+	"webcrawler/toolkit/seenset"
+)
+
+func TestLoadSeeds(t *testing.T) {
+	seeds, err := LoadSeeds(strings.NewReader(`
+# The seeds.
+http://a.com/
+{"url": "http://b.com/", "max_depth": 2, "meta": {"anchor_text": "b", "user_data": {"site": "b"}}}
+
+	http://c.com/x  
+`))
+	if err != nil {
+		t.Fatalf("An error occurs when loading seeds: %s", err)
+	}
+	if len(seeds) != 3 || seeds[0].HTTPReq.URL.String() != "http://a.com/" || seeds[2].HTTPReq.URL.String() != "http://c.com/x" {
+		t.Fatalf("Inconsistent seeds: %#v", seeds)
+	}
+	if seeds[1].MaxDepth != 2 || seeds[1].Meta.AnchorText != "b" || seeds[1].Meta.UserData["site"] != "b" {
+		t.Fatalf("Inconsistent seed: %#v", seeds[1])
+	}
+	if meta := seeds[1].request().Meta(); meta.MaxDepth != 2 || meta.AnchorText != "b" {
+		t.Fatalf("Inconsistent metadata of the seed request: %#v", meta)
+	}
+	path := filepath.Join(t.TempDir(), "seeds.json")
+	os.WriteFile(path, []byte(` [{"url": "http://a.com/"}, {"url": "http://b.com/", "max_depth": 1}]`), 0644)
+	seeds, err = LoadSeedFile(path)
+	if err != nil {
+		t.Fatalf("An error occurs when loading the seed file: %s", err)
+	}
+	if len(seeds) != 2 || seeds[1].HTTPReq.Method != http.MethodGet || seeds[1].MaxDepth != 1 {
+		t.Fatalf("Inconsistent seeds from the file: %#v", seeds)
+	}
+	if _, err := LoadSeedFile(filepath.Join(t.TempDir(), "none")); err == nil {
+		t.Fatal("No error when loading a missing seed file")
+	}
+	illegalSeeds := []string{
+		`[{"url": "http://a.com/"}`,
+		`{"url": 1}`,
+		"/relative",
+		"http://a.com/%zz",
+	}
+	for _, s := range illegalSeeds {
+		if _, err := LoadSeeds(strings.NewReader(s)); err == nil {
+			t.Fatalf("No error when loading illegal seeds: %q", s)
+		}
+	}
+}
+
+func TestSchedSeeds(t *testing.T) {
+	var lock sync.Mutex
+	hits := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		hits[r.URL.Path]++
+		lock.Unlock()
+		// Every page links to the next one in its chain.
+		dir, n := filepath.Split(r.URL.Path)
+		i, _ := strconv.Atoi(n)
+		fmt.Fprintf(w, `<a href="%s%d">next</a>`, dir, i+1)
+	}))
+	defer server.Close()
+	d, _ := downloader.New("D1", &http.Client{}, nil)
+	a, _ := analyzer.New("A2", []module.ParseResponse{parseRecrawlPage}, nil)
+	p, _ := pipeline.New("P3", []module.ProcessItem{func(item module.Item) (module.Item, error) {
+		return item, nil
+	}}, nil)
+	moduleArgs := ModuleArgs{
+		Downloaders: []module.Downloader{d},
+		Analyzers:   []module.Analyzer{a},
+		Pipelines:   []module.Pipeline{p},
+	}
+	sched := NewScheduler()
+	if _, err := sched.AddSeeds([]Seed{{}}); err == nil {
+		t.Fatal("No error when adding seeds to the uninitialized scheduler")
+	}
+	if err := sched.Init(genRequestArgs([]string{}, 2), genDataArgs(10, 2, 1), moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	if err := sched.StartSeeds(nil); err == nil {
+		t.Fatal("No error when starting scheduler without seed")
+	}
+	if err := sched.StartSeeds([]Seed{{}}); err == nil {
+		t.Fatal("No error when starting scheduler with a seed without HTTP request")
+	}
+	seedA, _ := NewSeed(server.URL + "/a/0")
+	seedA.MaxDepth = 1
+	seedB, _ := NewSeed(server.URL + "/b/0")
+	seedB.MaxDepth = 3
+	if err := sched.StartSeeds([]Seed{seedA, seedB}); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	waitIdle := func() {
+		begin := time.Now()
+		for !sched.Idle() || time.Since(begin) < 200*time.Millisecond {
+			if time.Since(begin) > 10*time.Second {
+				t.Fatal("The scheduler has not been idle")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	checkHits := func(chain string, expected int) {
+		lock.Lock()
+		defer lock.Unlock()
+		for i := 0; i <= expected; i++ {
+			path := fmt.Sprintf("/%s/%d", chain, i)
+			if i < expected && hits[path] != 1 || i == expected && hits[path] != 0 {
+				t.Fatalf("Inconsistent hits of %s: %d", path, hits[path])
+			}
+		}
+	}
+	waitIdle()
+	checkHits("a", 2)
+	checkHits("b", 4)
+	seedC, _ := NewSeed(server.URL + "/c/0")
+	accepted, err := sched.AddSeeds([]Seed{seedC, seedA})
+	if err != nil {
+		t.Fatalf("An error occurs when adding seeds: %s", err)
+	}
+	if accepted != 1 {
+		t.Fatalf("Inconsistent accepted seed number, expected: 1, actual: %d", accepted)
+	}
+	waitIdle()
+	checkHits("c", 3)
+}
+
+func TestSchedAddSeedsPaused(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html><body>")
+		for i := 0; i < 50; i++ {
+			fmt.Fprintf(w, `<a href="%s/%d">page</a>`, r.URL.Path, i)
+		}
+		fmt.Fprint(w, "</body></html>")
+	}))
+	defer server.Close()
+	sched := NewScheduler()
+	requestArgs := genRequestArgs([]string{}, 3)
+	// The request buffer pool holds one request, so that the seeds wait for
+	// the dispatcher.
+	if err := sched.Init(requestArgs, genDataArgs(1, 1, 0), genSimpleModuleArgs(2, 1, 1, t)); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL, nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := sched.Pause(); err != nil {
+		t.Fatalf("An error occurs when pausing scheduler: %s", err)
+	}
+	seeds := make([]Seed, 20)
+	for i := range seeds {
+		seeds[i], _ = NewSeed(fmt.Sprintf("%s/seed/%d", server.URL, i))
+	}
+	type result struct {
+		accepted int
+		err      error
+	}
+	add := func(seeds []Seed) chan result {
+		added := make(chan result, 1)
+		go func() {
+			accepted, err := sched.AddSeeds(seeds)
+			added <- result{accepted, err}
+		}()
+		return added
+	}
+	select {
+	case r := <-add(seeds[:10]):
+		if r.err != nil {
+			t.Fatalf("An error occurs when adding seeds: %s", r.err)
+		}
+		if r.accepted != 10 {
+			t.Fatalf("Inconsistent accepted seed number, expected: 10, actual: %d", r.accepted)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Adding the seeds to the paused scheduler is blocked")
+	}
+	added := add(seeds[10:])
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- sched.Stop()
+	}()
+	select {
+	case r := <-added:
+		// The seeds are either all added before the scheduler is stopped, or
+		// the stop is reported.
+		if r.err == nil && r.accepted != 10 {
+			t.Fatalf("Inconsistent accepted seed number, expected: 10, actual: %d", r.accepted)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Adding the seeds while stopping the scheduler is blocked")
+	}
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Fatalf("An error occurs when stopping scheduler: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("The scheduler with queued seeds is stuck (status: %s)", GetStatusDescription(sched.Status()))
+	}
+	if _, err := sched.AddSeeds(seeds); err == nil {
+		t.Fatal("No error when adding seeds to the stopped scheduler")
+	}
+}
+
+// slowSeenSet is a seen set which holds the checks of the seeds until
+// released.
+type slowSeenSet struct {
+	seenset.SeenSet
+	checking chan struct{}
+	release  chan struct{}
+}
+
+func (set *slowSeenSet) Contains(key string) bool {
+	if strings.Contains(key, "/seed/") {
+		select {
+		case set.checking <- struct{}{}:
+		default:
+		}
+		<-set.release
+	}
+	return set.SeenSet.Contains(key)
+}
+
+func TestSchedAddSeedsSlow(t *testing.T) {
+	server := genDrainServer(0)
+	defer server.Close()
+	set := &slowSeenSet{
+		SeenSet:  seenset.NewExact(),
+		checking: make(chan struct{}, 1),
+		release:  make(chan struct{}),
+	}
+	requestArgs := genRequestArgs([]string{}, 1)
+	requestArgs.SeenSet = SeenSetArgs{Shared: set}
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, genDataArgs(10, 2, 1), genSimpleModuleArgs(1, 1, 1, t)); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL, nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	seeds := make([]Seed, 5)
+	for i := range seeds {
+		seeds[i], _ = NewSeed(fmt.Sprintf("%s/seed/%d", server.URL, i))
+	}
+	added := make(chan error, 1)
+	go func() {
+		_, err := sched.AddSeeds(seeds)
+		added <- err
+	}()
+	select {
+	case <-set.checking:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The seeds have not been checked")
+	}
+	// Pausing and stopping don't wait for the seeds being sent.
+	for _, op := range []struct {
+		name string
+		fn   func() error
+	}{{"pausing", sched.Pause}, {"stopping", sched.Stop}} {
+		done := make(chan error, 1)
+		go func() {
+			done <- op.fn()
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("An error occurs when %s scheduler: %s", op.name, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("The scheduler is blocked by adding seeds when %s", op.name)
+		}
+	}
+	close(set.release)
+	select {
+	case err := <-added:
+		if err == nil {
+			t.Fatal("No error when the scheduler is stopped while adding seeds")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Adding the seeds to the stopped scheduler is blocked")
+	}
+}
@@ -0,0 +1,298 @@
+package scheduler
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"webcrawler/module"
+	"webcrawler/toolkit/robots"
+	"webcrawler/toolkit/sitemap"
+	"webcrawler/toolkit/urlnorm"
+)
+
+// The keys of the user data in the metadata of the requests from the
+// sitemaps, whose values are the lastmod (time.Time, if present) and the
+// priority (float64) of the URLs, e.g. for a scorer.
+const (
+	META_KEY_SITEMAP_LASTMOD  = "sitemap_lastmod"
+	META_KEY_SITEMAP_PRIORITY = "sitemap_priority"
+)
+
+const (
+	defaultMaxSitemaps  = 100
+	defaultSitemapHosts = 2
+)
+
+// sitemapJob is a host whose sitemaps are to be fetched.
+type sitemapJob struct {
+	ctx      context.Context
+	scheme   string
+	host     string
+	depth    uint32
+	maxDepth uint32
+}
+
+// sitemapper discovers the sitemaps of the crawled hosts. The hosts are
+// walked by at most maxHosts goroutines at the same time, and the sitemaps
+// are fetched in the turns of their hosts in the politeness queues.
+type sitemapper struct {
+	on          bool
+	client      *http.Client
+	userAgent   string
+	robots      robots.Cache
+	politeness  *politeness
+	maxSitemaps uint32
+	maxURLs     uint32
+	maxHosts    uint32
+	hosts       sync.Map
+	jobs        []sitemapJob
+	walkers     uint32
+	lock        sync.Mutex
+	hostNumber  uint64
+	fetched     uint64
+	failed      uint64
+	disallowed  uint64
+	urls        uint64
+	enqueued    uint64
+	expedited   uint64
+}
+
+func newSitemapper(args SitemapArgs, robotsArgs RobotsArgs, filter *robotsFilter, p *politeness) *sitemapper {
+	s := &sitemapper{
+		on:          args.Enabled,
+		politeness:  p,
+		maxSitemaps: args.MaxSitemaps,
+		maxURLs:     args.MaxURLs,
+		maxHosts:    args.MaxHosts,
+	}
+	if !s.on {
+		return s
+	}
+	if s.maxSitemaps == 0 {
+		s.maxSitemaps = defaultMaxSitemaps
+	}
+	if s.maxHosts == 0 {
+		s.maxHosts = defaultSitemapHosts
+	}
+	s.client = &http.Client{Timeout: 30 * time.Second}
+	s.userAgent = robotsArgs.UserAgent
+	if filter.enabled() {
+		s.robots = filter.cache
+	} else {
+		s.robots = robots.NewCache(&http.Client{Timeout: 10 * time.Second}, robotsArgs.UserAgent, robotsArgs.Expiry)
+	}
+	return s
+}
+
+func (s *sitemapper) enabled() bool {
+	return s.on
+}
+
+func (s *sitemapper) clear() {
+	s.hosts = sync.Map{}
+	s.lock.Lock()
+	s.jobs = nil
+	s.lock.Unlock()
+}
+
+// busy reports whether the sitemaps of any host are being fetched or waiting
+// for it.
+func (s *sitemapper) busy() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.walkers > 0
+}
+
+// add adds the job, and starts a walker if there are fewer than maxHosts.
+func (s *sitemapper) add(job sitemapJob, fn func(job sitemapJob, entry sitemap.Entry)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.jobs = append(s.jobs, job)
+	if s.walkers < s.maxHosts {
+		s.walkers++
+		go s.run(fn)
+	}
+}
+
+// run walks the hosts of the jobs until none is left.
+func (s *sitemapper) run(fn func(job sitemapJob, entry sitemap.Entry)) {
+	for {
+		s.lock.Lock()
+		if len(s.jobs) == 0 {
+			s.walkers--
+			s.lock.Unlock()
+			return
+		}
+		job := s.jobs[0]
+		s.jobs = s.jobs[1:]
+		s.lock.Unlock()
+		s.walk(job.ctx, job.scheme, job.host, func(entry sitemap.Entry) {
+			fn(job, entry)
+		})
+	}
+}
+
+// allowed reports whether the sitemap could be fetched by robots.txt of its
+// host.
+func (s *sitemapper) allowed(loc *url.URL) bool {
+	if s.robots.Get(loc.Scheme, loc.Host).Allowed(loc) {
+		return true
+	}
+	atomic.AddUint64(&s.disallowed, 1)
+	logger.Infof("Ignore the sitemap disallowed by robots.txt (URL: %s)", loc)
+	return false
+}
+
+// fetch fetches the sitemap in the turn of its host.
+func (s *sitemapper) fetch(ctx context.Context, loc *url.URL) (*sitemap.Sitemap, error) {
+	if err := s.politeness.acquire(ctx, loc.Host); err != nil {
+		return nil, err
+	}
+	defer s.politeness.release(loc.Host)
+	return sitemap.Fetch(ctx, s.client, s.userAgent, loc.String())
+}
+
+// walk fetches the sitemaps listed in robots.txt of the host and the one at
+// /sitemap.xml, following the sitemap indexes to the sitemaps on the same
+// host as the indexes, and calls fn with their URLs. The sitemaps disallowed
+// by robots.txt are skipped.
+func (s *sitemapper) walk(ctx context.Context, scheme string, host string, fn func(sitemap.Entry)) {
+	base := strings.ToLower(scheme + "://" + host)
+	rules := s.robots.Get(scheme, host)
+	// The sitemaps listed in robots.txt are expected to exist.
+	listed := map[string]bool{}
+	var queue []string
+	for _, loc := range rules.Sitemaps() {
+		if !listed[loc] {
+			listed[loc] = true
+			queue = append(queue, loc)
+		}
+	}
+	if defaultLoc := base + "/sitemap.xml"; !listed[defaultLoc] {
+		queue = append(queue, defaultLoc)
+	}
+	visited := map[string]bool{}
+	var fetched, urls uint32
+	for len(queue) > 0 && fetched < s.maxSitemaps {
+		if ctx.Err() != nil {
+			return
+		}
+		loc := queue[0]
+		queue = queue[1:]
+		if visited[loc] {
+			continue
+		}
+		visited[loc] = true
+		locURL, err := url.Parse(loc)
+		if err != nil || (locURL.Scheme != "http" && locURL.Scheme != "https") || locURL.Host == "" {
+			logger.Warnf("Ignore the illegal sitemap URL %q", loc)
+			continue
+		}
+		if !s.allowed(locURL) {
+			continue
+		}
+		fetched++
+		sm, err := s.fetch(ctx, locURL)
+		if err != nil {
+			if listed[loc] && ctx.Err() == nil {
+				atomic.AddUint64(&s.failed, 1)
+				logger.Warnf("Could not fetch the sitemap: %s (URL: %s)", err, loc)
+			}
+			continue
+		}
+		atomic.AddUint64(&s.fetched, 1)
+		for _, entry := range sm.Sitemaps {
+			child, err := url.Parse(entry.Loc)
+			if err != nil || !strings.EqualFold(child.Host, locURL.Host) {
+				logger.Warnf("Ignore the sitemap on another host in the index (URL: %s, index: %s)", entry.Loc, loc)
+				continue
+			}
+			listed[entry.Loc] = true
+			queue = append(queue, entry.Loc)
+		}
+		for _, entry := range sm.URLs {
+			if s.maxURLs > 0 && urls >= s.maxURLs {
+				return
+			}
+			urls++
+			atomic.AddUint64(&s.urls, 1)
+			fn(entry)
+		}
+	}
+}
+
+func (s *sitemapper) summary() SitemapSummaryStruct {
+	return SitemapSummaryStruct{
+		Enabled:    s.enabled(),
+		HostNumber: atomic.LoadUint64(&s.hostNumber),
+		Fetched:    atomic.LoadUint64(&s.fetched),
+		Failed:     atomic.LoadUint64(&s.failed),
+		Disallowed: atomic.LoadUint64(&s.disallowed),
+		URLs:       atomic.LoadUint64(&s.urls),
+		Enqueued:   atomic.LoadUint64(&s.enqueued),
+		Expedited:  atomic.LoadUint64(&s.expedited),
+	}
+}
+
+// discoverSitemaps enqueues the URLs in the sitemaps of the host of the
+// request in the background once per host, with the depth and the max depth
+// of the request.
+func (sched *myScheduler) discoverSitemaps(req *module.Request) {
+	s := sched.sitemaps
+	if !s.enabled() {
+		return
+	}
+	reqURL := req.HTTPReq().URL
+	key := strings.ToLower(reqURL.Scheme + "://" + reqURL.Host)
+	if _, loaded := s.hosts.LoadOrStore(key, struct{}{}); loaded {
+		return
+	}
+	atomic.AddUint64(&s.hostNumber, 1)
+	job := sitemapJob{
+		ctx:      sched.acceptCtx,
+		scheme:   reqURL.Scheme,
+		host:     reqURL.Host,
+		depth:    req.Depth(),
+		maxDepth: req.Meta().MaxDepth,
+	}
+	s.add(job, func(job sitemapJob, entry sitemap.Entry) {
+		sched.sendSitemapURL(entry, job.depth, job.maxDepth)
+	})
+}
+
+// sendSitemapURL sends the request of the URL in a sitemap through the
+// filters. The priority of the URL scaled to [0, 10] is the priority of the
+// request, and a lastmod after the last fetch in an incremental crawl makes
+// the URL due.
+func (sched *myScheduler) sendSitemapURL(entry sitemap.Entry, depth uint32, maxDepth uint32) {
+	httpReq, err := http.NewRequest(http.MethodGet, entry.Loc, nil)
+	if err != nil {
+		logger.Warnf("Ignore the URL in the sitemap: %s (URL: %s)", err, entry.Loc)
+		return
+	}
+	userData := map[string]interface{}{META_KEY_SITEMAP_PRIORITY: entry.Priority}
+	if !entry.LastMod.IsZero() {
+		userData[META_KEY_SITEMAP_LASTMOD] = entry.LastMod
+	}
+	meta := module.Meta{
+		Priority: int(math.Round(entry.Priority * 10)),
+		UserData: userData,
+		MaxDepth: maxDepth,
+	}
+	req := module.NewRequestWithMeta(httpReq, depth, meta)
+	reqURL := httpReq.URL
+	if sched.urlNorm.Enabled() {
+		reqURL = urlnorm.Normalize(reqURL, sched.urlNorm)
+	}
+	if sched.recrawl.expedite(reqURL.String(), entry.LastMod) {
+		atomic.AddUint64(&sched.sitemaps.expedited, 1)
+	}
+	if sched.sendReq(req) {
+		atomic.AddUint64(&sched.sitemaps.enqueued, 1)
+	}
+}
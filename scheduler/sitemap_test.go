@@ -0,0 +1,134 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"webcrawler/module"
+	"webcrawler/module/local/analyzer"
+	"webcrawler/module/local/downloader"
+	"webcrawler/module/local/pipeline"
+	"webcrawler/toolkit/recrawl"
+)
+
+func TestSchedSitemaps(t *testing.T) {
+	var lock sync.Mutex
+	hits := map[string]int{}
+	var otherHits int32
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&otherHits, 1)
+		fmt.Fprint(w, "User-agent: *\n")
+	}))
+	defer other.Close()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		hits[r.URL.Path]++
+		lock.Unlock()
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprintf(w, "User-agent: *\nDisallow: /private\nSitemap: %s/index.xml\n", server.URL)
+		case "/index.xml":
+			fmt.Fprintf(w, `<sitemapindex>
+<sitemap><loc>%[1]s/s1.xml</loc></sitemap>
+<sitemap><loc>%[1]s/private/s2.xml</loc></sitemap>
+<sitemap><loc>%[2]s/s3.xml</loc></sitemap>
+</sitemapindex>`, server.URL, other.URL)
+		case "/s1.xml":
+			fmt.Fprintf(w, `<urlset>
+<url><loc>%[1]s/p1</loc><lastmod>2030-01-01</lastmod><priority>1.0</priority></url>
+<url><loc>%[1]s/p2</loc></url>
+<url><loc>%[1]s/private</loc></url>
+<url><loc>http://other.com/x</loc></url>
+</urlset>`, server.URL)
+		case "/sitemap.xml":
+			fmt.Fprintf(w, "%s/p3\n%s/p1\n", server.URL, server.URL)
+		default:
+			fmt.Fprint(w, "<html></html>")
+		}
+	}))
+	defer server.Close()
+	store := recrawl.NewMemory()
+	now := time.Now()
+	// The page is modified after the last fetch by the lastmod.
+	store.Put(recrawl.Record{URL: server.URL + "/p1", LastFetch: now, NextFetch: now.Add(time.Hour)})
+	store.Put(recrawl.Record{URL: server.URL + "/p2", LastFetch: now, NextFetch: now.Add(time.Hour)})
+	collector := &changeCollector{changes: map[string]string{}}
+	d, _ := downloader.New("D1", &http.Client{}, nil)
+	a, _ := analyzer.New("A2", []module.ParseResponse{parseRecrawlPage}, nil)
+	p, _ := pipeline.New("P3", []module.ProcessItem{collector.process}, nil)
+	requestArgs := genRequestArgs([]string{}, 0)
+	requestArgs.Robots = RobotsArgs{Enabled: true, UserAgent: "webcrawler/1.0"}
+	requestArgs.Sitemaps = SitemapArgs{Enabled: true}
+	requestArgs.Recrawl = RecrawlArgs{Store: store}
+	metas := map[string]module.Meta{}
+	requestArgs.Scorer = func(req *module.Request) int {
+		lock.Lock()
+		metas[req.HTTPReq().URL.Path] = req.Meta()
+		lock.Unlock()
+		return req.Priority()
+	}
+	sched := NewScheduler()
+	moduleArgs := ModuleArgs{
+		Downloaders: []module.Downloader{d},
+		Analyzers:   []module.Analyzer{a},
+		Pipelines:   []module.Pipeline{p},
+	}
+	if err := sched.Init(requestArgs, genDataArgs(10, 2, 1), moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest(http.MethodGet, server.URL+"/", nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	begin := time.Now()
+	for !sched.Idle() || time.Since(begin) < 200*time.Millisecond {
+		if time.Since(begin) > 10*time.Second {
+			t.Fatal("The scheduler has not been idle")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	for path, expected := range map[string]int{"/": 1, "/p1": 1, "/p2": 0, "/p3": 1, "/private": 0,
+		"/private/s2.xml": 0, "/sitemap.xml": 1} {
+		if hits[path] != expected {
+			t.Fatalf("Inconsistent hits of %s, expected: %d, actual: %d", path, expected, hits[path])
+		}
+	}
+	// The sitemap on another host in the index is not followed.
+	if n := atomic.LoadInt32(&otherHits); n != 0 {
+		t.Fatalf("Inconsistent hits of the other host, expected: 0, actual: %d", n)
+	}
+	meta := metas["/p1"]
+	lastMod, _ := meta.UserData[META_KEY_SITEMAP_LASTMOD].(time.Time)
+	if meta.Priority != 10 || meta.UserData[META_KEY_SITEMAP_PRIORITY] != 1.0 ||
+		!lastMod.Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Inconsistent metadata of the URL in the sitemap: %#v", meta)
+	}
+	if meta := metas["/p3"]; meta.Priority != 5 || meta.UserData[META_KEY_SITEMAP_LASTMOD] != nil {
+		t.Fatalf("Inconsistent metadata of the URL in the sitemap: %#v", meta)
+	}
+	summary := sched.Summary().Struct().Sitemaps
+	expected := SitemapSummaryStruct{
+		Enabled:    true,
+		HostNumber: 1,
+		Fetched:    3,
+		Disallowed: 1,
+		URLs:       6,
+		// The URL disallowed by robots.txt is rejected in the download stage.
+		Enqueued:  3,
+		Expedited: 1,
+	}
+	if summary != expected {
+		t.Fatalf("Inconsistent sitemap summary, expected: %#v, actual: %#v", expected, summary)
+	}
+	if rejected := sched.Summary().Struct().Robots.Rejected; rejected != 1 {
+		t.Fatalf("Inconsistent rejected request number, expected: %d, actual: %d", 1, rejected)
+	}
+}
@@ -15,6 +15,8 @@ const (
 	SCHED_STATUS_STARTED       Status = 4
 	SCHED_STATUS_STOPPING      Status = 5
 	SCHED_STATUS_STOPPED       Status = 6
+	SCHED_STATUS_PAUSING       Status = 7
+	SCHED_STATUS_PAUSED        Status = 8
 )
 
 func checkStatus(currentStatus Status, wantedStatus Status, lock sync.Locker) (err error) {
@@ -29,6 +31,11 @@ func checkStatus(currentStatus Status, wantedStatus Status, lock sync.Locker) (e
 		err = genError("the scheduler is being starting")
 	case SCHED_STATUS_STOPPING:
 		err = genError("the scheduler is being stopping")
+	case SCHED_STATUS_PAUSING:
+		// The pausing scheduler can be stopped.
+		if wantedStatus != SCHED_STATUS_STOPPING {
+			err = genError("the scheduler is being pausing")
+		}
 	}
 	if err != nil {
 		return
@@ -43,7 +50,8 @@ func checkStatus(currentStatus Status, wantedStatus Status, lock sync.Locker) (e
 		switch currentStatus {
 		case SCHED_STATUS_STARTED:
 			err = genError("the scheduler has been started")
-
+		case SCHED_STATUS_PAUSED:
+			err = genError("the scheduler has been paused")
 		}
 	case SCHED_STATUS_STARTING:
 		switch currentStatus {
@@ -51,8 +59,15 @@ func checkStatus(currentStatus Status, wantedStatus Status, lock sync.Locker) (e
 			err = genError("the scheduler has not been initialized")
 		case SCHED_STATUS_STARTED:
 			err = genError("the scheduler has been started")
+		case SCHED_STATUS_PAUSED:
+			err = genError("the scheduler has been paused")
 		}
 	case SCHED_STATUS_STOPPING:
+		if currentStatus != SCHED_STATUS_STARTED && currentStatus != SCHED_STATUS_PAUSING &&
+			currentStatus != SCHED_STATUS_PAUSED {
+			err = genError("the scheduler has not been started")
+		}
+	case SCHED_STATUS_PAUSING:
 		if currentStatus != SCHED_STATUS_STARTED {
 			err = genError("the scheduler has not been started")
 		}
@@ -81,6 +96,10 @@ func GetStatusDescription(status Status) string {
 		return "stopping"
 	case SCHED_STATUS_STOPPED:
 		return "stopped"
+	case SCHED_STATUS_PAUSING:
+		return "pausing"
+	case SCHED_STATUS_PAUSED:
+		return "paused"
 	default:
 		return "unknown"
 	}
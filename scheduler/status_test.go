@@ -86,6 +86,50 @@ func TestCheckStatus(t *testing.T) {
 	}
 }
 
+func TestCheckStatusPause(t *testing.T) {
+	currentStatusList := []Status{
+		SCHED_STATUS_UNINITIALIZED,
+		SCHED_STATUS_INITIALIZING,
+		SCHED_STATUS_INITIALIZED,
+		SCHED_STATUS_STARTING,
+		SCHED_STATUS_STOPPING,
+		SCHED_STATUS_STOPPED,
+		SCHED_STATUS_PAUSING,
+		SCHED_STATUS_PAUSED,
+	}
+	wantedStatus := SCHED_STATUS_PAUSING
+	for _, currentStatus := range currentStatusList {
+		if err := checkStatus(currentStatus, wantedStatus, nil); err == nil {
+			t.Fatalf("It can still check status with incorrect current status %q, wanted Status %q",
+				GetStatusDescription(currentStatus), GetStatusDescription(wantedStatus))
+		}
+	}
+	if err := checkStatus(SCHED_STATUS_STARTED, wantedStatus, nil); err != nil {
+		t.Fatalf("An error occurs when checking status, current status %q, wanted Status %q",
+			GetStatusDescription(SCHED_STATUS_STARTED), GetStatusDescription(wantedStatus))
+	}
+	for _, wantedStatus := range []Status{SCHED_STATUS_INITIALIZING, SCHED_STATUS_STARTING} {
+		if err := checkStatus(SCHED_STATUS_PAUSING, wantedStatus, nil); err == nil {
+			t.Fatalf("It can still check status with incorrect current status %q, wanted Status %q",
+				GetStatusDescription(SCHED_STATUS_PAUSING), GetStatusDescription(wantedStatus))
+		}
+	}
+	if err := checkStatus(SCHED_STATUS_PAUSING, SCHED_STATUS_STOPPING, nil); err != nil {
+		t.Fatalf("An error occurs when checking status, current status %q, wanted Status %q",
+			GetStatusDescription(SCHED_STATUS_PAUSING), GetStatusDescription(SCHED_STATUS_STOPPING))
+	}
+	for _, wantedStatus := range []Status{SCHED_STATUS_INITIALIZING, SCHED_STATUS_STARTING} {
+		if err := checkStatus(SCHED_STATUS_PAUSED, wantedStatus, nil); err == nil {
+			t.Fatalf("It can still check status with incorrect current status %q, wanted Status %q",
+				GetStatusDescription(SCHED_STATUS_PAUSED), GetStatusDescription(wantedStatus))
+		}
+	}
+	if err := checkStatus(SCHED_STATUS_PAUSED, SCHED_STATUS_STOPPING, nil); err != nil {
+		t.Fatalf("An error occurs when checking status, current status %q, wanted Status %q",
+			GetStatusDescription(SCHED_STATUS_PAUSED), GetStatusDescription(SCHED_STATUS_STOPPING))
+	}
+}
+
 func TestCheckStatusInParallel(t *testing.T) {
 	number := 1000
 	var lock sync.Mutex
@@ -0,0 +1,52 @@
+package scheduler
+
+import (
+	"fmt"
+	"webcrawler/module"
+)
+
+// Scorer computes the priority of a request, e.g. from its depth, host or
+// the anchor text of the link. Requests with higher priorities are
+// downloaded first. Without a scorer the priority carried by the request is
+// used.
+type Scorer func(req *module.Request) int
+
+const (
+	CRAWL_STRATEGY_BFS        = "bfs"
+	CRAWL_STRATEGY_DFS        = "dfs"
+	CRAWL_STRATEGY_BEST_FIRST = "best-first"
+)
+
+// crawlStrategy decides the order of the queued requests: the higher rank
+// first, then the earlier one, or the later one if lifo is true.
+type crawlStrategy struct {
+	name string
+	rank Scorer
+	lifo bool
+}
+
+func newCrawlStrategy(name string, scorer Scorer) (crawlStrategy, error) {
+	switch name {
+	case "":
+		if scorer == nil {
+			scorer = func(req *module.Request) int {
+				return req.Priority()
+			}
+		}
+		return crawlStrategy{name: "priority", rank: scorer}, nil
+	case CRAWL_STRATEGY_BFS:
+		return crawlStrategy{name: name, rank: func(req *module.Request) int {
+			return -int(req.Depth())
+		}}, nil
+	case CRAWL_STRATEGY_DFS:
+		return crawlStrategy{name: name, rank: func(req *module.Request) int {
+			return int(req.Depth())
+		}, lifo: true}, nil
+	case CRAWL_STRATEGY_BEST_FIRST:
+		if scorer == nil {
+			return crawlStrategy{}, genError("nil scorer for best-first crawl strategy")
+		}
+		return crawlStrategy{name: name, rank: scorer}, nil
+	}
+	return crawlStrategy{}, genError(fmt.Sprintf("unsupported crawl strategy %q", name))
+}
@@ -0,0 +1,92 @@
+package scheduler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"webcrawler/module"
+)
+
+func genStrategyRequests(t *testing.T) []*module.Request {
+	cases := []struct {
+		url   string
+		depth uint32
+	}{
+		{"http://a.com/", 0},
+		{"http://a.com/1", 1},
+		{"http://b.com/2", 1},
+		{"http://a.com/1/1", 2},
+		{"http://b.com/2/1", 2},
+		{"http://a.com/sports", 1},
+	}
+	reqs := make([]*module.Request, 0, len(cases))
+	for _, c := range cases {
+		httpReq, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("An error occurs when creating a HTTP request: %s (url: %s)", err, c.url)
+		}
+		reqs = append(reqs, module.NewRequest(httpReq, c.depth))
+	}
+	return reqs
+}
+
+func checkStrategyOrder(t *testing.T, name string, scorer Scorer, expected []string) {
+	strategy, err := newCrawlStrategy(name, scorer)
+	if err != nil {
+		t.Fatalf("An error occurs when creating crawl strategy %q: %s", name, err)
+	}
+	p := newPoliteness(PolitenessArgs{}, strategy)
+	for _, req := range genStrategyRequests(t) {
+		p.push(req)
+	}
+	for i, url := range expected {
+		req, err := p.next(context.Background())
+		if err != nil {
+			t.Fatalf("An error occurs when getting the next request: %s", err)
+		}
+		if actual := req.HTTPReq().URL.String(); actual != url {
+			t.Fatalf("Inconsistent request %d for crawl strategy %q, expected: %s, actual: %s", i, name, url, actual)
+		}
+	}
+}
+
+func TestCrawlStrategy(t *testing.T) {
+	checkStrategyOrder(t, "", nil, []string{
+		"http://a.com/", "http://a.com/1", "http://b.com/2",
+		"http://a.com/1/1", "http://b.com/2/1", "http://a.com/sports",
+	})
+	checkStrategyOrder(t, CRAWL_STRATEGY_BFS, nil, []string{
+		"http://a.com/", "http://a.com/1", "http://b.com/2",
+		"http://a.com/sports", "http://a.com/1/1", "http://b.com/2/1",
+	})
+	checkStrategyOrder(t, CRAWL_STRATEGY_DFS, nil, []string{
+		"http://b.com/2/1", "http://a.com/1/1", "http://a.com/sports",
+		"http://b.com/2", "http://a.com/1", "http://a.com/",
+	})
+	sportsFirst := func(req *module.Request) int {
+		if strings.Contains(req.HTTPReq().URL.Path, "sports") {
+			return 10
+		}
+		return -int(req.Depth())
+	}
+	checkStrategyOrder(t, CRAWL_STRATEGY_BEST_FIRST, sportsFirst, []string{
+		"http://a.com/sports", "http://a.com/", "http://a.com/1",
+		"http://b.com/2", "http://a.com/1/1", "http://b.com/2/1",
+	})
+	if _, err := newCrawlStrategy(CRAWL_STRATEGY_BEST_FIRST, nil); err == nil {
+		t.Fatal("No error when creating best-first crawl strategy without scorer")
+	}
+	if _, err := newCrawlStrategy("random", nil); err == nil {
+		t.Fatal("No error when creating an unsupported crawl strategy")
+	}
+	requestArgs := genRequestArgs([]string{}, 0)
+	requestArgs.CrawlStrategy = CRAWL_STRATEGY_BEST_FIRST
+	if err := requestArgs.Check(); err == nil {
+		t.Fatal("No error when checking request arguments of best-first crawl strategy without scorer")
+	}
+	requestArgs.Scorer = sportsFirst
+	if err := requestArgs.Check(); err != nil {
+		t.Fatalf("An error occurs when checking request arguments: %s", err)
+	}
+}
@@ -33,18 +33,24 @@ type mySchedSummary struct {
 }
 
 type SummaryStruct struct {
-	RequestArgs     RequestArgs             `json:"request_args"`
-	DataArgs        DataArgs                `json:"data_args"`
-	ModuleArgs      ModuleArgsSummary       `json:"module_args"`
-	Status          string                  `json:"status"`
-	Downloaders     []module.SummaryStruct  `json:"downloaders"`
-	Analyzers       []module.SummaryStruct  `json:"analyzers"`
-	Pipelines       []module.SummaryStruct  `json:"pipelines"`
-	ReqBufferPool   BufferPoolSummaryStruct `json:"request_buffer_pool"`
-	RespBufferPool  BufferPoolSummaryStruct `json:"response_buffer_pool"`
-	ItemBufferPool  BufferPoolSummaryStruct `json:"item_buffer_pool"`
-	ErrorBufferPool BufferPoolSummaryStruct `json:"error_buffer_pool"`
-	NumURL          uint64                  `json:"url_number"`
+	RequestArgs     RequestArgs              `json:"request_args"`
+	DataArgs        DataArgs                 `json:"data_args"`
+	ModuleArgs      ModuleArgsSummary        `json:"module_args"`
+	Status          string                   `json:"status"`
+	Downloaders     []module.SummaryStruct   `json:"downloaders"`
+	Analyzers       []module.SummaryStruct   `json:"analyzers"`
+	Pipelines       []module.SummaryStruct   `json:"pipelines"`
+	ReqBufferPool   BufferPoolSummaryStruct  `json:"request_buffer_pool"`
+	RespBufferPool  BufferPoolSummaryStruct  `json:"response_buffer_pool"`
+	ItemBufferPool  BufferPoolSummaryStruct  `json:"item_buffer_pool"`
+	ErrorBufferPool BufferPoolSummaryStruct  `json:"error_buffer_pool"`
+	NumURL          uint64                   `json:"url_number"`
+	NumPending      uint64                   `json:"pending_number"`
+	HostQueue       HostQueueSummaryStruct   `json:"host_queue"`
+	Robots          RobotsSummaryStruct      `json:"robots"`
+	Sitemaps        SitemapSummaryStruct     `json:"sitemaps"`
+	ScopeRules      []ScopeRuleSummaryStruct `json:"scope_rules,omitempty"`
+	Recrawl         RecrawlSummaryStruct     `json:"recrawl"`
 }
 
 func (one *SummaryStruct) Same(another SummaryStruct) bool {
@@ -99,6 +105,29 @@ func (one *SummaryStruct) Same(another SummaryStruct) bool {
 	if another.NumURL != one.NumURL {
 		return false
 	}
+	if another.NumPending != one.NumPending {
+		return false
+	}
+	if another.HostQueue != one.HostQueue {
+		return false
+	}
+	if !another.Robots.Same(one.Robots) {
+		return false
+	}
+	if another.Sitemaps != one.Sitemaps {
+		return false
+	}
+	if another.Recrawl != one.Recrawl {
+		return false
+	}
+	if len(another.ScopeRules) != len(one.ScopeRules) {
+		return false
+	}
+	for i, rs := range another.ScopeRules {
+		if rs != one.ScopeRules[i] {
+			return false
+		}
+	}
 	return true
 }
 
@@ -116,7 +145,13 @@ func (ss *mySchedSummary) Struct() SummaryStruct {
 		RespBufferPool:  getBufferPoolSummary(ss.sched.respBufferPool),
 		ItemBufferPool:  getBufferPoolSummary(ss.sched.itemBufferPool),
 		ErrorBufferPool: getBufferPoolSummary(ss.sched.errorBufferPool),
-		NumURL:          0,
+		NumURL:          ss.sched.seenSet.Len(),
+		NumPending:      ss.sched.frontier.Len(),
+		HostQueue:       ss.sched.politeness.summary(),
+		Robots:          ss.sched.robots.summary(),
+		Sitemaps:        ss.sched.sitemaps.summary(),
+		ScopeRules:      ss.sched.scope.summary(),
+		Recrawl:         ss.sched.recrawl.summary(),
 	}
 }
 
@@ -135,6 +170,72 @@ type BufferPoolSummaryStruct struct {
 	Total           uint64 `json:"total"`
 }
 
+type HostQueueSummaryStruct struct {
+	HostNumber uint64 `json:"host_number"`
+	Queued     uint64 `json:"queued"`
+	Parked     uint64 `json:"parked"`
+	InFlight   uint64 `json:"in_flight"`
+}
+
+type ScopeRuleSummaryStruct struct {
+	Rule string `json:"rule"`
+	Hits uint64 `json:"hits"`
+}
+
+type RecrawlSummaryStruct struct {
+	Enabled bool   `json:"enabled"`
+	Records uint64 `json:"records"`
+	New     uint64 `json:"new"`
+	Changed uint64 `json:"changed"`
+	// Unchanged is the number of the unchanged pages, which are not
+	// analyzed.
+	Unchanged uint64 `json:"unchanged"`
+	// Skipped is the number of the requests not due for a revisit.
+	Skipped uint64 `json:"skipped"`
+	// Scheduled is the number of the requests due for a revisit when
+	// starting.
+	Scheduled uint64 `json:"scheduled"`
+}
+
+type SitemapSummaryStruct struct {
+	Enabled    bool   `json:"enabled"`
+	HostNumber uint64 `json:"host_number"`
+	Fetched    uint64 `json:"fetched"`
+	Failed     uint64 `json:"failed"`
+	// Disallowed is the number of the sitemaps disallowed by robots.txt.
+	Disallowed uint64 `json:"disallowed"`
+	URLs       uint64 `json:"urls"`
+	Enqueued   uint64 `json:"enqueued"`
+	// Expedited is the number of the URLs made due by their lastmod after
+	// the last fetch in an incremental crawl.
+	Expedited uint64 `json:"expedited"`
+}
+
+type RobotsSummaryStruct struct {
+	HostNumber   uint64   `json:"host_number"`
+	Rejected     uint64   `json:"rejected"`
+	RejectedURLs []string `json:"rejected_urls,omitempty"`
+	// Postponed is the number of the times the requests were put back while
+	// robots.txt was unavailable.
+	Postponed uint64 `json:"postponed"`
+}
+
+func (one *RobotsSummaryStruct) Same(another RobotsSummaryStruct) bool {
+	if another.HostNumber != one.HostNumber || another.Rejected != one.Rejected ||
+		another.Postponed != one.Postponed {
+		return false
+	}
+	if len(another.RejectedURLs) != len(one.RejectedURLs) {
+		return false
+	}
+	for i, url := range another.RejectedURLs {
+		if url != one.RejectedURLs[i] {
+			return false
+		}
+	}
+	return true
+}
+
 func getBufferPoolSummary(bufferPool buffer.Pool) BufferPoolSummaryStruct {
 	return BufferPoolSummaryStruct{
 		BufferCap:       bufferPool.BufferCap(),
@@ -160,7 +160,50 @@ func TestSummaryString(t *testing.T) {
 	expectedSummaryStr := `{
     "request_args": {
         "accepted_primary_domains": [],
-        "max_depth": 0
+        "max_depth": 0,
+        "politeness": {
+            "min_delay": 0,
+            "max_conns_per_host": 0,
+            "max_queued": 0,
+            "max_queued_per_host": 0,
+            "max_crawl_delay": 0
+        },
+        "robots": {
+            "enabled": false,
+            "user_agent": "",
+            "expiry": 0
+        },
+        "sitemaps": {
+            "enabled": false,
+            "max_sitemaps": 0,
+            "max_urls": 0,
+            "max_hosts": 0
+        },
+        "url_normalization": {
+            "lowercase_host": false,
+            "remove_default_port": false,
+            "remove_fragment": false,
+            "sort_query": false,
+            "remove_tracking_params": false,
+            "resolve_dot_segments": false,
+            "normalize_encoding": false
+        },
+        "seen_set": {
+            "type": ""
+        },
+        "recrawl": {
+            "policy": {
+                "initial_interval": 0,
+                "min_interval": 0,
+                "max_interval": 0,
+                "factor": 0
+            }
+        },
+        "timeouts": {
+            "download": 0,
+            "analyze": 0,
+            "pipeline": 0
+        }
     },
     "data_args": {
         "req_buffer_cap": 10,
@@ -170,7 +213,12 @@ func TestSummaryString(t *testing.T) {
         "item_buffer_cap": 10,
         "item_max_buffer_number": 2,
         "error_buffer_cap": 10,
-        "error_max_buffer_number": 2
+        "error_max_buffer_number": 2,
+        "concurrency": {
+            "download": 0,
+            "analyze": 0,
+            "pick": 0
+        }
     },
     "module_args": {
         "downloader_list_size": 2,
@@ -247,7 +295,38 @@ func TestSummaryString(t *testing.T) {
         "buffer_number": 1,
         "total": 0
     },
-    "url_number": 0
+    "url_number": 0,
+    "pending_number": 0,
+    "host_queue": {
+        "host_number": 0,
+        "queued": 0,
+        "parked": 0,
+        "in_flight": 0
+    },
+    "robots": {
+        "host_number": 0,
+        "rejected": 0,
+        "postponed": 0
+    },
+    "sitemaps": {
+        "enabled": false,
+        "host_number": 0,
+        "fetched": 0,
+        "failed": 0,
+        "disallowed": 0,
+        "urls": 0,
+        "enqueued": 0,
+        "expedited": 0
+    },
+    "recrawl": {
+        "enabled": false,
+        "records": 0,
+        "new": 0,
+        "changed": 0,
+        "unchanged": 0,
+        "skipped": 0,
+        "scheduled": 0
+    }
 }`
 	summaryStr := summary.String()
 	if summaryStr != expectedSummaryStr {
@@ -0,0 +1,175 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"webcrawler/module"
+	"webcrawler/toolkit/buffer"
+)
+
+// sender puts the data into a buffer pool in background by at most
+// cap(tokens) goroutines. The caller blocks while all of them are waiting for
+// the buffer pool, which passes the backpressure on to the caller.
+type sender struct {
+	ctx    context.Context
+	name   string
+	pool   buffer.Pool
+	tokens chan struct{}
+}
+
+func newSender(ctx context.Context, name string, pool buffer.Pool, max uint32) *sender {
+	if max == 0 {
+		max = 1
+	}
+	return &sender{
+		ctx:    ctx,
+		name:   name,
+		pool:   pool,
+		tokens: make(chan struct{}, max),
+	}
+}
+
+func (s *sender) send(datum interface{}) bool {
+	if datum == nil || s.pool == nil || s.pool.Closed() {
+		return false
+	}
+	select {
+	case s.tokens <- struct{}{}:
+	case <-s.ctx.Done():
+		return false
+	}
+	go s.put(datum)
+	return true
+}
+
+// trySend is like send, but returns false instead of blocking if all the
+// goroutines are busy.
+func (s *sender) trySend(datum interface{}) bool {
+	if datum == nil || s.pool == nil || s.pool.Closed() {
+		return false
+	}
+	select {
+	case s.tokens <- struct{}{}:
+	default:
+		return false
+	}
+	go s.put(datum)
+	return true
+}
+
+func (s *sender) put(datum interface{}) {
+	defer func() {
+		<-s.tokens
+	}()
+	if err := s.pool.Put(s.ctx, datum); err != nil {
+		logger.Warnf("The %s buffer pool was closed. Ignore %s sending", s.name, s.name)
+	}
+}
+
+// busy reports whether any datum is being put into the buffer pool.
+func (s *sender) busy() bool {
+	return s != nil && len(s.tokens) > 0
+}
+
+// pauseGate holds the workers before they handle the next datum while the
+// scheduler is paused. The zero value is open.
+type pauseGate struct {
+	lock sync.Mutex
+	// closed is non-nil while paused, and closed on resuming.
+	closed chan struct{}
+}
+
+func (g *pauseGate) pause() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.closed == nil {
+		g.closed = make(chan struct{})
+	}
+}
+
+func (g *pauseGate) resume() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.closed != nil {
+		close(g.closed)
+		g.closed = nil
+	}
+}
+
+// wait blocks while the gate is closed, and returns false if the context is
+// done first.
+func (g *pauseGate) wait(ctx context.Context) bool {
+	g.lock.Lock()
+	closed := g.closed
+	g.lock.Unlock()
+	if closed == nil {
+		return true
+	}
+	select {
+	case <-closed:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// dispatch takes the data from the buffer pool and hands them to the
+// workers. Only the dispatching goroutine polls the buffer pool, and it
+// blocks while all workers are busy.
+func (sched *myScheduler) dispatch(pool buffer.Pool, name string, workers uint32, handle func(datum interface{})) {
+	data := make(chan interface{})
+	for i := uint32(0); i < workers; i++ {
+		go func() {
+			for datum := range data {
+				sched.pauseGate.wait(sched.ctx)
+				handle(datum)
+			}
+		}()
+	}
+	go func() {
+		defer close(data)
+		for {
+			if sched.canceled() {
+				break
+			}
+			datum, err := pool.Get(sched.ctx)
+			if err != nil {
+				logger.Warnf("The %s buffer pool was closed. Break %s reception", name, name)
+				break
+			}
+			data <- datum
+		}
+	}()
+}
+
+// workerNumber returns the number of the workers of the stage handled by the
+// modules of the type.
+func (sched *myScheduler) workerNumber(moduleType module.Type, number uint32) uint32 {
+	if number > 0 {
+		return number
+	}
+	modules, err := sched.registrar.GetAllByType(moduleType)
+	if err != nil || len(modules) == 0 {
+		return 1
+	}
+	return uint32(len(modules))
+}
+
+// initSenders creates the senders for the buffer pools, each of which has as
+// many goroutines as the workers taking data from the buffer pool, and the
+// error sender has as many as all the workers. The numbers are fixed when the
+// scheduler starts, so the modules added later share the existing workers.
+func (sched *myScheduler) initSenders() {
+	sched.workers = ConcurrencyArgs{
+		Download: sched.workerNumber(module.TYPE_DOWNLOADER, sched.concurrency.Download),
+		Analyze:  sched.workerNumber(module.TYPE_ANALYZER, sched.concurrency.Analyze),
+		Pick:     sched.workerNumber(module.TYPE_PIPELINE, sched.concurrency.Pick),
+	}
+	logger.Infof("-- Workers: download: %d, analyze: %d, pick: %d",
+		sched.workers.Download, sched.workers.Analyze, sched.workers.Pick)
+	sched.reqSender = newSender(sched.ctx, "request", sched.reqBufferPool, sched.workers.Download)
+	sched.respSender = newSender(sched.ctx, "response", sched.respBufferPool, sched.workers.Analyze)
+	sched.itemSender = newSender(sched.ctx, "item", sched.itemBufferPool, sched.workers.Pick)
+	sched.errSender = newSender(sched.ctx, "error", sched.errorBufferPool,
+		sched.workers.Download+sched.workers.Analyze+sched.workers.Pick)
+}
@@ -0,0 +1,114 @@
+package scheduler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+	"webcrawler/module"
+	"webcrawler/toolkit/buffer"
+)
+
+func TestSender(t *testing.T) {
+	pool, _ := buffer.NewPool(1, 1)
+	s := newSender(context.Background(), "item", pool, 2)
+	if s.send(nil) {
+		t.Fatal("It can still send nil datum")
+	}
+	for i := 0; i < 3; i++ {
+		if !s.send(i) {
+			t.Fatalf("Could not send datum %d", i)
+		}
+	}
+	done := make(chan struct{})
+	go func() {
+		s.send(3)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("The sender has not been blocked by the full buffer pool")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if len(s.tokens) != cap(s.tokens) {
+		t.Fatalf("Inconsistent sending goroutine number, expected: %d, actual: %d", cap(s.tokens), len(s.tokens))
+	}
+	if _, err := pool.Get(context.Background()); err != nil {
+		t.Fatalf("An error occurs when getting datum: %s", err)
+	}
+	pool.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The sender has not been released by the closed buffer pool")
+	}
+	if s.send(4) {
+		t.Fatal("It can still send datum to closed buffer pool")
+	}
+}
+
+func TestSchedWorkers(t *testing.T) {
+	var current, max int32
+	drainServer := genDrainServer(20)
+	defer drainServer.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&current, 1)
+		defer atomic.AddInt32(&current, -1)
+		for m := atomic.LoadInt32(&max); n > m && !atomic.CompareAndSwapInt32(&max, m, n); {
+			m = atomic.LoadInt32(&max)
+		}
+		drainServer.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	requestArgs := genRequestArgs([]string{}, 1)
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(3, 2, 1, t)
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL, nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	mySched := sched.(*myScheduler)
+	expected := ConcurrencyArgs{Download: 3, Analyze: 2, Pick: 1}
+	if mySched.workers != expected {
+		t.Fatalf("Inconsistent workers, expected: %#v, actual: %#v", expected, mySched.workers)
+	}
+	begin := time.Now()
+	for !sched.Idle() || time.Since(begin) < 100*time.Millisecond {
+		if time.Since(begin) > 10*time.Second {
+			t.Fatal("The scheduler has not been idle")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&max); n < 2 || n > 3 {
+		t.Fatalf("Inconsistent max concurrent download number, expected: %d, actual: %d", 3, n)
+	}
+	var completed uint64
+	modules, _ := mySched.registrar.GetAllByType(module.TYPE_DOWNLOADER)
+	for _, m := range modules {
+		completed += m.CompletedCount()
+	}
+	if completed != 21 {
+		t.Fatalf("Inconsistent downloaded page number, expected: %d, actual: %d", 21, completed)
+	}
+	if picked := atomic.LoadUint64(&mySched.pickedItems); picked != 20 {
+		t.Fatalf("Inconsistent processed item number, expected: %d, actual: %d", 20, picked)
+	}
+	sched.Stop()
+	dataArgs.Concurrency = ConcurrencyArgs{Download: 4, Analyze: 1, Pick: 2}
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	if mySched.workers != dataArgs.Concurrency {
+		t.Fatalf("Inconsistent workers, expected: %#v, actual: %#v", dataArgs.Concurrency, mySched.workers)
+	}
+}
@@ -1,31 +1,55 @@
 package buffer
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"sync/atomic"
 	"webcrawler/errors"
 )
 
+// Pool is a bounded FIFO queue of data. Put blocks while the pool is full and
+// Get blocks while it is empty, until the context is done or the pool is
+// closed. The blocked callers are served in the order of arrival.
+//
+// The data are stored in the buffers of BufferCap, up to MaxBufferNumber of
+// them. BufferNumber is the number of the buffers allocated, which grows with
+// the data and is reset to one once the pool is drained and waited on.
 type Pool interface {
 	BufferCap() uint32
 	MaxBufferNumber() uint32
 	BufferNumber() uint32
 	Total() uint64
-	Put(datum interface{}) error
-	Get() (datum interface{}, err error)
+	Put(ctx context.Context, datum interface{}) error
+	Get(ctx context.Context) (datum interface{}, err error)
 	Close() bool
 	Closed() bool
 }
 
+// waiter is a blocked caller of Put or Get, which is served by the caller on
+// the other side.
+type waiter struct {
+	datum interface{}
+	err   error
+	ready chan struct{}
+}
+
+func newWaiter(datum interface{}) *waiter {
+	return &waiter{datum: datum, ready: make(chan struct{})}
+}
+
 type myPool struct {
 	bufferCap       uint32
 	maxBufferNumber uint32
 	bufferNumber    uint32
 	total           uint64
-	bufCh           chan Buffer
 	closed          uint32
-	rwlock          sync.RWMutex
+	// data is the queue of the data, whose head is data[head].
+	data    []interface{}
+	head    int
+	getters []*waiter
+	putters []*waiter
+	lock    sync.Mutex
 }
 
 func NewPool(bufferCap uint32, maxBufferNumber uint32) (Pool, error) {
@@ -37,14 +61,11 @@ func NewPool(bufferCap uint32, maxBufferNumber uint32) (Pool, error) {
 		errMsg := fmt.Sprintf("illegal max buffer number for buffer pool: %d", maxBufferNumber)
 		return nil, errors.NewIllegalParameterError(errMsg)
 	}
-	bufCh := make(chan Buffer, maxBufferNumber)
-	buf, _ := NewBuffer(bufferCap)
-	bufCh <- buf
 	return &myPool{
 		bufferCap:       bufferCap,
 		maxBufferNumber: maxBufferNumber,
 		bufferNumber:    1,
-		bufCh:           bufCh,
+		data:            make([]interface{}, 0, bufferCap),
 	}, nil
 }
 
@@ -57,132 +78,162 @@ func (pool *myPool) MaxBufferNumber() uint32 {
 }
 
 func (pool *myPool) BufferNumber() uint32 {
-	return pool.bufferNumber
+	return atomic.LoadUint32(&pool.bufferNumber)
 }
 
 func (pool *myPool) Total() uint64 {
 	return atomic.LoadUint64(&pool.total)
 }
 
-func (pool *myPool) Put(datum interface{}) (err error) {
+func (pool *myPool) Put(ctx context.Context, datum interface{}) error {
+	pool.lock.Lock()
 	if pool.Closed() {
+		pool.lock.Unlock()
 		return ErrClosedBufferPool
 	}
-	var count uint32
-	maxCount := pool.BufferNumber() * 5
-	var ok bool
-	for buf := range pool.bufCh {
-		ok, err = pool.putData(buf, datum, &count, maxCount)
-		if ok || err != nil {
-			break
-		}
+	if len(pool.getters) > 0 {
+		getter := pool.getters[0]
+		pool.getters = pool.getters[1:]
+		getter.datum = datum
+		close(getter.ready)
+		pool.lock.Unlock()
+		return nil
 	}
-	return
-}
-
-func (pool *myPool) putData(buf Buffer, datum interface{}, count *uint32, maxCount uint32) (ok bool, err error) {
-	if pool.Closed() {
-		return false, ErrClosedBufferPool
+	if len(pool.putters) == 0 && pool.len() < pool.capacity() {
+		pool.push(datum)
+		pool.lock.Unlock()
+		return nil
 	}
-	defer func() {
-		pool.rwlock.RLock()
-		if pool.Closed() {
-			atomic.AddUint32(&pool.bufferNumber, ^uint32(0))
-			err = ErrClosedBufferPool
-		} else {
-			pool.bufCh <- buf
-		}
-		pool.rwlock.RUnlock()
-	}()
-	ok, err = buf.Put(datum)
-	if ok {
-		atomic.AddUint64(&pool.total, 1)
-		return
-	}
-	if err != nil {
-		return
-	}
-	(*count)++
-	if *count > maxCount && pool.BufferNumber() < pool.MaxBufferNumber() {
-		pool.rwlock.Lock()
-		if pool.BufferNumber() < pool.MaxBufferNumber() {
-			if pool.Closed() {
-				pool.rwlock.Unlock()
-				return
-			}
-			newBuf, _ := NewBuffer(pool.bufferCap)
-			newBuf.Put(datum)
-			pool.bufCh <- newBuf
-			atomic.AddUint32(&pool.bufferNumber, 1)
-			atomic.AddUint64(&pool.total, 1)
-			ok = true
-		}
-		pool.rwlock.Unlock()
-		*count = 0
+	putter := newWaiter(datum)
+	pool.putters = append(pool.putters, putter)
+	pool.lock.Unlock()
+	select {
+	case <-putter.ready:
+		return putter.err
+	case <-ctx.Done():
+	}
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	if removeWaiter(&pool.putters, putter) {
+		return ctx.Err()
 	}
-	return
+	return putter.err
 }
 
-func (pool *myPool) Get() (datum interface{}, err error) {
+func (pool *myPool) Get(ctx context.Context) (datum interface{}, err error) {
+	pool.lock.Lock()
 	if pool.Closed() {
+		pool.lock.Unlock()
 		return nil, ErrClosedBufferPool
 	}
-	var count uint32
-	maxCount := pool.bufferNumber * 10
-	for buf := range pool.bufCh {
-		datum, err = pool.getData(buf, &count, maxCount)
-		if datum != nil || err != nil {
-			break
+	if pool.len() > 0 {
+		datum = pool.pop()
+		if len(pool.putters) > 0 {
+			putter := pool.putters[0]
+			pool.putters = pool.putters[1:]
+			pool.push(putter.datum)
+			close(putter.ready)
 		}
+		pool.lock.Unlock()
+		return datum, nil
 	}
-	return
-}
-
-func (pool *myPool) getData(buf Buffer, count *uint32, maxCount uint32) (datum interface{}, err error) {
-	if pool.Closed() {
-		return false, ErrClosedBufferPool
-	}
-	defer func() {
-		if *count > maxCount && buf.Len() == 0 && pool.bufferNumber > 1 {
-			buf.Close()
-			atomic.AddUint32(&pool.bufferNumber, ^uint32(0))
-			*count = 0
-			return
-		}
-		pool.rwlock.RLock()
-		if pool.Closed() {
-			atomic.AddUint32(&pool.bufferNumber, ^uint32(0))
-			err = ErrClosedBufferPool
-		} else {
-			pool.bufCh <- buf
-		}
-		pool.rwlock.RUnlock()
-	}()
-	datum, err = buf.Get()
-	if datum != nil {
-		atomic.AddUint64(&pool.total, ^uint64(0))
-		return
+	// The buffers are released once the pool is drained.
+	if cap(pool.data) > int(pool.bufferCap) {
+		pool.data = make([]interface{}, 0, pool.bufferCap)
+		pool.head = 0
+		atomic.StoreUint32(&pool.bufferNumber, 1)
+	}
+	getter := newWaiter(nil)
+	pool.getters = append(pool.getters, getter)
+	pool.lock.Unlock()
+	select {
+	case <-getter.ready:
+		return getter.datum, getter.err
+	case <-ctx.Done():
 	}
-	if err != nil {
-		return
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	if removeWaiter(&pool.getters, getter) {
+		return nil, ctx.Err()
 	}
-	(*count)++
-	return
+	return getter.datum, getter.err
 }
 
 func (pool *myPool) Close() bool {
 	if !atomic.CompareAndSwapUint32(&pool.closed, 0, 1) {
 		return false
 	}
-	pool.rwlock.Lock()
-	defer pool.rwlock.Unlock()
-	close(pool.bufCh)
-	for buf := range pool.bufCh {
-		buf.Close()
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	for _, w := range append(pool.getters, pool.putters...) {
+		w.err = ErrClosedBufferPool
+		close(w.ready)
 	}
+	pool.getters = nil
+	pool.putters = nil
+	pool.data = nil
+	pool.head = 0
+	atomic.StoreUint64(&pool.total, 0)
 	return true
 }
 
 func (pool *myPool) Closed() bool {
 	return atomic.LoadUint32(&pool.closed) == 1
 }
+
+func (pool *myPool) capacity() int {
+	return int(pool.bufferCap) * int(pool.maxBufferNumber)
+}
+
+func (pool *myPool) len() int {
+	return len(pool.data) - pool.head
+}
+
+// push appends the datum, and allocates one more buffer if the data fill the
+// allocated ones.
+func (pool *myPool) push(datum interface{}) {
+	if len(pool.data) == cap(pool.data) {
+		if pool.head > 0 {
+			pool.compact()
+		} else {
+			data := make([]interface{}, len(pool.data), cap(pool.data)+int(pool.bufferCap))
+			copy(data, pool.data)
+			pool.data = data
+			atomic.StoreUint32(&pool.bufferNumber, uint32(cap(data)/int(pool.bufferCap)))
+		}
+	}
+	pool.data = append(pool.data, datum)
+	atomic.AddUint64(&pool.total, 1)
+}
+
+func (pool *myPool) pop() interface{} {
+	datum := pool.data[pool.head]
+	pool.data[pool.head] = nil
+	pool.head++
+	if pool.head == len(pool.data) {
+		pool.data = pool.data[:0]
+		pool.head = 0
+	} else if pool.head >= cap(pool.data)/2 {
+		pool.compact()
+	}
+	atomic.AddUint64(&pool.total, ^uint64(0))
+	return datum
+}
+
+// compact moves the data to the front of the buffers.
+func (pool *myPool) compact() {
+	n := copy(pool.data, pool.data[pool.head:])
+	clear(pool.data[n:])
+	pool.data = pool.data[:n]
+	pool.head = 0
+}
+
+func removeWaiter(waiters *[]*waiter, w *waiter) bool {
+	for i, another := range *waiters {
+		if another == w {
+			*waiters = append((*waiters)[:i], (*waiters)[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,7 @@
 package buffer
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"sync"
@@ -41,7 +42,7 @@ func TestPoolNew(t *testing.T) {
 func addExtraDatum(pool Pool, datum interface{}) chan error {
 	sign := make(chan error, 1)
 	go func() {
-		sign <- pool.Put(datum)
+		sign <- pool.Put(context.Background(), datum)
 	}()
 	return sign
 }
@@ -60,7 +61,7 @@ func TestPoolPut(t *testing.T) {
 	}
 	var count, datum uint32
 	for _, datum := range data {
-		err := pool.Put(datum)
+		err := pool.Put(context.Background(), datum)
 		if err != nil {
 			t.Fatalf("An error occurs when putting a datum to the buffer pool: %s (datum: %d)", err, datum)
 		}
@@ -88,7 +89,7 @@ func TestPoolPut(t *testing.T) {
 		t.Logf("Timeout! could not put data to the full buffer pool")
 	}
 	pool.Close()
-	err = pool.Put(datum)
+	err = pool.Put(context.Background(), datum)
 	if err == nil {
 		t.Fatalf("It can still put data to the closed buffer pool (datum: %d)", datum)
 	}
@@ -110,7 +111,7 @@ func TestPoolPutInParallel(t *testing.T) {
 	testingFunc := func(datum interface{}) func(t *testing.T) {
 		return func(t *testing.T) {
 			t.Parallel()
-			err := pool.Put(datum)
+			err := pool.Put(context.Background(), datum)
 			if err != nil {
 				t.Fatalf("An error occurs when putting a datum to the buffer pool: %s (datum: %d)", err, datum)
 			}
@@ -148,7 +149,7 @@ func TestPoolPutInParallel(t *testing.T) {
 func getExtraDatum(pool Pool) chan error {
 	sign := make(chan error, 1)
 	go func() {
-		_, err := pool.Get()
+		_, err := pool.Get(context.Background())
 		sign <- err
 	}()
 	return sign
@@ -163,14 +164,14 @@ func TestPoolGet(t *testing.T) {
 	}
 	dataLen := uint32(bufferCap * maxBufferNumber)
 	for i := uint32(0); i < dataLen; i++ {
-		pool.Put(i)
+		pool.Put(context.Background(), i)
 	}
 	count := dataLen
 	expectedBufferNumber := maxBufferNumber
 	var datum uint32
 	var ok bool
 	for i := uint32(0); i < dataLen; i++ {
-		d, err := pool.Get()
+		d, err := pool.Get(context.Background())
 		if err != nil {
 			t.Fatalf("An error occurs when getting a datum for the buffer pool: %s", err)
 		}
@@ -200,9 +201,9 @@ func TestPoolGet(t *testing.T) {
 		t.Logf("Timeout! could not get data from the empty buffer pool")
 	}
 	datum = 0
-	pool.Put(datum)
+	pool.Put(context.Background(), datum)
 	pool.Close()
-	_, err = pool.Get()
+	_, err = pool.Get(context.Background())
 	if err == nil {
 		t.Fatal("It can still get datum from the closed buffer pool")
 	}
@@ -217,12 +218,12 @@ func TestPoolGetInParallel(t *testing.T) {
 	}
 	dataLen := uint32(bufferCap * maxBufferNumber)
 	for i := uint32(0); i < dataLen; i++ {
-		pool.Put(i)
+		pool.Put(context.Background(), i)
 	}
 	count := dataLen
 	testingFunc := func(t *testing.T) {
 		t.Parallel()
-		d, err := pool.Get()
+		d, err := pool.Get(context.Background())
 		if err != nil {
 			t.Fatalf("An error occurs when getting a datum from the buffer pool: %s", err)
 		}
@@ -300,7 +301,7 @@ func TestPoolPutAndgetInParllel(t *testing.T) {
 					}
 					continue
 				}
-				err := pool.Put(i)
+				err := pool.Put(context.Background(), i)
 				if err != nil {
 					t.Fatalf("An error occurs when putting a datum to the buffer pool: %s (datum: %d)", err, i)
 				}
@@ -326,7 +327,7 @@ func TestPoolPutAndgetInParllel(t *testing.T) {
 					}
 					continue
 				}
-				err := pool.Put(i)
+				err := pool.Put(context.Background(), i)
 				if err != nil {
 					t.Fatalf("An error occurs when putting a datum to the buffer pool: %s (datum: %d)", err, i)
 				}
@@ -348,7 +349,7 @@ func TestPoolPutAndgetInParllel(t *testing.T) {
 					}
 					continue
 				}
-				d, err := pool.Get()
+				d, err := pool.Get(context.Background())
 				if err != nil {
 					t.Fatalf("An error occurs when getting a datum from the buffer pool: %s", err)
 				}
@@ -379,7 +380,7 @@ func TestPoolPutAndgetInParllel(t *testing.T) {
 					}
 					continue
 				}
-				d, err := pool.Get()
+				d, err := pool.Get(context.Background())
 				if err != nil {
 					t.Fatalf("An error occurs when getting a datum from the buffer pool: %s", err)
 				}
@@ -416,7 +417,7 @@ func TestPoolCloseInParallel(t *testing.T) {
 	t.Run("Put", func(t *testing.T) {
 		t.Parallel()
 		for i := uint32(0); i < maxNumber; i++ {
-			err := pool.Put(i)
+			err := pool.Put(context.Background(), i)
 			if err != nil && !pool.Closed() {
 				t.Fatalf("An error occurs when putting a datum to the buffer pool: %s (datum: %d)", err, i)
 			}
@@ -424,8 +425,14 @@ func TestPoolCloseInParallel(t *testing.T) {
 	})
 	t.Run("Get", func(t *testing.T) {
 		t.Parallel()
+		// Get blocks on the empty pool if it runs before Put and Close.
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
 		for i := uint32(0); i < maxNumber; i++ {
-			_, err := pool.Get()
+			_, err := pool.Get(ctx)
+			if err == context.DeadlineExceeded {
+				break
+			}
 			if err != nil && !pool.Closed() {
 				t.Fatalf("An error occurs when getting a datum from the buffer pool: %s (index: %d)", err, i)
 			}
@@ -450,3 +457,115 @@ func TestPoolCloseInParallel(t *testing.T) {
 		}
 	})
 }
+
+func TestPoolContext(t *testing.T) {
+	pool, _ := NewPool(1, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Get(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Inconsistent error when getting from the empty buffer pool, expected: %v, actual: %v",
+			context.DeadlineExceeded, err)
+	}
+	if err := pool.Put(context.Background(), 1); err != nil {
+		t.Fatalf("An error occurs when putting a datum to the buffer pool: %s", err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := pool.Put(ctx, 2); err != context.DeadlineExceeded {
+		t.Fatalf("Inconsistent error when putting to the full buffer pool, expected: %v, actual: %v",
+			context.DeadlineExceeded, err)
+	}
+	if pool.Total() != 1 {
+		t.Fatalf("Inconsistent data total, expected: %d, actual: %d", 1, pool.Total())
+	}
+	sign := addExtraDatum(pool, 3)
+	time.Sleep(10 * time.Millisecond)
+	if d, err := pool.Get(context.Background()); err != nil || d != 1 {
+		t.Fatalf("Inconsistent datum, expected: %d, actual: %v (error: %v)", 1, d, err)
+	}
+	if err := <-sign; err != nil {
+		t.Fatalf("An error occurs when putting a datum to the buffer pool: %s", err)
+	}
+	if d, err := pool.Get(context.Background()); err != nil || d != 3 {
+		t.Fatalf("Inconsistent datum, expected: %d, actual: %v (error: %v)", 3, d, err)
+	}
+	getSign := getExtraDatum(pool)
+	time.Sleep(10 * time.Millisecond)
+	pool.Close()
+	select {
+	case err := <-getSign:
+		if err != ErrClosedBufferPool {
+			t.Fatalf("Inconsistent error, expected: %v, actual: %v", ErrClosedBufferPool, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The blocked getter has not been released by closing the buffer pool")
+	}
+}
+
+func TestPoolFairness(t *testing.T) {
+	pool, _ := NewPool(1, 1)
+	number := 10
+	results := make(chan [2]interface{}, number)
+	for i := 0; i < number; i++ {
+		go func(i int) {
+			d, _ := pool.Get(context.Background())
+			results <- [2]interface{}{i, d}
+		}(i)
+		// Wait for the getter to be blocked so that the order is certain.
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < number; i++ {
+		if err := pool.Put(context.Background(), i); err != nil {
+			t.Fatalf("An error occurs when putting a datum to the buffer pool: %s (datum: %d)", err, i)
+		}
+	}
+	for i := 0; i < number; i++ {
+		result := <-results
+		if result[0] != result[1] {
+			t.Fatalf("The getters have not been served in order, getter: %v, datum: %v", result[0], result[1])
+		}
+	}
+	for i := 0; i < number; i++ {
+		if err := pool.Put(context.Background(), i); err != nil {
+			t.Fatalf("An error occurs when putting a datum to the buffer pool: %s (datum: %d)", err, i)
+		}
+		if d, err := pool.Get(context.Background()); err != nil || d != i {
+			t.Fatalf("Inconsistent datum order, expected: %d, actual: %v (error: %v)", i, d, err)
+		}
+	}
+	pool.Close()
+}
+
+func BenchmarkPoolPutGet(b *testing.B) {
+	pool, _ := NewPool(100, 10)
+	defer pool.Close()
+	ctx := context.Background()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			pool.Put(ctx, 1)
+			pool.Get(ctx)
+		}
+	})
+}
+
+// BenchmarkPoolWithIdleGetters measures the throughput while other getters
+// are blocked on an empty pool, which costs no CPU.
+func BenchmarkPoolWithIdleGetters(b *testing.B) {
+	idlePool, _ := NewPool(100, 10)
+	defer idlePool.Close()
+	for i := 0; i < 8; i++ {
+		go idlePool.Get(context.Background())
+	}
+	pool, _ := NewPool(100, 10)
+	defer pool.Close()
+	ctx := context.Background()
+	b.ResetTimer()
+	go func() {
+		for i := 0; i < b.N; i++ {
+			pool.Put(ctx, i)
+		}
+	}()
+	for i := 0; i < b.N; i++ {
+		pool.Get(ctx)
+	}
+}
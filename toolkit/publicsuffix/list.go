@@ -0,0 +1,225 @@
+package publicsuffix
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"webcrawler/errors"
+
+	"golang.org/x/net/idna"
+)
+
+const DefaultURL = "https://publicsuffix.org/list/public_suffix_list.dat"
+
+// snapshot is the complete upstream list at the time of the release.
+//
+//go:embed public_suffix_list.dat
+var snapshot []byte
+
+const (
+	ruleNormal uint8 = 1 << iota
+	ruleWildcard
+	ruleException
+	rulePrivate
+)
+
+type List struct {
+	rules map[string]uint8
+}
+
+func Parse(r io.Reader) (*List, error) {
+	list := &List{rules: map[string]uint8{}}
+	private := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "//") {
+			if strings.Contains(line, "===BEGIN PRIVATE DOMAINS===") {
+				private = true
+			} else if strings.Contains(line, "===END PRIVATE DOMAINS===") {
+				private = false
+			}
+			continue
+		}
+		if index := strings.IndexAny(line, " \t"); index >= 0 {
+			line = line[:index]
+		}
+		if line == "" {
+			continue
+		}
+		var kind uint8
+		switch {
+		case strings.HasPrefix(line, "!"):
+			line = line[1:]
+			kind = ruleException
+		case strings.HasPrefix(line, "*."):
+			line = line[2:]
+			kind = ruleWildcard
+		default:
+			kind = ruleNormal
+		}
+		if private {
+			kind |= rulePrivate
+		}
+		list.rules[ToASCII(line)] |= kind
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(list.rules) == 0 {
+		return nil, errors.NewIllegalParameterError("empty public suffix list")
+	}
+	return list, nil
+}
+
+func (list *List) Len() int {
+	return len(list.rules)
+}
+
+// ToASCII converts the domain to lower case and its internationalized labels
+// to punycode, as the rules and the domains are matched.
+func ToASCII(domain string) string {
+	if ascii, err := idna.Lookup.ToASCII(domain); err == nil {
+		return ascii
+	}
+	// The lookup profile rejects the labels like "_dmarc", which are kept.
+	if ascii, err := idna.Punycode.ToASCII(strings.ToLower(domain)); err == nil {
+		return ascii
+	}
+	return strings.ToLower(domain)
+}
+
+// PublicSuffix returns the public suffix in punycode of the domain under the
+// prevailing rule. explicit is false when only the implicit "*" rule matched,
+// i.e. the top-level label is unknown to the list, and the suffix is the
+// top-level label.
+func (list *List) PublicSuffix(domain string) (suffix string, icann bool, explicit bool) {
+	domain = ToASCII(strings.TrimSuffix(domain, "."))
+	labels := strings.Split(domain, ".")
+	for i := range labels {
+		candidate := strings.Join(labels[i:], ".")
+		if kind := list.rules[candidate]; kind&ruleException != 0 {
+			return strings.Join(labels[i+1:], "."), kind&rulePrivate == 0, true
+		}
+		if kind := list.rules[candidate]; kind&ruleNormal != 0 {
+			return candidate, kind&rulePrivate == 0, true
+		}
+		if i+1 < len(labels) {
+			if kind := list.rules[strings.Join(labels[i+1:], ".")]; kind&ruleWildcard != 0 {
+				return candidate, kind&rulePrivate == 0, true
+			}
+		}
+	}
+	return labels[len(labels)-1], false, false
+}
+
+// EffectiveTLDPlusOne returns the public suffix in punycode of the domain
+// plus one more label.
+func (list *List) EffectiveTLDPlusOne(domain string) (string, error) {
+	domain = strings.TrimSuffix(domain, ".")
+	if domain == "" || strings.HasPrefix(domain, ".") || strings.Contains(domain, "..") {
+		return "", errors.NewIllegalParameterError(fmt.Sprintf("invalid domain %q", domain))
+	}
+	domain = ToASCII(domain)
+	suffix, _, _ := list.PublicSuffix(domain)
+	if len(domain) <= len(suffix) {
+		return "", errors.NewIllegalParameterError(fmt.Sprintf("domain %q is a public suffix", domain))
+	}
+	rest := domain[:len(domain)-len(suffix)-1]
+	if index := strings.LastIndex(rest, "."); index >= 0 {
+		rest = rest[index+1:]
+	}
+	return rest + "." + suffix, nil
+}
+
+// PrimaryDomain accepts a host as found in http.Request.Host, with an optional
+// port and IPv6 brackets. IP addresses are returned as they are.
+func (list *List) PrimaryDomain(host string) (string, error) {
+	host = strings.TrimSpace(host)
+	if host == "" {
+		return "", errors.NewIllegalParameterError("empty host")
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if index := strings.Index(host, "%"); index >= 0 && net.ParseIP(host[:index]) != nil {
+		host = host[:index]
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String(), nil
+	}
+	return list.EffectiveTLDPlusOne(host)
+}
+
+var (
+	defaultList *List
+	defaultLock sync.RWMutex
+)
+
+func init() {
+	list, err := Parse(bytes.NewReader(snapshot))
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded public suffix list: %s", err))
+	}
+	defaultList = list
+}
+
+func Default() *List {
+	defaultLock.RLock()
+	defer defaultLock.RUnlock()
+	return defaultList
+}
+
+func SetDefault(list *List) {
+	if list == nil {
+		return
+	}
+	defaultLock.Lock()
+	defaultList = list
+	defaultLock.Unlock()
+}
+
+// Refresh downloads the list from the URL (DefaultURL if empty) and makes it
+// the default one. The embedded snapshot stays in use if anything fails.
+func Refresh(client *http.Client, url string) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if url == "" {
+		url = DefaultURL
+	}
+	httpResp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d when refreshing public suffix list (URL: %s)",
+			httpResp.StatusCode, url)
+	}
+	list, err := Parse(httpResp.Body)
+	if err != nil {
+		return err
+	}
+	SetDefault(list)
+	return nil
+}
+
+func PublicSuffix(domain string) (suffix string, icann bool, explicit bool) {
+	return Default().PublicSuffix(domain)
+}
+
+func EffectiveTLDPlusOne(domain string) (string, error) {
+	return Default().EffectiveTLDPlusOne(domain)
+}
+
+func PrimaryDomain(host string) (string, error) {
+	return Default().PrimaryDomain(host)
+}
@@ -0,0 +1,109 @@
+package publicsuffix
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPublicSuffix(t *testing.T) {
+	cases := []struct {
+		domain   string
+		suffix   string
+		icann    bool
+		explicit bool
+	}{
+		{"cn.bing.com", "com", true, true},
+		{"www.bbc.co.uk", "co.uk", true, true},
+		{"www.sina.com.cn", "com.cn", true, true},
+		{"golang.github.io", "github.io", false, true},
+		{"a.b.ck", "b.ck", true, true},
+		{"www.ck", "ck", true, true},
+		{"x.city.kawasaki.jp", "kawasaki.jp", true, true},
+		{"x.y.kawasaki.jp", "y.kawasaki.jp", true, true},
+		{"foo.unknowntld", "unknowntld", false, false},
+		{"x.co.ke", "co.ke", true, true},
+		{"shop.例子.онлайн", "xn--80asehdb", true, true},
+		{"shop.xn--fsqu00a.xn--80asehdb", "xn--80asehdb", true, true},
+		{"WWW.Example.COM.", "com", true, true},
+	}
+	for _, c := range cases {
+		suffix, icann, explicit := PublicSuffix(c.domain)
+		if suffix != c.suffix || icann != c.icann || explicit != c.explicit {
+			t.Fatalf("Inconsistent public suffix for %q, expected: (%s, %v, %v), actual: (%s, %v, %v)",
+				c.domain, c.suffix, c.icann, c.explicit, suffix, icann, explicit)
+		}
+	}
+}
+
+func TestPrimaryDomain(t *testing.T) {
+	cases := map[string]string{
+		"cn.bing.com":          "bing.com",
+		"bing.net":             "bing.net",
+		"news.bbc.co.uk":       "bbc.co.uk",
+		"a.b.sina.com.cn":      "sina.com.cn",
+		"golang.github.io":     "golang.github.io",
+		"www.example.com:8080": "example.com",
+		"127.0.0.1":            "127.0.0.1",
+		"127.0.0.1:8080":       "127.0.0.1",
+		"[::1]:8080":           "::1",
+		"[2001:db8::1]":        "2001:db8::1",
+		"2001:db8::1":          "2001:db8::1",
+		"www.ck":               "www.ck",
+		"shop.x.co.ke":         "x.co.ke",
+		"www.example.ke":       "example.ke",
+		"www.example.unknown":  "example.unknown",
+		"123.abc":              "123.abc",
+		"shop.例子.онлайн":       "xn--fsqu00a.xn--80asehdb",
+		"WWW.例子.ОНЛАЙН:8080":   "xn--fsqu00a.xn--80asehdb",
+		"_dmarc.example.com":   "example.com",
+	}
+	for host, expected := range cases {
+		pd, err := PrimaryDomain(host)
+		if err != nil {
+			t.Fatalf("An error occurs when getting the primary domain: %s (host: %s)", err, host)
+		}
+		if pd != expected {
+			t.Fatalf("Inconsistent primary domain for %q, expected: %s, actual: %s", host, expected, pd)
+		}
+	}
+	for _, host := range []string{"", "com", "co.uk", "co.ke", "github.io", "unknown", "онлайн", "a..com"} {
+		if pd, err := PrimaryDomain(host); err == nil {
+			t.Fatalf("No error when getting the primary domain of %q (result: %s)", host, pd)
+		}
+	}
+}
+
+func TestParseAndRefresh(t *testing.T) {
+	if _, err := Parse(strings.NewReader("// only comments\n")); err == nil {
+		t.Fatal("No error when parsing an empty list")
+	}
+	data := "// ===BEGIN ICANN DOMAINS===\nabc\n// ===END ICANN DOMAINS===\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/list.dat" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(data))
+	}))
+	defer server.Close()
+	original := Default()
+	defer SetDefault(original)
+	if err := Refresh(server.Client(), server.URL+"/missing.dat"); err == nil {
+		t.Fatal("No error when refreshing from a missing list")
+	}
+	if Default() != original {
+		t.Fatal("The default list has been replaced after a failed refresh")
+	}
+	if err := Refresh(server.Client(), server.URL+"/list.dat"); err != nil {
+		t.Fatalf("An error occurs when refreshing the list: %s", err)
+	}
+	if Default().Len() != 1 {
+		t.Fatalf("Inconsistent rule number, expected: %d, actual: %d", 1, Default().Len())
+	}
+	pd, err := PrimaryDomain("www.123.abc")
+	if err != nil || pd != "123.abc" {
+		t.Fatalf("Inconsistent primary domain with the refreshed list, expected: %s, actual: %s (error: %v)", "123.abc", pd, err)
+	}
+}
@@ -0,0 +1,91 @@
+package recrawl
+
+import (
+	"time"
+	"webcrawler/errors"
+)
+
+// Record is what an incremental crawl remembers about a URL.
+type Record struct {
+	URL   string `json:"url"`
+	Depth uint32 `json:"depth"`
+	// ContentHash is the hex SHA-256 of the last fetched body.
+	ContentHash  string    `json:"content_hash"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FirstFetch   time.Time `json:"first_fetch"`
+	LastFetch    time.Time `json:"last_fetch"`
+	// LastChange is the time of the last fetch which found the content
+	// changed.
+	LastChange time.Time     `json:"last_change"`
+	NextFetch  time.Time     `json:"next_fetch"`
+	Interval   time.Duration `json:"interval"`
+	Fetches    uint64        `json:"fetches"`
+	Changes    uint64        `json:"changes"`
+}
+
+// Due reports whether the URL should be fetched again at the time.
+func (record Record) Due(now time.Time) bool {
+	return !now.Before(record.NextFetch)
+}
+
+// Policy adapts the revisit interval of each URL to how often it changes:
+// the interval is divided by the factor when the content changed, and
+// multiplied by it otherwise.
+type Policy struct {
+	// InitialInterval is the interval after the first fetch.
+	InitialInterval time.Duration `json:"initial_interval"`
+	MinInterval     time.Duration `json:"min_interval"`
+	MaxInterval     time.Duration `json:"max_interval"`
+	// Factor should be greater than 1.
+	Factor float64 `json:"factor"`
+}
+
+// DefaultPolicy revisits a URL daily at first, hourly at most and monthly at
+// least.
+func DefaultPolicy() Policy {
+	return Policy{
+		InitialInterval: 24 * time.Hour,
+		MinInterval:     time.Hour,
+		MaxInterval:     30 * 24 * time.Hour,
+		Factor:          2,
+	}
+}
+
+func (policy Policy) Check() error {
+	if policy.MinInterval <= 0 {
+		return errors.NewIllegalParameterError("non-positive min revisit interval")
+	}
+	if policy.MaxInterval < policy.MinInterval {
+		return errors.NewIllegalParameterError("max revisit interval less than the min one")
+	}
+	if policy.InitialInterval < policy.MinInterval || policy.InitialInterval > policy.MaxInterval {
+		return errors.NewIllegalParameterError("initial revisit interval out of range")
+	}
+	if policy.Factor <= 1 {
+		return errors.NewIllegalParameterError("revisit factor not greater than 1")
+	}
+	return nil
+}
+
+// Schedule updates the revisit interval and the next fetch time of the
+// record fetched at the time.
+func (policy Policy) Schedule(record *Record, changed bool, now time.Time) {
+	interval := record.Interval
+	switch {
+	case record.Fetches <= 1 || interval <= 0:
+		interval = policy.InitialInterval
+	case changed:
+		interval = time.Duration(float64(interval) / policy.Factor)
+	default:
+		interval = time.Duration(float64(interval) * policy.Factor)
+	}
+	if interval < policy.MinInterval {
+		interval = policy.MinInterval
+	}
+	if interval > policy.MaxInterval {
+		interval = policy.MaxInterval
+	}
+	record.Interval = interval
+	record.NextFetch = now.Add(interval)
+}
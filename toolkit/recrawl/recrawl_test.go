@@ -0,0 +1,143 @@
+package recrawl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPolicy(t *testing.T) {
+	if err := DefaultPolicy().Check(); err != nil {
+		t.Fatalf("An error occurs when checking the default policy: %s", err)
+	}
+	illegalPolicies := []Policy{
+		{},
+		{InitialInterval: time.Hour, MinInterval: time.Hour, MaxInterval: time.Minute, Factor: 2},
+		{InitialInterval: time.Minute, MinInterval: time.Hour, MaxInterval: 2 * time.Hour, Factor: 2},
+		{InitialInterval: time.Hour, MinInterval: time.Hour, MaxInterval: 2 * time.Hour, Factor: 1},
+	}
+	for _, policy := range illegalPolicies {
+		if err := policy.Check(); err == nil {
+			t.Fatalf("No error when checking illegal policy: %#v", policy)
+		}
+	}
+	policy := Policy{
+		InitialInterval: 4 * time.Hour,
+		MinInterval:     time.Hour,
+		MaxInterval:     16 * time.Hour,
+		Factor:          2,
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	record := Record{URL: "http://a.com/", Fetches: 1}
+	steps := []struct {
+		changed  bool
+		interval time.Duration
+	}{
+		{true, 4 * time.Hour},
+		{false, 8 * time.Hour},
+		{false, 16 * time.Hour},
+		{false, 16 * time.Hour},
+		{true, 8 * time.Hour},
+		{true, 4 * time.Hour},
+		{true, 2 * time.Hour},
+		{true, time.Hour},
+		{true, time.Hour},
+	}
+	for i, step := range steps {
+		policy.Schedule(&record, step.changed, now)
+		if record.Interval != step.interval {
+			t.Fatalf("Inconsistent interval #%d, expected: %s, actual: %s", i, step.interval, record.Interval)
+		}
+		if !record.NextFetch.Equal(now.Add(step.interval)) {
+			t.Fatalf("Inconsistent next fetch #%d: %s", i, record.NextFetch)
+		}
+		if record.Due(now) || !record.Due(record.NextFetch) {
+			t.Fatalf("Inconsistent due #%d", i)
+		}
+		record.Fetches++
+	}
+}
+
+func testStore(store Store, t *testing.T) {
+	if err := store.Put(Record{}); err == nil {
+		t.Fatal("No error when putting a record without URL")
+	}
+	for i := 0; i < 10; i++ {
+		record := Record{URL: fmt.Sprintf("http://a.com/%d", i), Fetches: 1}
+		if err := store.Put(record); err != nil {
+			t.Fatalf("An error occurs when putting a record: %s", err)
+		}
+	}
+	if err := store.Put(Record{URL: "http://a.com/0", Fetches: 2}); err != nil {
+		t.Fatalf("An error occurs when putting a record: %s", err)
+	}
+	if record, ok := store.Get("http://a.com/0"); !ok || record.Fetches != 2 {
+		t.Fatalf("Inconsistent record: %#v", record)
+	}
+	if _, ok := store.Get("http://b.com/"); ok {
+		t.Fatal("A record not put is in the store")
+	}
+	if store.Len() != 10 {
+		t.Fatalf("Inconsistent store length, expected: 10, actual: %d", store.Len())
+	}
+	var ranged int
+	store.Range(func(record Record) bool {
+		ranged++
+		return ranged < 5
+	})
+	if ranged != 5 {
+		t.Fatalf("Inconsistent ranged record number, expected: 5, actual: %d", ranged)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemory()
+	defer store.Close()
+	testStore(store, t)
+}
+
+func TestFileStore(t *testing.T) {
+	if _, err := NewFile(""); err == nil {
+		t.Fatal("No error when creating a file store with empty directory")
+	}
+	dir := t.TempDir()
+	store, err := NewFile(dir)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a file store: %s", err)
+	}
+	testStore(store, t)
+	if err := store.Close(); err != nil {
+		t.Fatalf("An error occurs when closing the file store: %s", err)
+	}
+	if err := store.Put(Record{URL: "http://a.com/x"}); err == nil {
+		t.Fatal("No error when putting into a closed store")
+	}
+	// A partial line after a crash is ignored.
+	path := filepath.Join(dir, storeFileName)
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	file.WriteString(`{"url":"http://a.com/par`)
+	file.Close()
+	store, err = NewFile(dir)
+	if err != nil {
+		t.Fatalf("An error occurs when reopening the file store: %s", err)
+	}
+	defer store.Close()
+	if store.Len() != 10 {
+		t.Fatalf("Inconsistent store length after reopening, expected: 10, actual: %d", store.Len())
+	}
+	if record, ok := store.Get("http://a.com/0"); !ok || record.Fetches != 2 {
+		t.Fatalf("Inconsistent record after reopening: %#v", record)
+	}
+	data, _ := os.ReadFile(path)
+	var lines int
+	for _, b := range data {
+		if b == '\n' {
+			lines++
+		}
+	}
+	if lines != 10 {
+		t.Fatalf("The store file has not been compacted, lines: %d", lines)
+	}
+}
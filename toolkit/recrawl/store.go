@@ -0,0 +1,209 @@
+package recrawl
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"webcrawler/errors"
+)
+
+const storeFileName = "recrawl.log"
+
+// Store keeps the records of the URLs across the crawls.
+type Store interface {
+	Get(url string) (Record, bool)
+	// Put adds or replaces the record of its URL.
+	Put(record Record) error
+	// Range calls fn with each record in no particular order until it
+	// returns false.
+	Range(fn func(record Record) bool)
+	Len() uint64
+	Close() error
+}
+
+type memoryStore struct {
+	records map[string]Record
+	lock    sync.RWMutex
+}
+
+// NewMemory creates a store which holds the records in memory only.
+func NewMemory() Store {
+	return &memoryStore{records: map[string]Record{}}
+}
+
+func (store *memoryStore) Get(url string) (Record, bool) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+	record, ok := store.records[url]
+	return record, ok
+}
+
+func (store *memoryStore) Put(record Record) error {
+	if record.URL == "" {
+		return errors.NewIllegalParameterError("empty URL of the record")
+	}
+	store.lock.Lock()
+	store.records[record.URL] = record
+	store.lock.Unlock()
+	return nil
+}
+
+func (store *memoryStore) Range(fn func(record Record) bool) {
+	store.lock.RLock()
+	records := make([]Record, 0, len(store.records))
+	for _, record := range store.records {
+		records = append(records, record)
+	}
+	store.lock.RUnlock()
+	for _, record := range records {
+		if !fn(record) {
+			return
+		}
+	}
+}
+
+func (store *memoryStore) Len() uint64 {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+	return uint64(len(store.records))
+}
+
+func (store *memoryStore) Close() error {
+	return nil
+}
+
+// fileStore holds the records in memory and appends each put one to a log
+// file, which is compacted when opened.
+type fileStore struct {
+	memoryStore
+	path   string
+	file   *os.File
+	writer *bufio.Writer
+}
+
+// NewFile creates a store which keeps the records in a log file in the
+// directory, so that they are kept after being closed and reopened.
+func NewFile(dir string) (Store, error) {
+	if dir == "" {
+		return nil, errors.NewIllegalParameterError("empty directory for the recrawl store")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	store := &fileStore{
+		memoryStore: memoryStore{records: map[string]Record{}},
+		path:        filepath.Join(dir, storeFileName),
+	}
+	lines, err := store.load()
+	if err != nil {
+		return nil, err
+	}
+	if lines > len(store.records) {
+		if err := store.compact(); err != nil {
+			return nil, err
+		}
+	}
+	file, err := os.OpenFile(store.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	store.file = file
+	store.writer = bufio.NewWriter(file)
+	return store, nil
+}
+
+// load reads the log file and returns the number of the lines. The later
+// record of a URL replaces the earlier one.
+func (store *fileStore) load() (int, error) {
+	file, err := os.Open(store.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	var lines int
+	for scanner.Scan() {
+		lines++
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil || record.URL == "" {
+			// The last line may be partial after a crash.
+			continue
+		}
+		store.records[record.URL] = record
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("corrupted recrawl store file %s: %s", store.path, err)
+	}
+	return lines, nil
+}
+
+// compact rewrites the log file with the current records.
+func (store *fileStore) compact() (err error) {
+	file, err := os.CreateTemp(filepath.Dir(store.path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, record := range store.records {
+		if err = encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	if err = writer.Flush(); err != nil {
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), store.path)
+}
+
+func (store *fileStore) Put(record Record) error {
+	if record.URL == "" {
+		return errors.NewIllegalParameterError("empty URL of the record")
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if store.file == nil {
+		return fmt.Errorf("closed recrawl store %s", store.path)
+	}
+	store.writer.Write(line)
+	store.writer.WriteByte('\n')
+	if err := store.writer.Flush(); err != nil {
+		return err
+	}
+	store.records[record.URL] = record
+	return nil
+}
+
+func (store *fileStore) Close() error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if store.file == nil {
+		return nil
+	}
+	err := store.writer.Flush()
+	if cerr := store.file.Close(); err == nil {
+		err = cerr
+	}
+	store.file = nil
+	return err
+}
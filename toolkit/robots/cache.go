@@ -0,0 +1,163 @@
+package robots
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxRobotsSize = 500 * 1024
+
+var DefaultExpiry = 24 * time.Hour
+
+// UnavailableError means that robots.txt of a host could not be fetched or
+// the server failed, and it is fetched again after RetryAt.
+type UnavailableError struct {
+	Base string
+	// Failures is the number of the consecutive failed fetches.
+	Failures int
+	RetryAt  time.Time
+	Err      error
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("robots.txt of %s is unavailable (failures: %d): %s", e.Base, e.Failures, e.Err)
+}
+
+type entry struct {
+	rules   *Rules
+	err     *UnavailableError
+	done    bool
+	expires time.Time
+	ready   chan struct{}
+}
+
+func (e *entry) result() (*Rules, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.rules, nil
+}
+
+type Cache interface {
+	// Get returns the rules of the host, which disallow all while robots.txt
+	// is unavailable.
+	Get(scheme string, host string) *Rules
+	// Rules returns the rules of the host, or an *UnavailableError while
+	// robots.txt is unavailable.
+	Rules(scheme string, host string) (*Rules, error)
+	Allowed(u *url.URL) (bool, *Rules)
+	Len() int
+}
+
+func NewCache(client *http.Client, userAgent string, expiry time.Duration) Cache {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if expiry <= 0 {
+		expiry = DefaultExpiry
+	}
+	return &myCache{
+		client:    client,
+		userAgent: userAgent,
+		expiry:    expiry,
+		entries:   map[string]*entry{},
+	}
+}
+
+type myCache struct {
+	client    *http.Client
+	userAgent string
+	expiry    time.Duration
+	entries   map[string]*entry
+	lock      sync.Mutex
+}
+
+func (cache *myCache) Allowed(u *url.URL) (bool, *Rules) {
+	if u == nil {
+		return false, nil
+	}
+	rules := cache.Get(u.Scheme, u.Host)
+	return rules.Allowed(u), rules
+}
+
+func (cache *myCache) Get(scheme string, host string) *Rules {
+	rules, err := cache.Rules(scheme, host)
+	if err != nil {
+		return DisallowAll()
+	}
+	return rules
+}
+
+// Rules returns the cached rules of the host, fetching them at most once at a
+// time per host when they are missing or expired. A failed fetch is cached
+// for at most one minute.
+func (cache *myCache) Rules(scheme string, host string) (*Rules, error) {
+	key := strings.ToLower(scheme + "://" + host)
+	cache.lock.Lock()
+	e, ok := cache.entries[key]
+	if ok && (!e.done || time.Now().Before(e.expires)) {
+		cache.lock.Unlock()
+		<-e.ready
+		return e.result()
+	}
+	var failures int
+	if ok && e.err != nil {
+		failures = e.err.Failures
+	}
+	e = &entry{ready: make(chan struct{})}
+	cache.entries[key] = e
+	cache.lock.Unlock()
+	rules, expiry, err := cache.fetch(key)
+	cache.lock.Lock()
+	e.rules = rules
+	e.expires = time.Now().Add(expiry)
+	if err != nil {
+		e.err = &UnavailableError{Base: key, Failures: failures + 1, RetryAt: e.expires, Err: err}
+	}
+	e.done = true
+	cache.lock.Unlock()
+	close(e.ready)
+	return e.result()
+}
+
+func (cache *myCache) fetch(base string) (*Rules, time.Duration, error) {
+	errorExpiry := cache.expiry
+	if errorExpiry > time.Minute {
+		errorExpiry = time.Minute
+	}
+	httpReq, err := http.NewRequest("GET", base+"/robots.txt", nil)
+	if err != nil {
+		return AllowAll(), cache.expiry, nil
+	}
+	if cache.userAgent != "" {
+		httpReq.Header.Set("User-Agent", cache.userAgent)
+	}
+	httpResp, err := cache.client.Do(httpReq)
+	if err != nil {
+		return nil, errorExpiry, err
+	}
+	defer httpResp.Body.Close()
+	switch {
+	case httpResp.StatusCode >= 200 && httpResp.StatusCode < 300:
+		data, err := io.ReadAll(io.LimitReader(httpResp.Body, maxRobotsSize))
+		if err != nil {
+			return nil, errorExpiry, err
+		}
+		return Parse(data, cache.userAgent), cache.expiry, nil
+	case httpResp.StatusCode >= 400 && httpResp.StatusCode < 500:
+		return AllowAll(), cache.expiry, nil
+	default:
+		return nil, errorExpiry, fmt.Errorf("unexpected status %s", httpResp.Status)
+	}
+}
+
+func (cache *myCache) Len() int {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	return len(cache.entries)
+}
@@ -0,0 +1,88 @@
+package robots
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		atomic.AddInt32(&count, 1)
+		w.Write([]byte("User-agent: *\nDisallow: /secret\nCrawl-delay: 1\n"))
+	}))
+	defer server.Close()
+	cache := NewCache(server.Client(), "webcrawler", 100*time.Millisecond)
+	secretURL, _ := url.Parse(server.URL + "/secret/1")
+	publicURL, _ := url.Parse(server.URL + "/public/1")
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if allowed, _ := cache.Allowed(secretURL); allowed {
+				t.Errorf("The URL %s is allowed", secretURL)
+			}
+		}()
+	}
+	wg.Wait()
+	allowed, rules := cache.Allowed(publicURL)
+	if !allowed {
+		t.Fatalf("The URL %s is not allowed", publicURL)
+	}
+	if rules.CrawlDelay() != time.Second {
+		t.Fatalf("Inconsistent crawl delay, expected: %s, actual: %s", time.Second, rules.CrawlDelay())
+	}
+	if n := atomic.LoadInt32(&count); n != 1 {
+		t.Fatalf("Inconsistent fetch count, expected: %d, actual: %d", 1, n)
+	}
+	if cache.Len() != 1 {
+		t.Fatalf("Inconsistent cache length, expected: %d, actual: %d", 1, cache.Len())
+	}
+	time.Sleep(150 * time.Millisecond)
+	cache.Allowed(publicURL)
+	if n := atomic.LoadInt32(&count); n != 2 {
+		t.Fatalf("The expired rules have not been fetched again, fetch count: %d", n)
+	}
+}
+
+func TestCacheStatus(t *testing.T) {
+	status := http.StatusNotFound
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL + "/index.html")
+	cache := NewCache(server.Client(), "webcrawler", time.Millisecond)
+	if allowed, _ := cache.Allowed(u); !allowed {
+		t.Fatalf("The URL %s is not allowed when robots.txt is not found", u)
+	}
+	status = http.StatusServiceUnavailable
+	time.Sleep(5 * time.Millisecond)
+	if allowed, _ := cache.Allowed(u); allowed {
+		t.Fatalf("The URL %s is allowed when robots.txt is unavailable", u)
+	}
+	_, err := cache.Rules(u.Scheme, u.Host)
+	unavailable, ok := err.(*UnavailableError)
+	if !ok {
+		t.Fatalf("Inconsistent error when robots.txt is unavailable, expected: %T, actual: %T (%v)", unavailable, err, err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err = cache.Rules(u.Scheme, u.Host); err == nil || err.(*UnavailableError).Failures <= unavailable.Failures {
+		t.Fatalf("The failures have not been counted: %v", err)
+	}
+	status = http.StatusOK
+	time.Sleep(5 * time.Millisecond)
+	if rules, err := cache.Rules(u.Scheme, u.Host); err != nil || !rules.Allowed(u) {
+		t.Fatalf("The URL %s is not allowed after robots.txt is available again (error: %v)", u, err)
+	}
+}
@@ -0,0 +1,196 @@
+package robots
+
+import (
+	"bufio"
+	"bytes"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type rule struct {
+	allow   bool
+	pattern string
+}
+
+type Rules struct {
+	rules      []rule
+	crawlDelay time.Duration
+	sitemaps   []string
+}
+
+type group struct {
+	agents     []string
+	rules      []rule
+	crawlDelay time.Duration
+}
+
+func AllowAll() *Rules {
+	return &Rules{}
+}
+
+func DisallowAll() *Rules {
+	return &Rules{rules: []rule{{allow: false, pattern: "/"}}}
+}
+
+// Parse selects the group whose user-agent token is the longest one contained
+// in the given user agent, falling back to the "*" group. Groups with the same
+// token are merged as RFC 9309 requires.
+func Parse(data []byte, userAgent string) *Rules {
+	var groups []*group
+	var current *group
+	var sitemaps []string
+	lastWasAgent := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if index := strings.Index(line, "#"); index >= 0 {
+			line = line[:index]
+		}
+		index := strings.Index(line, ":")
+		if index < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:index]))
+		value := strings.TrimSpace(line[index+1:])
+		switch key {
+		case "user-agent":
+			if !lastWasAgent || current == nil {
+				current = &group{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			lastWasAgent = true
+			continue
+		case "allow", "disallow":
+			if current != nil {
+				if key == "disallow" && value == "" {
+					break
+				}
+				current.rules = append(current.rules, rule{allow: key == "allow", pattern: value})
+			}
+		case "crawl-delay":
+			if current != nil {
+				if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+					current.crawlDelay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		case "sitemap":
+			if value != "" {
+				sitemaps = append(sitemaps, value)
+			}
+		}
+		lastWasAgent = false
+	}
+	rules := &Rules{sitemaps: sitemaps}
+	token := productToken(userAgent)
+	var matched []*group
+	matchedLen := -1
+	for _, g := range groups {
+		for _, agent := range g.agents {
+			length := -1
+			if agent == "*" {
+				length = 0
+			} else if agent != "" && token != "" && strings.Contains(token, agent) {
+				length = len(agent)
+			}
+			if length < 0 || length < matchedLen {
+				continue
+			}
+			if length > matchedLen {
+				matched = nil
+				matchedLen = length
+			}
+			matched = append(matched, g)
+			break
+		}
+	}
+	for _, g := range matched {
+		rules.rules = append(rules.rules, g.rules...)
+		if g.crawlDelay > rules.crawlDelay {
+			rules.crawlDelay = g.crawlDelay
+		}
+	}
+	return rules
+}
+
+func productToken(userAgent string) string {
+	userAgent = strings.ToLower(strings.TrimSpace(userAgent))
+	if index := strings.IndexAny(userAgent, "/ "); index >= 0 {
+		userAgent = userAgent[:index]
+	}
+	return userAgent
+}
+
+func (rules *Rules) CrawlDelay() time.Duration {
+	return rules.crawlDelay
+}
+
+func (rules *Rules) Sitemaps() []string {
+	sitemaps := make([]string, len(rules.sitemaps))
+	copy(sitemaps, rules.sitemaps)
+	return sitemaps
+}
+
+func (rules *Rules) Allowed(u *url.URL) bool {
+	if u == nil {
+		return false
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return rules.AllowedPath(path)
+}
+
+// AllowedPath applies the longest matching rule; on a tie, allow wins.
+func (rules *Rules) AllowedPath(path string) bool {
+	if path == "/robots.txt" {
+		return true
+	}
+	allowed := true
+	matchedLen := -1
+	for _, r := range rules.rules {
+		if !match(r.pattern, path) {
+			continue
+		}
+		length := len(r.pattern)
+		if length > matchedLen || (length == matchedLen && r.allow) {
+			allowed = r.allow
+			matchedLen = length
+		}
+	}
+	return allowed
+}
+
+func match(pattern string, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for i := 1; i < len(parts); i++ {
+		part := parts[i]
+		if i == len(parts)-1 && anchored {
+			return strings.HasSuffix(rest, part)
+		}
+		index := strings.Index(rest, part)
+		if index < 0 {
+			return false
+		}
+		rest = rest[index+len(part):]
+	}
+	if anchored && len(parts) == 1 {
+		return rest == ""
+	}
+	return true
+}
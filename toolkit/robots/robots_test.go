@@ -0,0 +1,118 @@
+package robots
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+var robotsTxt = `
+# comment
+User-agent: *
+Disallow: /private/
+Allow: /private/public
+Disallow: /*.gif$
+Crawl-delay: 2
+
+User-agent: webcrawler
+User-agent: otherbot
+Disallow: /search
+Allow: /search/about
+Crawl-delay: 0.5
+
+User-agent: badbot
+Disallow: /
+
+Sitemap: http://example.com/sitemap.xml
+Sitemap: http://example.com/news.xml
+`
+
+func TestParseDefaultGroup(t *testing.T) {
+	rules := Parse([]byte(robotsTxt), "SomeBrowser/1.0")
+	cases := map[string]bool{
+		"/":                     true,
+		"/private/":             false,
+		"/private/x":            false,
+		"/private/public":       true,
+		"/private/public/x":     true,
+		"/images/a.gif":         false,
+		"/images/a.gif?x=1":     true,
+		"/search":               true,
+		"/robots.txt":           true,
+		"/privateer/index.html": true,
+	}
+	for path, expected := range cases {
+		if actual := rules.AllowedPath(path); actual != expected {
+			t.Fatalf("Inconsistent result for %q, expected: %v, actual: %v", path, expected, actual)
+		}
+	}
+	if rules.CrawlDelay() != 2*time.Second {
+		t.Fatalf("Inconsistent crawl delay, expected: %s, actual: %s", 2*time.Second, rules.CrawlDelay())
+	}
+	sitemaps := rules.Sitemaps()
+	if len(sitemaps) != 2 || sitemaps[0] != "http://example.com/sitemap.xml" {
+		t.Fatalf("Inconsistent sitemaps: %v", sitemaps)
+	}
+}
+
+func TestParseSpecificGroup(t *testing.T) {
+	rules := Parse([]byte(robotsTxt), "WebCrawler/2.1 (+http://example.com)")
+	cases := map[string]bool{
+		"/private/":      true,
+		"/search?q=go":   false,
+		"/search/about":  true,
+		"/search/about2": true,
+		"/searching":     false,
+	}
+	for path, expected := range cases {
+		if actual := rules.AllowedPath(path); actual != expected {
+			t.Fatalf("Inconsistent result for %q, expected: %v, actual: %v", path, expected, actual)
+		}
+	}
+	if rules.CrawlDelay() != 500*time.Millisecond {
+		t.Fatalf("Inconsistent crawl delay, expected: %s, actual: %s", 500*time.Millisecond, rules.CrawlDelay())
+	}
+	rules = Parse([]byte(robotsTxt), "BadBot")
+	u, _ := url.Parse("http://example.com/index.html")
+	if rules.Allowed(u) {
+		t.Fatalf("The URL %s is still allowed for a bot disallowed everywhere", u)
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	rules := Parse(nil, "webcrawler")
+	u, _ := url.Parse("http://example.com/a/b?c=d")
+	if !rules.Allowed(u) {
+		t.Fatalf("The URL %s is not allowed by empty rules", u)
+	}
+	if DisallowAll().Allowed(u) {
+		t.Fatalf("The URL %s is allowed by disallow-all rules", u)
+	}
+	if rules.Allowed(nil) {
+		t.Fatal("A nil URL is allowed")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		pattern  string
+		path     string
+		expected bool
+	}{
+		{"/fish", "/fish.html", true},
+		{"/fish", "/Fish.asp", false},
+		{"/fish*", "/fishheads/yummy.html", true},
+		{"/*.php", "/folder/filename.php?parameters", true},
+		{"/*.php$", "/filename.php?parameters", false},
+		{"/*.php$", "/folder/filename.php", true},
+		{"/fish*.php", "/fishheads/catfish.php?parameters", true},
+		{"/fish$", "/fish", true},
+		{"/fish$", "/fish/", false},
+	}
+	for _, c := range cases {
+		if actual := match(c.pattern, c.path); actual != c.expected {
+			t.Fatalf("Inconsistent match result for pattern %q and path %q, expected: %v, actual: %v",
+				c.pattern, c.path, c.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package seenset
+
+import (
+	"hash/maphash"
+	"math"
+	"sync"
+	"webcrawler/errors"
+)
+
+const (
+	DefaultBloomCapacity          = 1 << 20
+	DefaultBloomFalsePositiveRate = 0.001
+)
+
+// The parameters of the scalable Bloom filter described by Almeida et al.:
+// every new filter has twice the capacity and a tighter error rate, so the
+// compound false positive rate stays below the given one.
+const (
+	bloomGrowth         = 2
+	bloomTighteningRate = 0.5
+)
+
+type bloomFilter struct {
+	bits     []uint64
+	m        uint64
+	k        uint64
+	capacity uint64
+	count    uint64
+}
+
+func newBloomFilter(capacity uint64, fpRate float64) *bloomFilter {
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	k := uint64(math.Round(float64(m) / float64(capacity) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &bloomFilter{
+		bits:     make([]uint64, (m+63)/64),
+		m:        m,
+		k:        k,
+		capacity: capacity,
+	}
+}
+
+func (filter *bloomFilter) contains(h1, h2 uint64) bool {
+	for i := uint64(0); i < filter.k; i++ {
+		pos := (h1 + i*h2) % filter.m
+		if filter.bits[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (filter *bloomFilter) add(h1, h2 uint64) {
+	for i := uint64(0); i < filter.k; i++ {
+		pos := (h1 + i*h2) % filter.m
+		filter.bits[pos/64] |= 1 << (pos % 64)
+	}
+	filter.count++
+}
+
+type bloomSet struct {
+	capacity uint64
+	fpRate   float64
+	seeds    [2]maphash.Seed
+	filters  []*bloomFilter
+	count    uint64
+	lock     sync.RWMutex
+}
+
+// NewBloom creates a scalable Bloom filter. The capacity is the expected key
+// number of the first filter and fpRate is the upper bound of the false
+// positive rate. Zero values mean the defaults.
+func NewBloom(capacity uint64, fpRate float64) (SeenSet, error) {
+	if capacity == 0 {
+		capacity = DefaultBloomCapacity
+	}
+	if fpRate == 0 {
+		fpRate = DefaultBloomFalsePositiveRate
+	}
+	if fpRate < 0 || fpRate >= 1 {
+		return nil, errors.NewIllegalParameterError("false positive rate should be in (0, 1)")
+	}
+	set := &bloomSet{
+		capacity: capacity,
+		fpRate:   fpRate,
+		seeds:    [2]maphash.Seed{maphash.MakeSeed(), maphash.MakeSeed()},
+	}
+	set.Clear()
+	return set, nil
+}
+
+func (set *bloomSet) hash(key string) (uint64, uint64) {
+	return maphash.String(set.seeds[0], key), maphash.String(set.seeds[1], key) | 1
+}
+
+func (set *bloomSet) containsHash(h1, h2 uint64) bool {
+	for _, filter := range set.filters {
+		if filter.contains(h1, h2) {
+			return true
+		}
+	}
+	return false
+}
+
+func (set *bloomSet) Add(key string) bool {
+	h1, h2 := set.hash(key)
+	set.lock.Lock()
+	defer set.lock.Unlock()
+	if set.containsHash(h1, h2) {
+		return false
+	}
+	last := set.filters[len(set.filters)-1]
+	if last.count >= last.capacity {
+		fpRate := set.fpRate * (1 - bloomTighteningRate) *
+			math.Pow(bloomTighteningRate, float64(len(set.filters)))
+		last = newBloomFilter(last.capacity*bloomGrowth, fpRate)
+		set.filters = append(set.filters, last)
+	}
+	last.add(h1, h2)
+	set.count++
+	return true
+}
+
+func (set *bloomSet) Contains(key string) bool {
+	h1, h2 := set.hash(key)
+	set.lock.RLock()
+	defer set.lock.RUnlock()
+	return set.containsHash(h1, h2)
+}
+
+func (set *bloomSet) Len() uint64 {
+	set.lock.RLock()
+	defer set.lock.RUnlock()
+	return set.count
+}
+
+func (set *bloomSet) Clear() error {
+	set.lock.Lock()
+	set.filters = []*bloomFilter{newBloomFilter(set.capacity, set.fpRate*(1-bloomTighteningRate))}
+	set.count = 0
+	set.lock.Unlock()
+	return nil
+}
+
+func (set *bloomSet) Close() error {
+	return nil
+}
@@ -0,0 +1,341 @@
+package seenset
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"webcrawler/errors"
+)
+
+const (
+	diskFileName     = "seen.tbl"
+	diskMagic        = "WCSEEN01"
+	diskHeaderSize   = 32
+	diskSlotSize     = 16
+	diskInitialSlots = 1 << 16
+	diskCopyChunk    = 4096
+	// diskProbeChunk is the number of the slots read at once in probing.
+	diskProbeChunk = 16
+	// diskHeaderInterval is the number of the additions between the writes
+	// of the count in the header.
+	diskHeaderInterval = 1024
+)
+
+type fingerprint [diskSlotSize]byte
+
+var emptySlot fingerprint
+
+func newFingerprint(key string) fingerprint {
+	var fp fingerprint
+	sum := sha256.Sum256([]byte(key))
+	copy(fp[:], sum[:])
+	if fp == emptySlot {
+		fp[diskSlotSize-1] = 1
+	}
+	return fp
+}
+
+// diskSet is an open addressing hash table of 128-bit key fingerprints kept
+// in a file, so only the header stays in memory. It keeps its content after
+// being closed and reopened. The count in the header is written every
+// diskHeaderInterval additions and on closing, so it may be behind after a
+// crash.
+//
+// Add and Contains report the key as present if the file could not be
+// accessed, so that a failing disk does not crawl every URL again, and the
+// first error is kept for Err.
+type diskSet struct {
+	path  string
+	file  *os.File
+	slots uint64
+	count uint64
+	// growAt is the count to grow the table at.
+	growAt uint64
+	// unsaved is the number of the additions since the header was written.
+	unsaved uint64
+	lock    sync.RWMutex
+	// err is guarded by errLock, since Contains only holds the read lock.
+	err     error
+	errLock sync.Mutex
+}
+
+func NewDisk(dir string) (SeenSet, error) {
+	if dir == "" {
+		return nil, errors.NewIllegalParameterError("empty directory for the disk seen set")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	set := &diskSet{path: filepath.Join(dir, diskFileName)}
+	if err := set.open(); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+func (set *diskSet) open() error {
+	file, err := os.OpenFile(set.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if info.Size() == 0 {
+		if err = initDiskTable(file, diskInitialSlots); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	header := make([]byte, diskHeaderSize)
+	if _, err = file.ReadAt(header, 0); err != nil {
+		file.Close()
+		return err
+	}
+	if string(header[:len(diskMagic)]) != diskMagic {
+		file.Close()
+		return fmt.Errorf("invalid seen set file %s", set.path)
+	}
+	slots := binary.LittleEndian.Uint64(header[8:16])
+	if slots == 0 || info.Size() != 0 && info.Size() != int64(diskHeaderSize+slots*diskSlotSize) {
+		file.Close()
+		return fmt.Errorf("corrupted seen set file %s", set.path)
+	}
+	set.file = file
+	set.slots = slots
+	set.count = binary.LittleEndian.Uint64(header[16:24])
+	set.growAt = slots / 2
+	return nil
+}
+
+func initDiskTable(file *os.File, slots uint64) error {
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	if err := file.Truncate(int64(diskHeaderSize + slots*diskSlotSize)); err != nil {
+		return err
+	}
+	return writeDiskHeader(file, slots, 0)
+}
+
+func writeDiskHeader(file *os.File, slots uint64, count uint64) error {
+	header := make([]byte, diskHeaderSize)
+	copy(header, diskMagic)
+	binary.LittleEndian.PutUint64(header[8:16], slots)
+	binary.LittleEndian.PutUint64(header[16:24], count)
+	_, err := file.WriteAt(header, 0)
+	return err
+}
+
+// findSlot returns the slot holding the fingerprint or the empty slot where
+// it should be put. The slots are read diskProbeChunk at a time.
+func findSlot(file *os.File, slots uint64, fp fingerprint) (uint64, bool, error) {
+	var current fingerprint
+	chunk := make([]byte, diskProbeChunk*diskSlotSize)
+	index := binary.LittleEndian.Uint64(fp[:8]) % slots
+	for probed := uint64(0); probed < slots; {
+		n := slots - index
+		if n > diskProbeChunk {
+			n = diskProbeChunk
+		}
+		if _, err := file.ReadAt(chunk[:n*diskSlotSize], int64(diskHeaderSize+index*diskSlotSize)); err != nil {
+			return 0, false, err
+		}
+		for i := uint64(0); i < n && probed < slots; i++ {
+			copy(current[:], chunk[i*diskSlotSize:])
+			if current == emptySlot {
+				return index, false, nil
+			}
+			if current == fp {
+				return index, true, nil
+			}
+			index = (index + 1) % slots
+			probed++
+		}
+	}
+	return 0, false, fmt.Errorf("full seen set table")
+}
+
+func putSlot(file *os.File, index uint64, fp fingerprint) error {
+	_, err := file.WriteAt(fp[:], int64(diskHeaderSize+index*diskSlotSize))
+	return err
+}
+
+func (set *diskSet) Add(key string) bool {
+	fp := newFingerprint(key)
+	set.lock.Lock()
+	defer set.lock.Unlock()
+	if set.file == nil {
+		set.fail(errors.NewIllegalParameterError("closed seen set"))
+		return false
+	}
+	index, found, err := findSlot(set.file, set.slots, fp)
+	if err != nil {
+		set.fail(err)
+		return false
+	}
+	if found {
+		return false
+	}
+	if err = putSlot(set.file, index, fp); err != nil {
+		set.fail(err)
+		return false
+	}
+	set.count++
+	set.unsaved++
+	if set.count > set.growAt {
+		if err = set.grow(); err != nil {
+			// The table is still usable, and the growth is retried once
+			// the half of the rest slots are taken.
+			set.fail(err)
+			set.growAt = set.count + (set.slots-set.count)/2
+		}
+	}
+	if set.unsaved >= diskHeaderInterval {
+		set.saveHeader()
+	}
+	return true
+}
+
+// fail keeps the first error.
+func (set *diskSet) fail(err error) {
+	set.errLock.Lock()
+	defer set.errLock.Unlock()
+	if set.err == nil {
+		set.err = err
+	}
+}
+
+// saveHeader writes the count in the header.
+func (set *diskSet) saveHeader() error {
+	if err := writeDiskHeader(set.file, set.slots, set.count); err != nil {
+		set.fail(err)
+		return err
+	}
+	set.unsaved = 0
+	return nil
+}
+
+// Err returns the first error in accessing the file since the set was opened
+// or cleared.
+func (set *diskSet) Err() error {
+	set.errLock.Lock()
+	defer set.errLock.Unlock()
+	return set.err
+}
+
+// grow rehashes the table into a file with twice the slots.
+func (set *diskSet) grow() error {
+	tmpPath := set.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	slots := set.slots * 2
+	if err = initDiskTable(tmp, slots); err != nil {
+		tmp.Close()
+		return err
+	}
+	chunk := make([]byte, diskCopyChunk*diskSlotSize)
+	var fp fingerprint
+	for offset := int64(diskHeaderSize); ; {
+		n, err := set.file.ReadAt(chunk, offset)
+		if err != nil && err != io.EOF {
+			tmp.Close()
+			return err
+		}
+		for i := 0; i+diskSlotSize <= n; i += diskSlotSize {
+			copy(fp[:], chunk[i:i+diskSlotSize])
+			if fp == emptySlot {
+				continue
+			}
+			index, _, err := findSlot(tmp, slots, fp)
+			if err == nil {
+				err = putSlot(tmp, index, fp)
+			}
+			if err != nil {
+				tmp.Close()
+				return err
+			}
+		}
+		offset += int64(n)
+		if err == io.EOF || n == 0 {
+			break
+		}
+	}
+	if err = writeDiskHeader(tmp, slots, set.count); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = os.Rename(tmpPath, set.path); err != nil {
+		tmp.Close()
+		return err
+	}
+	set.file.Close()
+	set.file = tmp
+	set.slots = slots
+	set.growAt = slots / 2
+	set.unsaved = 0
+	return nil
+}
+
+func (set *diskSet) Contains(key string) bool {
+	fp := newFingerprint(key)
+	set.lock.RLock()
+	defer set.lock.RUnlock()
+	if set.file == nil {
+		set.fail(errors.NewIllegalParameterError("closed seen set"))
+		return true
+	}
+	_, found, err := findSlot(set.file, set.slots, fp)
+	if err != nil {
+		set.fail(err)
+		return true
+	}
+	return found
+}
+
+func (set *diskSet) Len() uint64 {
+	set.lock.RLock()
+	defer set.lock.RUnlock()
+	return set.count
+}
+
+func (set *diskSet) Clear() error {
+	set.lock.Lock()
+	defer set.lock.Unlock()
+	if set.file == nil {
+		return errors.NewIllegalParameterError("closed seen set")
+	}
+	if err := initDiskTable(set.file, diskInitialSlots); err != nil {
+		return err
+	}
+	set.slots = diskInitialSlots
+	set.count = 0
+	set.growAt = diskInitialSlots / 2
+	set.unsaved = 0
+	set.errLock.Lock()
+	set.err = nil
+	set.errLock.Unlock()
+	return nil
+}
+
+func (set *diskSet) Close() error {
+	set.lock.Lock()
+	defer set.lock.Unlock()
+	if set.file == nil {
+		return nil
+	}
+	err := set.saveHeader()
+	if cerr := set.file.Close(); err == nil {
+		err = cerr
+	}
+	set.file = nil
+	return err
+}
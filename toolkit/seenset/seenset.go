@@ -0,0 +1,77 @@
+package seenset
+
+import (
+	"sync"
+)
+
+// SeenSet records the keys that have been seen.
+type SeenSet interface {
+	// Add adds the key and reports whether it was absent before.
+	// The check and the addition are atomic.
+	Add(key string) bool
+	Contains(key string) bool
+	// Len returns the number of the added keys. It may be approximate
+	// for probabilistic implementations.
+	Len() uint64
+	Clear() error
+	Close() error
+}
+
+// Failer is implemented by the seen sets which could fail to access their
+// storage, and report the keys as present meanwhile.
+type Failer interface {
+	// Err returns the first error since the set was opened or cleared.
+	Err() error
+}
+
+// Err returns the error of the set if it's a Failer.
+func Err(set SeenSet) error {
+	if failer, ok := set.(Failer); ok {
+		return failer.Err()
+	}
+	return nil
+}
+
+type exactSet struct {
+	keys map[string]struct{}
+	lock sync.RWMutex
+}
+
+// NewExact creates a seen set which holds all of the keys in memory.
+func NewExact() SeenSet {
+	return &exactSet{keys: map[string]struct{}{}}
+}
+
+func (set *exactSet) Add(key string) bool {
+	set.lock.Lock()
+	defer set.lock.Unlock()
+	if _, ok := set.keys[key]; ok {
+		return false
+	}
+	set.keys[key] = struct{}{}
+	return true
+}
+
+func (set *exactSet) Contains(key string) bool {
+	set.lock.RLock()
+	defer set.lock.RUnlock()
+	_, ok := set.keys[key]
+	return ok
+}
+
+func (set *exactSet) Len() uint64 {
+	set.lock.RLock()
+	defer set.lock.RUnlock()
+	return uint64(len(set.keys))
+}
+
+func (set *exactSet) Clear() error {
+	set.lock.Lock()
+	set.keys = map[string]struct{}{}
+	set.lock.Unlock()
+	return nil
+}
+
+func (set *exactSet) Close() error {
+	return set.Clear()
+}
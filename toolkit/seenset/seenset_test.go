@@ -0,0 +1,178 @@
+package seenset
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+)
+
+func testSeenSet(set SeenSet, exact bool, t *testing.T) {
+	number := 1000
+	for i := 0; i < number; i++ {
+		key := fmt.Sprintf("http://a.com/%d", i)
+		if !set.Add(key) {
+			if exact {
+				t.Fatalf("The key %q is reported as seen before adding", key)
+			}
+			continue
+		}
+		if set.Add(key) {
+			t.Fatalf("The key %q can be added repeatedly", key)
+		}
+	}
+	for i := 0; i < number; i++ {
+		key := fmt.Sprintf("http://a.com/%d", i)
+		if !set.Contains(key) {
+			t.Fatalf("The key %q is not contained", key)
+		}
+	}
+	if exact && set.Len() != uint64(number) {
+		t.Fatalf("Inconsistent seen set length, expected: %d, actual: %d", number, set.Len())
+	}
+	var wg sync.WaitGroup
+	var added uint64
+	var lock sync.Mutex
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if set.Add("http://b.com/") {
+				lock.Lock()
+				added++
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if added != 1 && (exact || added > 1) {
+		t.Fatalf("Inconsistent number of concurrent additions, expected: %d, actual: %d", 1, added)
+	}
+	if err := set.Clear(); err != nil {
+		t.Fatalf("An error occurs when clearing the seen set: %s", err)
+	}
+	if set.Len() != 0 || set.Contains("http://b.com/") {
+		t.Fatal("The seen set is not empty after clearing")
+	}
+}
+
+func TestExact(t *testing.T) {
+	set := NewExact()
+	defer set.Close()
+	testSeenSet(set, true, t)
+}
+
+func TestBloom(t *testing.T) {
+	if _, err := NewBloom(10, 1.5); err == nil {
+		t.Fatal("No error when creating a Bloom filter with illegal false positive rate")
+	}
+	set, err := NewBloom(100, 0.01)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a Bloom filter: %s", err)
+	}
+	defer set.Close()
+	testSeenSet(set, false, t)
+	number := 20000
+	for i := 0; i < number; i++ {
+		set.Add(fmt.Sprintf("http://a.com/%d", i))
+	}
+	if len(set.(*bloomSet).filters) < 2 {
+		t.Fatal("The Bloom filter did not scale")
+	}
+	falsePositives := 0
+	for i := 0; i < number; i++ {
+		if set.Contains(fmt.Sprintf("http://c.com/%d", i)) {
+			falsePositives++
+		}
+	}
+	// The rate is bounded in expectation, so allow some deviation.
+	if rate := float64(falsePositives) / float64(number); rate > 0.015 {
+		t.Fatalf("The false positive rate is too high, expected: <= %f, actual: %f", 0.015, rate)
+	}
+}
+
+func TestDisk(t *testing.T) {
+	if _, err := NewDisk(""); err == nil {
+		t.Fatal("No error when creating a disk seen set without directory")
+	}
+	dir := t.TempDir()
+	set, err := NewDisk(dir)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a disk seen set: %s", err)
+	}
+	testSeenSet(set, true, t)
+	number := diskInitialSlots
+	for i := 0; i < number; i++ {
+		set.Add(fmt.Sprintf("http://a.com/%d", i))
+	}
+	if set.(*diskSet).slots <= diskInitialSlots {
+		t.Fatal("The disk seen set did not grow")
+	}
+	if err = set.Close(); err != nil {
+		t.Fatalf("An error occurs when closing the disk seen set: %s", err)
+	}
+	set, err = NewDisk(dir)
+	if err != nil {
+		t.Fatalf("An error occurs when reopening the disk seen set: %s", err)
+	}
+	defer set.Close()
+	if set.Len() != uint64(number) {
+		t.Fatalf("Inconsistent reopened seen set length, expected: %d, actual: %d", number, set.Len())
+	}
+	for i := 0; i < number; i += 97 {
+		key := fmt.Sprintf("http://a.com/%d", i)
+		if !set.Contains(key) || set.Add(key) {
+			t.Fatalf("The key %q is lost after reopening", key)
+		}
+	}
+	if set.Contains("http://c.com/") {
+		t.Fatal("The disk seen set contains a key never added")
+	}
+}
+
+func TestDiskErrors(t *testing.T) {
+	dir := t.TempDir()
+	set, err := NewDisk(dir)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a disk seen set: %s", err)
+	}
+	disk := set.(*diskSet)
+	// The table could not grow into the temporary file.
+	tmpPath := disk.path + ".tmp"
+	if err = os.Mkdir(tmpPath, 0755); err != nil {
+		t.Fatalf("An error occurs when creating a directory: %s", err)
+	}
+	number := diskInitialSlots/2 + 1
+	for i := 0; i < number; i++ {
+		if !set.Add(fmt.Sprintf("http://a.com/%d", i)) {
+			t.Fatalf("The key %d is not added to the table which could not grow", i)
+		}
+	}
+	if Err(set) == nil {
+		t.Fatal("No error when the table could not grow")
+	}
+	if disk.slots != diskInitialSlots || disk.growAt <= uint64(number) {
+		t.Fatalf("Inconsistent table after the failed growth, slots: %d, grow at: %d", disk.slots, disk.growAt)
+	}
+	os.Remove(tmpPath)
+	for i := number; disk.slots == diskInitialSlots && i < diskInitialSlots; i++ {
+		set.Add(fmt.Sprintf("http://a.com/%d", i))
+	}
+	if disk.slots <= diskInitialSlots {
+		t.Fatal("The disk seen set did not grow after the failure")
+	}
+	if err = set.Clear(); err != nil || Err(set) != nil {
+		t.Fatalf("The error is kept after clearing: %v, %v", err, Err(set))
+	}
+	// The keys are reported as present if the file could not be accessed.
+	disk.file.Close()
+	if set.Add("http://b.com/") || !set.Contains("http://b.com/") {
+		t.Fatal("A key is reported as absent when the file could not be accessed")
+	}
+	if Err(set) == nil {
+		t.Fatal("No error when the file could not be accessed")
+	}
+	if Err(NewExact()) != nil {
+		t.Fatal("An error of the exact seen set")
+	}
+}
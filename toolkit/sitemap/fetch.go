@@ -0,0 +1,29 @@
+package sitemap
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Fetch downloads and parses the sitemap at the location.
+func Fetch(ctx context.Context, client *http.Client, userAgent string, loc string) (*Sitemap, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, loc, nil)
+	if err != nil {
+		return nil, err
+	}
+	if userAgent != "" {
+		httpReq.Header.Set("User-Agent", userAgent)
+	}
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(httpResp.Body, 64*1024))
+		return nil, fmt.Errorf("unexpected status code %d", httpResp.StatusCode)
+	}
+	return Parse(httpResp.Body)
+}
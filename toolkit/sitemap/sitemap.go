@@ -0,0 +1,175 @@
+package sitemap
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// MaxSize is the max uncompressed size of a sitemap.
+const MaxSize = 50 << 20
+
+// MaxEntries is the max number of the entries in a sitemap.
+const MaxEntries = 50000
+
+// DefaultPriority is the priority of a URL without one.
+const DefaultPriority = 0.5
+
+// Entry is a URL in a urlset, or a sitemap in a sitemapindex.
+type Entry struct {
+	Loc        string
+	LastMod    time.Time
+	ChangeFreq string
+	// Priority is in [0, 1], and DefaultPriority if absent.
+	Priority float64
+}
+
+// Sitemap is either a urlset with URLs, or a sitemapindex with Sitemaps.
+type Sitemap struct {
+	URLs     []Entry
+	Sitemaps []Entry
+}
+
+type xmlEntry struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod"`
+	ChangeFreq string `xml:"changefreq"`
+	Priority   string `xml:"priority"`
+}
+
+// Parse parses a sitemap in XML or plain text with one URL per line, which
+// may be compressed by gzip. The entries without location are ignored, and
+// the ones beyond MaxEntries are dropped.
+func Parse(r io.Reader) (*Sitemap, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		br = bufio.NewReader(gr)
+	}
+	data, err := io.ReadAll(io.LimitReader(br, MaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxSize {
+		return nil, fmt.Errorf("sitemap larger than %d bytes", MaxSize)
+	}
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	if len(trimmed) > 0 && trimmed[0] != '<' {
+		return parseText(trimmed), nil
+	}
+	return parseXML(trimmed)
+}
+
+func parseText(data []byte) *Sitemap {
+	sitemap := &Sitemap{}
+	for _, line := range strings.Split(string(data), "\n") {
+		loc := strings.TrimSpace(line)
+		if loc == "" {
+			continue
+		}
+		if len(sitemap.URLs) >= MaxEntries {
+			break
+		}
+		sitemap.URLs = append(sitemap.URLs, Entry{Loc: loc, Priority: DefaultPriority})
+	}
+	return sitemap
+}
+
+func parseXML(data []byte) (*Sitemap, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	sitemap := &Sitemap{}
+	var root string
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if root == "" {
+			root = start.Name.Local
+			if root != "urlset" && root != "sitemapindex" {
+				return nil, fmt.Errorf("unsupported sitemap root element %q", root)
+			}
+			continue
+		}
+		if start.Name.Local != "url" && start.Name.Local != "sitemap" {
+			decoder.Skip()
+			continue
+		}
+		var e xmlEntry
+		if err := decoder.DecodeElement(&e, &start); err != nil {
+			return nil, err
+		}
+		entry, ok := e.entry()
+		if !ok {
+			continue
+		}
+		if len(sitemap.URLs)+len(sitemap.Sitemaps) >= MaxEntries {
+			break
+		}
+		if root == "urlset" && start.Name.Local == "url" {
+			sitemap.URLs = append(sitemap.URLs, entry)
+		} else if root == "sitemapindex" && start.Name.Local == "sitemap" {
+			sitemap.Sitemaps = append(sitemap.Sitemaps, entry)
+		}
+	}
+	if root == "" {
+		return nil, fmt.Errorf("no sitemap root element")
+	}
+	return sitemap, nil
+}
+
+func (e xmlEntry) entry() (Entry, bool) {
+	loc := strings.TrimSpace(e.Loc)
+	if loc == "" {
+		return Entry{}, false
+	}
+	entry := Entry{
+		Loc:        loc,
+		LastMod:    ParseTime(e.LastMod),
+		ChangeFreq: strings.ToLower(strings.TrimSpace(e.ChangeFreq)),
+		Priority:   DefaultPriority,
+	}
+	var priority float64
+	if _, err := fmt.Sscanf(strings.TrimSpace(e.Priority), "%g", &priority); err == nil &&
+		priority >= 0 && priority <= 1 {
+		entry.Priority = priority
+	}
+	return entry, true
+}
+
+// timeLayouts are the W3C datetime formats of lastmod.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+// ParseTime parses a W3C datetime, and returns zero time if it is illegal.
+func ParseTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,123 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const urlset = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc> http://a.com/1 </loc>
+    <lastmod>2024-01-02</lastmod>
+    <changefreq>Daily</changefreq>
+    <priority>0.8</priority>
+  </url>
+  <url>
+    <loc>http://a.com/2</loc>
+    <lastmod>2024-01-02T03:04:05+08:00</lastmod>
+    <priority>2</priority>
+  </url>
+  <url><lastmod>2024-01-02</lastmod></url>
+</urlset>`
+
+const sitemapindex = `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>http://a.com/s1.xml</loc><lastmod>2024-01</lastmod></sitemap>
+  <sitemap><loc>http://a.com/s2.xml.gz</loc></sitemap>
+</sitemapindex>`
+
+func gzipData(data string) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Write([]byte(data))
+	gw.Close()
+	return buf.Bytes()
+}
+
+func checkURLSet(sm *Sitemap, t *testing.T) {
+	if len(sm.URLs) != 2 || len(sm.Sitemaps) != 0 {
+		t.Fatalf("Inconsistent sitemap: %#v", sm)
+	}
+	first := sm.URLs[0]
+	if first.Loc != "http://a.com/1" || first.Priority != 0.8 || first.ChangeFreq != "daily" ||
+		!first.LastMod.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Inconsistent entry: %#v", first)
+	}
+	second := sm.URLs[1]
+	if second.Priority != DefaultPriority ||
+		!second.LastMod.Equal(time.Date(2024, 1, 1, 19, 4, 5, 0, time.UTC)) {
+		t.Fatalf("Inconsistent entry: %#v", second)
+	}
+}
+
+func TestParse(t *testing.T) {
+	sm, err := Parse(strings.NewReader(urlset))
+	if err != nil {
+		t.Fatalf("An error occurs when parsing a urlset: %s", err)
+	}
+	checkURLSet(sm, t)
+	sm, err = Parse(bytes.NewReader(gzipData(urlset)))
+	if err != nil {
+		t.Fatalf("An error occurs when parsing a gzipped urlset: %s", err)
+	}
+	checkURLSet(sm, t)
+	sm, err = Parse(strings.NewReader(sitemapindex))
+	if err != nil {
+		t.Fatalf("An error occurs when parsing a sitemapindex: %s", err)
+	}
+	if len(sm.URLs) != 0 || len(sm.Sitemaps) != 2 || sm.Sitemaps[1].Loc != "http://a.com/s2.xml.gz" ||
+		!sm.Sitemaps[0].LastMod.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Inconsistent sitemap index: %#v", sm)
+	}
+	sm, err = Parse(strings.NewReader("http://a.com/1\r\n\r\nhttp://a.com/2\n"))
+	if err != nil {
+		t.Fatalf("An error occurs when parsing a text sitemap: %s", err)
+	}
+	if len(sm.URLs) != 2 || sm.URLs[1].Loc != "http://a.com/2" {
+		t.Fatalf("Inconsistent text sitemap: %#v", sm)
+	}
+	illegalSitemaps := []string{
+		"<html></html>",
+		"<urlset><url><loc>http://a.com/</loc>",
+		"<?xml version=\"1.0\"?>",
+	}
+	for _, s := range illegalSitemaps {
+		if _, err := Parse(strings.NewReader(s)); err == nil {
+			t.Fatalf("No error when parsing illegal sitemap: %q", s)
+		}
+	}
+	if !ParseTime("2024-13-01").IsZero() {
+		t.Fatal("Non-zero time of illegal lastmod")
+	}
+}
+
+func TestFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != "webcrawler/1.0" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/sitemap.xml.gz":
+			w.Header().Set("Content-Type", "application/x-gzip")
+			w.Write(gzipData(urlset))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	sm, err := Fetch(context.Background(), &http.Client{}, "webcrawler/1.0", server.URL+"/sitemap.xml.gz")
+	if err != nil {
+		t.Fatalf("An error occurs when fetching a sitemap: %s", err)
+	}
+	checkURLSet(sm, t)
+	if _, err := Fetch(context.Background(), &http.Client{}, "webcrawler/1.0", server.URL+"/none.xml"); err == nil {
+		t.Fatal("No error when fetching a missing sitemap")
+	}
+}
@@ -0,0 +1,282 @@
+package urlnorm
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// DefaultTrackingParams is used when tracking parameter removal is enabled
+// but no parameter is given. A trailing "*" matches any suffix.
+var DefaultTrackingParams = []string{
+	"utm_*",
+	"gclid",
+	"dclid",
+	"fbclid",
+	"msclkid",
+	"yclid",
+	"igshid",
+	"mc_cid",
+	"mc_eid",
+	"_ga",
+	"_hsenc",
+	"_hsmi",
+	"spm",
+}
+
+// Options selects the normalization steps. The zero value leaves URLs
+// untouched.
+type Options struct {
+	LowercaseHost        bool     `json:"lowercase_host"`
+	RemoveDefaultPort    bool     `json:"remove_default_port"`
+	RemoveFragment       bool     `json:"remove_fragment"`
+	SortQuery            bool     `json:"sort_query"`
+	RemoveTrackingParams bool     `json:"remove_tracking_params"`
+	TrackingParams       []string `json:"tracking_params,omitempty"`
+	ResolveDotSegments   bool     `json:"resolve_dot_segments"`
+	NormalizeEncoding    bool     `json:"normalize_encoding"`
+}
+
+func DefaultOptions() Options {
+	return Options{
+		LowercaseHost:        true,
+		RemoveDefaultPort:    true,
+		RemoveFragment:       true,
+		SortQuery:            true,
+		RemoveTrackingParams: true,
+		ResolveDotSegments:   true,
+		NormalizeEncoding:    true,
+	}
+}
+
+func (opts Options) Enabled() bool {
+	return opts.LowercaseHost || opts.RemoveDefaultPort || opts.RemoveFragment ||
+		opts.SortQuery || opts.RemoveTrackingParams || opts.ResolveDotSegments ||
+		opts.NormalizeEncoding
+}
+
+func (opts Options) Same(another Options) bool {
+	if opts.LowercaseHost != another.LowercaseHost ||
+		opts.RemoveDefaultPort != another.RemoveDefaultPort ||
+		opts.RemoveFragment != another.RemoveFragment ||
+		opts.SortQuery != another.SortQuery ||
+		opts.RemoveTrackingParams != another.RemoveTrackingParams ||
+		opts.ResolveDotSegments != another.ResolveDotSegments ||
+		opts.NormalizeEncoding != another.NormalizeEncoding {
+		return false
+	}
+	if len(opts.TrackingParams) != len(another.TrackingParams) {
+		return false
+	}
+	for i, param := range opts.TrackingParams {
+		if param != another.TrackingParams[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Normalize returns a normalized copy of the URL. The original one is not
+// modified.
+func Normalize(u *url.URL, opts Options) *url.URL {
+	if u == nil {
+		return nil
+	}
+	normalized := *u
+	if u.User != nil {
+		user := *u.User
+		normalized.User = &user
+	}
+	normalized.Scheme = strings.ToLower(normalized.Scheme)
+	if opts.LowercaseHost {
+		normalized.Host = strings.ToLower(normalized.Host)
+	}
+	if opts.RemoveDefaultPort {
+		normalized.Host = removeDefaultPort(normalized.Scheme, normalized.Host)
+	}
+	if opts.RemoveFragment {
+		normalized.Fragment = ""
+		normalized.RawFragment = ""
+	}
+	if normalized.Opaque == "" && (opts.ResolveDotSegments || opts.NormalizeEncoding) {
+		escaped := normalized.EscapedPath()
+		if opts.NormalizeEncoding {
+			escaped = normalizeEncoding(escaped)
+		}
+		if opts.ResolveDotSegments {
+			escaped = removeDotSegments(escaped)
+			if escaped == "" && normalized.Host != "" {
+				escaped = "/"
+			}
+		}
+		if p, err := url.PathUnescape(escaped); err == nil {
+			normalized.Path = p
+			normalized.RawPath = escaped
+			if normalized.EscapedPath() != escaped {
+				normalized.RawPath = ""
+			}
+		}
+	}
+	if opts.NormalizeEncoding || opts.SortQuery || opts.RemoveTrackingParams {
+		normalized.RawQuery = normalizeQuery(normalized.RawQuery, opts)
+		if normalized.RawQuery != "" {
+			normalized.ForceQuery = false
+		}
+	}
+	return &normalized
+}
+
+func NormalizeString(rawURL string, opts Options) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	return Normalize(u, opts).String(), nil
+}
+
+func removeDefaultPort(scheme string, host string) string {
+	index := strings.LastIndex(host, ":")
+	if index < 0 || strings.Contains(host[index:], "]") {
+		return host
+	}
+	port := host[index+1:]
+	if port == "" || (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+		return host[:index]
+	}
+	return host
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func unhex(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// normalizeEncoding decodes percent-encoded unreserved characters and
+// uppercases the hex digits of the remaining escapes.
+func normalizeEncoding(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+2 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		hi, ok1 := unhex(s[i+1])
+		lo, ok2 := unhex(s[i+2])
+		if !ok1 || !ok2 {
+			b.WriteByte(s[i])
+			continue
+		}
+		if c := hi<<4 | lo; isUnreserved(c) {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('%')
+			b.WriteString(strings.ToUpper(s[i+1 : i+3]))
+		}
+		i += 2
+	}
+	return b.String()
+}
+
+// removeDotSegments implements the algorithm in RFC 3986, section 5.2.4.
+func removeDotSegments(p string) string {
+	if !strings.Contains(p, ".") {
+		return p
+	}
+	var output []string
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+			if last {
+				output = append(output, "")
+			}
+		case "..":
+			if len(output) > 1 || (len(output) == 1 && output[0] != "") {
+				output = output[:len(output)-1]
+			}
+			if last {
+				output = append(output, "")
+			}
+		default:
+			output = append(output, segment)
+		}
+	}
+	result := strings.Join(output, "/")
+	if strings.HasPrefix(p, "/") && !strings.HasPrefix(result, "/") {
+		result = "/" + result
+	}
+	return result
+}
+
+func isTrackingParam(name string, params []string) bool {
+	name = strings.ToLower(name)
+	for _, param := range params {
+		param = strings.ToLower(param)
+		if prefix, ok := strings.CutSuffix(param, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == param {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeQuery(rawQuery string, opts Options) string {
+	if rawQuery == "" {
+		return ""
+	}
+	trackingParams := opts.TrackingParams
+	if len(trackingParams) == 0 {
+		trackingParams = DefaultTrackingParams
+	}
+	pairs := strings.Split(rawQuery, "&")
+	kept := pairs[:0]
+	for _, pair := range pairs {
+		if pair == "" {
+			continue
+		}
+		if opts.NormalizeEncoding {
+			pair = normalizeEncoding(pair)
+		}
+		if opts.RemoveTrackingParams {
+			name, _, _ := strings.Cut(pair, "=")
+			if unescaped, err := url.QueryUnescape(name); err == nil {
+				name = unescaped
+			}
+			if isTrackingParam(name, trackingParams) {
+				continue
+			}
+		}
+		kept = append(kept, pair)
+	}
+	if opts.SortQuery {
+		sort.SliceStable(kept, func(i, j int) bool {
+			ki, _, _ := strings.Cut(kept[i], "=")
+			kj, _, _ := strings.Cut(kept[j], "=")
+			if ki != kj {
+				return ki < kj
+			}
+			return kept[i] < kept[j]
+		})
+	}
+	return strings.Join(kept, "&")
+}
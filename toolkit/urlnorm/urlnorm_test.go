@@ -0,0 +1,88 @@
+package urlnorm
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestNormalizeDefault(t *testing.T) {
+	opts := DefaultOptions()
+	cases := map[string]string{
+		"http://a.com/x?b=1&a=2":                          "http://a.com/x?a=2&b=1",
+		"http://A.com:80/x?a=2&b=1#frag":                  "http://a.com/x?a=2&b=1",
+		"https://a.com:443/x?utm_source=s&a=1&fbclid=f":   "https://a.com/x?a=1",
+		"https://a.com:8443/x":                            "https://a.com:8443/x",
+		"http://a.com":                                    "http://a.com/",
+		"http://a.com/a/./b/../c/":                        "http://a.com/a/c/",
+		"http://a.com/../../a/.":                          "http://a.com/a/",
+		"http://a.com/%7euser/%e4%b8%ad?q=%7e%2f":         "http://a.com/~user/%E4%B8%AD?q=~%2F",
+		"http://a.com/a%2Fb":                              "http://a.com/a%2Fb",
+		"http://a.com/x?utm_campaign=c&UTM_MEDIUM=m":      "http://a.com/x",
+		"HTTP://user@WWW.Example.COM:80/?b=2&a=3&a=1#top": "http://user@www.example.com/?a=1&a=3&b=2",
+		"http://[::1]:80/x":                               "http://[::1]/x",
+	}
+	for raw, expected := range cases {
+		actual, err := NormalizeString(raw, opts)
+		if err != nil {
+			t.Fatalf("An error occurs when normalizing URL %q: %s", raw, err)
+		}
+		if actual != expected {
+			t.Fatalf("Inconsistent normalized URL for %q, expected: %s, actual: %s", raw, expected, actual)
+		}
+	}
+}
+
+func TestNormalizeOptions(t *testing.T) {
+	raw := "http://A.com:80/a/../x?utm_source=s&b=1&a=2#frag"
+	cases := []struct {
+		opts     Options
+		expected string
+	}{
+		{Options{}, "http://A.com:80/a/../x?utm_source=s&b=1&a=2#frag"},
+		{Options{LowercaseHost: true}, "http://a.com:80/a/../x?utm_source=s&b=1&a=2#frag"},
+		{Options{RemoveDefaultPort: true}, "http://A.com/a/../x?utm_source=s&b=1&a=2#frag"},
+		{Options{RemoveFragment: true}, "http://A.com:80/a/../x?utm_source=s&b=1&a=2"},
+		{Options{SortQuery: true}, "http://A.com:80/a/../x?a=2&b=1&utm_source=s#frag"},
+		{Options{RemoveTrackingParams: true}, "http://A.com:80/a/../x?b=1&a=2#frag"},
+		{Options{RemoveTrackingParams: true, TrackingParams: []string{"b"}}, "http://A.com:80/a/../x?utm_source=s&a=2#frag"},
+		{Options{ResolveDotSegments: true}, "http://A.com:80/x?utm_source=s&b=1&a=2#frag"},
+	}
+	for _, c := range cases {
+		actual, err := NormalizeString(raw, c.opts)
+		if err != nil {
+			t.Fatalf("An error occurs when normalizing URL %q: %s", raw, err)
+		}
+		if actual != c.expected {
+			t.Fatalf("Inconsistent normalized URL with options %#v, expected: %s, actual: %s", c.opts, c.expected, actual)
+		}
+	}
+	if (Options{}).Enabled() {
+		t.Fatal("The zero options are enabled")
+	}
+	if !DefaultOptions().Enabled() {
+		t.Fatal("The default options are disabled")
+	}
+	if !DefaultOptions().Same(DefaultOptions()) {
+		t.Fatal("Different default options")
+	}
+	if DefaultOptions().Same(Options{TrackingParams: []string{"a"}}) {
+		t.Fatal("Same options with different tracking parameters")
+	}
+}
+
+func TestNormalizeCopy(t *testing.T) {
+	u, _ := url.Parse("http://A.com:80/x#frag")
+	normalized := Normalize(u, DefaultOptions())
+	if u.String() != "http://A.com:80/x#frag" {
+		t.Fatalf("The original URL has been modified: %s", u)
+	}
+	if normalized.String() != "http://a.com/x" {
+		t.Fatalf("Inconsistent normalized URL, expected: %s, actual: %s", "http://a.com/x", normalized)
+	}
+	if Normalize(nil, DefaultOptions()) != nil {
+		t.Fatal("The normalized nil URL is not nil")
+	}
+	if _, err := NormalizeString("http://a b.com/%zz", DefaultOptions()); err == nil {
+		t.Fatal("No error when normalizing an illegal URL")
+	}
+}
@@ -0,0 +1,123 @@
+package warc
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Reader reads the records from a WARC file, either uncompressed or with
+// every record compressed as a separate gzip member.
+type Reader struct {
+	src        *countingReader
+	br         *bufio.Reader
+	gz         *gzip.Reader
+	compressed bool
+	// offset is the offset of the next record.
+	offset int64
+	// last is the offset of the last record read.
+	last int64
+}
+
+func NewReader(r io.Reader) (*Reader, error) {
+	src := &countingReader{br: bufio.NewReader(r)}
+	magic, err := src.br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	reader := &Reader{src: src}
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		reader.compressed = true
+	} else {
+		reader.br = bufio.NewReader(src)
+	}
+	return reader, nil
+}
+
+// Next returns the next record, or io.EOF at the end.
+func (r *Reader) Next() (*Record, error) {
+	if !r.compressed {
+		record, n, err := readRecord(r.br)
+		r.last = r.offset
+		r.offset += n
+		return record, err
+	}
+	r.last = r.src.n
+	var err error
+	if r.gz == nil {
+		r.gz, err = gzip.NewReader(r.src)
+	} else {
+		err = r.gz.Reset(r.src)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The reader stops at the end of every member so that the offset of
+	// every record is known.
+	r.gz.Multistream(false)
+	br := bufio.NewReader(r.gz)
+	record, _, err := readRecord(br)
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := br.Peek(1); err != io.EOF {
+		if err == nil {
+			err = fmt.Errorf("more than one WARC record in a gzip member")
+		}
+		return nil, err
+	}
+	return record, nil
+}
+
+// Offset returns the offset of the last record read in the file, which can
+// be read again with ReadAt.
+func (r *Reader) Offset() int64 {
+	return r.last
+}
+
+// ReadAt reads the record at the offset of the file.
+func ReadAt(path string, offset int64) (*Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	reader, err := NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	record, err := reader.Next()
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return record, err
+}
+
+// countingReader counts the bytes read. It is an io.ByteReader so that the
+// gzip reader does not read beyond a member.
+type countingReader struct {
+	br *bufio.Reader
+	n  int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.br.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+func (cr *countingReader) ReadByte() (byte, error) {
+	b, err := cr.br.ReadByte()
+	if err == nil {
+		cr.n++
+	}
+	return b, err
+}
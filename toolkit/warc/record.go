@@ -0,0 +1,252 @@
+package warc
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VERSION is the version of the written records.
+const VERSION = "WARC/1.1"
+
+const (
+	TYPE_WARCINFO = "warcinfo"
+	TYPE_REQUEST  = "request"
+	TYPE_RESPONSE = "response"
+	TYPE_METADATA = "metadata"
+	TYPE_RESOURCE = "resource"
+	TYPE_REVISIT  = "revisit"
+)
+
+const (
+	CONTENT_TYPE_HTTP_REQUEST  = "application/http;msgtype=request"
+	CONTENT_TYPE_HTTP_RESPONSE = "application/http;msgtype=response"
+	CONTENT_TYPE_FIELDS        = "application/warc-fields"
+)
+
+// maxHeaderLineSize is the max size of a header line of a record.
+const maxHeaderLineSize = 64 * 1024
+
+// MaxBlockSize is the max size of the block of a record read, which is read
+// into memory.
+const MaxBlockSize = 256 << 20
+
+// Record is a WARC record. The named header fields are written in the order
+// of the specification, followed by the other fields in Fields.
+type Record struct {
+	Type string
+	// ID is generated when written if empty.
+	ID string
+	// Date is the time of writing if zero.
+	Date         time.Time
+	TargetURI    string
+	ContentType  string
+	ConcurrentTo string
+	RefersTo     string
+	WarcinfoID   string
+	Filename     string
+	// PayloadDigest is the digest of the HTTP body of a response.
+	PayloadDigest string
+	// BlockDigest is computed when written.
+	BlockDigest string
+	Fields      map[string]string
+	Block       []byte
+}
+
+// NewID returns a new record ID in the form of "<urn:uuid:...>".
+func NewID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// Digest returns the SHA-1 digest of the data in the form of
+// "sha1:<base32>".
+func Digest(data []byte) string {
+	sum := sha1.Sum(data)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+// FieldsBlock encodes the fields as an application/warc-fields block, sorted
+// by name.
+func FieldsBlock(fields map[string]string) []byte {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s: %s\r\n", name, fields[name])
+	}
+	return []byte(b.String())
+}
+
+// ParseFields decodes an application/warc-fields block.
+func ParseFields(block []byte) map[string]string {
+	fields := map[string]string{}
+	for _, line := range strings.Split(string(block), "\n") {
+		name, value, ok := strings.Cut(strings.TrimRight(line, "\r"), ":")
+		if ok {
+			fields[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	return fields
+}
+
+// writeTo writes the record with the ID, the date and the block digest
+// filled.
+func (record *Record) writeTo(w io.Writer) error {
+	if record.Type == "" {
+		return fmt.Errorf("empty WARC record type")
+	}
+	if record.ID == "" {
+		record.ID = NewID()
+	}
+	if record.Date.IsZero() {
+		record.Date = time.Now()
+	}
+	record.BlockDigest = Digest(record.Block)
+	bw := bufio.NewWriter(w)
+	bw.WriteString(VERSION + "\r\n")
+	writeField := func(name string, value string) {
+		if value != "" {
+			bw.WriteString(name + ": " + value + "\r\n")
+		}
+	}
+	writeField("WARC-Type", record.Type)
+	writeField("WARC-Record-ID", record.ID)
+	writeField("WARC-Date", record.Date.UTC().Format(time.RFC3339Nano))
+	writeField("WARC-Target-URI", record.TargetURI)
+	writeField("WARC-Concurrent-To", record.ConcurrentTo)
+	writeField("WARC-Refers-To", record.RefersTo)
+	writeField("WARC-Warcinfo-ID", record.WarcinfoID)
+	writeField("WARC-Filename", record.Filename)
+	writeField("WARC-Payload-Digest", record.PayloadDigest)
+	writeField("WARC-Block-Digest", record.BlockDigest)
+	writeField("Content-Type", record.ContentType)
+	names := make([]string, 0, len(record.Fields))
+	for name := range record.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeField(name, record.Fields[name])
+	}
+	bw.WriteString("Content-Length: " + strconv.Itoa(len(record.Block)) + "\r\n\r\n")
+	bw.Write(record.Block)
+	bw.WriteString("\r\n\r\n")
+	return bw.Flush()
+}
+
+// readRecord reads a record and returns the number of the bytes read.
+func readRecord(br *bufio.Reader) (*Record, int64, error) {
+	var n int64
+	readLine := func() (string, error) {
+		line, err := br.ReadSlice('\n')
+		n += int64(len(line))
+		if err == bufio.ErrBufferFull || len(line) > maxHeaderLineSize {
+			return "", fmt.Errorf("too long WARC header line")
+		}
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(line), "\r\n"), nil
+	}
+	version, err := readLine()
+	if err == io.EOF && n == 0 {
+		return nil, 0, io.EOF
+	}
+	if err != nil {
+		return nil, n, unexpectedEOF(err)
+	}
+	if version != "WARC/1.1" && version != "WARC/1.0" {
+		return nil, n, fmt.Errorf("unsupported WARC version %q", version)
+	}
+	record := &Record{}
+	contentLength := int64(-1)
+	for {
+		line, err := readLine()
+		if err != nil {
+			return nil, n, unexpectedEOF(err)
+		}
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, n, fmt.Errorf("illegal WARC header line %q", line)
+		}
+		name = strings.TrimSpace(name)
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(name) {
+		case "warc-type":
+			record.Type = value
+		case "warc-record-id":
+			record.ID = value
+		case "warc-date":
+			record.Date, _ = time.Parse(time.RFC3339Nano, value)
+		case "warc-target-uri":
+			// WARC/1.0 wraps the URI in angle brackets.
+			record.TargetURI = strings.Trim(value, "<>")
+		case "warc-concurrent-to":
+			record.ConcurrentTo = value
+		case "warc-refers-to":
+			record.RefersTo = value
+		case "warc-warcinfo-id":
+			record.WarcinfoID = value
+		case "warc-filename":
+			record.Filename = value
+		case "warc-payload-digest":
+			record.PayloadDigest = value
+		case "warc-block-digest":
+			record.BlockDigest = value
+		case "content-type":
+			record.ContentType = value
+		case "content-length":
+			contentLength, err = strconv.ParseInt(value, 10, 64)
+			if err != nil || contentLength < 0 {
+				return nil, n, fmt.Errorf("illegal WARC content length %q", value)
+			}
+		default:
+			if record.Fields == nil {
+				record.Fields = map[string]string{}
+			}
+			record.Fields[name] = value
+		}
+	}
+	if contentLength < 0 {
+		return nil, n, fmt.Errorf("no WARC content length")
+	}
+	if contentLength > MaxBlockSize {
+		return nil, n, fmt.Errorf("WARC block of record %s larger than %d bytes", record.ID, MaxBlockSize)
+	}
+	record.Block = make([]byte, contentLength)
+	read, err := io.ReadFull(br, record.Block)
+	n += int64(read)
+	if err != nil {
+		return nil, n, unexpectedEOF(err)
+	}
+	for i := 0; i < 2; i++ {
+		if line, err := readLine(); err != nil || line != "" {
+			return nil, n, fmt.Errorf("illegal end of WARC record %s", record.ID)
+		}
+	}
+	return record, n, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,231 @@
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRecord(t *testing.T) {
+	record := &Record{
+		Type:        TYPE_RESOURCE,
+		TargetURI:   "http://a.com/",
+		ContentType: "text/plain",
+		Fields:      map[string]string{"X-Test": "1"},
+		Block:       []byte("hello\r\n\r\nworld"),
+	}
+	var buf bytes.Buffer
+	if err := record.writeTo(&buf); err != nil {
+		t.Fatalf("An error occurs when writing a record: %s", err)
+	}
+	if !strings.HasPrefix(buf.String(), "WARC/1.1\r\nWARC-Type: resource\r\n") ||
+		!strings.HasSuffix(buf.String(), "world\r\n\r\n") {
+		t.Fatalf("Inconsistent record: %q", buf.String())
+	}
+	size := int64(buf.Len())
+	read, n, err := readRecord(bufio.NewReader(&buf))
+	if err != nil {
+		t.Fatalf("An error occurs when reading a record: %s", err)
+	}
+	if n != size {
+		t.Fatalf("Inconsistent read size, expected: %d, actual: %d", size, n)
+	}
+	if read.ID != record.ID || !read.Date.Equal(record.Date) || read.TargetURI != record.TargetURI ||
+		read.BlockDigest != Digest(record.Block) || read.Fields["X-Test"] != "1" ||
+		string(read.Block) != string(record.Block) {
+		t.Fatalf("Inconsistent read record: %#v", read)
+	}
+	illegalRecords := []string{
+		"HTTP/1.1 200 OK\r\n\r\n",
+		"WARC/1.1\r\nWARC-Type: resource\r\n\r\n",
+		"WARC/1.1\r\nContent-Length: 10\r\n\r\nabc",
+		"WARC/1.1\r\nContent-Length: 3\r\n\r\nabcdef",
+		"WARC/1.1\r\nContent-Length: 1000000000000\r\n\r\nabc",
+	}
+	for _, s := range illegalRecords {
+		if _, _, err := readRecord(bufio.NewReader(strings.NewReader(s))); err == nil {
+			t.Fatalf("No error when reading illegal record: %q", s)
+		}
+	}
+	fields := map[string]string{"a": "1", "b": "x: y"}
+	parsed := ParseFields(FieldsBlock(fields))
+	if len(parsed) != 2 || parsed["a"] != "1" || parsed["b"] != "x: y" {
+		t.Fatalf("Inconsistent fields: %v", parsed)
+	}
+}
+
+func readAll(path string, t *testing.T) ([]*Record, []int64) {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("An error occurs when opening a WARC file: %s", err)
+	}
+	defer file.Close()
+	reader, err := NewReader(file)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a reader: %s", err)
+	}
+	var records []*Record
+	var offsets []int64
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return records, offsets
+		}
+		if err != nil {
+			t.Fatalf("An error occurs when reading a record: %s", err)
+		}
+		records = append(records, record)
+		offsets = append(offsets, reader.Offset())
+	}
+}
+
+func testWriter(gzip bool, t *testing.T) {
+	if _, err := NewWriter(WriterArgs{}); err == nil {
+		t.Fatal("No error when creating a writer with empty directory")
+	}
+	dir := t.TempDir()
+	w, err := NewWriter(WriterArgs{Dir: dir, MaxSize: 100, Gzip: gzip, Info: map[string]string{"operator": "test"}})
+	if err != nil {
+		t.Fatalf("An error occurs when creating a writer: %s", err)
+	}
+	body := strings.Repeat("x", 600)
+	for i := 0; i < 3; i++ {
+		if err := w.Write(&Record{Type: TYPE_RESOURCE, TargetURI: "http://a.com/", Block: []byte(body)}); err != nil {
+			t.Fatalf("An error occurs when writing a record: %s", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("An error occurs when closing the writer: %s", err)
+	}
+	if err := w.Write(&Record{Type: TYPE_RESOURCE}); err == nil {
+		t.Fatal("No error when writing into a closed writer")
+	}
+	files := w.Files()
+	if len(files) != 3 || w.Records() != 6 {
+		t.Fatalf("Inconsistent files: %v, records: %d", files, w.Records())
+	}
+	for _, file := range files {
+		if gzip != strings.HasSuffix(file, ".warc.gz") {
+			t.Fatalf("Inconsistent file name: %s", file)
+		}
+		records, offsets := readAll(file, t)
+		if len(records) != 2 || records[0].Type != TYPE_WARCINFO || records[1].Type != TYPE_RESOURCE {
+			t.Fatalf("Inconsistent records in %s: %d", file, len(records))
+		}
+		if ParseFields(records[0].Block)["operator"] != "test" || records[1].WarcinfoID != records[0].ID {
+			t.Fatalf("Inconsistent warcinfo record: %#v", records[0])
+		}
+		record, err := ReadAt(file, offsets[1])
+		if err != nil {
+			t.Fatalf("An error occurs when reading a record at %d: %s", offsets[1], err)
+		}
+		if record.ID != records[1].ID || string(record.Block) != body {
+			t.Fatalf("Inconsistent record at %d: %#v", offsets[1], record)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	testWriter(false, t)
+}
+
+func TestWriterGzip(t *testing.T) {
+	testWriter(true, t)
+}
+
+func TestWriteExchange(t *testing.T) {
+	w, err := NewWriter(WriterArgs{Dir: t.TempDir(), Gzip: true})
+	if err != nil {
+		t.Fatalf("An error occurs when creating a writer: %s", err)
+	}
+	httpReq, _ := http.NewRequest(http.MethodGet, "http://a.com/x", nil)
+	httpResp := &http.Response{
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"text/html"}},
+		Body:          io.NopCloser(strings.NewReader("<html></html>")),
+		ContentLength: -1,
+		Request:       httpReq,
+	}
+	if err := w.WriteExchange(&http.Response{}, nil); err == nil {
+		t.Fatal("No error when writing a response without request")
+	}
+	if err := w.WriteExchange(httpResp, map[string]string{"depth": "1"}); err != nil {
+		t.Fatalf("An error occurs when writing an exchange: %s", err)
+	}
+	w.Close()
+	if body, _ := io.ReadAll(httpResp.Body); string(body) != "<html></html>" {
+		t.Fatalf("Inconsistent body after writing: %q", body)
+	}
+	records, _ := readAll(w.Files()[0], t)
+	if len(records) != 4 {
+		t.Fatalf("Inconsistent record number, expected: 4, actual: %d", len(records))
+	}
+	request, response, metadata := records[1], records[2], records[3]
+	if request.Type != TYPE_REQUEST || request.ConcurrentTo != response.ID ||
+		!strings.HasPrefix(string(request.Block), "GET /x HTTP/1.1\r\nHost: a.com\r\n") {
+		t.Fatalf("Inconsistent request record: %#v", request)
+	}
+	if response.Type != TYPE_RESPONSE || response.TargetURI != "http://a.com/x" ||
+		response.PayloadDigest != Digest([]byte("<html></html>")) {
+		t.Fatalf("Inconsistent response record: %#v", response)
+	}
+	replayed, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(response.Block)), nil)
+	if err != nil {
+		t.Fatalf("An error occurs when parsing the response record: %s", err)
+	}
+	body, _ := io.ReadAll(replayed.Body)
+	if replayed.StatusCode != http.StatusOK || replayed.Header.Get("Content-Type") != "text/html" ||
+		string(body) != "<html></html>" {
+		t.Fatalf("Inconsistent archived response: %#v, body: %q", replayed, body)
+	}
+	if metadata.Type != TYPE_METADATA || metadata.RefersTo != response.ID ||
+		ParseFields(metadata.Block)["depth"] != "1" {
+		t.Fatalf("Inconsistent metadata record: %#v", metadata)
+	}
+}
+
+func TestWriteExchangeTruncated(t *testing.T) {
+	if _, err := NewWriter(WriterArgs{Dir: t.TempDir(), MaxBodySize: MaxBlockSize}); err == nil {
+		t.Fatal("No error when creating a writer with too large max body size")
+	}
+	w, err := NewWriter(WriterArgs{Dir: t.TempDir(), MaxBodySize: 4})
+	if err != nil {
+		t.Fatalf("An error occurs when creating a writer: %s", err)
+	}
+	httpReq, _ := http.NewRequest(http.MethodGet, "http://a.com/x", nil)
+	httpResp := &http.Response{
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader("<html></html>")),
+		Request:    httpReq,
+	}
+	if err := w.WriteExchange(httpResp, nil); err != nil {
+		t.Fatalf("An error occurs when writing an exchange: %s", err)
+	}
+	w.Close()
+	if body, _ := io.ReadAll(httpResp.Body); string(body) != "<html></html>" {
+		t.Fatalf("Inconsistent body after writing: %q", body)
+	}
+	records, _ := readAll(w.Files()[0], t)
+	response := records[2]
+	if response.Fields["WARC-Truncated"] != "length" || response.PayloadDigest != "" {
+		t.Fatalf("Inconsistent truncated response record: %#v", response)
+	}
+	replayed, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(response.Block)), nil)
+	if err != nil {
+		t.Fatalf("An error occurs when parsing the response record: %s", err)
+	}
+	if body, _ := io.ReadAll(replayed.Body); string(body) != "<htm" {
+		t.Fatalf("Inconsistent truncated body, expected: %q, actual: %q", "<htm", body)
+	}
+}
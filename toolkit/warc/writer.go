@@ -0,0 +1,312 @@
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"webcrawler/errors"
+)
+
+// defaultMaxSize is the default size of a WARC file to rotate at.
+const defaultMaxSize = 1 << 30
+
+// defaultMaxBodySize is the default max size of a response body recorded.
+const defaultMaxBodySize = 32 << 20
+
+type WriterArgs struct {
+	// Dir is the directory of the WARC files.
+	Dir string
+	// Prefix is the prefix of the WARC file names. Empty means "crawl".
+	Prefix string
+	// MaxSize is the size of a WARC file to start a new one at. A file may
+	// exceed it by the records written together. Zero means 1GB.
+	MaxSize int64
+	// MaxBodySize is the max size of a response body recorded, beyond which
+	// the record is truncated. Zero means 32MB, and it should be at most the
+	// half of MaxBlockSize.
+	MaxBodySize int64
+	// Gzip compresses every record as a separate gzip member, and names the
+	// files with ".warc.gz".
+	Gzip bool
+	// Info is the fields of the warcinfo record beginning every file.
+	Info map[string]string
+}
+
+// Writer writes the records into the WARC files in a directory, starting a
+// new file when the current one reaches the max size. It is safe for
+// concurrent use.
+type Writer struct {
+	args    WriterArgs
+	lock    sync.Mutex
+	file    *os.File
+	size    int64
+	seq     int
+	infoID  string
+	files   []string
+	records uint64
+	closed  bool
+	now     func() time.Time
+}
+
+func NewWriter(args WriterArgs) (*Writer, error) {
+	if args.Dir == "" {
+		return nil, errors.NewIllegalParameterError("empty directory for the WARC files")
+	}
+	if args.MaxSize < 0 {
+		return nil, errors.NewIllegalParameterError("negative max size of the WARC files")
+	}
+	if args.MaxSize == 0 {
+		args.MaxSize = defaultMaxSize
+	}
+	if args.MaxBodySize < 0 || args.MaxBodySize > MaxBlockSize/2 {
+		return nil, errors.NewIllegalParameterError(fmt.Sprintf("illegal max body size: %d", args.MaxBodySize))
+	}
+	if args.MaxBodySize == 0 {
+		args.MaxBodySize = defaultMaxBodySize
+	}
+	if args.Prefix == "" {
+		args.Prefix = "crawl"
+	}
+	if err := os.MkdirAll(args.Dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Writer{args: args, now: time.Now}, nil
+}
+
+// Write writes the records into the same file.
+func (w *Writer) Write(records ...*Record) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return fmt.Errorf("the WARC writer has been closed")
+	}
+	if w.file == nil || w.size >= w.args.MaxSize {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	for _, record := range records {
+		if record.WarcinfoID == "" {
+			record.WarcinfoID = w.infoID
+		}
+		if err := w.write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteExchange writes the request, the response and the metadata records
+// of the HTTP response and its request. The body of the response is read
+// into memory up to MaxBodySize, and replaced with a reader of the read bytes
+// followed by the rest. The response record of a larger body is truncated
+// with the field "WARC-Truncated: length". The metadata record is omitted if
+// the fields are empty. If the body could not be read, the replaced reader
+// returns the read bytes followed by the error.
+func (w *Writer) WriteExchange(httpResp *http.Response, fields map[string]string) error {
+	httpReq := httpResp.Request
+	if httpReq == nil || httpReq.URL == nil {
+		return errors.NewIllegalParameterError("no request of the HTTP response")
+	}
+	var body []byte
+	var truncated bool
+	if httpResp.Body != nil {
+		var err error
+		body, err = io.ReadAll(io.LimitReader(httpResp.Body, w.args.MaxBodySize+1))
+		if err != nil {
+			httpResp.Body.Close()
+			httpResp.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), errReader{err}))
+			return fmt.Errorf("could not read the response body: %s", err)
+		}
+		if int64(len(body)) > w.args.MaxBodySize {
+			httpResp.Body = readCloser{io.MultiReader(bytes.NewReader(body), httpResp.Body), httpResp.Body}
+			body = body[:w.args.MaxBodySize]
+			truncated = true
+		} else {
+			httpResp.Body.Close()
+			httpResp.Body = io.NopCloser(bytes.NewReader(body))
+		}
+	}
+	reqBlock, err := httputil.DumpRequestOut(httpReq, true)
+	if err != nil {
+		return fmt.Errorf("could not dump the request: %s", err)
+	}
+	dump := *httpResp
+	dump.Body = io.NopCloser(bytes.NewReader(body))
+	dump.ContentLength = int64(len(body))
+	dump.TransferEncoding = nil
+	dump.Trailer = nil
+	var respBlock bytes.Buffer
+	if err := dump.Write(&respBlock); err != nil {
+		return fmt.Errorf("could not dump the response: %s", err)
+	}
+	now := w.now()
+	uri := httpReq.URL.String()
+	response := &Record{
+		Type:          TYPE_RESPONSE,
+		ID:            NewID(),
+		Date:          now,
+		TargetURI:     uri,
+		ContentType:   CONTENT_TYPE_HTTP_RESPONSE,
+		PayloadDigest: Digest(body),
+		Block:         respBlock.Bytes(),
+	}
+	if truncated {
+		// The digest of the truncated payload is not the one of the payload.
+		response.PayloadDigest = ""
+		response.Fields = map[string]string{"WARC-Truncated": "length"}
+	}
+	request := &Record{
+		Type:         TYPE_REQUEST,
+		Date:         now,
+		TargetURI:    uri,
+		ContentType:  CONTENT_TYPE_HTTP_REQUEST,
+		ConcurrentTo: response.ID,
+		Block:        reqBlock,
+	}
+	records := []*Record{request, response}
+	if len(fields) > 0 {
+		records = append(records, &Record{
+			Type:        TYPE_METADATA,
+			Date:        now,
+			TargetURI:   uri,
+			ContentType: CONTENT_TYPE_FIELDS,
+			RefersTo:    response.ID,
+			Block:       FieldsBlock(fields),
+		})
+	}
+	return w.Write(records...)
+}
+
+// rotate closes the current file and starts a new one with a warcinfo
+// record.
+func (w *Writer) rotate() error {
+	if err := w.closeFile(); err != nil {
+		return err
+	}
+	ext := ".warc"
+	if w.args.Gzip {
+		ext += ".gz"
+	}
+	timestamp := w.now().UTC().Format("20060102150405")
+	var file *os.File
+	for {
+		w.seq++
+		name := fmt.Sprintf("%s-%s-%05d%s", w.args.Prefix, timestamp, w.seq, ext)
+		var err error
+		file, err = os.OpenFile(filepath.Join(w.args.Dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return err
+		}
+	}
+	w.file = file
+	w.size = 0
+	w.files = append(w.files, file.Name())
+	fields := map[string]string{
+		"software":   "webcrawler",
+		"format":     "WARC File Format 1.1",
+		"conformsTo": "http://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/",
+	}
+	for name, value := range w.args.Info {
+		fields[name] = value
+	}
+	info := &Record{
+		Type:        TYPE_WARCINFO,
+		Date:        w.now(),
+		Filename:    filepath.Base(file.Name()),
+		ContentType: CONTENT_TYPE_FIELDS,
+		Block:       FieldsBlock(fields),
+	}
+	if err := w.write(info); err != nil {
+		return err
+	}
+	w.infoID = info.ID
+	return nil
+}
+
+func (w *Writer) write(record *Record) error {
+	cw := &countingWriter{w: w.file}
+	if w.args.Gzip {
+		gw := gzip.NewWriter(cw)
+		if err := record.writeTo(gw); err != nil {
+			return err
+		}
+		if err := gw.Close(); err != nil {
+			return err
+		}
+	} else if err := record.writeTo(cw); err != nil {
+		return err
+	}
+	w.size += cw.n
+	w.records++
+	return nil
+}
+
+func (w *Writer) closeFile() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// Files returns the paths of the written files in order.
+func (w *Writer) Files() []string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return append([]string(nil), w.files...)
+}
+
+// Records returns the number of the written records, including the warcinfo
+// ones.
+func (w *Writer) Records() uint64 {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.records
+}
+
+func (w *Writer) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.closeFile()
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// readCloser reads from the reader and closes the closer.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}